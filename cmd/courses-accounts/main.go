@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+type command string
+
+const (
+	commandList    command = "list"
+	commandAdd     command = "add"
+	commandRemove  command = "remove"
+	commandEnroll  command = "enroll-totp"
+	commandConfirm command = "confirm-totp"
+	commandDisable command = "disable-totp"
+)
+
+const defaultIssuer = "DummyPage"
+
+type config struct {
+	Command      command
+	AccountsPath string
+	Account      string
+	Issuer       string
+	Code         string
+	Skew         int
+}
+
+type dependencies struct {
+	now    func() time.Time
+	stdout io.Writer
+}
+
+func main() {
+	err := run(os.Args[1:], dependencies{
+		now:    time.Now,
+		stdout: os.Stdout,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func parseArgs(args []string) (config, error) {
+	usage := errors.New("expected list, add, remove, enroll-totp, confirm-totp, or disable-totp command")
+	if len(args) == 0 {
+		return config{}, usage
+	}
+	result := config{Command: command(args[0])}
+	switch result.Command {
+	case commandList, commandAdd, commandRemove, commandEnroll, commandConfirm, commandDisable:
+	default:
+		return config{}, usage
+	}
+
+	flags := flag.NewFlagSet("courses-accounts", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&result.AccountsPath, "accounts", "", "unlock accounts JSON path")
+	flags.StringVar(&result.Account, "account", "", "account name")
+	flags.StringVar(&result.Issuer, "issuer", defaultIssuer, "issuer shown by authenticator apps")
+	flags.StringVar(&result.Code, "code", "", "current TOTP code")
+	flags.IntVar(&result.Skew, "skew", 1, "accepted TOTP steps before and after now")
+	if err := flags.Parse(args[1:]); err != nil {
+		return config{}, errors.New("invalid arguments")
+	}
+	if flags.NArg() != 0 {
+		return config{}, errors.New("unexpected positional arguments")
+	}
+	if strings.TrimSpace(result.AccountsPath) == "" {
+		return config{}, errors.New("--accounts is required")
+	}
+	if result.Command != commandList && strings.TrimSpace(result.Account) == "" {
+		return config{}, errors.New("--account is required")
+	}
+	if result.Command == commandConfirm && strings.TrimSpace(result.Code) == "" {
+		return config{}, errors.New("--code is required")
+	}
+	return result, nil
+}
+
+func run(args []string, deps dependencies) error {
+	cfg, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+	if deps.now == nil || deps.stdout == nil {
+		return errors.New("invalid runtime dependencies")
+	}
+	if cfg.Command != commandList {
+		// The server rewrites the file when a recovery code is used; holding
+		// its lock until the change is published keeps a used code used.
+		unlock, err := filelock.Lock(cfg.AccountsPath)
+		if err != nil {
+			return errors.New("lock unlock accounts: unavailable")
+		}
+		defer unlock()
+	}
+
+	accounts, err := loadAccounts(cfg.AccountsPath)
+	if err != nil {
+		return errors.New("load unlock accounts: invalid or unavailable input")
+	}
+
+	switch cfg.Command {
+	case commandList:
+		for _, account := range accounts.List() {
+			fmt.Fprintf(deps.stdout, "%s\ttotp=%t\trecovery_codes=%d\n", account.Name, account.TOTPEnabled, len(account.RecoveryCodes))
+		}
+		return nil
+	case commandAdd:
+		account, err := accounts.Add(cfg.Account)
+		if err != nil {
+			return fmt.Errorf("add account: %w", err)
+		}
+		if err := saveAccounts(cfg.AccountsPath, accounts); err != nil {
+			return err
+		}
+		fmt.Fprintf(deps.stdout, "added=%s\n", account.Name)
+	case commandRemove:
+		if err := accounts.Remove(cfg.Account); err != nil {
+			return fmt.Errorf("remove account: %w", err)
+		}
+		if err := saveAccounts(cfg.AccountsPath, accounts); err != nil {
+			return err
+		}
+		fmt.Fprintf(deps.stdout, "removed=%s\n", courses.NormalizeUnlockAccountName(cfg.Account))
+	case commandEnroll:
+		account, codes, err := accounts.EnrollTOTP(cfg.Account, deps.now())
+		if err != nil {
+			return fmt.Errorf("enroll totp: %w", err)
+		}
+		if err := saveAccounts(cfg.AccountsPath, accounts); err != nil {
+			return err
+		}
+		fmt.Fprintf(deps.stdout, "uri=%s\n", courses.TOTPProvisioningURI(account.TOTPSecret, cfg.Issuer, account.Name))
+		for _, code := range codes {
+			fmt.Fprintf(deps.stdout, "recovery_code=%s\n", code)
+		}
+		fmt.Fprintln(deps.stdout, "TOTP stays disabled until confirm-totp accepts a code from the app.")
+	case commandConfirm:
+		if err := accounts.ConfirmTOTP(cfg.Account, strings.TrimSpace(cfg.Code), deps.now(), cfg.Skew); err != nil {
+			return fmt.Errorf("confirm totp: %w", err)
+		}
+		if err := saveAccounts(cfg.AccountsPath, accounts); err != nil {
+			return err
+		}
+		fmt.Fprintf(deps.stdout, "totp_enabled=%s\n", courses.NormalizeUnlockAccountName(cfg.Account))
+	case commandDisable:
+		if err := accounts.DisableTOTP(cfg.Account); err != nil {
+			return fmt.Errorf("disable totp: %w", err)
+		}
+		if err := saveAccounts(cfg.AccountsPath, accounts); err != nil {
+			return err
+		}
+		fmt.Fprintf(deps.stdout, "totp_disabled=%s\n", courses.NormalizeUnlockAccountName(cfg.Account))
+	}
+	return nil
+}
+
+func loadAccounts(path string) (*courses.UnlockAccounts, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return courses.NewUnlockAccounts(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return courses.LoadUnlockAccounts(file)
+}
+
+func saveAccounts(path string, accounts *courses.UnlockAccounts) error {
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(accounts); err != nil {
+		return errors.New("encode unlock accounts")
+	}
+	if err := writeAtomicFile(path, output.Bytes()); err != nil {
+		return errors.New("publish unlock accounts")
+	}
+	return nil
+}
+
+func writeAtomicFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".unlock-accounts-*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
+	if err := file.Chmod(0o600); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+var testNow = time.Date(2026, 7, 27, 9, 0, 0, 0, time.UTC)
+
+func TestRunEnrollsAndConfirmsTOTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	var stdout bytes.Buffer
+	for _, args := range [][]string{
+		{"add", "--accounts", path, "--account", "Alice"},
+		{"enroll-totp", "--accounts", path, "--account", "alice", "--issuer", "Courses"},
+	} {
+		if err := run(args, testDependencies(&stdout)); err != nil {
+			t.Fatalf("run(%v) error = %v", args, err)
+		}
+	}
+	output := stdout.String()
+	if got := strings.Count(output, "recovery_code="); got != 10 {
+		t.Fatalf("recovery codes printed = %d, want 10:\n%s", got, output)
+	}
+	uriLine := output[strings.Index(output, "uri=")+len("uri="):]
+	uri, err := url.Parse(uriLine[:strings.IndexByte(uriLine, '\n')])
+	if err != nil || uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Query().Get("issuer") != "Courses" {
+		t.Fatalf("provisioning URI = %v, %v", uri, err)
+	}
+
+	if err := run([]string{"confirm-totp", "--accounts", path, "--account", "alice", "--code", "12345"}, testDependencies(&stdout)); err == nil {
+		t.Fatal("confirm-totp accepted an invalid code")
+	}
+	code, err := courses.TOTPCode(uri.Query().Get("secret"), courses.TOTPStep(testNow))
+	if err != nil {
+		t.Fatalf("TOTPCode() error = %v", err)
+	}
+	if err := run([]string{"confirm-totp", "--accounts", path, "--account", "alice", "--code", code}, testDependencies(&stdout)); err != nil {
+		t.Fatalf("confirm-totp error = %v", err)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"list", "--accounts", path}, testDependencies(&stdout)); err != nil {
+		t.Fatalf("list error = %v", err)
+	}
+	if got := stdout.String(); got != "alice\ttotp=true\trecovery_codes=10\n" {
+		t.Fatalf("list output = %q", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("accounts file mode = %v, %v", info, err)
+	}
+}
+
+func TestParseArgsRequiresAccount(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"rename", "--accounts", "a.json"},
+		{"add", "--account", "alice"},
+		{"enroll-totp", "--accounts", "a.json"},
+		{"confirm-totp", "--accounts", "a.json", "--account", "alice"},
+	} {
+		if _, err := parseArgs(args); err == nil {
+			t.Fatalf("parseArgs(%v) error = nil", args)
+		}
+	}
+}
+
+func testDependencies(stdout *bytes.Buffer) dependencies {
+	return dependencies{
+		now:    func() time.Time { return testNow },
+		stdout: stdout,
+	}
+}
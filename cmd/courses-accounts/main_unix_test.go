@@ -0,0 +1,70 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+func TestRunWaitsForConcurrentRecoveryCodeUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	accounts := courses.NewUnlockAccounts()
+	if _, err := accounts.Add("alice"); err != nil {
+		t.Fatalf("add account: %v", err)
+	}
+	account, codes, err := accounts.EnrollTOTP("alice", testNow)
+	if err != nil {
+		t.Fatalf("enroll totp: %v", err)
+	}
+	code, _ := courses.TOTPCode(account.TOTPSecret, courses.TOTPStep(testNow))
+	if err := accounts.ConfirmTOTP("alice", code, testNow, 0); err != nil {
+		t.Fatalf("confirm totp: %v", err)
+	}
+	if err := saveAccounts(path, accounts); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+
+	// Play the server: consume a recovery code under the file lock while
+	// the CLI adds an account to the same file.
+	unlock, err := filelock.Lock(path)
+	if err != nil {
+		t.Fatalf("lock accounts: %v", err)
+	}
+	accounts, err = loadAccounts(path)
+	if err != nil {
+		t.Fatalf("load accounts: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		var stdout bytes.Buffer
+		done <- run([]string{"add", "--accounts", path, "--account", "bob"}, testDependencies(&stdout))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if !accounts.UseRecoveryCode("alice", codes[0]) {
+		t.Fatal("UseRecoveryCode() = false")
+	}
+	if err := saveAccounts(path, accounts); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	accounts, err = loadAccounts(path)
+	if err != nil {
+		t.Fatalf("reload accounts: %v", err)
+	}
+	if _, ok := accounts.Get("bob"); !ok {
+		t.Fatal("added account is missing")
+	}
+	if stored, _ := accounts.Get("alice"); len(stored.RecoveryCodes) != len(codes)-1 {
+		t.Fatalf("stored recovery codes = %d, want %d", len(stored.RecoveryCodes), len(codes)-1)
+	}
+}
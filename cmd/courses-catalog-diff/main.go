@@ -0,0 +1,266 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+const maxCatalogBytes = 512 << 20
+
+type outputFormat string
+
+const (
+	formatText     outputFormat = "text"
+	formatJSON     outputFormat = "json"
+	formatMarkdown outputFormat = "markdown"
+)
+
+type config struct {
+	OldPath string
+	NewPath string
+	Format  outputFormat
+}
+
+type dependencies struct {
+	stdout io.Writer
+}
+
+func main() {
+	if err := run(os.Args[1:], dependencies{stdout: os.Stdout}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "usage: courses-catalog-diff --old <catalog.json.gz> --new <catalog.json.gz> [--format text|json|markdown]")
+		os.Exit(1)
+	}
+}
+
+func parseArgs(args []string) (config, error) {
+	flags := flag.NewFlagSet("courses-catalog-diff", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var result config
+	var format string
+	flags.StringVar(&result.OldPath, "old", "", "previous catalog JSON gzip path")
+	flags.StringVar(&result.NewPath, "new", "", "new catalog JSON gzip path")
+	flags.StringVar(&format, "format", string(formatText), "output format: text, json, or markdown")
+	if err := flags.Parse(args); err != nil {
+		return config{}, errors.New("invalid arguments")
+	}
+	if flags.NArg() != 0 {
+		return config{}, errors.New("unexpected positional arguments")
+	}
+	if strings.TrimSpace(result.OldPath) == "" || strings.TrimSpace(result.NewPath) == "" {
+		return config{}, errors.New("--old and --new are required")
+	}
+	result.Format = outputFormat(format)
+	switch result.Format {
+	case formatText, formatJSON, formatMarkdown:
+	default:
+		return config{}, errors.New("--format must be text, json, or markdown")
+	}
+	return result, nil
+}
+
+func run(args []string, deps dependencies) error {
+	cfg, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+	if deps.stdout == nil {
+		return errors.New("invalid runtime dependencies")
+	}
+
+	previous, err := loadCatalog(cfg.OldPath)
+	if err != nil {
+		return fmt.Errorf("load old catalog %q: %w", cfg.OldPath, err)
+	}
+	current, err := loadCatalog(cfg.NewPath)
+	if err != nil {
+		return fmt.Errorf("load new catalog %q: %w", cfg.NewPath, err)
+	}
+	diff := courses.DiffCatalogs(previous, current)
+
+	switch cfg.Format {
+	case formatJSON:
+		encoder := json.NewEncoder(deps.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	case formatMarkdown:
+		writeMarkdown(deps.stdout, diff)
+	default:
+		writeText(deps.stdout, diff)
+	}
+	return nil
+}
+
+func writeText(w io.Writer, diff courses.CatalogDiff) {
+	fmt.Fprintf(
+		w,
+		"catalog diff: %d -> %d courses, %d added, %d removed, %d changed\n",
+		diff.OldEntries,
+		diff.NewEntries,
+		len(diff.Added),
+		len(diff.Removed),
+		len(diff.Changed),
+	)
+	for _, entry := range diff.Added {
+		fmt.Fprintf(w, "added\t%s\t%s\n", entry.CourseID, entry.Title)
+	}
+	for _, entry := range diff.Removed {
+		fmt.Fprintf(w, "removed\t%s\t%s\n", entry.CourseID, entry.Title)
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(w, "changed\t%s\t%s\n", change.CourseID, change.Title)
+		for _, field := range change.Fields {
+			fmt.Fprintf(w, "\t%s: %q -> %q\n", field.Field, field.Old, field.New)
+		}
+		for _, link := range change.LinksAdded {
+			fmt.Fprintf(w, "\tlink added: %s\n", link)
+		}
+		for _, link := range change.LinksRemoved {
+			fmt.Fprintf(w, "\tlink removed: %s\n", link)
+		}
+		for _, enrichment := range change.Enrichment {
+			fmt.Fprintf(w, "\tenrichment %s: %s -> %s\n", enrichment.URL, linkContentSummary(enrichment.Old), linkContentSummary(enrichment.New))
+		}
+	}
+	for _, facet := range diff.Facets {
+		fmt.Fprintf(w, "facet\t%s\t%s\t%d -> %d (%+d)\n", facet.Facet, facet.ID, facet.Old, facet.New, facet.Delta)
+	}
+}
+
+func writeMarkdown(w io.Writer, diff courses.CatalogDiff) {
+	fmt.Fprintln(w, "# Catalog diff")
+	fmt.Fprintln(w)
+	fmt.Fprintf(
+		w,
+		"%d → %d courses: %d added, %d removed, %d changed.\n",
+		diff.OldEntries,
+		diff.NewEntries,
+		len(diff.Added),
+		len(diff.Removed),
+		len(diff.Changed),
+	)
+	if len(diff.Added) != 0 {
+		fmt.Fprintf(w, "\n## Added (%d)\n\n", len(diff.Added))
+		for _, entry := range diff.Added {
+			fmt.Fprintf(w, "- `%s` %s\n", entry.CourseID, escapeMarkdown(entry.Title))
+		}
+	}
+	if len(diff.Removed) != 0 {
+		fmt.Fprintf(w, "\n## Removed (%d)\n\n", len(diff.Removed))
+		for _, entry := range diff.Removed {
+			fmt.Fprintf(w, "- `%s` %s\n", entry.CourseID, escapeMarkdown(entry.Title))
+		}
+	}
+	if len(diff.Changed) != 0 {
+		fmt.Fprintf(w, "\n## Changed (%d)\n", len(diff.Changed))
+		for _, change := range diff.Changed {
+			fmt.Fprintf(w, "\n### %s (`%s`)\n\n", escapeMarkdown(change.Title), change.CourseID)
+			for _, field := range change.Fields {
+				fmt.Fprintf(w, "- %s: %s → %s\n", field.Field, markdownValue(field.Old), markdownValue(field.New))
+			}
+			for _, link := range change.LinksAdded {
+				fmt.Fprintf(w, "- link added: <%s>\n", link)
+			}
+			for _, link := range change.LinksRemoved {
+				fmt.Fprintf(w, "- link removed: <%s>\n", link)
+			}
+			for _, enrichment := range change.Enrichment {
+				fmt.Fprintf(
+					w,
+					"- enrichment <%s>: %s → %s\n",
+					enrichment.URL,
+					escapeMarkdown(linkContentSummary(enrichment.Old)),
+					escapeMarkdown(linkContentSummary(enrichment.New)),
+				)
+			}
+		}
+	}
+	if len(diff.Facets) != 0 {
+		fmt.Fprintln(w, "\n## Facet counts")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Facet | Value | Old | New | Delta |")
+		fmt.Fprintln(w, "| --- | --- | ---: | ---: | ---: |")
+		for _, facet := range diff.Facets {
+			fmt.Fprintf(w, "| %s | %s | %d | %d | %+d |\n", facet.Facet, escapeMarkdown(facet.ID), facet.Old, facet.New, facet.Delta)
+		}
+	}
+}
+
+func linkContentSummary(content *courses.LinkContent) string {
+	if content == nil {
+		return "none"
+	}
+	var parts []string
+	if content.Name != "" {
+		parts = append(parts, fmt.Sprintf("%q", content.Name))
+	}
+	if content.FileCount != 0 {
+		parts = append(parts, fmt.Sprintf("%d files", content.FileCount))
+	}
+	if content.SizeBytes != 0 {
+		parts = append(parts, fmt.Sprintf("%d bytes", content.SizeBytes))
+	}
+	if len(parts) == 0 {
+		return "fetched"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func markdownValue(value string) string {
+	if value == "" {
+		return "_none_"
+	}
+	return escapeMarkdown(value)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "|", `\|`, "#", `\#`,
+)
+
+func escapeMarkdown(value string) string {
+	return markdownEscaper.Replace(value)
+}
+
+func loadCatalog(path string) (courses.Catalog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return courses.Catalog{}, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return courses.Catalog{}, err
+	}
+	defer reader.Close()
+
+	limited := &io.LimitedReader{R: reader, N: maxCatalogBytes + 1}
+	decoder := json.NewDecoder(limited)
+	decoder.DisallowUnknownFields()
+	var catalog courses.Catalog
+	if err := decoder.Decode(&catalog); err != nil {
+		return courses.Catalog{}, err
+	}
+	var extra json.RawMessage
+	if err := decoder.Decode(&extra); err != io.EOF {
+		if err == nil {
+			return courses.Catalog{}, errors.New("multiple catalog values")
+		}
+		return courses.Catalog{}, err
+	}
+	if limited.N <= 0 {
+		return courses.Catalog{}, errors.New("catalog too large")
+	}
+	if catalog.SchemaVersion != "courses-catalog/v2" || catalog.Entries == nil {
+		return courses.Catalog{}, errors.New("unsupported catalog")
+	}
+	return catalog, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+const (
+	keptCourseID    = "course:0123456789abcdef0123456789abcdef"
+	removedCourseID = "course:11111111111111111111111111111111"
+	addedCourseID   = "course:22222222222222222222222222222222"
+)
+
+func TestRunWritesDiffAsTextJSONAndMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.json.gz")
+	newPath := filepath.Join(dir, "new.json.gz")
+	writeCatalog(t, oldPath, courses.Catalog{
+		SchemaVersion: "courses-catalog/v2",
+		Categories:    []courses.CategoryMetadata{{ID: "development", Label: "Разработка", Count: 2}},
+		Entries: []courses.CatalogEntry{
+			{ID: keptCourseID, Title: "Go basics", Categories: []string{"development"}, Links: []courses.CatalogLink{
+				{URL: "https://files.example.test/a"},
+			}},
+			{ID: removedCourseID, Title: "Old *course*", Categories: []string{"development"}},
+		},
+	})
+	writeCatalog(t, newPath, courses.Catalog{
+		SchemaVersion: "courses-catalog/v2",
+		Categories:    []courses.CategoryMetadata{{ID: "development", Label: "Разработка", Count: 2}, {ID: "design", Label: "Дизайн", Count: 1}},
+		Entries: []courses.CatalogEntry{
+			{ID: keptCourseID, Title: "Go basics", Categories: []string{"development"}, Links: []courses.CatalogLink{
+				{URL: "https://files.example.test/a", Content: &courses.LinkContent{Name: "go", FileCount: 2}},
+				{URL: "https://files.example.test/b"},
+			}},
+			{ID: addedCourseID, Title: "Design", Categories: []string{"design", "development"}},
+		},
+	})
+	args := []string{"--old", oldPath, "--new", newPath}
+
+	var text bytes.Buffer
+	if err := run(args, dependencies{stdout: &text}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	for _, want := range []string{
+		"catalog diff: 2 -> 2 courses, 1 added, 1 removed, 1 changed\n",
+		"added\t" + addedCourseID + "\tDesign\n",
+		"removed\t" + removedCourseID + "\tOld *course*\n",
+		"\tlink added: https://files.example.test/b\n",
+		"\tenrichment https://files.example.test/a: none -> \"go\", 2 files\n",
+		"facet\tcategory\tdesign\t0 -> 1 (+1)\n",
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Fatalf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := run(append(args, "--format", "json"), dependencies{stdout: &encoded}); err != nil {
+		t.Fatalf("run() json error = %v", err)
+	}
+	var diff courses.CatalogDiff
+	if err := json.Unmarshal(encoded.Bytes(), &diff); err != nil {
+		t.Fatalf("decode json output: %v", err)
+	}
+	if len(diff.Added) != 1 || len(diff.Removed) != 1 || len(diff.Changed) != 1 || len(diff.Facets) != 1 {
+		t.Fatalf("json diff = %+v", diff)
+	}
+
+	var markdown bytes.Buffer
+	if err := run(append(args, "--format", "markdown"), dependencies{stdout: &markdown}); err != nil {
+		t.Fatalf("run() markdown error = %v", err)
+	}
+	for _, want := range []string{
+		"## Removed (1)\n\n- `" + removedCourseID + "` Old \\*course\\*\n",
+		"### Go basics (`" + keptCourseID + "`)\n",
+		"| category | design | 0 | 1 | +1 |\n",
+	} {
+		if !strings.Contains(markdown.String(), want) {
+			t.Fatalf("markdown output missing %q:\n%s", want, markdown.String())
+		}
+	}
+}
+
+func TestRunRejectsInvalidArgumentsAndCatalogs(t *testing.T) {
+	dir := t.TempDir()
+	catalogPath := filepath.Join(dir, "catalog.json.gz")
+	writeCatalog(t, catalogPath, courses.Catalog{SchemaVersion: "courses-catalog/v2", Entries: []courses.CatalogEntry{}})
+	invalidPath := filepath.Join(dir, "invalid.json.gz")
+	if err := os.WriteFile(invalidPath, []byte("not gzip"), 0o600); err != nil {
+		t.Fatalf("write invalid catalog: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"missing new", []string{"--old", catalogPath}, "--old and --new are required"},
+		{"unknown format", []string{"--old", catalogPath, "--new", catalogPath, "--format", "html"}, "--format must be"},
+		{"positional", []string{"--old", catalogPath, "--new", catalogPath, "extra"}, "unexpected positional arguments"},
+		{"invalid catalog", []string{"--old", catalogPath, "--new", invalidPath}, "load new catalog"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			if err := run(test.args, dependencies{stdout: &stdout}); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("run() error = %v, want %q", err, test.want)
+			}
+			if stdout.Len() != 0 {
+				t.Fatalf("stdout = %q, want nothing", stdout.String())
+			}
+		})
+	}
+}
+
+func writeCatalog(t *testing.T, path string, catalog courses.Catalog) {
+	t.Helper()
+	var payload bytes.Buffer
+	writer := gzip.NewWriter(&payload)
+	if err := json.NewEncoder(writer).Encode(catalog); err != nil {
+		t.Fatalf("encode catalog: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close catalog: %v", err)
+	}
+	if err := os.WriteFile(path, payload.Bytes(), 0o600); err != nil {
+		t.Fatalf("write catalog: %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -16,19 +18,19 @@ func main() {
 	config, err := parseArgs(os.Args[1:])
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		fmt.Fprintln(os.Stderr, "usage: courses-data --input <export.json> [--input <export.json>...] [--input-dir <exports-dir>] --output <catalog.json.gz> [--torrent-dir <dir>] [--title-rules <rules.json>] [--link-tombstones <tombstones.json>] [--link-suppressions <suppressions.json>] [--link-enrichment <cache.json>]")
+		fmt.Fprintln(os.Stderr, "usage: courses-data --input <export.json> [--input <export.json>...] [--input-dir <exports-dir>] --output <catalog.json.gz> [--torrent-dir <dir>] [--title-rules <rules.json>] [--taxonomy <taxonomy.json>] [--format-rules <rules.json>] [--link-tombstones <tombstones.json>] [--link-suppressions <suppressions.json>] [--link-enrichment <cache.json>] [--entry-overrides <overrides.json>] [--previous <catalog.json.gz> [--verify-incremental]] [--cross-channel-dedup] [--classifier-threshold <0-1>]")
+		fmt.Fprintln(os.Stderr, "   or: courses-data --input <export.json> [--input ...] --explain <entry-id|course-id|title> [--explain-format text|json] [build options]")
 		fmt.Fprintln(os.Stderr, "   or: courses-data <telegram-export.json> <catalog.json.gz> [torrent-dir]")
 		os.Exit(2)
 	}
-	if err := buildFilesWithLinkEnrichment(
-		config.InputPaths,
-		config.OutputPath,
-		config.TorrentDir,
-		config.TitleRulesPath,
-		config.LinkTombstonesPath,
-		config.LinkSuppressionsPath,
-		config.LinkEnrichmentPath,
-	); err != nil {
+	if config.Explain != "" {
+		if err := explainClassification(config, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "explain classification:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := buildCatalogFile(config); err != nil {
 		fmt.Fprintln(os.Stderr, "build courses catalog:", err)
 		os.Exit(1)
 	}
@@ -39,9 +41,18 @@ type config struct {
 	OutputPath           string
 	TorrentDir           string
 	TitleRulesPath       string
+	TaxonomyPath         string
+	FormatRulesPath      string
 	LinkTombstonesPath   string
 	LinkSuppressionsPath string
 	LinkEnrichmentPath   string
+	EntryOverridesPath   string
+	PreviousPath         string
+	VerifyIncremental    bool
+	CrossChannelDedup    bool
+	ClassifierThreshold  float64
+	Explain              string
+	ExplainFormat        string
 }
 
 type repeatedStrings []string
@@ -75,14 +86,23 @@ func parseArgs(args []string) (config, error) {
 	var inputs repeatedStrings
 	var inputDir string
 	result := config{}
-	flags.Var(&inputs, "input", "source export JSON path")
-	flags.StringVar(&inputDir, "input-dir", "", "directory containing source export JSON files")
+	flags.Var(&inputs, "input", "source export JSON, CSV or Markdown list path")
+	flags.StringVar(&inputDir, "input-dir", "", "directory containing source export JSON, CSV or Markdown files")
 	flags.StringVar(&result.OutputPath, "output", "", "catalog JSON gzip output path")
 	flags.StringVar(&result.TorrentDir, "torrent-dir", "", "directory containing downloaded .torrent files")
 	flags.StringVar(&result.TitleRulesPath, "title-rules", "", "title normalization rules JSON path")
+	flags.StringVar(&result.TaxonomyPath, "taxonomy", "", "category taxonomy JSON path; the built-in taxonomy is used when empty")
+	flags.StringVar(&result.FormatRulesPath, "format-rules", "", "format classification rules JSON path; the built-in rules are used when empty")
 	flags.StringVar(&result.LinkTombstonesPath, "link-tombstones", "", "link tombstones JSON path")
 	flags.StringVar(&result.LinkSuppressionsPath, "link-suppressions", "", "occurrence-specific link suppressions JSON path")
 	flags.StringVar(&result.LinkEnrichmentPath, "link-enrichment", "", "link enrichment cache JSON path")
+	flags.StringVar(&result.EntryOverridesPath, "entry-overrides", "", "per-entry curator overrides JSON path")
+	flags.StringVar(&result.PreviousPath, "previous", "", "previous catalog JSON gzip path to build incrementally from")
+	flags.BoolVar(&result.VerifyIncremental, "verify-incremental", false, "also run a full rebuild and fail unless the outputs match")
+	flags.BoolVar(&result.CrossChannelDedup, "cross-channel-dedup", false, "merge the same course posted in different channels")
+	flags.Float64Var(&result.ClassifierThreshold, "classifier-threshold", 0, "assign \"other\" entries the naive Bayes category whose confidence reaches this threshold; 0 disables")
+	flags.StringVar(&result.Explain, "explain", "", "explain the classification of the entries matching this entry ID, course ID or title instead of building")
+	flags.StringVar(&result.ExplainFormat, "explain-format", "text", "explain output format: text or json")
 	if err := flags.Parse(args); err != nil {
 		return config{}, err
 	}
@@ -91,7 +111,7 @@ func parseArgs(args []string) (config, error) {
 	}
 	result.InputPaths = append(result.InputPaths, inputs...)
 	if strings.TrimSpace(inputDir) != "" {
-		paths, err := inputPathsFromDir(inputDir)
+		paths, err := courses.SourceInputPaths(inputDir)
 		if err != nil {
 			return config{}, err
 		}
@@ -100,26 +120,23 @@ func parseArgs(args []string) (config, error) {
 	if len(result.InputPaths) == 0 {
 		return config{}, fmt.Errorf("at least one --input or --input-dir is required")
 	}
+	if result.ClassifierThreshold < 0 || result.ClassifierThreshold > 1 {
+		return config{}, fmt.Errorf("--classifier-threshold must be between 0 and 1")
+	}
+	result.Explain = strings.TrimSpace(result.Explain)
+	if result.Explain != "" {
+		if result.ExplainFormat != "text" && result.ExplainFormat != "json" {
+			return config{}, fmt.Errorf("--explain-format must be text or json")
+		}
+		return result, nil
+	}
 	if strings.TrimSpace(result.OutputPath) == "" {
 		return config{}, fmt.Errorf("--output is required")
 	}
-	return result, nil
-}
-
-func inputPathsFromDir(inputDir string) ([]string, error) {
-	entries, err := os.ReadDir(inputDir)
-	if err != nil {
-		return nil, fmt.Errorf("read input directory: %w", err)
+	if result.VerifyIncremental && strings.TrimSpace(result.PreviousPath) == "" {
+		return config{}, fmt.Errorf("--verify-incremental requires --previous")
 	}
-	paths := make([]string, 0, len(entries))
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
-			continue
-		}
-		paths = append(paths, filepath.Join(inputDir, entry.Name()))
-	}
-	slices.Sort(paths)
-	return paths, nil
+	return result, nil
 }
 
 func buildFile(inputPath, outputPath, torrentDir string) error {
@@ -134,38 +151,76 @@ func buildFilesWithLinkEnrichment(
 	inputPaths []string,
 	outputPath, torrentDir, titleRulesPath, linkTombstonesPath, linkSuppressionsPath, linkEnrichmentPath string,
 ) error {
-	titleRules, err := loadTitleRulesFile(titleRulesPath)
-	if err != nil {
-		return err
-	}
-	linkTombstones, err := loadLinkTombstonesFile(linkTombstonesPath)
-	if err != nil {
-		return err
-	}
-	linkSuppressions, err := loadLinkSuppressionsFile(linkSuppressionsPath)
-	if err != nil {
-		return err
-	}
-	linkEnrichment, err := loadLinkEnrichmentFile(linkEnrichmentPath)
+	return buildFilesIncrementally(
+		inputPaths,
+		outputPath,
+		torrentDir,
+		titleRulesPath,
+		linkTombstonesPath,
+		linkSuppressionsPath,
+		linkEnrichmentPath,
+		"",
+		false,
+	)
+}
+
+// buildFilesIncrementally builds from previousPath when it is set, reusing
+// its entries for clusters the newer exports do not touch.
+func buildFilesIncrementally(
+	inputPaths []string,
+	outputPath, torrentDir, titleRulesPath, linkTombstonesPath, linkSuppressionsPath, linkEnrichmentPath string,
+	previousPath string,
+	verifyIncremental bool,
+) error {
+	return buildCatalogFile(config{
+		InputPaths:           inputPaths,
+		OutputPath:           outputPath,
+		TorrentDir:           torrentDir,
+		TitleRulesPath:       titleRulesPath,
+		LinkTombstonesPath:   linkTombstonesPath,
+		LinkSuppressionsPath: linkSuppressionsPath,
+		LinkEnrichmentPath:   linkEnrichmentPath,
+		PreviousPath:         previousPath,
+		VerifyIncremental:    verifyIncremental,
+	})
+}
+
+func buildCatalogFile(config config) error {
+	outputPath := config.OutputPath
+	options, err := loadBuildOptions(config)
 	if err != nil {
 		return err
 	}
 
-	inputs := make([]courses.SourceInput, 0, len(inputPaths))
-	closers := make([]io.Closer, 0, len(inputPaths))
-	defer func() {
-		for _, closer := range closers {
-			_ = closer.Close()
-		}
-	}()
-	for _, inputPath := range inputPaths {
-		input, err := os.Open(inputPath)
+	var previous *os.File
+	var incrementalOptions courses.IncrementalOptions
+	if strings.TrimSpace(config.PreviousPath) != "" {
+		previous, err = os.Open(config.PreviousPath)
 		if err != nil {
-			return fmt.Errorf("open source export %q: %w", inputPath, err)
+			return fmt.Errorf("open previous catalog %q: %w", config.PreviousPath, err)
 		}
-		closers = append(closers, input)
-		inputs = append(inputs, courses.SourceInput{Reader: input, Name: inputPath})
+		defer previous.Close()
+		incrementalOptions = courses.IncrementalOptions{Previous: previous, Verify: config.VerifyIncremental}
+
+		// Catalogs written before source states existed have none; their
+		// inputs must then hold every export.
+		statePath := courses.SourceStatePath(config.PreviousPath)
+		previousSources, err := os.Open(statePath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return fmt.Errorf("open previous source state %q: %w", statePath, err)
+		default:
+			defer previousSources.Close()
+			incrementalOptions.PreviousSources = previousSources
+		}
+	}
+
+	inputs, closeInputs, err := courses.OpenSourceInputs(config.InputPaths)
+	if err != nil {
+		return err
 	}
+	defer closeInputs()
 
 	outputDir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(outputDir, 0o700); err != nil {
@@ -177,15 +232,24 @@ func buildFilesWithLinkEnrichment(
 	}
 	tempPath := temp.Name()
 	defer os.Remove(tempPath)
+	sourcesTemp, err := os.CreateTemp(outputDir, ".courses-sources-*.tmp")
+	if err != nil {
+		_ = temp.Close()
+		return fmt.Errorf("create temporary source state: %w", err)
+	}
+	sourcesTempPath := sourcesTemp.Name()
+	defer os.Remove(sourcesTempPath)
 
-	stats, buildErr := courses.BuildGzipFromSourcesWithOptions(inputs, temp, courses.BuildOptions{
-		TorrentDir:       torrentDir,
-		TitleRules:       titleRules,
-		LinkTombstones:   linkTombstones,
-		LinkSuppressions: linkSuppressions,
-		LinkEnrichment:   linkEnrichment,
-	})
-	closeErr := temp.Close()
+	var stats courses.CatalogStats
+	var incremental courses.IncrementalStats
+	var buildErr error
+	if previous != nil {
+		incrementalOptions.Sources = sourcesTemp
+		stats, incremental, buildErr = courses.BuildGzipIncremental(inputs, temp, options, incrementalOptions)
+	} else {
+		stats, buildErr = courses.BuildGzipWithSources(inputs, temp, sourcesTemp, options)
+	}
+	closeErr := errors.Join(temp.Close(), sourcesTemp.Close())
 	if buildErr != nil {
 		return buildErr
 	}
@@ -195,9 +259,17 @@ func buildFilesWithLinkEnrichment(
 	if err := os.Chmod(tempPath, 0o600); err != nil {
 		return fmt.Errorf("set catalog permissions: %w", err)
 	}
+	if err := os.Chmod(sourcesTempPath, 0o600); err != nil {
+		return fmt.Errorf("set source state permissions: %w", err)
+	}
+	// A failure between the renames leaves a source state that no longer
+	// matches the catalog, which the next incremental build rejects.
 	if err := os.Rename(tempPath, outputPath); err != nil {
 		return fmt.Errorf("publish catalog: %w", err)
 	}
+	if err := os.Rename(sourcesTempPath, courses.SourceStatePath(outputPath)); err != nil {
+		return fmt.Errorf("publish source state: %w", err)
+	}
 
 	fmt.Printf(
 		"built %s: %d courses from %d source entries, %d normalized titles, %d enriched links, %d links, %d passwords\n",
@@ -209,73 +281,139 @@ func buildFilesWithLinkEnrichment(
 		stats.Links,
 		stats.Passwords,
 	)
+	if options.EntryOverrides != nil {
+		fmt.Printf("entry overrides: %d overridden courses, %d stale overrides\n", stats.OverriddenEntries, stats.StaleOverrides)
+	}
+	if previous != nil {
+		fmt.Printf(
+			"incremental: %d changed exports, %d skipped exports, %d reused courses, %d rebuilt courses, verified=%t\n",
+			incremental.ChangedExports,
+			incremental.SkippedExports,
+			incremental.ReusedEntries,
+			incremental.RebuiltEntries,
+			incremental.Verified,
+		)
+		if incremental.FullRebuild != "" {
+			fmt.Printf("incremental: full rebuild: %s\n", incremental.FullRebuild)
+		}
+	}
 	return nil
 }
 
-func loadLinkEnrichmentFile(path string) (*courses.LinkEnrichmentCache, error) {
-	path = strings.TrimSpace(path)
-	if path == "" {
-		return nil, nil
-	}
-	file, err := os.Open(path)
+func loadBuildOptions(config config) (courses.BuildOptions, error) {
+	return courses.LoadBuildOptions(courses.BuildFiles{
+		TorrentDir:          config.TorrentDir,
+		TitleRules:          config.TitleRulesPath,
+		Taxonomy:            config.TaxonomyPath,
+		FormatRules:         config.FormatRulesPath,
+		LinkTombstones:      config.LinkTombstonesPath,
+		LinkSuppressions:    config.LinkSuppressionsPath,
+		LinkEnrichment:      config.LinkEnrichmentPath,
+		EntryOverrides:      config.EntryOverridesPath,
+		CrossChannelDedup:   config.CrossChannelDedup,
+		ClassifierThreshold: config.ClassifierThreshold,
+	})
+}
+
+func explainClassification(config config, output io.Writer) error {
+	options, err := loadBuildOptions(config)
 	if err != nil {
-		return nil, fmt.Errorf("load link enrichment %q: %w", path, err)
+		return err
 	}
-	defer file.Close()
-	cache, err := courses.LoadLinkEnrichmentCache(file)
+	inputs, closeInputs, err := courses.OpenSourceInputs(config.InputPaths)
 	if err != nil {
-		return nil, fmt.Errorf("load link enrichment %q: %w", path, err)
+		return err
 	}
-	return cache, nil
-}
+	defer closeInputs()
 
-func loadLinkTombstonesFile(path string) (*courses.LinkTombstones, error) {
-	path = strings.TrimSpace(path)
-	if path == "" {
-		return nil, nil
-	}
-	file, err := os.Open(path)
+	explanations, err := courses.ExplainClassification(inputs, config.Explain, options)
 	if err != nil {
-		return nil, fmt.Errorf("load link tombstones %q: %w", path, err)
+		return err
 	}
-	defer file.Close()
-	tombstones, err := courses.LoadLinkTombstones(file)
-	if err != nil {
-		return nil, fmt.Errorf("load link tombstones %q: %w", path, err)
+	if config.ExplainFormat == "json" {
+		encoder := json.NewEncoder(output)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(explanations)
 	}
-	return tombstones, nil
+	for index, explanation := range explanations {
+		if index > 0 {
+			fmt.Fprintln(output)
+		}
+		writeExplanationText(output, explanation)
+	}
+	return nil
 }
 
-func loadLinkSuppressionsFile(path string) (*courses.LinkSuppressions, error) {
-	path = strings.TrimSpace(path)
-	if path == "" {
-		return nil, nil
+func writeExplanationText(output io.Writer, explanation courses.ClassificationExplanation) {
+	course := "not in catalog"
+	if explanation.CourseID != "" {
+		course = "course " + explanation.CourseID
 	}
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("load link suppressions %q: %w", path, err)
+	fmt.Fprintf(output, "entry %s (%s)\n", explanation.EntryID, course)
+	fmt.Fprintf(output, "  title: %s\n", explanation.Title)
+	if explanation.Author != nil {
+		fmt.Fprintf(output, "  author: %s\n", *explanation.Author)
 	}
-	defer file.Close()
-	suppressions, err := courses.LoadLinkSuppressions(file)
-	if err != nil {
-		return nil, fmt.Errorf("load link suppressions %q: %w", path, err)
+
+	fmt.Fprintf(output, "  categories: %s\n", strings.Join(explanation.Categories, ", "))
+	if len(explanation.CategoryMatches) == 0 {
+		fmt.Fprintln(output, "    no category keywords matched")
+	}
+	for _, match := range explanation.CategoryMatches {
+		var notes []string
+		for _, blocked := range match.Blocked {
+			if blocked.Vetoed {
+				notes = append(notes, "blocked by "+strings.Join(blocked.Matched, ", "))
+			} else {
+				notes = append(notes, fmt.Sprintf("block on %s lifted by %s", strings.Join(blocked.Matched, ", "), strings.Join(blocked.Unless, ", ")))
+			}
+		}
+		if match.OverriddenBy != "" {
+			notes = append(notes, "overridden by "+match.OverriddenBy)
+		}
+		writeExplanationMatch(output, match.Applied, match.Category, match.Keywords, notes)
+	}
+	if explanation.CategorySource == "classifier" {
+		fmt.Fprintf(output, "  classifier: %s with confidence %.3f\n", explanation.CourseCategories[0], explanation.CategoryConfidence)
+	}
+	if topic := explanation.AuthorTopic; topic != nil {
+		fmt.Fprintf(
+			output,
+			"  author topic: %s, shared by %d of %d classified entries by %q\n",
+			topic.Topic,
+			topic.TopicCount,
+			topic.Classified,
+			topic.Author,
+		)
+	}
+	if explanation.CourseID != "" && !slices.Equal(explanation.CourseCategories, explanation.Categories) {
+		fmt.Fprintf(output, "  course categories: %s\n", strings.Join(explanation.CourseCategories, ", "))
 	}
-	return suppressions, nil
-}
 
-func loadTitleRulesFile(path string) (*courses.TitleRules, error) {
-	path = strings.TrimSpace(path)
-	if path == "" {
-		return nil, nil
+	fmt.Fprintf(output, "  formats: %s (%s)\n", strings.Join(explanation.Formats, ", "), strings.Join(explanation.FormatSources, ", "))
+	for _, match := range explanation.FormatMatches {
+		var notes []string
+		if len(match.Unless) != 0 {
+			notes = append(notes, "vetoed by "+strings.Join(match.Unless, ", "))
+		} else if !match.Fired {
+			notes = append(notes, "required tokens missing")
+		}
+		writeExplanationMatch(output, match.Fired, match.Format+" "+match.Signal+":"+match.Rule, match.Matched, notes)
 	}
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("load title rules %q: %w", path, err)
+	if explanation.CourseID != "" && !slices.Equal(explanation.CourseFormats, explanation.Formats) {
+		fmt.Fprintf(output, "  course formats: %s\n", strings.Join(explanation.CourseFormats, ", "))
 	}
-	defer file.Close()
-	rules, err := courses.LoadTitleRules(file)
-	if err != nil {
-		return nil, fmt.Errorf("load title rules %q: %w", path, err)
+}
+
+func writeExplanationMatch(output io.Writer, applied bool, name string, matched, notes []string) {
+	mark := "+"
+	if !applied {
+		mark = "-"
+	}
+	fmt.Fprintf(output, "    %s %s: %s", mark, name, strings.Join(matched, ", "))
+	if len(notes) != 0 {
+		fmt.Fprintf(output, " [%s]", strings.Join(notes, "; "))
 	}
-	return rules, nil
+	fmt.Fprintln(output)
 }
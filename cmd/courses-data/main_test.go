@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/xenking/dummypage/internal/courses"
 )
 
 func TestBuildFileAcceptsOptionalTorrentDir(t *testing.T) {
@@ -125,6 +129,115 @@ func TestBuildFilesMergesMultipleInputsFromFlagStyleConfig(t *testing.T) {
 	}
 }
 
+func TestBuildFilesIncrementallyFromPreviousCatalog(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.json")
+	newPath := filepath.Join(dir, "new.json")
+	previousPath := filepath.Join(dir, "previous.json.gz")
+	outputPath := filepath.Join(dir, "catalog.json.gz")
+	if err := os.WriteFile(oldPath, []byte(sourceWithTitleJSON("1:1:0", "1:1", 1, "Legacy Course")), 0o600); err != nil {
+		t.Fatalf("write old source: %v", err)
+	}
+	newSource := strings.Replace(sourceWithTitleJSON("1:2:0", "1:2", 2, "Current Course"), "2026-07-26T00:00:00Z", "2026-08-26T00:00:00Z", 1)
+	if err := os.WriteFile(newPath, []byte(newSource), 0o600); err != nil {
+		t.Fatalf("write new source: %v", err)
+	}
+	if err := buildFiles([]string{oldPath}, previousPath, "", "", ""); err != nil {
+		t.Fatalf("build previous catalog: %v", err)
+	}
+
+	config, err := parseArgs([]string{
+		"--input", oldPath,
+		"--input", newPath,
+		"--output", outputPath,
+		"--previous", previousPath,
+		"--verify-incremental",
+	})
+	if err != nil {
+		t.Fatalf("parse args: %v", err)
+	}
+	if err := buildFilesIncrementally(
+		config.InputPaths,
+		config.OutputPath,
+		config.TorrentDir,
+		config.TitleRulesPath,
+		config.LinkTombstonesPath,
+		config.LinkSuppressionsPath,
+		config.LinkEnrichmentPath,
+		config.PreviousPath,
+		config.VerifyIncremental,
+	); err != nil {
+		t.Fatalf("build incrementally: %v", err)
+	}
+
+	payload := readGzipFile(t, outputPath)
+	if !strings.Contains(payload, "Legacy Course") || !strings.Contains(payload, "Current Course") {
+		t.Fatalf("catalog does not preserve both inputs: %s", payload)
+	}
+	if _, err := parseArgs([]string{"--input", oldPath, "--output", outputPath, "--verify-incremental"}); err == nil {
+		t.Fatal("parse args accepted --verify-incremental without --previous")
+	}
+}
+
+func TestBuildFilesIncrementallyFromOnlyNewExports(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.json")
+	newPath := filepath.Join(dir, "new.json")
+	previousPath := filepath.Join(dir, "previous.json.gz")
+	fullPath := filepath.Join(dir, "full.json.gz")
+	if err := os.WriteFile(oldPath, []byte(sourceWithTitleJSON("1:1:0", "1:1", 1, "Legacy Course")), 0o600); err != nil {
+		t.Fatalf("write old source: %v", err)
+	}
+	newSource := strings.Replace(sourceWithTitleJSON("1:2:0", "1:2", 2, "Current Course"), "2026-07-26T00:00:00Z", "2026-08-26T00:00:00Z", 1)
+	if err := os.WriteFile(newPath, []byte(newSource), 0o600); err != nil {
+		t.Fatalf("write new source: %v", err)
+	}
+	if err := buildFiles([]string{oldPath}, previousPath, "", "", ""); err != nil {
+		t.Fatalf("build previous catalog: %v", err)
+	}
+	if err := buildFiles([]string{oldPath, newPath}, fullPath, "", "", ""); err != nil {
+		t.Fatalf("build full catalog: %v", err)
+	}
+	statePath := courses.SourceStatePath(previousPath)
+	info, err := os.Stat(statePath)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("source state = %v, %v", info, err)
+	}
+	state, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read source state: %v", err)
+	}
+	published := readGzipFile(t, previousPath)
+
+	// Without its source state the previous catalog cannot stand in for
+	// the older exports.
+	if err := os.Remove(statePath); err != nil {
+		t.Fatalf("remove source state: %v", err)
+	}
+	err = buildCatalogFile(config{InputPaths: []string{newPath}, OutputPath: previousPath, PreviousPath: previousPath})
+	if err == nil || !strings.Contains(err.Error(), "missing from the inputs") {
+		t.Fatalf("error = %v, want missing older exports", err)
+	}
+	if got := readGzipFile(t, previousPath); got != published {
+		t.Fatalf("catalog replaced by a build from only the new export: %s", got)
+	}
+
+	if err := os.WriteFile(statePath, state, 0o600); err != nil {
+		t.Fatalf("restore source state: %v", err)
+	}
+	if err := buildCatalogFile(config{
+		InputPaths:        []string{newPath},
+		OutputPath:        previousPath,
+		PreviousPath:      previousPath,
+		VerifyIncremental: true,
+	}); err != nil {
+		t.Fatalf("build incrementally from the new export: %v", err)
+	}
+	if got, want := readGzipFile(t, previousPath), readGzipFile(t, fullPath); got != want {
+		t.Fatalf("incremental catalog = %s, want full rebuild %s", got, want)
+	}
+}
+
 func TestParseArgsAcceptsTitleRules(t *testing.T) {
 	dir := t.TempDir()
 	inputPath := filepath.Join(dir, "source.json")
@@ -211,6 +324,91 @@ func TestParseArgsAcceptsLinkEnrichment(t *testing.T) {
 	}
 }
 
+func TestParseArgsAcceptsCrossChannelDedup(t *testing.T) {
+	dir := t.TempDir()
+	config, err := parseArgs([]string{
+		"--input", filepath.Join(dir, "source.json"),
+		"--output", filepath.Join(dir, "catalog.json.gz"),
+		"--cross-channel-dedup",
+	})
+	if err != nil {
+		t.Fatalf("parse args: %v", err)
+	}
+	if !config.CrossChannelDedup {
+		t.Fatalf("config = %+v, want cross-channel dedup", config)
+	}
+}
+
+func TestParseArgsAcceptsFormatRules(t *testing.T) {
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "format-rules.json")
+	config, err := parseArgs([]string{
+		"--input", filepath.Join(dir, "source.json"),
+		"--output", filepath.Join(dir, "catalog.json.gz"),
+		"--format-rules", rulesPath,
+	})
+	if err != nil {
+		t.Fatalf("parse args: %v", err)
+	}
+	if config.FormatRulesPath != rulesPath {
+		t.Fatalf("format rules path = %q, want %q", config.FormatRulesPath, rulesPath)
+	}
+}
+
+func TestParseArgsValidatesClassifierThreshold(t *testing.T) {
+	dir := t.TempDir()
+	args := []string{"--input", filepath.Join(dir, "source.json"), "--output", filepath.Join(dir, "catalog.json.gz")}
+	config, err := parseArgs(append(args, "--classifier-threshold", "0.85"))
+	if err != nil {
+		t.Fatalf("parse args: %v", err)
+	}
+	if config.ClassifierThreshold != 0.85 {
+		t.Fatalf("classifier threshold = %v, want 0.85", config.ClassifierThreshold)
+	}
+	if _, err := parseArgs(append(args, "--classifier-threshold", "1.5")); err == nil {
+		t.Fatal("parse args accepted a threshold above 1")
+	}
+}
+
+func TestExplainClassificationWritesTextAndJSON(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "source.json")
+	if err := os.WriteFile(inputPath, []byte(sourceWithTitleJSON("1:1:0", "1:1", 1, "Python курс")), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	config, err := parseArgs([]string{"--input", inputPath, "--explain", "python КУРС"})
+	if err != nil {
+		t.Fatalf("parse args: %v", err)
+	}
+	var text bytes.Buffer
+	if err := explainClassification(config, &text); err != nil {
+		t.Fatalf("explain text: %v", err)
+	}
+	for _, want := range []string{"entry 1:1:0 (course ", "categories: development", "+ development: python", "+ course title:course: курс"} {
+		if !strings.Contains(text.String(), want) {
+			t.Fatalf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	config.ExplainFormat = "json"
+	var payload bytes.Buffer
+	if err := explainClassification(config, &payload); err != nil {
+		t.Fatalf("explain json: %v", err)
+	}
+	var explanations []courses.ClassificationExplanation
+	if err := json.Unmarshal(payload.Bytes(), &explanations); err != nil {
+		t.Fatalf("decode json output: %v", err)
+	}
+	if len(explanations) != 1 || explanations[0].EntryID != "1:1:0" || len(explanations[0].FormatMatches) != 1 {
+		t.Fatalf("explanations = %+v", explanations)
+	}
+
+	if _, err := parseArgs([]string{"--input", inputPath, "--explain", "1:1:0", "--explain-format", "yaml"}); err == nil {
+		t.Fatal("parse args accepted an unknown explain format")
+	}
+}
+
 func TestBuildFilesWithLinkEnrichmentEmitsCachedContent(t *testing.T) {
 	dir := t.TempDir()
 	inputPath := filepath.Join(dir, "source.json")
@@ -398,6 +596,59 @@ func TestBuildFilesMalformedLinkTombstonesDoesNotOpenSourcesOrReplaceOutput(t *t
 	}
 }
 
+func TestBuildCatalogFileMalformedTaxonomyDoesNotReplaceOutput(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "catalog.json.gz")
+	taxonomyPath := filepath.Join(dir, "taxonomy.json")
+	const sentinel = "sentinel output"
+
+	if err := os.WriteFile(outputPath, []byte(sentinel), 0o600); err != nil {
+		t.Fatalf("write sentinel output: %v", err)
+	}
+	if err := os.WriteFile(taxonomyPath, []byte(`{"schema_version":"course-taxonomy/v1","categories":[]}`), 0o600); err != nil {
+		t.Fatalf("write malformed taxonomy: %v", err)
+	}
+
+	err := buildCatalogFile(config{
+		InputPaths:   []string{filepath.Join(dir, "missing-source.json")},
+		OutputPath:   outputPath,
+		TaxonomyPath: taxonomyPath,
+	})
+	if err == nil || !strings.Contains(err.Error(), "load taxonomy") || !strings.Contains(err.Error(), taxonomyPath) {
+		t.Fatalf("error = %v, want contextual taxonomy path", err)
+	}
+	if got, _ := os.ReadFile(outputPath); string(got) != sentinel {
+		t.Fatalf("output was replaced: %q", string(got))
+	}
+}
+
+func TestBuildCatalogFileMalformedEntryOverridesDoesNotReplaceOutput(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "catalog.json.gz")
+	overridesPath := filepath.Join(dir, "entry-overrides.json")
+	const sentinel = "sentinel output"
+
+	if err := os.WriteFile(outputPath, []byte(sentinel), 0o600); err != nil {
+		t.Fatalf("write sentinel output: %v", err)
+	}
+	overrides := `{"schema_version":"entry-overrides/v1","overrides":[{"source_entry_id":"1:1:0","hidden":true}]}`
+	if err := os.WriteFile(overridesPath, []byte(overrides), 0o600); err != nil {
+		t.Fatalf("write malformed entry overrides: %v", err)
+	}
+
+	err := buildCatalogFile(config{
+		InputPaths:         []string{filepath.Join(dir, "missing-source.json")},
+		OutputPath:         outputPath,
+		EntryOverridesPath: overridesPath,
+	})
+	if err == nil || !strings.Contains(err.Error(), "load entry overrides") || !strings.Contains(err.Error(), overridesPath) {
+		t.Fatalf("error = %v, want contextual entry overrides path", err)
+	}
+	if got, _ := os.ReadFile(outputPath); string(got) != sentinel {
+		t.Fatalf("output was replaced: %q", string(got))
+	}
+}
+
 func TestBuildFilesMalformedTitleRulesDoesNotOpenSourcesOrReplaceOutput(t *testing.T) {
 	dir := t.TempDir()
 	missingInputPath := filepath.Join(dir, "missing-source.json")
@@ -430,7 +681,7 @@ func TestBuildFilesMalformedTitleRulesDoesNotOpenSourcesOrReplaceOutput(t *testi
 
 func TestParseArgsReadsInputDirectoryInStableOrder(t *testing.T) {
 	dir := t.TempDir()
-	for _, name := range []string{"b.json", "a.json", "ignore.txt"} {
+	for _, name := range []string{"b.json", "a.json", "c.csv", "d.md", "ignore.txt"} {
 		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600); err != nil {
 			t.Fatalf("write %s: %v", name, err)
 		}
@@ -440,12 +691,30 @@ func TestParseArgsReadsInputDirectoryInStableOrder(t *testing.T) {
 	if err != nil {
 		t.Fatalf("parse args: %v", err)
 	}
-	want := []string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")}
-	if len(config.InputPaths) != len(want) || config.InputPaths[0] != want[0] || config.InputPaths[1] != want[1] {
+	want := []string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json"), filepath.Join(dir, "c.csv"), filepath.Join(dir, "d.md")}
+	if !slices.Equal(config.InputPaths, want) {
 		t.Fatalf("input paths = %v, want %v", config.InputPaths, want)
 	}
 }
 
+func TestBuildFilesReadsCuratedCSVList(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "curated.csv")
+	outputPath := filepath.Join(dir, "catalog.json.gz")
+	csv := "title,author,added_at,links\nCurated Course,Fixture Author,2024-01-01,https://files.example.test/curated\n"
+	if err := os.WriteFile(inputPath, []byte(csv), 0o600); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+
+	if err := buildFiles([]string{inputPath}, outputPath, "", "", ""); err != nil {
+		t.Fatalf("build files: %v", err)
+	}
+	payload := readGzipFile(t, outputPath)
+	if !strings.Contains(payload, "Curated Course") || !strings.Contains(payload, `"title":"curated"`) {
+		t.Fatalf("catalog does not contain the curated list: %s", payload)
+	}
+}
+
 func readGzipFile(t *testing.T, path string) string {
 	t.Helper()
 
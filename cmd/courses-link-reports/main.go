@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+const maxCatalogBytes = 512 << 20
+
+type command string
+
+const (
+	commandList    command = "list"
+	commandApprove command = "approve"
+	commandDismiss command = "dismiss"
+)
+
+type config struct {
+	Command          command
+	ReportsPath      string
+	CatalogPath      string
+	TombstonesPath   string
+	SuppressionsPath string
+	ID               string
+	Resolution       courses.LinkReportResolution
+}
+
+type dependencies struct {
+	now    func() time.Time
+	stdout io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], dependencies{
+		now:    time.Now,
+		stdout: os.Stdout,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func parseArgs(args []string) (config, error) {
+	if len(args) == 0 {
+		return config{}, errors.New("expected list, approve, or dismiss command")
+	}
+	var result config
+	result.Command = command(args[0])
+	switch result.Command {
+	case commandList, commandApprove, commandDismiss:
+	default:
+		return config{}, errors.New("expected list, approve, or dismiss command")
+	}
+
+	flags := flag.NewFlagSet("courses-link-reports", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var resolution string
+	flags.StringVar(&result.ReportsPath, "reports", "", "link reports JSON path")
+	flags.StringVar(&result.CatalogPath, "catalog", "", "catalog JSON gzip path")
+	flags.StringVar(&result.TombstonesPath, "tombstones", "", "link tombstones JSON path")
+	flags.StringVar(&result.SuppressionsPath, "suppressions", "", "link suppressions JSON path")
+	flags.StringVar(&result.ID, "id", "", "link report ID")
+	flags.StringVar(&resolution, "as", "", "approval resolution: tombstone or suppression")
+	if err := flags.Parse(args[1:]); err != nil {
+		return config{}, errors.New("invalid arguments")
+	}
+	if flags.NArg() != 0 {
+		return config{}, errors.New("unexpected positional arguments")
+	}
+	result.Resolution = courses.LinkReportResolution(resolution)
+	if strings.TrimSpace(result.ReportsPath) == "" {
+		return config{}, errors.New("--reports is required")
+	}
+
+	switch result.Command {
+	case commandList:
+		if result.ID != "" || resolution != "" || result.TombstonesPath != "" || result.SuppressionsPath != "" {
+			return config{}, errors.New("list accepts only --reports and --catalog")
+		}
+	case commandDismiss:
+		if strings.TrimSpace(result.ID) == "" {
+			return config{}, errors.New("--id is required")
+		}
+		if resolution != "" || result.CatalogPath != "" || result.TombstonesPath != "" || result.SuppressionsPath != "" {
+			return config{}, errors.New("dismiss accepts only --reports and --id")
+		}
+	case commandApprove:
+		if strings.TrimSpace(result.ID) == "" {
+			return config{}, errors.New("--id is required")
+		}
+		switch result.Resolution {
+		case courses.LinkReportResolutionTombstone:
+			if strings.TrimSpace(result.TombstonesPath) == "" || result.SuppressionsPath != "" || result.CatalogPath != "" {
+				return config{}, errors.New("--as tombstone requires only --tombstones")
+			}
+			if samePath(result.ReportsPath, result.TombstonesPath) {
+				return config{}, errors.New("input and output paths must be distinct")
+			}
+		case courses.LinkReportResolutionSuppression:
+			if strings.TrimSpace(result.SuppressionsPath) == "" || strings.TrimSpace(result.CatalogPath) == "" || result.TombstonesPath != "" {
+				return config{}, errors.New("--as suppression requires --suppressions and --catalog")
+			}
+			if samePath(result.ReportsPath, result.SuppressionsPath) ||
+				samePath(result.ReportsPath, result.CatalogPath) ||
+				samePath(result.SuppressionsPath, result.CatalogPath) {
+				return config{}, errors.New("input and output paths must be distinct")
+			}
+		default:
+			return config{}, errors.New("--as must be tombstone or suppression")
+		}
+	}
+	if result.CatalogPath != "" && samePath(result.ReportsPath, result.CatalogPath) {
+		return config{}, errors.New("input and output paths must be distinct")
+	}
+	return result, nil
+}
+
+func run(ctx context.Context, args []string, deps dependencies) error {
+	cfg, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+	if deps.now == nil || deps.stdout == nil {
+		return errors.New("invalid runtime dependencies")
+	}
+	if cfg.Command != commandList {
+		// The server appends reports to the same file; holding its lock
+		// until the outputs are published keeps either side from dropping
+		// the other's update.
+		unlock, err := filelock.Lock(cfg.ReportsPath)
+		if err != nil {
+			return errors.New("lock link reports: unavailable")
+		}
+		defer unlock()
+	}
+
+	reports, err := loadReports(cfg.ReportsPath)
+	if err != nil {
+		return errors.New("load link reports: invalid or unavailable input")
+	}
+	var catalog courses.Catalog
+	if cfg.CatalogPath != "" {
+		catalog, err = loadCatalog(cfg.CatalogPath)
+		if err != nil {
+			return errors.New("load catalog: invalid or unavailable input")
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch cfg.Command {
+	case commandList:
+		printPending(deps.stdout, reports.Pending(), catalog, cfg.CatalogPath != "")
+		return nil
+	case commandDismiss:
+		report, err := reports.Dismiss(cfg.ID, deps.now())
+		if err != nil {
+			return fmt.Errorf("dismiss link report: %w", err)
+		}
+		reportsJSON, err := marshalPrivateJSON(reports)
+		if err != nil {
+			return errors.New("encode link reports")
+		}
+		if err := writeAtomicFile(ctx, cfg.ReportsPath, reportsJSON); err != nil {
+			return errors.New("publish link reports")
+		}
+		fmt.Fprintf(deps.stdout, "dismissed=%s\n", report.ID)
+		return nil
+	}
+
+	now := deps.now()
+	report, err := reports.Approve(cfg.ID, cfg.Resolution, now)
+	if err != nil {
+		return fmt.Errorf("approve link report: %w", err)
+	}
+	reportsJSON, err := marshalPrivateJSON(reports)
+	if err != nil {
+		return errors.New("encode link reports")
+	}
+
+	targetPath := cfg.TombstonesPath
+	var targetJSON []byte
+	added := 0
+	if cfg.Resolution == courses.LinkReportResolutionTombstone {
+		tombstones, err := loadOptionalTombstones(cfg.TombstonesPath)
+		if err != nil {
+			return errors.New("load tombstones: invalid or unavailable input")
+		}
+		if tombstones.AddLinkReport(report, now) {
+			added = 1
+		}
+		if targetJSON, err = marshalPrivateJSON(tombstones); err != nil {
+			return errors.New("encode tombstones")
+		}
+	} else {
+		targetPath = cfg.SuppressionsPath
+		sourceEntryIDs := courses.CourseSourceEntryIDs(catalog, report.CourseID)
+		if len(sourceEntryIDs) == 0 {
+			return errors.New("approve link report: course is not in catalog")
+		}
+		suppressions, err := loadOptionalSuppressions(cfg.SuppressionsPath)
+		if err != nil {
+			return errors.New("load suppressions: invalid or unavailable input")
+		}
+		added = suppressions.AddLinkReport(report, sourceEntryIDs, now)
+		if targetJSON, err = marshalPrivateJSON(suppressions); err != nil {
+			return errors.New("encode suppressions")
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := writeAtomicPair(ctx, targetPath, targetJSON, cfg.ReportsPath, reportsJSON); err != nil {
+		return errors.New("publish moderation outputs")
+	}
+	fmt.Fprintf(deps.stdout, "approved=%s resolution=%s added=%d\n", report.ID, report.Resolution, added)
+	return nil
+}
+
+func printPending(w io.Writer, pending []courses.LinkReport, catalog courses.Catalog, withCatalog bool) {
+	entries := make(map[string]courses.CatalogEntry, len(catalog.Entries))
+	for _, entry := range catalog.Entries {
+		entries[entry.ID] = entry
+	}
+	for _, report := range pending {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\treports=%d\tfirst=%s\tlast=%s\n",
+			report.ID,
+			report.CourseID,
+			report.Reason,
+			report.Reports,
+			report.FirstReportedAt,
+			report.LastReportedAt,
+		)
+		if !withCatalog {
+			continue
+		}
+		entry, ok := entries[report.CourseID]
+		if !ok {
+			fmt.Fprintln(w, "\tcourse not in catalog")
+			continue
+		}
+		fmt.Fprintf(w, "\ttitle=%s\n", entry.Title)
+		matched := false
+		for _, link := range entry.Links {
+			if report.MatchesURL(link.URL) {
+				fmt.Fprintf(w, "\turl=%s\n", link.URL)
+				matched = true
+			}
+		}
+		if !matched {
+			fmt.Fprintln(w, "\tlink no longer in catalog")
+		}
+	}
+	fmt.Fprintf(w, "pending=%d\n", len(pending))
+}
+
+func loadReports(path string) (*courses.LinkReports, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return courses.NewLinkReports(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return courses.LoadLinkReports(file)
+}
+
+func loadCatalog(path string) (courses.Catalog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return courses.Catalog{}, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return courses.Catalog{}, err
+	}
+	defer reader.Close()
+
+	limited := &io.LimitedReader{R: reader, N: maxCatalogBytes + 1}
+	decoder := json.NewDecoder(limited)
+	decoder.DisallowUnknownFields()
+	var catalog courses.Catalog
+	if err := decoder.Decode(&catalog); err != nil {
+		return courses.Catalog{}, err
+	}
+	var extra json.RawMessage
+	if err := decoder.Decode(&extra); err != io.EOF {
+		if err == nil {
+			return courses.Catalog{}, errors.New("multiple catalog values")
+		}
+		return courses.Catalog{}, err
+	}
+	if limited.N <= 0 {
+		return courses.Catalog{}, errors.New("catalog too large")
+	}
+	if catalog.SchemaVersion != "courses-catalog/v2" || catalog.Entries == nil {
+		return courses.Catalog{}, errors.New("unsupported catalog")
+	}
+	return catalog, nil
+}
+
+func loadOptionalTombstones(path string) (*courses.LinkTombstones, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return courses.NewLinkTombstones(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return courses.LoadLinkTombstones(file)
+}
+
+func loadOptionalSuppressions(path string) (*courses.LinkSuppressions, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return courses.NewLinkSuppressions(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return courses.LoadLinkSuppressions(file)
+}
+
+func marshalPrivateJSON(value any) ([]byte, error) {
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+type pendingAtomicFile struct {
+	path     string
+	tempPath string
+}
+
+func writeAtomicFile(ctx context.Context, path string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := validateAtomicTarget(path); err != nil {
+		return err
+	}
+	pending, err := prepareAtomicFile(path, data)
+	if err != nil {
+		return err
+	}
+	defer pending.cleanup()
+	if err := os.Rename(pending.tempPath, pending.path); err != nil {
+		return err
+	}
+	pending.tempPath = ""
+	return nil
+}
+
+func writeAtomicPair(ctx context.Context, firstPath string, first []byte, secondPath string, second []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if samePath(firstPath, secondPath) {
+		return errors.New("atomic output paths must be distinct")
+	}
+	if err := validateAtomicTarget(firstPath); err != nil {
+		return err
+	}
+	if err := validateAtomicTarget(secondPath); err != nil {
+		return err
+	}
+	firstPending, err := prepareAtomicFile(firstPath, first)
+	if err != nil {
+		return err
+	}
+	defer firstPending.cleanup()
+	secondPending, err := prepareAtomicFile(secondPath, second)
+	if err != nil {
+		return err
+	}
+	defer secondPending.cleanup()
+
+	firstBackup, firstExisted, err := prepareAtomicBackup(firstPath)
+	if err != nil {
+		return err
+	}
+	defer firstBackup.cleanup()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(firstPending.tempPath, firstPending.path); err != nil {
+		return err
+	}
+	firstPending.tempPath = ""
+	if err := os.Rename(secondPending.tempPath, secondPending.path); err != nil {
+		if rollbackErr := restoreAtomicTarget(firstPath, firstBackup, firstExisted); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	secondPending.tempPath = ""
+	return nil
+}
+
+func validateAtomicTarget(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("atomic output target must be a regular file")
+	}
+	return nil
+}
+
+func prepareAtomicBackup(path string) (*pendingAtomicFile, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &pendingAtomicFile{path: path}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	pending, err := prepareAtomicFile(path, data)
+	if err != nil {
+		return nil, false, err
+	}
+	return pending, true, nil
+}
+
+func restoreAtomicTarget(path string, backup *pendingAtomicFile, existed bool) error {
+	if !existed {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if backup == nil || backup.tempPath == "" {
+		return errors.New("atomic output rollback is unavailable")
+	}
+	if err := os.Rename(backup.tempPath, path); err != nil {
+		return err
+	}
+	backup.tempPath = ""
+	return nil
+}
+
+func prepareAtomicFile(path string, data []byte) (*pendingAtomicFile, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(dir, ".courses-link-reports-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	pending := &pendingAtomicFile{path: path, tempPath: file.Name()}
+	ok := false
+	defer func() {
+		if !ok {
+			_ = file.Close()
+			pending.cleanup()
+		}
+	}()
+	if err := file.Chmod(0o600); err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(data); err != nil {
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	ok = true
+	return pending, nil
+}
+
+func (p *pendingAtomicFile) cleanup() {
+	if p != nil && p.tempPath != "" {
+		_ = os.Remove(p.tempPath)
+	}
+}
+
+func samePath(left, right string) bool {
+	leftPath := canonicalPath(left)
+	rightPath := canonicalPath(right)
+	if leftPath == rightPath {
+		return true
+	}
+	leftInfo, leftErr := os.Stat(leftPath)
+	rightInfo, rightErr := os.Stat(rightPath)
+	return leftErr == nil && rightErr == nil && os.SameFile(leftInfo, rightInfo)
+}
+
+func canonicalPath(path string) string {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if resolved, err := filepath.EvalSymlinks(absolute); err == nil {
+		return filepath.Clean(resolved)
+	}
+	parent := filepath.Dir(absolute)
+	if resolvedParent, err := filepath.EvalSymlinks(parent); err == nil {
+		return filepath.Join(resolvedParent, filepath.Base(absolute))
+	}
+	return filepath.Clean(absolute)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+const testCourseID = "course:0123456789abcdef0123456789abcdef"
+
+var testNow = time.Date(2026, 7, 27, 9, 0, 0, 0, time.UTC)
+
+func TestRunListShowsPendingReportsWithCatalogLinks(t *testing.T) {
+	dir := t.TempDir()
+	reportsPath := filepath.Join(dir, "reports.json")
+	catalogPath := filepath.Join(dir, "catalog.json.gz")
+	reported := "https://files.example.test/reported"
+	pending := writeReports(t, reportsPath, reported)
+	writeCatalog(t, catalogPath, []string{"https://files.example.test/other", reported})
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"list", "--reports", reportsPath, "--catalog", catalogPath}, testDependencies(&stdout))
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	output := stdout.String()
+	for _, want := range []string{pending.ID, testCourseID, "dead", "title=Private course", "url=" + reported, "pending=1"} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("list output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "/other") {
+		t.Fatalf("list output includes unreported link:\n%s", output)
+	}
+}
+
+func TestRunApproveAsTombstoneUpdatesTombstonesAndQueue(t *testing.T) {
+	dir := t.TempDir()
+	reportsPath := filepath.Join(dir, "reports.json")
+	tombstonesPath := filepath.Join(dir, "tombstones.json")
+	reported := "https://files.example.test/reported"
+	pending := writeReports(t, reportsPath, reported)
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{
+		"approve",
+		"--reports", reportsPath,
+		"--id", pending.ID,
+		"--as", "tombstone",
+		"--tombstones", tombstonesPath,
+	}, testDependencies(&stdout))
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if got := stdout.String(); got != "approved="+pending.ID+" resolution=tombstone added=1\n" {
+		t.Fatalf("stdout = %q", got)
+	}
+
+	tombstones := loadTestTombstones(t, tombstonesPath)
+	if !tombstones.ContainsURL(reported) {
+		t.Fatal("tombstones do not contain approved report link")
+	}
+	reports := loadTestReports(t, reportsPath)
+	approved, ok := reports.Get(pending.ID)
+	if !ok || approved.Status != courses.LinkReportStatusApproved || approved.ResolvedAt != "2026-07-27T09:00:00Z" {
+		t.Fatalf("approved report = %#v", approved)
+	}
+	assertMode0600(t, tombstonesPath)
+	assertMode0600(t, reportsPath)
+
+	err = run(context.Background(), []string{
+		"approve",
+		"--reports", reportsPath,
+		"--id", pending.ID,
+		"--as", "tombstone",
+		"--tombstones", tombstonesPath,
+	}, testDependencies(&stdout))
+	if !errors.Is(err, courses.ErrLinkReportResolved) {
+		t.Fatalf("second approve error = %v, want ErrLinkReportResolved", err)
+	}
+}
+
+func TestRunApproveAsSuppressionUsesCatalogSourceEntries(t *testing.T) {
+	dir := t.TempDir()
+	reportsPath := filepath.Join(dir, "reports.json")
+	catalogPath := filepath.Join(dir, "catalog.json.gz")
+	suppressionsPath := filepath.Join(dir, "suppressions.json")
+	reported := "https://files.example.test/reported"
+	pending := writeReports(t, reportsPath, reported)
+	writeCatalog(t, catalogPath, []string{reported})
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{
+		"approve",
+		"--reports", reportsPath,
+		"--id", pending.ID,
+		"--as", "suppression",
+		"--catalog", catalogPath,
+		"--suppressions", suppressionsPath,
+	}, testDependencies(&stdout))
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	file, err := os.Open(suppressionsPath)
+	if err != nil {
+		t.Fatalf("open suppressions: %v", err)
+	}
+	defer file.Close()
+	suppressions, err := courses.LoadLinkSuppressions(file)
+	if err != nil {
+		t.Fatalf("LoadLinkSuppressions() error = %v", err)
+	}
+	if !suppressions.ContainsURL("entry:a", reported) || !suppressions.ContainsURL("entry:b", reported) {
+		t.Fatal("suppressions do not cover every source entry of the reported course")
+	}
+}
+
+func TestRunDismissLeavesModerationOutputsUntouched(t *testing.T) {
+	dir := t.TempDir()
+	reportsPath := filepath.Join(dir, "reports.json")
+	pending := writeReports(t, reportsPath, "https://files.example.test/reported")
+
+	var stdout bytes.Buffer
+	if err := run(context.Background(), []string{"dismiss", "--reports", reportsPath, "--id", pending.ID}, testDependencies(&stdout)); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	dismissed, _ := loadTestReports(t, reportsPath).Get(pending.ID)
+	if dismissed.Status != courses.LinkReportStatusDismissed {
+		t.Fatalf("dismissed report = %#v", dismissed)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != filepath.Base(reportsPath) && name != filepath.Base(filelock.Path(reportsPath)) {
+			t.Fatalf("dir has %q, want only the reports file and its lock", name)
+		}
+	}
+}
+
+func TestParseArgsRejectsInvalidCombinations(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"missing command", nil},
+		{"unknown command", []string{"purge", "--reports", "r.json"}},
+		{"missing reports", []string{"list"}},
+		{"list with id", []string{"list", "--reports", "r.json", "--id", "0123456789abcdef"}},
+		{"dismiss without id", []string{"dismiss", "--reports", "r.json"}},
+		{"approve without resolution", []string{"approve", "--reports", "r.json", "--id", "x"}},
+		{"approve unknown resolution", []string{"approve", "--reports", "r.json", "--id", "x", "--as", "delete"}},
+		{"tombstone without output", []string{"approve", "--reports", "r.json", "--id", "x", "--as", "tombstone"}},
+		{"suppression without catalog", []string{"approve", "--reports", "r.json", "--id", "x", "--as", "suppression", "--suppressions", "s.json"}},
+		{"same paths", []string{"approve", "--reports", "r.json", "--id", "x", "--as", "tombstone", "--tombstones", "r.json"}},
+		{"positional", []string{"list", "--reports", "r.json", "extra"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseArgs(test.args); err == nil {
+				t.Fatal("parseArgs() error = nil, want error")
+			}
+		})
+	}
+}
+
+func testDependencies(stdout *bytes.Buffer) dependencies {
+	return dependencies{
+		now:    func() time.Time { return testNow },
+		stdout: stdout,
+	}
+}
+
+func writeReports(t *testing.T, path, rawURL string) courses.LinkReport {
+	t.Helper()
+	reports := courses.NewLinkReports()
+	report, err := reports.Submit(testCourseID, rawURL, courses.LinkReportReasonDead, testNow.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	data, err := json.Marshal(reports)
+	if err != nil {
+		t.Fatalf("marshal reports: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write reports: %v", err)
+	}
+	return report
+}
+
+func writeCatalog(t *testing.T, path string, links []string) {
+	t.Helper()
+	var payload bytes.Buffer
+	writer := gzip.NewWriter(&payload)
+	catalogLinks := make([]map[string]any, 0, len(links))
+	for _, rawURL := range links {
+		catalogLinks = append(catalogLinks, map[string]any{"url": rawURL})
+	}
+	if err := json.NewEncoder(writer).Encode(map[string]any{
+		"schema_version": "courses-catalog/v2",
+		"entries": []any{map[string]any{
+			"id":      testCourseID,
+			"title":   "Private course",
+			"links":   catalogLinks,
+			"sources": []any{map[string]any{"entry_id": "entry:a"}, map[string]any{"entry_id": "entry:b"}},
+		}},
+	}); err != nil {
+		t.Fatalf("encode catalog: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close catalog: %v", err)
+	}
+	if err := os.WriteFile(path, payload.Bytes(), 0o600); err != nil {
+		t.Fatalf("write catalog: %v", err)
+	}
+}
+
+func loadTestReports(t *testing.T, path string) *courses.LinkReports {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open reports: %v", err)
+	}
+	defer file.Close()
+	reports, err := courses.LoadLinkReports(file)
+	if err != nil {
+		t.Fatalf("LoadLinkReports() error = %v", err)
+	}
+	return reports
+}
+
+func loadTestTombstones(t *testing.T, path string) *courses.LinkTombstones {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open tombstones: %v", err)
+	}
+	defer file.Close()
+	tombstones, err := courses.LoadLinkTombstones(file)
+	if err != nil {
+		t.Fatalf("LoadLinkTombstones() error = %v", err)
+	}
+	return tombstones
+}
+
+func assertMode0600(t *testing.T, path string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
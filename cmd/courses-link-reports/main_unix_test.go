@@ -0,0 +1,57 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+func TestRunWaitsForConcurrentReportSubmission(t *testing.T) {
+	reportsPath := filepath.Join(t.TempDir(), "reports.json")
+	pending := writeReports(t, reportsPath, "https://files.example.test/reported")
+
+	// Play the server: hold the lock across load, submit, and rewrite while
+	// the CLI dismisses a report in the same file.
+	unlock, err := filelock.Lock(reportsPath)
+	if err != nil {
+		t.Fatalf("lock reports: %v", err)
+	}
+	reports := loadTestReports(t, reportsPath)
+	done := make(chan error, 1)
+	go func() {
+		var stdout bytes.Buffer
+		done <- run(context.Background(), []string{"dismiss", "--reports", reportsPath, "--id", pending.ID}, testDependencies(&stdout))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := reports.Submit(testCourseID, "https://files.example.test/other", courses.LinkReportReasonDead, testNow); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	data, err := json.Marshal(reports)
+	if err != nil {
+		t.Fatalf("marshal reports: %v", err)
+	}
+	if err := writeAtomicFile(context.Background(), reportsPath, data); err != nil {
+		t.Fatalf("write reports: %v", err)
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	reports = loadTestReports(t, reportsPath)
+	dismissed, _ := reports.Get(pending.ID)
+	if dismissed.Status != courses.LinkReportStatusDismissed {
+		t.Fatalf("dismissed report = %#v", dismissed)
+	}
+	if submitted := reports.Pending(); len(submitted) != 1 || !submitted[0].MatchesURL("https://files.example.test/other") {
+		t.Fatalf("pending reports = %#v", submitted)
+	}
+}
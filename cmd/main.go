@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/phuslu/log"
@@ -14,6 +18,12 @@ import (
 )
 
 func main() {
+	check := flag.Bool("check", false, "validate configuration and exit")
+	flag.Parse()
+	if *check {
+		os.Exit(runCheck())
+	}
+
 	ctx, cancel := appContext()
 	defer cancel()
 
@@ -43,6 +53,16 @@ func runMain(ctx context.Context) error {
 		log.Fatal().Err(err).Stack().Msg("init logger")
 	}
 
+	if err := server.Validate(cfg.Server); err != nil {
+		for _, problem := range strings.Split(err.Error(), "\n") {
+			log.Error().Msg(problem)
+		}
+		log.Fatal().Msg("invalid configuration")
+	}
+	if err := server.ValidateCatalog(cfg.Server); err != nil {
+		log.Warn().Err(err).Msg("courses catalog unavailable until a build publishes one")
+	}
+
 	ctx = meta.WithLogger(ctx, &log.DefaultLogger)
 
 	s := server.New(cfg.Server, &log.DefaultLogger)
@@ -51,6 +71,22 @@ func runMain(ctx context.Context) error {
 	return nil
 }
 
+// runCheck validates the configuration, prints every problem and returns the
+// process exit code.
+func runCheck() int {
+	cfg := &config.Config{}
+	if err := initConfig(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "load config:", err)
+		return 2
+	}
+	if err := errors.Join(server.Validate(cfg.Server), server.ValidateCatalog(cfg.Server)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("configuration OK")
+	return 0
+}
+
 func initConfig(cfg *config.Config) error {
 	const envPrefix = "APP"
 
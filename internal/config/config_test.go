@@ -1,11 +1,24 @@
 package config
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestCoursesEnvironmentNames(t *testing.T) {
 	t.Setenv("APP_SERVER_COURSES_CATALOG", "/app/data/catalog.json.gz")
 	t.Setenv("APP_SERVER_COURSES_PASSWORD_HASH", "base64-bcrypt")
 	t.Setenv("APP_SERVER_COURSES_PASSWORD_HASH_FILE", "/app/data/catalog-password.hash")
+	t.Setenv("APP_SERVER_COURSES_LINK_REPORTS_FILE", "/app/data/link-reports.json")
+	t.Setenv("APP_SERVER_CONTENT_SECURITY_POLICY_REPORT_ONLY", "true")
+	t.Setenv("APP_SERVER_TRUSTED_PROXIES", "10.0.0.0/8,192.0.2.1")
+	t.Setenv("APP_SERVER_PUBLIC_ORIGIN", "https://courses.example")
+	t.Setenv("APP_SERVER_ADMIN_TOKEN_HASH", "admin-bcrypt")
+	t.Setenv("APP_SERVER_TRACE_PARENT", "true")
+	t.Setenv("APP_SERVER_TRACE_FILE", "/app/data/spans.jsonl")
+	t.Setenv("APP_SERVER_JOBS_REBUILD_EVERY", "6h")
+	t.Setenv("APP_SERVER_JOBS_SOURCE_DIR", "/app/data/exports")
+	t.Setenv("APP_SERVER_ACCESS_RULES", "/large allow 10.0.0.0/8 @/etc/office.txt,/ deny @/etc/blocked.txt")
 
 	var cfg Config
 	if err := LoadEnv("APP", &cfg); err != nil {
@@ -21,4 +34,31 @@ func TestCoursesEnvironmentNames(t *testing.T) {
 	if got, want := cfg.Server.CoursesPasswordHashFile, "/app/data/catalog-password.hash"; got != want {
 		t.Fatalf("CoursesPasswordHashFile = %q, want %q", got, want)
 	}
+	if got, want := cfg.Server.CoursesLinkReportsFile, "/app/data/link-reports.json"; got != want {
+		t.Fatalf("CoursesLinkReportsFile = %q, want %q", got, want)
+	}
+	if !cfg.Server.ContentSecurityPolicyReportOnly {
+		t.Fatal("ContentSecurityPolicyReportOnly = false, want true")
+	}
+	if got := cfg.Server.TrustedProxies; len(got) != 2 || got[0] != "10.0.0.0/8" || got[1] != "192.0.2.1" {
+		t.Fatalf("TrustedProxies = %q", got)
+	}
+	if got, want := cfg.Server.PublicOrigin, "https://courses.example"; got != want {
+		t.Fatalf("PublicOrigin = %q, want %q", got, want)
+	}
+	if got := cfg.Server.AccessRules; len(got) != 2 || got[0] != "/large allow 10.0.0.0/8 @/etc/office.txt" || got[1] != "/ deny @/etc/blocked.txt" {
+		t.Fatalf("AccessRules = %q", got)
+	}
+	if got, want := cfg.Server.AdminTokenHash, "admin-bcrypt"; got != want {
+		t.Fatalf("AdminTokenHash = %q, want %q", got, want)
+	}
+	if !cfg.Server.TraceParent || cfg.Server.TraceFile != "/app/data/spans.jsonl" {
+		t.Fatalf("TraceParent = %t, TraceFile = %q", cfg.Server.TraceParent, cfg.Server.TraceFile)
+	}
+	if got, want := cfg.Server.Jobs.RebuildEvery, 6*time.Hour; got != want {
+		t.Fatalf("Jobs.RebuildEvery = %v, want %v", got, want)
+	}
+	if got, want := cfg.Server.Jobs.SourceDir, "/app/data/exports"; got != want {
+		t.Fatalf("Jobs.SourceDir = %q, want %q", got, want)
+	}
 }
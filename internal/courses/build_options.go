@@ -0,0 +1,81 @@
+package courses
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BuildFiles names the files and settings of a catalog build, as
+// courses-data and the scheduled rebuild configure it. An empty path leaves
+// that option unset.
+type BuildFiles struct {
+	TorrentDir       string
+	TitleRules       string
+	Taxonomy         string
+	FormatRules      string
+	LinkTombstones   string
+	LinkSuppressions string
+	LinkEnrichment   string
+	EntryOverrides   string
+
+	CrossChannelDedup   bool
+	ClassifierThreshold float64
+
+	// MissingLinkFilesEmpty loads link tombstones, suppressions and
+	// enrichment files that do not exist yet as empty. The scheduler sets it
+	// because its audit and enrich jobs create them on their first run.
+	MissingLinkFilesEmpty bool
+}
+
+// LoadBuildOptions loads every file in files into the options of a build.
+// It reports each file that does not load, not only the first.
+func LoadBuildOptions(files BuildFiles) (BuildOptions, error) {
+	options := BuildOptions{
+		TorrentDir:          files.TorrentDir,
+		CrossChannelDedup:   files.CrossChannelDedup,
+		ClassifierThreshold: files.ClassifierThreshold,
+	}
+	var problems []error
+	var err error
+	options.TitleRules, err = loadBuildFile("title rules", files.TitleRules, false, LoadTitleRules)
+	problems = append(problems, err)
+	options.Taxonomy, err = loadBuildFile("taxonomy", files.Taxonomy, false, LoadTaxonomy)
+	problems = append(problems, err)
+	options.FormatRules, err = loadBuildFile("format rules", files.FormatRules, false, LoadFormatRules)
+	problems = append(problems, err)
+	options.LinkTombstones, err = loadBuildFile("link tombstones", files.LinkTombstones, files.MissingLinkFilesEmpty, LoadLinkTombstones)
+	problems = append(problems, err)
+	options.LinkSuppressions, err = loadBuildFile("link suppressions", files.LinkSuppressions, files.MissingLinkFilesEmpty, LoadLinkSuppressions)
+	problems = append(problems, err)
+	options.LinkEnrichment, err = loadBuildFile("link enrichment", files.LinkEnrichment, files.MissingLinkFilesEmpty, LoadLinkEnrichmentCache)
+	problems = append(problems, err)
+	options.EntryOverrides, err = loadBuildFile("entry overrides", files.EntryOverrides, false, LoadEntryOverrides)
+	problems = append(problems, err)
+	if err := errors.Join(problems...); err != nil {
+		return BuildOptions{}, err
+	}
+	return options, nil
+}
+
+func loadBuildFile[T any](name, path string, missingEmpty bool, load func(io.Reader) (*T, error)) (*T, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if missingEmpty && errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load %s %q: %w", name, path, err)
+	}
+	defer file.Close()
+	value, err := load(file)
+	if err != nil {
+		return nil, fmt.Errorf("load %s %q: %w", name, path, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,53 @@
+package courses
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadBuildOptionsReportsEveryFileThatDoesNotLoad(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`{"schema_version":"unknown/v0"}`), 0o600); err != nil {
+		t.Fatalf("write malformed file: %v", err)
+	}
+
+	_, err := LoadBuildOptions(BuildFiles{Taxonomy: malformed, EntryOverrides: malformed})
+	if err == nil {
+		t.Fatal("LoadBuildOptions() error = nil")
+	}
+	for _, want := range []string{"load taxonomy", "load entry overrides", malformed} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("LoadBuildOptions() error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadBuildOptionsMissingLinkFiles(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	files := BuildFiles{
+		TorrentDir:          "torrents",
+		LinkTombstones:      missing,
+		LinkSuppressions:    missing,
+		LinkEnrichment:      missing,
+		CrossChannelDedup:   true,
+		ClassifierThreshold: 0.85,
+	}
+	if _, err := LoadBuildOptions(files); err == nil || !strings.Contains(err.Error(), "load link tombstones") {
+		t.Fatalf("LoadBuildOptions() error = %v, want missing link tombstones", err)
+	}
+
+	files.MissingLinkFilesEmpty = true
+	options, err := LoadBuildOptions(files)
+	if err != nil {
+		t.Fatalf("LoadBuildOptions() error = %v", err)
+	}
+	if options.LinkTombstones != nil || options.LinkSuppressions != nil || options.LinkEnrichment != nil {
+		t.Fatalf("link options = %+v, want empty", options)
+	}
+	if options.TorrentDir != "torrents" || !options.CrossChannelDedup || options.ClassifierThreshold != 0.85 {
+		t.Fatalf("options = %+v, want settings carried over", options)
+	}
+}
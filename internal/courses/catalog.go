@@ -1,6 +1,7 @@
 package courses
 
 import (
+	"bytes"
 	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
@@ -11,6 +12,7 @@ import (
 	"net/url"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -38,19 +40,45 @@ type sourceExport struct {
 	} `json:"stats"`
 	Messages       []sourceMessage `json:"messages"`
 	CatalogEntries []sourceEntry   `json:"catalog_entries"`
+
+	// channels lists the channels of a multi-channel merge, primary first,
+	// and entryChannels holds the channels index of every catalog entry.
+	// Both are nil for a single channel.
+	channels      []catalogSource
+	entryChannels []int
+	// digest is the SHA-256 of the input the export was decoded from, so
+	// a source state can tell which inputs it already holds.
+	digest string
 }
 
 type SourceInput struct {
 	Reader io.Reader
 	Name   string
+	// Format is empty for Telegram exports, or SourceFormatCSV or
+	// SourceFormatMarkdown for curated lists.
+	Format string
+	// Channel names the synthetic channel a curated list belongs to; lists
+	// sharing it dedup as one channel. It defaults to Name.
+	Channel string
 }
 
 type BuildOptions struct {
 	TorrentDir       string
 	TitleRules       *TitleRules
+	Taxonomy         *Taxonomy
+	FormatRules      *FormatRules
 	LinkTombstones   *LinkTombstones
 	LinkSuppressions *LinkSuppressions
 	LinkEnrichment   *LinkEnrichmentCache
+	EntryOverrides   *EntryOverrides
+
+	// CrossChannelDedup merges a course posted in several channels into one
+	// entry. By default every channel keeps its own entries.
+	CrossChannelDedup bool
+	// ClassifierThreshold enables the naive Bayes fallback for entries only
+	// classified as "other": it assigns the category whose posterior reaches
+	// the threshold. Zero disables it.
+	ClassifierThreshold float64
 }
 
 type catalogSource struct {
@@ -114,10 +142,22 @@ type Catalog struct {
 	SourceSchema  string             `json:"source_schema"`
 	ExportedAt    string             `json:"exported_at"`
 	Source        catalogSource      `json:"source"`
+	Sources       []catalogSource    `json:"sources"`
 	Stats         CatalogStats       `json:"stats"`
 	Categories    []CategoryMetadata `json:"categories"`
 	Formats       []FormatMetadata   `json:"formats"`
+	Channels      []ChannelMetadata  `json:"channels"`
 	Entries       []CatalogEntry     `json:"entries"`
+
+	// BuildFingerprint identifies the settings that shaped the entries, so
+	// an incremental build knows whether they can be reused.
+	BuildFingerprint string `json:"build_fingerprint,omitempty"`
+	// StaleOverrides lists the keys of entry overrides that matched no entry.
+	StaleOverrides []string `json:"stale_overrides,omitempty"`
+
+	// authorTopics holds the author-topic propagation applied by the build,
+	// keyed by course ID, for classification explanations.
+	authorTopics map[string]AuthorTopicPropagation
 }
 
 type CatalogStats struct {
@@ -134,6 +174,8 @@ type CatalogStats struct {
 	EnrichedLinks              int `json:"enriched_links"`
 	Passwords                  int `json:"passwords"`
 	NormalizedTitles           int `json:"normalized_titles"`
+	OverriddenEntries          int `json:"overridden_entries"`
+	StaleOverrides             int `json:"stale_overrides"`
 }
 
 type CategoryMetadata struct {
@@ -149,6 +191,12 @@ type FormatMetadata struct {
 	Count int    `json:"count"`
 }
 
+type ChannelMetadata struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
 type CatalogEntry struct {
 	ID              string          `json:"id"`
 	Title           string          `json:"title"`
@@ -169,6 +217,18 @@ type CatalogEntry struct {
 	Passwords       []string        `json:"passwords"`
 	Notes           []string        `json:"notes"`
 	Sources         []CatalogSource `json:"sources"`
+	Channels        []string        `json:"channels"`
+
+	// CategorySource tells how Categories were assigned: "keywords",
+	// "author_topic", "classifier", or "fallback" when only "other" applies.
+	// CategoryConfidence is the classifier's posterior for its category.
+	CategorySource     string  `json:"category_source"`
+	CategoryConfidence float64 `json:"category_confidence,omitempty"`
+
+	// Hidden entries stay in the catalog but out of search results.
+	// Overrides names the fields curator overrides replaced.
+	Hidden    bool     `json:"hidden,omitempty"`
+	Overrides []string `json:"overrides,omitempty"`
 }
 
 type CatalogSource struct {
@@ -180,6 +240,7 @@ type CatalogSource struct {
 	AddedAt           string   `json:"added_at"`
 	Origin            string   `json:"origin"`
 	Availability      string   `json:"availability"`
+	Channel           string   `json:"channel"`
 }
 
 type CatalogLink struct {
@@ -198,11 +259,13 @@ type categoryDefinition struct {
 	Label    string
 	Hidden   bool
 	Keywords []string
+	Blocked  []blockedCategoryRule
 }
 
 type formatDefinition struct {
 	ID    string
 	Label string
+	Rules []formatRule
 }
 
 var (
@@ -213,156 +276,6 @@ var (
 	spacePattern        = regexp.MustCompile(`\s+`)
 	danglingYearPattern = regexp.MustCompile(`\s+\((\d{4})$`)
 	shortDatePattern    = regexp.MustCompile(`^\d{1,2}[./]\d{1,2}(?:[./]\d{2,4})?$`)
-	categoryDefinitions = []categoryDefinition{
-		{
-			ID: "development", Label: "Разработка",
-			Keywords: []string{
-				"python", "javascript", "typescript", "java", "react", "vue", "node", "php", "go",
-				"golang", "rust", "c++", "c#", ".net", "swift", "kotlin", "android", "ios",
-				"flutter", "frontend", "backend", "fullstack", "html", "css", "sql", "mysql",
-				"postgresql", "api", "разработ*", "программир*", "верстк*", "битрикс", "bitrix",
-				"1c", "1с", "asp net", "backend разработ*", "веб разработ*", "programming",
-				"django", "laravel", "nestjs", "nextjs", "next js", "wordpress", "leetcode",
-				"алгоритм*", "структуры данных", "front end", "front-end", "veb verstka", "veb-verstka",
-			},
-		},
-		{
-			ID: "devops_security_qa", Label: "DevOps, безопасность, QA",
-			Keywords: []string{
-				"devops", "linux", "docker", "kubernetes", "k8s", "ci cd", "gitlab ci",
-				"qa", "тестирован*", "автотест*", "selenium", "playwright", "кибербезопас*",
-				"информационная безопасность", "пентест", "pentest", "хакинг", "security",
-				"nginx", "ansible", "terraform", "sre", "bug bounty", "взлом*", "хакер*",
-				"анонимност*", "darknet", "cisco", "тестировщик*", "системный администратор",
-				"социальная инженерия", "devtools", "i2p", "freenet",
-			},
-		},
-		{
-			ID: "data_ai", Label: "Данные и AI",
-			Keywords: []string{
-				"data science", "data scientist", "data analyst", "аналитик данных", "анализ данных",
-				"machine learning", "машинное обучение", "нейросет*", "нейронк*", "chatgpt", "gpt",
-				"llm", "ai", "искусственный интеллект", "prompt", "midjourney", "stable diffusion",
-				"power bi", "tableau", "excel аналитик", "эксель аналитик", "аналитик bi",
-				"pandas", "python для анализа", "дашборд*", "big data", "sql аналитик",
-				"excel", "эксель", "power query", "power pivot", "аналитика", "статистик*", "анализ данных",
-			},
-		},
-		{
-			ID: "nocode_automation", Label: "No-code и автоматизация",
-			Keywords: []string{
-				"no code", "no-code", "nocode", "ноукод", "airtable", "make.com", "zapier",
-				"integromat", "автоматизац*", "чат бот*", "чатбот*", "ботостроен*", "tilda",
-				"notion", "glide", "bubble", "webflow",
-			},
-		},
-		{
-			ID: "design_ui_graphic", Label: "Дизайн, UI, графика",
-			Keywords: []string{
-				"дизайн*", "designer", "ux", "ui", "figma", "photoshop", "illustrator",
-				"graphic design", "графическ*", "иллюстрац*", "логотип", "брендинг",
-				"типограф*", "лендинг", "web design", "визуал*", "sketch",
-				"дизайнер", "дизайнера", "дизайнеров", "интерфейс*", "ui ux", "adobe xd",
-			},
-		},
-		{
-			ID: "three_d_motion_vfx", Label: "3D, motion, VFX",
-			Keywords: []string{
-				"3d", "blender", "cinema 4d", "maya", "zbrush", "houdini", "motion",
-				"motion design", "vfx", "after effects", "анимац*", "моушн", "моделирован*",
-				"визуализац*", "рендер", "cgi", "hard surface", "toon boom",
-				"revit", "autocad", "архвиз*", "архитектурная визуализац*", "проектирован*",
-			},
-		},
-		{
-			ID: "photo_video", Label: "Фото и видео",
-			Keywords: []string{
-				"фотограф*", "фотосъемк*", "фото съемк*", "предметная съемк*", "ретуш*",
-				"lightroom", "capture one", "premiere", "davinci", "видеосъемк*", "видео съемк*",
-				"монтаж ролик*", "видеомонтаж", "постобработ*", "операторск*", "цветокоррекц*",
-				"видеопродакшн", "видеоконтент", "reels съемк*", "youtube монтаж", "монтаж",
-				"фильммейкинг", "снимать видео", "кино на коленке",
-			},
-		},
-		{
-			ID: "game_dev_design", Label: "GameDev и геймдизайн",
-			Keywords: []string{
-				"game dev", "gamedev", "game design", "геймдизайн", "разработка игр",
-				"игровой дизайн", "unity", "unreal engine", "godot", "level design",
-				"геймплей", "игровая механик*",
-			},
-		},
-		{
-			ID: "marketing_ads_smm", Label: "Маркетинг, реклама, SMM",
-			Keywords: []string{
-				"маркет*", "smm", "таргет*", "трафик", "seo", "контекст*", "директ",
-				"google ads", "facebook ads", "instagram", "reels", "tiktok", "youtube",
-				"продвиж*", "копирайт*", "контент", "воронк*", "лиды", "арбитраж",
-				"бренд", "блог", "инфобизнес", "реклам*", "targetolog", "marketolog",
-				"email маркетинг", "контент маркетинг", "яндекс директ", "performance marketing",
-			},
-		},
-		{
-			ID: "income_online", Label: "Заработок и онлайн-бизнес",
-			Keywords: []string{
-				"заработок", "заработать", "зарабатывать", "доход онлайн", "онлайн доход",
-				"пассивный доход", "удаленный доход", "монетизац*", "онлайн бизнес",
-				"онлайн-бизнес", "интернет бизнес", "заработок в интернете", "доход в интернете",
-				"зарабатывай*", "заработка", "дополнительный доход", "источник дохода", "миллион",
-			},
-		},
-		{
-			ID: "ecommerce_marketplaces", Label: "E-commerce и маркетплейсы",
-			Keywords: []string{
-				"ecommerce", "e-commerce", "интернет магазин", "маркетплейс*", "wildberries",
-				"ozon", "яндекс маркет", "авито", "селлер", "карточки товаров",
-				"товарный бизнес", "продажи на маркетплейсах", "shopify", "интернет-магазин",
-				"amazon fba", "карточка товара", "таобао", "taobao", "marketplace", "marketpleys",
-				"marketpleysami", "alipay", "байер", "fiverr", "товарка", "wechat",
-			},
-		},
-		{ID: "business_management", Label: "Бизнес и управление", Keywords: []string{"бизнес", "предпринимат*", "стартап", "менеджмент", "управлен*", "руководител*", "команд*", "product manager", "project manager", "scrum", "agile", "стратег*", "операцион*", "юнит экономик*", "финмодел*", "producer", "продюсер*"}},
-		{ID: "sales_service", Label: "Продажи и клиентский сервис", Keywords: []string{"продаж*", "sales", "переговор*", "crm", "клиентский сервис", "клиент*", "аккаунт менедж*", "скрипты продаж", "продающ*", "сервис"}},
-		{ID: "finance_crypto", Label: "Финансы, инвестиции, крипта", Keywords: []string{"инвестиц*", "инвестирование", "трейдинг", "трейдер*", "trading", "бирж*", "акци*", "облигац*", "крипт*", "crypto", "bitcoin", "nft", "defi", "binance", "финанс*", "налог*", "бухгалтер*", "капитал", "портфел*", "forex", "теханализ", "экономик*", "бюджет", "деньги", "кредит*", "страхован*", "учет финансов", "торговая система", "дивиденд*"}},
-		{ID: "psychology_selfdev", Label: "Психология и саморазвитие", Keywords: []string{"психолог*", "психотерап*", "самооценк*", "эмоци*", "тревог*", "стресс", "депрес*", "мышление", "осознан*", "медитац*", "мотивац*", "привычк*", "саморазвит*", "коуч*", "личностный рост", "харизм*", "выгоран*", "границы", "терапия", "терапи*", "травм*", "гештальт*", "расстановк*", "метафорическ*", "созависим*", "уверенность", "emdr", "самогипноз", "социофоб*", "гипнотерап*", "схематерап*", "спиральная динамика"}},
-		{ID: "relationships_family", Label: "Отношения и семья", Keywords: []string{"отношен*", "семейные отношен*", "семейный конфликт*", "пара", "партнер*", "знакомств*", "секс", "любовь", "брак", "развод", "женственност*", "мужчина", "мужчины", "женщина", "женщины", "девушка", "оргазм*", "либидо", "конфликты в паре"}},
-		{ID: "health_fitness_nutrition", Label: "Здоровье, фитнес, питание", Keywords: []string{"здоров*", "питан*", "диет*", "нутрици*", "фитнес", "трениров*", "йога", "массаж", "похуд*", "сон", "гормон*", "медицин*", "остеопат*", "реабилитац*", "беремен*", "роды", "фармаколог*", "метаболизм", "пилатес", "осанка", "биохакинг", "анатоми*", "тестостерон", "витамин*", "биомеханик*", "иммунитет", "позвоноч*", "воркаут", "биохими*", "дофамин", "подтягиван*", "мышц*"}},
-		{ID: "beauty_style", Label: "Красота и стиль", Keywords: []string{"косметолог*", "макияж", "визаж", "маникюр", "ногт*", "лицо", "кожа", "волос*", "ресниц*", "makeup", "косметик*", "стиль", "стилист*", "гардероб", "имидж", "бров*", "парикмахер*", "уход за кожей", "плетение кос", "наращивание"}},
-		{ID: "education_languages_school", Label: "Образование, языки, школа", Keywords: []string{"английск*", "english", "немецк*", "испанск*", "японск*", "французск*", "китайск*", "чешск*", "иностранный язык", "егэ", "огэ", "школ*", "учител*", "педагог*", "репетитор*", "математик*", "matematika", "физик*", "истори*", "литератур*", "образован*", "методист", "ielts", "toefl", "университет", "обучение детей", "русский язык", "grammar", "грамматик*", "american accent"}},
-		{ID: "parenting_children", Label: "Дети и родительство", Keywords: []string{"дети", "детей", "ребенок", "ребенка", "детск*", "родител*", "родительство", "мама", "мамы", "дошкольник*", "подрост*", "воспитан*", "материнств*", "детская психология", "развитие ребенка", "алалия"}},
-		{
-			ID: "law_realty", Label: "Право, недвижимость",
-			Keywords: []string{
-				"юрист*", "юридическ*", "право", "закон*", "договор*", "суд", "ип", "ооо",
-				"самозанят*", "недвижим*", "риелтор*", "ипотек*", "аренд*", "госзакуп*", "имуществ*",
-				"должник*", "строительств*", "планировка",
-			},
-		},
-		{ID: "career_hr_freelance", Label: "Карьера, HR, фриланс", Keywords: []string{"карьер*", "резюме", "собеседован*", "работа", "фриланс", "удален*", "професси*", "стажиров*", "hr", "рекрут*", "linkedin", "портфолио", "soft skills", "профориентац*"}},
-		{ID: "esoteric_astrology", Label: "Эзотерика и астрология", Keywords: []string{"астрол*", "астро", "таро", "нумеролог*", "эзотер*", "руны", "чакр*", "магия", "ритуал*", "регресс*", "карм*", "транзит*", "хорар*", "матрица судьбы", "матрица денег", "карта желаний", "ведическ*", "рейки", "натальная карта", "дизайн человека"}},
-		{ID: "communication_public_speaking", Label: "Коммуникация и публичные выступления", Keywords: []string{"публичные выступ*", "выступлен*", "оратор*", "коммуникац*", "презентац*", "голос в переговорах", "речь", "сторителлинг", "storytelling", "нетворкинг", "переговоры", "язык тела", "невербальн*", "юмор", "шутить"}},
-		{ID: "music_audio_voice", Label: "Музыка, аудио, голос", Keywords: []string{"музык*", "вокал", "петь", "гитар*", "fingerstyle", "битмейк*", "ableton", "fl studio", "сведен*", "мастеринг", "звук", "фортепиан*", "электроник*"}},
-		{ID: "art_writing_hobbies", Label: "Искусство, письмо, хобби", Keywords: []string{"писател*", "сценари*", "режиссер*", "искусств*", "живопис*", "акварел*", "рисован*", "рисунок", "карандаш", "портрет", "аниме", "скетч*", "танц*", "шахмат*", "хобби", "каллиграф*", "иллюстрация", "проза", "поэзия"}},
-		{ID: "home_cooking_craft", Label: "Дом, кулинария, рукоделие", Keywords: []string{"кулинар*", "готовк*", "рецепт*", "шить*", "пошив", "вязан*", "рукодел*", "интерьер*", "ремонт", "дом", "декор", "кондитер*", "выпечк*", "торт", "торты", "пирог*", "хлеб", "мыловар*", "мыло", "кофейня", "шеф повар", "вязание", "садовод*", "электромонтаж"}},
-		{ID: "other", Label: "Другое"},
-		{
-			ID: "service", Label: "Служебное", Hidden: true,
-			Keywords: []string{"инструкция как качать", "инструкция как скачать", "как скачать по magnet"},
-		},
-	}
-	formatDefinitions = []formatDefinition{
-		{ID: "course", Label: "Курс"},
-		{ID: "workshop", Label: "Воркшоп / практикум"},
-		{ID: "live_recording", Label: "Запись эфира"},
-		{ID: "marathon", Label: "Марафон / челлендж"},
-		{ID: "book_guide", Label: "Книга / гайд"},
-		{ID: "templates_assets", Label: "Шаблоны / ассеты"},
-		{ID: "bundle_library", Label: "Бандл / библиотека"},
-		{ID: "club_membership", Label: "Клуб / подписка"},
-		{ID: "audio", Label: "Аудио"},
-		{ID: "torrent_attachment", Label: "Torrent-вложение"},
-		{ID: "unspecified", Label: "Тип не указан"},
-	}
 )
 
 func BuildGzip(input io.Reader, output io.Writer) (CatalogStats, error) {
@@ -378,8 +291,20 @@ func BuildGzipFromSources(inputs []SourceInput, output io.Writer, torrentDir str
 }
 
 func BuildGzipFromSourcesWithOptions(inputs []SourceInput, output io.Writer, options BuildOptions) (CatalogStats, error) {
+	sources, err := decodeSourceInputs(inputs)
+	if err != nil {
+		return CatalogStats{}, err
+	}
+	source, err := mergeSourceExports(sources)
+	if err != nil {
+		return CatalogStats{}, err
+	}
+	return buildGzipFromSource(source, output, options)
+}
+
+func decodeSourceInputs(inputs []SourceInput) ([]sourceExport, error) {
 	if len(inputs) == 0 {
-		return CatalogStats{}, errors.New("no source exports provided")
+		return nil, errors.New("no source exports provided")
 	}
 	sources := make([]sourceExport, 0, len(inputs))
 	for index, input := range inputs {
@@ -388,24 +313,48 @@ func BuildGzipFromSourcesWithOptions(inputs []SourceInput, output io.Writer, opt
 			name = fmt.Sprintf("input %d", index+1)
 		}
 		if input.Reader == nil {
-			return CatalogStats{}, fmt.Errorf("%s: source reader is nil", name)
+			return nil, fmt.Errorf("%s: source reader is nil", name)
+		}
+		channel := strings.TrimSpace(input.Channel)
+		if channel == "" {
+			channel = name
+		}
+		hash := sha256.New()
+		reader := io.TeeReader(input.Reader, hash)
+		var source sourceExport
+		var err error
+		switch input.Format {
+		case "":
+			source, err = decodeSourceExport(reader)
+		case SourceFormatCSV:
+			source, err = decodeCSVSource(reader, channel)
+		case SourceFormatMarkdown:
+			source, err = decodeMarkdownSource(reader, channel)
+		default:
+			err = fmt.Errorf("unsupported source format %q", input.Format)
+		}
+		if err == nil {
+			_, err = io.Copy(io.Discard, reader)
 		}
-		source, err := decodeSourceExport(input.Reader)
 		if err != nil {
-			return CatalogStats{}, fmt.Errorf("%s: %w", name, err)
+			return nil, fmt.Errorf("%s: %w", name, err)
 		}
+		source.digest = hex.EncodeToString(hash.Sum(nil))
 		sources = append(sources, source)
 	}
-	source, err := mergeSourceExports(sources)
-	if err != nil {
-		return CatalogStats{}, err
-	}
-	return buildGzipFromSource(source, output, options)
+	return sources, nil
 }
 
 func decodeSourceExport(input io.Reader) (sourceExport, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return sourceExport{}, fmt.Errorf("read source export: %w", err)
+	}
+	if isDesktopExport(data) {
+		return decodeDesktopExport(data)
+	}
 	var source sourceExport
-	decoder := json.NewDecoder(input)
+	decoder := json.NewDecoder(bytes.NewReader(data))
 	if err := decoder.Decode(&source); err != nil {
 		return sourceExport{}, fmt.Errorf("decode source export: %w", err)
 	}
@@ -422,33 +371,56 @@ func decodeSourceExport(input io.Reader) (sourceExport, error) {
 }
 
 func buildGzipFromSource(source sourceExport, output io.Writer, options BuildOptions) (CatalogStats, error) {
+	catalog, err := buildCatalog(source, options, nil)
+	if err != nil {
+		return CatalogStats{}, err
+	}
+	data, err := encodeCatalog(catalog)
+	if err != nil {
+		return CatalogStats{}, err
+	}
+	if err := writeCatalogGzip(output, data); err != nil {
+		return CatalogStats{}, err
+	}
+	return catalog.Stats, nil
+}
+
+// buildCatalog runs the build pipeline over one merged export. When reuse
+// is set, clusters it vouches for are copied from the previous catalog
+// instead of being normalized, classified and merged again.
+func buildCatalog(source sourceExport, options BuildOptions, reuse *catalogReuse) (Catalog, error) {
 	messages := make(map[string]sourceMessage, len(source.Messages))
 	for _, message := range source.Messages {
 		if strings.TrimSpace(message.MessageID) == "" {
-			return CatalogStats{}, errors.New("source message has no stable identity")
+			return Catalog{}, errors.New("source message has no stable identity")
 		}
 		if _, exists := messages[message.MessageID]; exists {
-			return CatalogStats{}, fmt.Errorf("duplicate source message %q", message.MessageID)
+			return Catalog{}, fmt.Errorf("duplicate source message %q", message.MessageID)
 		}
 		messages[message.MessageID] = message
 	}
 	torrentLinks, err := buildTorrentLinks(options.TorrentDir, source.Messages)
 	if err != nil {
-		return CatalogStats{}, err
+		return Catalog{}, err
 	}
 
+	taxonomy := options.taxonomy()
+	categoryOrder := taxonomy.categoryIDs()
+	formatRules := options.formatRules()
+	formatOrder := formatRules.formatIDs()
+	scopes := identityScopes(source, options)
 	clusters := newDisjointSet(len(source.CatalogEntries))
 	identityOwners := make(map[string]int, len(source.CatalogEntries))
 	titleLinkOwners := make(map[string]int, len(source.CatalogEntries))
 	for index, entry := range source.CatalogEntries {
-		identityKey := courseIdentityKey(source.Source.ChannelID, entry)
+		identityKey := courseIdentityKey(scopes[index], entry)
 		if owner, exists := identityOwners[identityKey]; exists {
 			clusters.union(index, owner)
 		} else {
 			identityOwners[identityKey] = index
 		}
 
-		titleKey := normalizeSearchText(cleanCourseTitle(entry.Title))
+		titleKey := fmt.Sprintf("%d\x1f%s", scopes[index], normalizeSearchText(cleanCourseTitle(entry.Title)))
 		for _, link := range entry.Links {
 			if options.LinkTombstones.ContainsURL(link.URL) {
 				continue
@@ -469,15 +441,15 @@ func buildGzipFromSource(source sourceExport, output io.Writer, options BuildOpt
 		}
 	}
 	for index, entry := range source.CatalogEntries {
-		for _, identityKey := range repairedCourseIdentityKeys(source.Source.ChannelID, entry) {
+		for _, identityKey := range repairedCourseIdentityKeys(scopes[index], entry) {
 			if owner, exists := identityOwners[identityKey]; exists {
 				clusters.union(index, owner)
 			}
 		}
 	}
-	unionSharedURLTitleVariants(source.CatalogEntries, clusters, options.LinkTombstones, options.LinkSuppressions)
+	unionSharedURLTitleVariants(scopes, source.CatalogEntries, clusters, options.LinkTombstones, options.LinkSuppressions)
 	unionPostNormalizationTitleVariants(
-		source.Source.ChannelID,
+		scopes,
 		source.CatalogEntries,
 		clusters,
 		options.TitleRules,
@@ -496,15 +468,17 @@ func buildGzipFromSource(source sourceExport, output io.Writer, options BuildOpt
 	}
 	canonicalIdentityKeys := make(map[int]string, len(canonicalIndexes))
 	for root, index := range canonicalIndexes {
-		canonicalIdentityKeys[root] = courseIdentityKey(source.Source.ChannelID, source.CatalogEntries[index])
+		canonicalIdentityKeys[root] = courseIdentityKey(scopes[index], source.CatalogEntries[index])
 	}
 
 	catalog := Catalog{
-		SchemaVersion: catalogSchema,
-		SourceSchema:  source.SchemaVersion,
-		ExportedAt:    source.ExportedAt,
-		Source:        source.Source,
-		Entries:       make([]CatalogEntry, 0, len(source.CatalogEntries)),
+		SchemaVersion:    catalogSchema,
+		SourceSchema:     source.SchemaVersion,
+		ExportedAt:       source.ExportedAt,
+		Source:           source.Source,
+		Sources:          sourceChannels(source),
+		BuildFingerprint: buildFingerprint(options),
+		Entries:          make([]CatalogEntry, 0, len(source.CatalogEntries)),
 		Stats: CatalogStats{
 			Messages:        len(source.Messages),
 			SourceEntries:   len(source.CatalogEntries),
@@ -512,28 +486,25 @@ func buildGzipFromSource(source sourceExport, output io.Writer, options BuildOpt
 			SourcePasswords: source.Stats.Parsing.PasswordValueCount,
 		},
 	}
-	entryIndexes := make(map[int]int, len(source.CatalogEntries))
-	entryKeysByID := make(map[string]string, len(source.CatalogEntries))
 	sourceEntryIDs := make(map[string]struct{}, len(source.CatalogEntries))
+	entryLinks := make([][]CatalogLink, len(source.CatalogEntries))
 
 	for index, entry := range source.CatalogEntries {
 		if strings.TrimSpace(entry.EntryID) == "" || strings.TrimSpace(entry.MessageID) == "" {
-			return CatalogStats{}, fmt.Errorf("entry %d has no stable identity", index)
+			return Catalog{}, fmt.Errorf("entry %d has no stable identity", index)
 		}
 		if _, exists := sourceEntryIDs[entry.EntryID]; exists {
-			return CatalogStats{}, fmt.Errorf("duplicate source entry %q", entry.EntryID)
+			return Catalog{}, fmt.Errorf("duplicate source entry %q", entry.EntryID)
 		}
 		sourceEntryIDs[entry.EntryID] = struct{}{}
 		if strings.TrimSpace(entry.Title) == "" {
-			return CatalogStats{}, fmt.Errorf("entry %q has no title", entry.EntryID)
+			return Catalog{}, fmt.Errorf("entry %q has no title", entry.EntryID)
 		}
 		if _, err := time.Parse(time.RFC3339Nano, entry.AddedAt); err != nil {
-			return CatalogStats{}, fmt.Errorf("entry %q has invalid added_at %q: %w", entry.EntryID, entry.AddedAt, err)
+			return Catalog{}, fmt.Errorf("entry %q has invalid added_at %q: %w", entry.EntryID, entry.AddedAt, err)
 		}
-
-		message, ok := messages[entry.MessageID]
-		if !ok {
-			return CatalogStats{}, fmt.Errorf("entry %q references missing message %q", entry.EntryID, entry.MessageID)
+		if _, ok := messages[entry.MessageID]; !ok {
+			return Catalog{}, fmt.Errorf("entry %q references missing message %q", entry.EntryID, entry.MessageID)
 		}
 
 		links := make([]CatalogLink, 0, len(entry.Links))
@@ -566,38 +537,43 @@ func buildGzipFromSource(source sourceExport, output io.Writer, options BuildOpt
 				links = mergeLinks(links, []CatalogLink{torrentLink})
 			}
 		}
+		entryLinks[index] = links
+	}
 
+	var reusable map[int]CatalogEntry
+	if reuse != nil {
+		reusable = reuse.reusableEntries(source, clusters, canonicalIndexes, canonicalIdentityKeys, entryLinks)
+	}
+	entryIndexes := make(map[int]int, len(source.CatalogEntries))
+	entryKeysByID := make(map[string]string, len(source.CatalogEntries))
+
+	for index, entry := range source.CatalogEntries {
 		clusterRoot := clusters.find(index)
 		identityKey := canonicalIdentityKeys[clusterRoot]
 		courseID := courseID(identityKey)
 		if existingKey, exists := entryKeysByID[courseID]; exists && existingKey != identityKey {
-			return CatalogStats{}, fmt.Errorf("course identity collision for %q", courseID)
+			return Catalog{}, fmt.Errorf("course identity collision for %q", courseID)
 		}
 		entryKeysByID[courseID] = identityKey
 
-		displayEntry, structurallyNormalized := repairLegacyDateRangeHeading(entry)
-		sourceTitle := cleanCourseTitle(displayEntry.Title)
-		sourceTitle, strippedTitleURL := stripExactExtractedTitleURL(sourceTitle, displayEntry.Links)
-		displayTitle := sourceTitle
-		normalized := false
-		if options.TitleRules != nil {
-			displayTitle, normalized = newTitleNormalizer(options.TitleRules).Normalize(sourceTitle)
-		}
-		var titleOriginal *string
-		if structurallyNormalized || strippedTitleURL {
-			original := cleanCourseTitle(entry.Title)
-			titleOriginal = &original
-		} else if normalized {
-			titleOriginal = &sourceTitle
-		}
-		classificationEntry := displayEntry
-		classificationEntry.Title = displayTitle
-		categories := classify(classificationEntry)
-		formats, formatSource := classifyFormats(classificationEntry, message)
+		if previous, ok := reusable[clusterRoot]; ok {
+			if _, exists := entryIndexes[clusterRoot]; !exists {
+				entryIndexes[clusterRoot] = len(catalog.Entries)
+				catalog.Entries = append(catalog.Entries, previous)
+				reuse.reused++
+			}
+			continue
+		}
+
+		message := messages[entry.MessageID]
+		displayEntry, titleOriginal := displaySourceEntry(entry, options.TitleRules)
+		categories := taxonomy.classify(displayEntry)
+		formats, formatSources := formatRules.classify(displayEntry, message)
+		channel := channelKey(entryChannel(source, index))
 
 		candidate := CatalogEntry{
 			ID:              courseID,
-			Title:           displayTitle,
+			Title:           displayEntry.Title,
 			TitleOriginal:   titleOriginal,
 			Author:          cleanOptionalString(displayEntry.Credit.Author),
 			Year:            entry.Year,
@@ -610,8 +586,8 @@ func buildGzipFromSource(source sourceExport, output io.Writer, options BuildOpt
 			PrimaryCategory: categories[0],
 			Formats:         formats,
 			PrimaryFormat:   formats[0],
-			FormatSources:   []string{formatSource},
-			Links:           links,
+			FormatSources:   formatSources,
+			Links:           entryLinks[index],
 			Passwords:       uniqueStrings(entry.Passwords),
 			Notes:           noteValues(entry.Notes),
 			Sources: []CatalogSource{{
@@ -623,16 +599,19 @@ func buildGzipFromSource(source sourceExport, output io.Writer, options BuildOpt
 				AddedAt:           entry.AddedAt,
 				Origin:            entry.Origin,
 				Availability:      entry.Availability,
+				Channel:           channel,
 			}},
+			Channels:       []string{channel},
+			CategorySource: keywordCategorySource(categories),
 		}
 
 		if existingIndex, exists := entryIndexes[clusterRoot]; exists {
 			if index == canonicalIndexes[clusterRoot] {
 				existing := catalog.Entries[existingIndex]
-				mergeCatalogEntry(&candidate, existing)
+				mergeCatalogEntry(&candidate, existing, categoryOrder, formatOrder)
 				catalog.Entries[existingIndex] = candidate
 			} else {
-				mergeCatalogEntry(&catalog.Entries[existingIndex], candidate)
+				mergeCatalogEntry(&catalog.Entries[existingIndex], candidate, categoryOrder, formatOrder)
 			}
 			continue
 		}
@@ -641,35 +620,80 @@ func buildGzipFromSource(source sourceExport, output io.Writer, options BuildOpt
 	}
 
 	catalog.Stats.EntriesWithoutLinksRemoved = removeEntriesWithoutLinks(&catalog.Entries)
-	propagateAuthorTopics(catalog.Entries)
-	recomputeCatalogStatsAndFacets(&catalog)
+	catalog.StaleOverrides, err = options.EntryOverrides.apply(catalog.Entries, taxonomy, formatRules)
+	if err != nil {
+		return Catalog{}, err
+	}
+	catalog.Stats.StaleOverrides = len(catalog.StaleOverrides)
+	resetClassifiedEntries(catalog.Entries)
+	catalog.authorTopics = propagateAuthorTopics(catalog.Entries, categoryOrder)
+	if options.ClassifierThreshold > 0 {
+		classifyOtherEntries(catalog.Entries, categoryOrder, options.ClassifierThreshold)
+	}
+	recomputeCatalogStatsAndFacets(&catalog, taxonomy, formatRules)
+	return catalog, nil
+}
+
+// displaySourceEntry returns the entry as the catalog shows and classifies
+// it, with its display title, and the original title when that differs.
+func displaySourceEntry(entry sourceEntry, titleRules *TitleRules) (sourceEntry, *string) {
+	displayEntry, structurallyNormalized := repairLegacyDateRangeHeading(entry)
+	sourceTitle := cleanCourseTitle(displayEntry.Title)
+	sourceTitle, strippedTitleURL := stripExactExtractedTitleURL(sourceTitle, displayEntry.Links)
+	displayTitle := sourceTitle
+	normalized := false
+	if titleRules != nil {
+		displayTitle, normalized = newTitleNormalizer(titleRules).Normalize(sourceTitle)
+	}
+	var titleOriginal *string
+	if structurallyNormalized || strippedTitleURL {
+		original := cleanCourseTitle(entry.Title)
+		titleOriginal = &original
+	} else if normalized {
+		titleOriginal = &sourceTitle
+	}
+	displayEntry.Title = displayTitle
+	return displayEntry, titleOriginal
+}
+
+func encodeCatalog(catalog Catalog) ([]byte, error) {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(catalog); err != nil {
+		return nil, fmt.Errorf("encode catalog: %w", err)
+	}
+	return data.Bytes(), nil
+}
 
+func writeCatalogGzip(output io.Writer, data []byte) error {
 	gzipWriter, err := gzip.NewWriterLevel(output, gzip.BestCompression)
 	if err != nil {
-		return CatalogStats{}, fmt.Errorf("create gzip writer: %w", err)
+		return fmt.Errorf("create gzip writer: %w", err)
 	}
-	encoder := json.NewEncoder(gzipWriter)
-	encoder.SetEscapeHTML(false)
-	if err := encoder.Encode(catalog); err != nil {
+	if _, err := gzipWriter.Write(data); err != nil {
 		_ = gzipWriter.Close()
-		return CatalogStats{}, fmt.Errorf("encode catalog: %w", err)
+		return fmt.Errorf("write gzip catalog: %w", err)
 	}
 	if err := gzipWriter.Close(); err != nil {
-		return CatalogStats{}, fmt.Errorf("close gzip catalog: %w", err)
+		return fmt.Errorf("close gzip catalog: %w", err)
 	}
-	return catalog.Stats, nil
+	return nil
 }
 
-func recomputeCatalogStatsAndFacets(catalog *Catalog) {
+func recomputeCatalogStatsAndFacets(catalog *Catalog, taxonomy *Taxonomy, formatRules *FormatRules) {
 	catalog.Stats.Entries = len(catalog.Entries)
 	catalog.Stats.Links = 0
 	catalog.Stats.EnrichedLinks = 0
 	catalog.Stats.Passwords = 0
 	catalog.Stats.NormalizedTitles = 0
+	catalog.Stats.OverriddenEntries = 0
 	catalog.Categories = catalog.Categories[:0]
 	catalog.Formats = catalog.Formats[:0]
-	categoryCounts := make(map[string]int, len(categoryDefinitions))
-	formatCounts := make(map[string]int, len(formatDefinitions))
+	catalog.Channels = catalog.Channels[:0]
+	categoryCounts := make(map[string]int, len(taxonomy.categories))
+	formatCounts := make(map[string]int, len(formatRules.formats))
+	channelCounts := make(map[string]int, len(catalog.Sources))
 	for _, entry := range catalog.Entries {
 		catalog.Stats.Links += len(entry.Links)
 		for _, link := range entry.Links {
@@ -681,14 +705,20 @@ func recomputeCatalogStatsAndFacets(catalog *Catalog) {
 		if entry.TitleOriginal != nil {
 			catalog.Stats.NormalizedTitles++
 		}
+		if len(entry.Overrides) != 0 {
+			catalog.Stats.OverriddenEntries++
+		}
 		for _, category := range entry.Categories {
 			categoryCounts[category]++
 		}
 		for _, format := range entry.Formats {
 			formatCounts[format]++
 		}
+		for _, channel := range entry.Channels {
+			channelCounts[channel]++
+		}
 	}
-	for _, definition := range categoryDefinitions {
+	for _, definition := range taxonomy.categories {
 		catalog.Categories = append(catalog.Categories, CategoryMetadata{
 			ID:     definition.ID,
 			Label:  definition.Label,
@@ -696,13 +726,21 @@ func recomputeCatalogStatsAndFacets(catalog *Catalog) {
 			Hidden: definition.Hidden,
 		})
 	}
-	for _, definition := range formatDefinitions {
+	for _, definition := range formatRules.formats {
 		catalog.Formats = append(catalog.Formats, FormatMetadata{
 			ID:    definition.ID,
 			Label: definition.Label,
 			Count: formatCounts[definition.ID],
 		})
 	}
+	for _, channel := range catalog.Sources {
+		id := channelKey(channel)
+		catalog.Channels = append(catalog.Channels, ChannelMetadata{
+			ID:    id,
+			Label: channelLabel(channel),
+			Count: channelCounts[id],
+		})
+	}
 }
 
 func removeEntriesWithoutLinks(entries *[]CatalogEntry) int {
@@ -720,7 +758,79 @@ func removeEntriesWithoutLinks(entries *[]CatalogEntry) int {
 	return removed
 }
 
+// sourceChannel holds the exports of one channel. Later exports join it
+// when they match key, the source of its first export.
+type sourceChannel struct {
+	key     catalogSource
+	exports []sourceExport
+}
+
+// groupSourceChannels appends every export to the channel it belongs to,
+// opening a new channel for the first export of each.
+func groupSourceChannels(channels []sourceChannel, sources []sourceExport) []sourceChannel {
+	for _, source := range sources {
+		index := slices.IndexFunc(channels, func(channel sourceChannel) bool {
+			return sameSourceChannel(channel.key, source.Source)
+		})
+		if index < 0 {
+			channels = append(channels, sourceChannel{key: source.Source})
+			index = len(channels) - 1
+		}
+		channels[index].exports = append(channels[index].exports, source)
+	}
+	return channels
+}
+
+// mergeSourceExports merges the exports of every channel separately and then
+// concatenates the channels, the first one seen being the primary channel.
 func mergeSourceExports(sources []sourceExport) (sourceExport, error) {
+	return mergeSourceChannels(groupSourceChannels(nil, sources))
+}
+
+// mergeSourceChannels is mergeSourceExports for grouped exports. It leaves
+// every channel holding only its merged export.
+func mergeSourceChannels(channels []sourceChannel) (sourceExport, error) {
+	for index := range channels {
+		channels[index].exports = []sourceExport{mergeChannelExports(channels[index].exports)}
+	}
+	if len(channels) == 1 {
+		return channels[0].exports[0], nil
+	}
+
+	var merged sourceExport
+	messageChannels := make(map[string]int)
+	for channelIndex := range channels {
+		channel := channels[channelIndex].exports[0]
+		for _, message := range channel.Messages {
+			if owner, exists := messageChannels[message.MessageID]; exists {
+				return sourceExport{}, fmt.Errorf(
+					"source message %q appears in channels %s and %s",
+					message.MessageID, channelKey(merged.channels[owner]), channelKey(channel.Source),
+				)
+			}
+			messageChannels[message.MessageID] = channelIndex
+		}
+		if channelIndex == 0 {
+			merged = channel
+			merged.Messages = slices.Clone(channel.Messages)
+			merged.CatalogEntries = slices.Clone(channel.CatalogEntries)
+		} else {
+			if timestampBefore(merged.ExportedAt, channel.ExportedAt) {
+				merged.ExportedAt = channel.ExportedAt
+			}
+			merged.Messages = append(merged.Messages, channel.Messages...)
+			merged.CatalogEntries = append(merged.CatalogEntries, channel.CatalogEntries...)
+		}
+		merged.channels = append(merged.channels, channel.Source)
+		for range channel.CatalogEntries {
+			merged.entryChannels = append(merged.entryChannels, channelIndex)
+		}
+	}
+	recomputeSourceStats(&merged)
+	return merged, nil
+}
+
+func mergeChannelExports(sources []sourceExport) sourceExport {
 	merged := sources[0]
 	messageIndexes := make(map[string]int, len(merged.Messages))
 	entryIndexes := make(map[string]int, len(merged.CatalogEntries))
@@ -732,9 +842,6 @@ func mergeSourceExports(sources []sourceExport) (sourceExport, error) {
 	}
 
 	for _, source := range sources[1:] {
-		if err := validateSameSourceIdentity(merged.Source, source.Source); err != nil {
-			return sourceExport{}, err
-		}
 		mergeSourceMetadata(&merged, source)
 		for _, message := range source.Messages {
 			if existingIndex, exists := messageIndexes[message.MessageID]; exists {
@@ -754,19 +861,69 @@ func mergeSourceExports(sources []sourceExport) (sourceExport, error) {
 		}
 	}
 	recomputeSourceStats(&merged)
-	return merged, nil
+	return merged
 }
 
-func validateSameSourceIdentity(left, right catalogSource) error {
-	if left.ChannelID != 0 && right.ChannelID != 0 && left.ChannelID != right.ChannelID {
-		return fmt.Errorf("conflicting source channel IDs %d and %d", left.ChannelID, right.ChannelID)
+// sameSourceChannel reports whether two exports come from one channel. An
+// export that names neither a channel ID nor a web URL joins any channel.
+func sameSourceChannel(left, right catalogSource) bool {
+	if left.ChannelID != 0 && right.ChannelID != 0 {
+		return left.ChannelID == right.ChannelID
 	}
-	if left.ChannelID == 0 && right.ChannelID == 0 &&
-		strings.TrimSpace(left.WebURL) != "" && strings.TrimSpace(right.WebURL) != "" &&
-		strings.TrimSpace(left.WebURL) != strings.TrimSpace(right.WebURL) {
-		return fmt.Errorf("conflicting source web URLs %q and %q", left.WebURL, right.WebURL)
+	leftURL := strings.TrimSpace(left.WebURL)
+	rightURL := strings.TrimSpace(right.WebURL)
+	return leftURL == "" || rightURL == "" || leftURL == rightURL
+}
+
+func sourceChannels(source sourceExport) []catalogSource {
+	if len(source.channels) == 0 {
+		return []catalogSource{source.Source}
 	}
-	return nil
+	return source.channels
+}
+
+func entryChannel(source sourceExport, index int) catalogSource {
+	if len(source.entryChannels) == 0 {
+		return source.Source
+	}
+	return source.channels[source.entryChannels[index]]
+}
+
+// channelKey identifies a channel in entries and facets: its Telegram ID,
+// or its web URL when it has none.
+func channelKey(channel catalogSource) string {
+	if webURL := strings.TrimSpace(channel.WebURL); channel.ChannelID == 0 && webURL != "" {
+		return webURL
+	}
+	return strconv.FormatInt(channel.ChannelID, 10)
+}
+
+func channelLabel(channel catalogSource) string {
+	if title := strings.TrimSpace(channel.Title); title != "" {
+		return title
+	}
+	return channelKey(channel)
+}
+
+// identityScopes returns the channel ID each entry's identity keys are
+// scoped to. Entries of the primary channel, and every entry when
+// cross-channel dedup is on, use the primary channel ID, so single-channel
+// course IDs do not depend on the option.
+func identityScopes(source sourceExport, options BuildOptions) []int64 {
+	scopes := make([]int64, len(source.CatalogEntries))
+	for index := range scopes {
+		scopes[index] = source.Source.ChannelID
+		if options.CrossChannelDedup || len(source.entryChannels) == 0 || source.entryChannels[index] == 0 {
+			continue
+		}
+		channel := source.channels[source.entryChannels[index]]
+		if channel.ChannelID != 0 {
+			scopes[index] = channel.ChannelID
+		} else {
+			scopes[index] = syntheticChannelID(channel.WebURL)
+		}
+	}
+	return scopes
 }
 
 func mergeSourceMetadata(target *sourceExport, source sourceExport) {
@@ -1303,6 +1460,7 @@ func malformedAuthorTitle(title string) (string, string, bool) {
 }
 
 func unionSharedURLTitleVariants(
+	scopes []int64,
 	entries []sourceEntry,
 	clusters *disjointSet,
 	tombstones *LinkTombstones,
@@ -1321,6 +1479,7 @@ func unionSharedURLTitleVariants(
 			if err != nil {
 				continue
 			}
+			key = fmt.Sprintf("%d\x1f%s", scopes[index], key)
 			ownersByURL[key] = append(ownersByURL[key], index)
 		}
 	}
@@ -1338,7 +1497,7 @@ func unionSharedURLTitleVariants(
 }
 
 func unionPostNormalizationTitleVariants(
-	channelID int64,
+	scopes []int64,
 	entries []sourceEntry,
 	clusters *disjointSet,
 	rules *TitleRules,
@@ -1360,7 +1519,7 @@ func unionPostNormalizationTitleVariants(
 			author = normalizeSearchText(*displayEntry.Credit.Author)
 		}
 		identityKey := courseIdentityKeyFromParts(
-			channelID,
+			scopes[index],
 			displayTitle,
 			author,
 			entry.Year,
@@ -1437,7 +1596,7 @@ func uniqueStrings(values []string) []string {
 	return result
 }
 
-func mergeCatalogEntry(target *CatalogEntry, source CatalogEntry) {
+func mergeCatalogEntry(target *CatalogEntry, source CatalogEntry, categoryOrder, formatOrder []string) {
 	if timestampBefore(source.FirstAddedAt, target.FirstAddedAt) {
 		target.FirstAddedAt = source.FirstAddedAt
 	}
@@ -1446,15 +1605,17 @@ func mergeCatalogEntry(target *CatalogEntry, source CatalogEntry) {
 	}
 	target.Origins = appendUnique(target.Origins, source.Origins)
 	target.Availability = appendUnique(target.Availability, source.Availability)
-	target.Categories = orderedUnion(target.Categories, source.Categories, categoryIDs())
+	target.Categories = orderedUnion(target.Categories, source.Categories, categoryOrder)
 	target.PrimaryCategory = target.Categories[0]
-	target.Formats = orderedUnion(target.Formats, source.Formats, formatIDs())
+	target.CategorySource = keywordCategorySource(target.Categories)
+	target.Formats = orderedUnion(target.Formats, source.Formats, formatOrder)
 	target.PrimaryFormat = target.Formats[0]
 	target.FormatSources = appendUnique(target.FormatSources, source.FormatSources)
 	target.Links = mergeLinks(target.Links, source.Links)
 	target.Passwords = appendUnique(target.Passwords, source.Passwords)
 	target.Notes = appendUnique(target.Notes, source.Notes)
 	target.Sources = append(target.Sources, source.Sources...)
+	target.Channels = appendUnique(target.Channels, source.Channels)
 }
 
 func timestampBefore(left, right string) bool {
@@ -1500,22 +1661,6 @@ func orderedUnion(left, right, order []string) []string {
 	return result
 }
 
-func categoryIDs() []string {
-	ids := make([]string, 0, len(categoryDefinitions))
-	for _, definition := range categoryDefinitions {
-		ids = append(ids, definition.ID)
-	}
-	return ids
-}
-
-func formatIDs() []string {
-	ids := make([]string, 0, len(formatDefinitions))
-	for _, definition := range formatDefinitions {
-		ids = append(ids, definition.ID)
-	}
-	return ids
-}
-
 func mergeLinks(target, values []CatalogLink) []CatalogLink {
 	indexes := make(map[string]int, len(target)+len(values))
 	for index, link := range target {
@@ -1613,7 +1758,10 @@ func catalogLinkMergeKey(link CatalogLink) string {
 	return key
 }
 
-func propagateAuthorTopics(entries []CatalogEntry) {
+// propagateAuthorTopics moves an author's "other" entries into the topic
+// most of their classified entries share, and returns what it applied keyed
+// by course ID.
+func propagateAuthorTopics(entries []CatalogEntry, order []string) map[string]AuthorTopicPropagation {
 	type authorStats struct {
 		otherIndexes []int
 		classified   int
@@ -1630,7 +1778,7 @@ func propagateAuthorTopics(entries []CatalogEntry) {
 			stats = &authorStats{topics: make(map[string]int)}
 			statsByAuthor[author] = stats
 		}
-		if slices.Equal(entry.Categories, []string{"other"}) {
+		if slices.Equal(entry.Categories, []string{"other"}) && entry.CategorySource != categorySourceOverride {
 			stats.otherIndexes = append(stats.otherIndexes, index)
 			continue
 		}
@@ -1642,8 +1790,8 @@ func propagateAuthorTopics(entries []CatalogEntry) {
 		stats.topics[topic]++
 	}
 
-	order := categoryIDs()
-	for _, stats := range statsByAuthor {
+	applied := make(map[string]AuthorTopicPropagation)
+	for author, stats := range statsByAuthor {
 		if len(stats.otherIndexes) == 0 || stats.classified < 2 {
 			continue
 		}
@@ -1654,8 +1802,16 @@ func propagateAuthorTopics(entries []CatalogEntry) {
 		for _, index := range stats.otherIndexes {
 			entries[index].Categories = []string{topic}
 			entries[index].PrimaryCategory = topic
+			entries[index].CategorySource = categorySourceAuthorTopic
+			applied[entries[index].ID] = AuthorTopicPropagation{
+				Author:     author,
+				Topic:      topic,
+				TopicCount: count,
+				Classified: stats.classified,
+			}
 		}
 	}
+	return applied
 }
 
 func normalizedEntryAuthor(entry CatalogEntry) string {
@@ -1687,237 +1843,6 @@ func dominantAuthorTopic(counts map[string]int, order []string) (string, int) {
 	return bestTopic, bestCount
 }
 
-func classify(entry sourceEntry) []string {
-	author := ""
-	if entry.Credit.Author != nil {
-		author = *entry.Credit.Author
-	}
-	text := normalizeSearchText(strings.Join([]string{entry.Title, author, entry.RawBlock}, " "))
-	tokens := strings.Fields(text)
-	if matchesCategory(text, categoryDefinitions[len(categoryDefinitions)-1].Keywords) {
-		return []string{"service"}
-	}
-
-	var categories []string
-	for _, definition := range categoryDefinitions {
-		if definition.ID == "other" || definition.ID == "service" {
-			continue
-		}
-		if matchesCategory(text, definition.Keywords) && !blockedCategoryMatch(definition.ID, text, tokens) {
-			categories = append(categories, definition.ID)
-		}
-	}
-	if len(categories) == 0 {
-		return []string{"other"}
-	}
-	return categories
-}
-
-func blockedCategoryMatch(categoryID, text string, tokens []string) bool {
-	switch categoryID {
-	case "photo_video":
-		if containsTokenSequence(tokens, "монтаж", "электропроводки") ||
-			containsTokenSequence(tokens, "монтаж", "электрики") {
-			return true
-		}
-		return containsAnyToken(tokens, "видеокурс", "видеокурса", "видеокурсы", "видеокурсов") &&
-			!matchesCategory(text, []string{"фотограф*", "фотосъемк*", "видеосъемк*", "монтаж ролик*", "видеомонтаж", "ретуш*", "postproduction"})
-	case "education_languages_school":
-		return containsTokenSequence(tokens, "язык", "тела")
-	case "music_audio_voice":
-		return containsTokenSequence(tokens, "тон", "голоса")
-	case "three_d_motion_vfx":
-		return containsTokenSequence(tokens, "3d", "secure")
-	case "relationships_family":
-		return containsTokenSequence(tokens, "семейный", "бюджет") || containsTokenSequence(tokens, "семейные", "финансы")
-	case "esoteric_astrology":
-		return containsTokenSequence(tokens, "матрица", "управления", "проектом") ||
-			containsTokenSequence(tokens, "матрица", "управления", "проектами")
-	default:
-		return false
-	}
-}
-
-func classifyFormats(entry sourceEntry, message sourceMessage) ([]string, string) {
-	text := normalizeSearchText(entry.Title)
-	tokens := strings.Fields(text)
-	formats := make([]string, 0, 2)
-
-	if matchesCourseFormat(tokens) {
-		formats = append(formats, "course")
-	}
-	if matchesWorkshopFormat(tokens) {
-		formats = append(formats, "workshop")
-	}
-	if matchesLiveRecordingFormat(tokens) {
-		formats = append(formats, "live_recording")
-	}
-	if matchesMarathonFormat(tokens) {
-		formats = append(formats, "marathon")
-	}
-	if matchesBookFormat(tokens) {
-		formats = append(formats, "book_guide")
-	}
-	if matchesTemplatesAssetsFormat(tokens) {
-		formats = append(formats, "templates_assets")
-	}
-	if matchesBundleLibraryFormat(tokens) {
-		formats = append(formats, "bundle_library")
-	}
-	if matchesClubMembershipFormat(tokens) {
-		formats = append(formats, "club_membership")
-	}
-	if matchesAudioFormat(tokens) {
-		formats = append(formats, "audio")
-	}
-	if message.Media.Type == "messageMediaDocument" &&
-		strings.EqualFold(message.Media.Document.MIMEType, "application/x-bittorrent") {
-		formats = append(formats, "torrent_attachment")
-	}
-
-	if len(formats) == 0 {
-		return []string{"unspecified"}, "fallback"
-	}
-	if formats[0] == "torrent_attachment" {
-		return formats, "availability"
-	}
-	return formats, "title"
-}
-
-func matchesAudioFormat(tokens []string) bool {
-	if containsAnyToken(tokens,
-		"аудиокурс", "аудиокурсы", "аудиокурса", "аудиокурсов",
-		"аудиокнига", "аудиокниги", "аудиокнигу", "аудиокнигой", "аудиокниг",
-		"аудиоурок", "аудиоуроки", "аудиоуроков",
-		"аудиолекция", "аудиолекции", "аудиолекций",
-		"аудиотренинг", "аудиотренинги", "аудиотренингов",
-		"аудиопрограмма", "аудиопрограммы", "аудиопрограмм",
-		"audiocourse", "audiocourses", "audiobook", "audiobooks",
-	) {
-		return true
-	}
-	for index, token := range tokens {
-		if token != "аудио" && token != "audio" {
-			continue
-		}
-		if index+1 < len(tokens) && containsAnyToken(tokens[index+1:index+2],
-			"курс", "курсы", "книга", "книги", "урок", "уроки", "лекция", "лекции",
-			"тренинг", "программа", "course", "courses", "book", "books", "lesson",
-			"lessons", "lecture", "lectures", "training", "assembly",
-		) {
-			return true
-		}
-	}
-	return false
-}
-
-func matchesBookFormat(tokens []string) bool {
-	if containsAnyToken(tokens,
-		"книга", "книги", "книгу", "книгой", "книге",
-		"book", "books", "ebook", "ebooks", "workbook", "workbooks",
-		"гайд", "гайда", "гайды", "гайдов", "guide", "guides",
-		"чеклист", "чеклиста", "чеклисты", "чеклистов",
-		"шпаргалка", "шпаргалки", "шпаргалку", "шпаргалок",
-		"методичка", "методички", "методичку", "методичек",
-		"пособие", "пособия", "пособию", "пособий",
-		"учебник", "учебника", "учебники", "учебников",
-		"самоучитель", "самоучителя", "самоучители", "самоучителей",
-		"мануал", "мануала", "мануалы", "мануалов", "manual", "manuals",
-	) {
-		return true
-	}
-	if containsTokenSequence(tokens, "чек", "лист") || containsTokenSequence(tokens, "work", "book") {
-		return true
-	}
-	if containsAnyToken(tokens, "книг") && containsAnyToken(tokens,
-		"все", "сборник", "сборники", "библиотека", "библиотеки", "коллекция",
-		"коллекции", "комплект", "комплекты", "список", "списки",
-	) {
-		return true
-	}
-	return false
-}
-
-func matchesLiveRecordingFormat(tokens []string) bool {
-	return containsAnyToken(tokens,
-		"вебинар", "вебинара", "вебинары", "вебинаров", "вебинаре",
-		"webinar", "webinars", "мастеркласс", "мастерклассы",
-		"masterclass", "masterclasses",
-		"семинар", "семинара", "семинары", "семинаров", "семинаре",
-		"seminar", "seminars",
-		"лекция", "лекции", "лекцию", "лекций", "lecture", "lectures",
-	) || containsTokenSequence(tokens, "мастер", "класс") ||
-		containsTokenSequence(tokens, "master", "class")
-}
-
-func matchesWorkshopFormat(tokens []string) bool {
-	hasWorkshop := containsAnyToken(tokens,
-		"интенсив", "интенсива", "интенсивы", "интенсивов", "интенсиве",
-		"интенсивный", "интенсивная", "интенсивное", "интенсивные",
-		"intensive", "intensives",
-		"воркшоп", "воркшопа", "воркшопы", "воркшопов", "workshop", "workshops",
-		"буткемп", "буткемпа", "буткемпы", "буткемпов", "bootcamp", "bootcamps",
-	)
-	if hasWorkshop {
-		return true
-	}
-	hasPracticum := containsAnyToken(tokens,
-		"практикум", "практикума", "практикумы", "практикумов", "практикуме",
-	)
-	return hasPracticum && !containsTokenSequence(tokens, "яндекс", "практикум")
-}
-
-func matchesMarathonFormat(tokens []string) bool {
-	return containsAnyToken(tokens,
-		"марафон", "марафона", "марафоны", "марафонов", "марафоне",
-		"marathon", "marathons",
-		"челлендж", "челленджа", "челленджи", "челленджей",
-		"challenge", "challenges",
-	)
-}
-
-func matchesCourseFormat(tokens []string) bool {
-	return containsAnyToken(tokens,
-		"курс", "курса", "курсы", "курсу", "курсом", "курсе", "курсов",
-		"курсами", "курсах", "course", "courses",
-		"видеокурс", "видеокурса", "видеокурсы", "видеокурсов",
-		"онлайнкурс", "онлайнкурса", "онлайнкурсы", "онлайнкурсов",
-		"профессия", "профессии", "профессию", "профессией", "профессий",
-		"обучение", "обучения", "обучению", "обучением", "обучении",
-		"тренинг", "тренинга", "тренинги", "тренингов", "тренинге",
-		"training", "trainings",
-	)
-}
-
-func matchesTemplatesAssetsFormat(tokens []string) bool {
-	return containsAnyToken(tokens,
-		"материал", "материала", "материалы", "материалу", "материалом",
-		"материале", "материалов", "материалами", "материалах",
-		"шаблон", "шаблона", "шаблоны", "шаблону", "шаблоном", "шаблоне",
-		"шаблонов", "шаблонами", "шаблонах", "template", "templates",
-		"пресет", "пресета", "пресеты", "пресетов", "пресетами", "preset", "presets",
-		"исходник", "исходника", "исходники", "исходников", "исходниками",
-		"asset", "assets",
-		"плагин", "плагина", "плагины", "плагинов", "плагинами", "plugin", "plugins",
-	)
-}
-
-func matchesBundleLibraryFormat(tokens []string) bool {
-	return containsAnyToken(tokens,
-		"бандл", "бандла", "бандлы", "бандлов", "bundle", "bundles",
-		"библиотека", "библиотеки", "library", "libraries",
-		"коллекция", "коллекции", "collection", "collections",
-		"пакет", "пакеты", "pack", "packs",
-	)
-}
-
-func matchesClubMembershipFormat(tokens []string) bool {
-	return containsAnyToken(tokens,
-		"клуб", "клуба", "клубы", "club", "membership", "подписка", "подписки",
-		"сообщество", "сообщества", "community",
-	)
-}
-
 func containsAnyToken(tokens []string, values ...string) bool {
 	return slices.ContainsFunc(tokens, func(token string) bool {
 		return slices.Contains(values, token)
@@ -1945,40 +1870,32 @@ func normalizeSearchText(value string) string {
 
 func matchesCategory(text string, keywords []string) bool {
 	tokens := strings.Fields(text)
-	for _, keyword := range keywords {
-		prefix := strings.HasSuffix(keyword, "*")
-		keyword = strings.TrimSuffix(keyword, "*")
-		keyword = normalizeSearchText(keyword)
-		if keyword == "" {
-			continue
-		}
-		if prefix {
-			if slices.ContainsFunc(tokens, func(token string) bool {
-				return strings.HasPrefix(token, keyword)
-			}) {
-				return true
-			}
-			continue
-		}
-		if len([]rune(keyword)) <= 4 && !strings.Contains(keyword, " ") {
-			if slices.Contains(tokens, keyword) {
-				return true
-			}
-			continue
-		}
-		if !strings.Contains(keyword, " ") {
-			if slices.ContainsFunc(tokens, func(token string) bool {
-				return strings.HasPrefix(token, keyword)
-			}) {
-				return true
-			}
-			continue
-		}
-		if strings.Contains(" "+text+" ", " "+keyword+" ") {
-			return true
-		}
+	return slices.ContainsFunc(keywords, func(keyword string) bool {
+		return matchesCategoryKeyword(text, tokens, keyword)
+	})
+}
+
+func matchesCategoryKeyword(text string, tokens []string, keyword string) bool {
+	prefix := strings.HasSuffix(keyword, "*")
+	keyword = strings.TrimSuffix(keyword, "*")
+	keyword = normalizeSearchText(keyword)
+	if keyword == "" {
+		return false
 	}
-	return false
+	if prefix {
+		return slices.ContainsFunc(tokens, func(token string) bool {
+			return strings.HasPrefix(token, keyword)
+		})
+	}
+	if len([]rune(keyword)) <= 4 && !strings.Contains(keyword, " ") {
+		return slices.Contains(tokens, keyword)
+	}
+	if !strings.Contains(keyword, " ") {
+		return slices.ContainsFunc(tokens, func(token string) bool {
+			return strings.HasPrefix(token, keyword)
+		})
+	}
+	return strings.Contains(" "+text+" ", " "+keyword+" ")
 }
 
 func validateSourceCounts(source sourceExport) error {
@@ -0,0 +1,89 @@
+package courses
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestBuildGzipKeepsChannelsApartByDefault(t *testing.T) {
+	first, second := channelTestExports(t)
+
+	var output bytes.Buffer
+	stats, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, first)}, {Reader: sourceReader(t, second)}},
+		&output,
+		BuildOptions{},
+	)
+	if err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	if stats.Messages != 2 || stats.SourceEntries != 2 || stats.Entries != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	catalog := decodeBuiltCatalog(t, &output)
+	if catalog.Source.ChannelID != 1 || len(catalog.Sources) != 2 || catalog.Sources[1].Title != "Second Export" {
+		t.Fatalf("sources = %+v, %+v", catalog.Source, catalog.Sources)
+	}
+	if !slices.Equal(catalog.Channels, []ChannelMetadata{
+		{ID: "1", Label: "Course Export", Count: 1},
+		{ID: "2", Label: "Second Export", Count: 1},
+	}) {
+		t.Fatalf("channels = %+v", catalog.Channels)
+	}
+	if catalog.ExportedAt != second.ExportedAt {
+		t.Fatalf("exported_at = %q, want the newest channel export", catalog.ExportedAt)
+	}
+
+	var single bytes.Buffer
+	if _, err := BuildGzip(sourceReader(t, first), &single); err != nil {
+		t.Fatalf("build single channel catalog: %v", err)
+	}
+	if primary := decodeBuiltCatalog(t, &single).Entries[0]; catalog.Entries[0].ID != primary.ID {
+		t.Fatalf("primary channel course ID = %q, want %q", catalog.Entries[0].ID, primary.ID)
+	}
+	for _, entry := range catalog.Entries {
+		if len(entry.Channels) != 1 || entry.Sources[0].Channel != entry.Channels[0] {
+			t.Fatalf("entry channels = %+v, sources = %+v", entry.Channels, entry.Sources)
+		}
+	}
+}
+
+func TestBuildGzipMergesChannelsWithCrossChannelDedup(t *testing.T) {
+	first, second := channelTestExports(t)
+
+	var output bytes.Buffer
+	stats, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, first)}, {Reader: sourceReader(t, second)}},
+		&output,
+		BuildOptions{CrossChannelDedup: true},
+	)
+	if err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	if stats.Entries != 1 {
+		t.Fatalf("stats = %+v, want the shared course merged", stats)
+	}
+	entry := decodeBuiltCatalog(t, &output).Entries[0]
+	if !slices.Equal(entry.Channels, []string{"1", "2"}) || len(entry.Sources) != 2 ||
+		entry.Sources[1].Channel != "2" || entry.Sources[1].MessageURL != "https://messages.example.test/second/1" {
+		t.Fatalf("entry = %+v", entry)
+	}
+}
+
+func channelTestExports(t *testing.T) (sourceExport, sourceExport) {
+	t.Helper()
+
+	first := validSource(t, validSourceEntry())
+	second := validSource(t, validSourceEntry())
+	second.ExportedAt = "2026-08-01T00:00:00Z"
+	second.Source = catalogSource{ChannelID: 2, Title: "Second Export", WebURL: "https://second.example.test"}
+	second.Messages = []sourceMessage{
+		{MessageID: "2:1", TelegramMessageID: 1, URL: "https://messages.example.test/second/1"},
+	}
+	second.CatalogEntries[0].EntryID = "2:1:0"
+	second.CatalogEntries[0].MessageID = "2:1"
+	second.CatalogEntries[0].SourceMessageIDs = []string{"2:1"}
+	setSourceCounts(&second)
+	return first, second
+}
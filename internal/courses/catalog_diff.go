@@ -0,0 +1,203 @@
+package courses
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Facets compared by DiffCatalogs, in report order.
+const (
+	CatalogFacetCategory = "category"
+	CatalogFacetFormat   = "format"
+	CatalogFacetChannel  = "channel"
+)
+
+type CatalogDiff struct {
+	OldEntries int                  `json:"old_entries"`
+	NewEntries int                  `json:"new_entries"`
+	Added      []CatalogDiffEntry   `json:"added"`
+	Removed    []CatalogDiffEntry   `json:"removed"`
+	Changed    []CatalogEntryChange `json:"changed"`
+	Facets     []FacetCountDelta    `json:"facets"`
+}
+
+type CatalogDiffEntry struct {
+	CourseID string `json:"course_id"`
+	Title    string `json:"title"`
+}
+
+// CatalogEntryChange lists what changed in a course present in both
+// catalogs. Title is the course's title in the new catalog.
+type CatalogEntryChange struct {
+	CourseID     string                 `json:"course_id"`
+	Title        string                 `json:"title"`
+	Fields       []CatalogFieldChange   `json:"fields,omitempty"`
+	LinksAdded   []string               `json:"links_added,omitempty"`
+	LinksRemoved []string               `json:"links_removed,omitempty"`
+	Enrichment   []LinkEnrichmentChange `json:"enrichment,omitempty"`
+}
+
+// CatalogFieldChange holds a field's old and new values. Categories are
+// joined with ", " and a missing author is empty.
+type CatalogFieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// LinkEnrichmentChange reports a link kept by a course whose fetched
+// content changed, appeared, or disappeared.
+type LinkEnrichmentChange struct {
+	URL string       `json:"url"`
+	Old *LinkContent `json:"old"`
+	New *LinkContent `json:"new"`
+}
+
+type FacetCountDelta struct {
+	Facet string `json:"facet"`
+	ID    string `json:"id"`
+	Old   int    `json:"old"`
+	New   int    `json:"new"`
+	Delta int    `json:"delta"`
+}
+
+// DiffCatalogs compares two catalogs by course ID. Courses, links, and facet
+// values are reported in ID or URL order, so the same catalogs always give
+// the same diff.
+func DiffCatalogs(previous, current Catalog) CatalogDiff {
+	diff := CatalogDiff{
+		OldEntries: len(previous.Entries),
+		NewEntries: len(current.Entries),
+		Added:      []CatalogDiffEntry{},
+		Removed:    []CatalogDiffEntry{},
+		Changed:    []CatalogEntryChange{},
+		Facets:     []FacetCountDelta{},
+	}
+	oldEntries := make(map[string]CatalogEntry, len(previous.Entries))
+	for _, entry := range previous.Entries {
+		oldEntries[entry.ID] = entry
+	}
+	newIDs := make(map[string]struct{}, len(current.Entries))
+	for _, entry := range current.Entries {
+		newIDs[entry.ID] = struct{}{}
+		old, ok := oldEntries[entry.ID]
+		if !ok {
+			diff.Added = append(diff.Added, CatalogDiffEntry{CourseID: entry.ID, Title: entry.Title})
+			continue
+		}
+		if change, changed := diffCatalogEntry(old, entry); changed {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+	for _, entry := range previous.Entries {
+		if _, ok := newIDs[entry.ID]; !ok {
+			diff.Removed = append(diff.Removed, CatalogDiffEntry{CourseID: entry.ID, Title: entry.Title})
+		}
+	}
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].CourseID < diff.Added[j].CourseID })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].CourseID < diff.Removed[j].CourseID })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].CourseID < diff.Changed[j].CourseID })
+
+	diff.Facets = appendFacetDeltas(diff.Facets, CatalogFacetCategory, categoryCounts(previous.Categories), categoryCounts(current.Categories))
+	diff.Facets = appendFacetDeltas(diff.Facets, CatalogFacetFormat, formatCounts(previous.Formats), formatCounts(current.Formats))
+	diff.Facets = appendFacetDeltas(diff.Facets, CatalogFacetChannel, channelCounts(previous.Channels), channelCounts(current.Channels))
+	return diff
+}
+
+func (diff CatalogDiff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 && len(diff.Facets) == 0
+}
+
+func diffCatalogEntry(old, current CatalogEntry) (CatalogEntryChange, bool) {
+	change := CatalogEntryChange{CourseID: current.ID, Title: current.Title}
+	fields := []CatalogFieldChange{
+		{Field: "title", Old: old.Title, New: current.Title},
+		{Field: "author", Old: optionalString(old.Author), New: optionalString(current.Author)},
+		{Field: "categories", Old: strings.Join(old.Categories, ", "), New: strings.Join(current.Categories, ", ")},
+	}
+	for _, field := range fields {
+		if field.Old != field.New {
+			change.Fields = append(change.Fields, field)
+		}
+	}
+
+	oldLinks := make(map[string]CatalogLink, len(old.Links))
+	for _, link := range old.Links {
+		oldLinks[link.URL] = link
+	}
+	newLinks := make(map[string]CatalogLink, len(current.Links))
+	for _, link := range current.Links {
+		newLinks[link.URL] = link
+		previous, ok := oldLinks[link.URL]
+		if !ok {
+			change.LinksAdded = append(change.LinksAdded, link.URL)
+			continue
+		}
+		if !reflect.DeepEqual(previous.Content, link.Content) {
+			change.Enrichment = append(change.Enrichment, LinkEnrichmentChange{URL: link.URL, Old: previous.Content, New: link.Content})
+		}
+	}
+	for _, link := range old.Links {
+		if _, ok := newLinks[link.URL]; !ok {
+			change.LinksRemoved = append(change.LinksRemoved, link.URL)
+		}
+	}
+	slices.Sort(change.LinksAdded)
+	slices.Sort(change.LinksRemoved)
+	sort.Slice(change.Enrichment, func(i, j int) bool { return change.Enrichment[i].URL < change.Enrichment[j].URL })
+
+	changed := len(change.Fields) != 0 || len(change.LinksAdded) != 0 || len(change.LinksRemoved) != 0 || len(change.Enrichment) != 0
+	return change, changed
+}
+
+func optionalString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func appendFacetDeltas(deltas []FacetCountDelta, facet string, old, current map[string]int) []FacetCountDelta {
+	ids := make([]string, 0, len(old)+len(current))
+	for id := range old {
+		ids = append(ids, id)
+	}
+	for id := range current {
+		if _, ok := old[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if delta := current[id] - old[id]; delta != 0 {
+			deltas = append(deltas, FacetCountDelta{Facet: facet, ID: id, Old: old[id], New: current[id], Delta: delta})
+		}
+	}
+	return deltas
+}
+
+func categoryCounts(categories []CategoryMetadata) map[string]int {
+	counts := make(map[string]int, len(categories))
+	for _, category := range categories {
+		counts[category.ID] = category.Count
+	}
+	return counts
+}
+
+func formatCounts(formats []FormatMetadata) map[string]int {
+	counts := make(map[string]int, len(formats))
+	for _, format := range formats {
+		counts[format.ID] = format.Count
+	}
+	return counts
+}
+
+func channelCounts(channels []ChannelMetadata) map[string]int {
+	counts := make(map[string]int, len(channels))
+	for _, channel := range channels {
+		counts[channel.ID] = channel.Count
+	}
+	return counts
+}
@@ -0,0 +1,75 @@
+package courses
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffCatalogsReportsEntryLinkAndFacetChanges(t *testing.T) {
+	author := "Fixture Author"
+	previous := Catalog{
+		Categories: []CategoryMetadata{{ID: "development", Count: 2}, {ID: "design", Count: 1}},
+		Formats:    []FormatMetadata{{ID: "course", Count: 3}},
+		Channels:   []ChannelMetadata{{ID: "main", Count: 3}},
+		Entries: []CatalogEntry{
+			{ID: "course:b", Title: "Go basics", Author: &author, Categories: []string{"development"}, Links: []CatalogLink{
+				{URL: "https://files.example.test/a", Content: &LinkContent{Name: "Go", FileCount: 3}},
+				{URL: "https://files.example.test/old"},
+			}},
+			{ID: "course:a", Title: "Unchanged", Categories: []string{"development"}, Links: []CatalogLink{{URL: "https://files.example.test/u"}}},
+			{ID: "course:c", Title: "Removed design", Categories: []string{"design"}},
+		},
+	}
+	current := Catalog{
+		Categories: []CategoryMetadata{{ID: "development", Count: 1}, {ID: "marketing_ads_smm", Count: 2}},
+		Formats:    []FormatMetadata{{ID: "course", Count: 3}},
+		Channels:   []ChannelMetadata{{ID: "main", Count: 3}},
+		Entries: []CatalogEntry{
+			{ID: "course:d", Title: "New SMM", Categories: []string{"marketing_ads_smm"}},
+			{ID: "course:a", Title: "Unchanged", Categories: []string{"development"}, Links: []CatalogLink{{URL: "https://files.example.test/u"}}},
+			{ID: "course:b", Title: "Go basics, 2nd edition", Categories: []string{"development", "marketing_ads_smm"}, Links: []CatalogLink{
+				{URL: "https://files.example.test/new"},
+				{URL: "https://files.example.test/a", Content: &LinkContent{Name: "Go", FileCount: 4}},
+			}},
+		},
+	}
+
+	diff := DiffCatalogs(previous, current)
+	if diff.OldEntries != 3 || diff.NewEntries != 3 {
+		t.Fatalf("entry counts = %d, %d", diff.OldEntries, diff.NewEntries)
+	}
+	if !slices.Equal(diff.Added, []CatalogDiffEntry{{CourseID: "course:d", Title: "New SMM"}}) ||
+		!slices.Equal(diff.Removed, []CatalogDiffEntry{{CourseID: "course:c", Title: "Removed design"}}) {
+		t.Fatalf("added = %+v, removed = %+v", diff.Added, diff.Removed)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("changed = %+v", diff.Changed)
+	}
+	change := diff.Changed[0]
+	if change.CourseID != "course:b" || !slices.Equal(change.Fields, []CatalogFieldChange{
+		{Field: "title", Old: "Go basics", New: "Go basics, 2nd edition"},
+		{Field: "author", Old: "Fixture Author", New: ""},
+		{Field: "categories", Old: "development", New: "development, marketing_ads_smm"},
+	}) {
+		t.Fatalf("change = %+v", change)
+	}
+	if !slices.Equal(change.LinksAdded, []string{"https://files.example.test/new"}) ||
+		!slices.Equal(change.LinksRemoved, []string{"https://files.example.test/old"}) {
+		t.Fatalf("links added = %v, removed = %v", change.LinksAdded, change.LinksRemoved)
+	}
+	if len(change.Enrichment) != 1 || change.Enrichment[0].URL != "https://files.example.test/a" ||
+		change.Enrichment[0].Old.FileCount != 3 || change.Enrichment[0].New.FileCount != 4 {
+		t.Fatalf("enrichment = %+v", change.Enrichment)
+	}
+	if !slices.Equal(diff.Facets, []FacetCountDelta{
+		{Facet: "category", ID: "design", Old: 1, New: 0, Delta: -1},
+		{Facet: "category", ID: "development", Old: 2, New: 1, Delta: -1},
+		{Facet: "category", ID: "marketing_ads_smm", Old: 0, New: 2, Delta: 2},
+	}) {
+		t.Fatalf("facets = %+v", diff.Facets)
+	}
+
+	if same := DiffCatalogs(current, current); !same.Empty() {
+		t.Fatalf("diff of a catalog with itself = %+v", same)
+	}
+}
@@ -0,0 +1,442 @@
+package courses
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// catalogBuildVersion changes whenever the builder would turn the same
+// inputs into different entries, so catalogs written by an older builder
+// are never reused.
+const catalogBuildVersion = 3
+
+type IncrementalOptions struct {
+	// Previous is the gzip catalog written by an earlier build.
+	Previous io.Reader
+	// PreviousSources is the source state written with Previous. With it,
+	// inputs only need the exports added or changed since that build;
+	// without it they must hold every export Previous was built from.
+	PreviousSources io.Reader
+	// Sources, when set, receives the source state of the new catalog.
+	Sources io.Writer
+	// Verify also runs a full rebuild and fails unless both outputs are
+	// byte for byte identical.
+	Verify bool
+}
+
+type IncrementalStats struct {
+	ChangedExports int
+	// SkippedExports counts inputs the previous source state already holds.
+	SkippedExports int
+	ReusedEntries  int
+	RebuiltEntries int
+	// FullRebuild says why nothing could be reused from the previous
+	// catalog. It is empty when the previous catalog was usable.
+	FullRebuild string
+	Verified    bool
+}
+
+// BuildGzipWithSources is BuildGzipFromSourcesWithOptions that also writes
+// the source state of the catalog to sources, so a later
+// BuildGzipIncremental can start from it.
+func BuildGzipWithSources(inputs []SourceInput, output, sources io.Writer, options BuildOptions) (CatalogStats, error) {
+	exports, err := decodeSourceInputs(inputs)
+	if err != nil {
+		return CatalogStats{}, err
+	}
+	channels := groupSourceChannels(nil, exports)
+	source, err := mergeSourceChannels(channels)
+	if err != nil {
+		return CatalogStats{}, err
+	}
+	state, err := encodeSourceState(channels, appendSourceDigests(nil, exports))
+	if err != nil {
+		return CatalogStats{}, err
+	}
+	stats, err := buildGzipFromSource(source, output, options)
+	if err != nil {
+		return CatalogStats{}, err
+	}
+	if err := writeCatalogGzip(sources, state); err != nil {
+		return CatalogStats{}, fmt.Errorf("write source state: %w", err)
+	}
+	return stats, nil
+}
+
+// BuildGzipIncremental builds the same catalog as
+// BuildGzipFromSourcesWithOptions, reusing entries of the previous catalog
+// whose clusters no new or changed export touches.
+//
+// With PreviousSources the history comes from the source state, inputs it
+// already holds are skipped, and the rest are merged on top of it in order,
+// exactly as if they had been passed after the older exports to a full
+// rebuild. Without it, inputs must hold the whole history and the exports
+// newer than the previous catalog's ExportedAt count as changed. Either way
+// a build missing any source entry of the previous catalog fails instead of
+// writing a catalog that silently drops older courses.
+//
+// Every export is still clustered, because a new entry can join any cluster
+// in the history. Normalization, classification and merging, which dominate
+// a build, run only for touched clusters and for every cluster of an author
+// one of them belongs to, so author topic propagation sees the same entries
+// it would in a full rebuild.
+func BuildGzipIncremental(
+	inputs []SourceInput,
+	output io.Writer,
+	options BuildOptions,
+	incremental IncrementalOptions,
+) (CatalogStats, IncrementalStats, error) {
+	if incremental.Previous == nil {
+		return CatalogStats{}, IncrementalStats{}, errors.New("previous catalog is required")
+	}
+	previous, err := decodePreviousCatalog(incremental.Previous)
+	if err != nil {
+		return CatalogStats{}, IncrementalStats{}, err
+	}
+	sources, err := decodeSourceInputs(inputs)
+	if err != nil {
+		return CatalogStats{}, IncrementalStats{}, err
+	}
+
+	var stats IncrementalStats
+	var channels []sourceChannel
+	var older, changed []sourceExport
+	var digests []string
+	if incremental.PreviousSources != nil {
+		state, err := decodeSourceState(incremental.PreviousSources)
+		if err != nil {
+			return CatalogStats{}, IncrementalStats{}, err
+		}
+		channels = state.sourceChannels()
+		if err := checkSourceState(previous, channels); err != nil {
+			return CatalogStats{}, IncrementalStats{}, err
+		}
+		for _, channel := range channels {
+			older = append(older, channel.exports...)
+		}
+		digests = state.Inputs
+		for _, source := range sources {
+			if slices.Contains(digests, source.digest) {
+				stats.SkippedExports++
+				continue
+			}
+			changed = append(changed, source)
+		}
+		channels = groupSourceChannels(channels, changed)
+	} else {
+		channels = groupSourceChannels(nil, sources)
+		older, changed = splitByExportedAt(previous, sources)
+	}
+	if missing := missingPreviousSourceEntries(previous, slices.Concat(older, changed)); missing != 0 {
+		return CatalogStats{}, IncrementalStats{}, fmt.Errorf(
+			"incremental build: %d source entries of the previous catalog are missing from the inputs; pass its source state or every export",
+			missing,
+		)
+	}
+
+	reuse := newCatalogReuse(previous, older, changed, options, &stats)
+	source, err := mergeSourceChannels(channels)
+	if err != nil {
+		return CatalogStats{}, IncrementalStats{}, err
+	}
+	var state []byte
+	if incremental.Sources != nil {
+		state, err = encodeSourceState(channels, appendSourceDigests(slices.Clone(digests), changed))
+		if err != nil {
+			return CatalogStats{}, IncrementalStats{}, err
+		}
+	}
+	catalog, err := buildCatalog(source, options, reuse)
+	if err != nil {
+		return CatalogStats{}, IncrementalStats{}, err
+	}
+	if reuse != nil {
+		stats.ReusedEntries = reuse.reused
+	}
+	stats.RebuiltEntries = len(catalog.Entries) - stats.ReusedEntries
+	data, err := encodeCatalog(catalog)
+	if err != nil {
+		return CatalogStats{}, IncrementalStats{}, err
+	}
+
+	if incremental.Verify {
+		full, err := buildCatalog(source, options, nil)
+		if err != nil {
+			return CatalogStats{}, IncrementalStats{}, fmt.Errorf("verify incremental build: %w", err)
+		}
+		fullData, err := encodeCatalog(full)
+		if err != nil {
+			return CatalogStats{}, IncrementalStats{}, fmt.Errorf("verify incremental build: %w", err)
+		}
+		if !bytes.Equal(data, fullData) {
+			return CatalogStats{}, IncrementalStats{}, fmt.Errorf(
+				"verify incremental build: output differs from full rebuild%s",
+				describeCatalogDifference(catalog, full),
+			)
+		}
+		stats.Verified = true
+	}
+
+	if err := writeCatalogGzip(output, data); err != nil {
+		return CatalogStats{}, IncrementalStats{}, err
+	}
+	if state != nil {
+		if err := writeCatalogGzip(incremental.Sources, state); err != nil {
+			return CatalogStats{}, IncrementalStats{}, fmt.Errorf("write source state: %w", err)
+		}
+	}
+	return catalog.Stats, stats, nil
+}
+
+func decodePreviousCatalog(input io.Reader) (Catalog, error) {
+	reader, err := gzip.NewReader(input)
+	if err != nil {
+		return Catalog{}, fmt.Errorf("read previous catalog: %w", err)
+	}
+	defer reader.Close()
+	var catalog Catalog
+	if err := json.NewDecoder(reader).Decode(&catalog); err != nil {
+		return Catalog{}, fmt.Errorf("decode previous catalog: %w", err)
+	}
+	if catalog.SchemaVersion != catalogSchema {
+		return Catalog{}, fmt.Errorf("previous catalog schema %q, want %q", catalog.SchemaVersion, catalogSchema)
+	}
+	return catalog, nil
+}
+
+// checkSourceState rejects a source state that is not the one the previous
+// catalog was built from, such as one left behind by an older build.
+func checkSourceState(previous Catalog, channels []sourceChannel) error {
+	source, err := mergeSourceChannels(slices.Clone(channels))
+	if err != nil {
+		return fmt.Errorf("previous source state: %w", err)
+	}
+	if source.ExportedAt != previous.ExportedAt ||
+		len(source.Messages) != previous.Stats.Messages ||
+		len(source.CatalogEntries) != previous.Stats.SourceEntries {
+		return errors.New("previous source state does not match the previous catalog")
+	}
+	return nil
+}
+
+// splitByExportedAt separates the exports no newer than the previous
+// catalog, which it was presumably built from, from the changed ones. All
+// exports count as changed when either timestamp is invalid.
+func splitByExportedAt(previous Catalog, sources []sourceExport) (older, changed []sourceExport) {
+	previousExportedAt, previousErr := time.Parse(time.RFC3339Nano, previous.ExportedAt)
+	for _, source := range sources {
+		exportedAt, err := time.Parse(time.RFC3339Nano, source.ExportedAt)
+		if previousErr == nil && err == nil && !exportedAt.After(previousExportedAt) {
+			older = append(older, source)
+			continue
+		}
+		changed = append(changed, source)
+	}
+	return older, changed
+}
+
+// missingPreviousSourceEntries counts the source entries merged into the
+// previous catalog that no input export contains.
+func missingPreviousSourceEntries(previous Catalog, sources []sourceExport) int {
+	entryIDs := make(map[string]struct{})
+	for _, source := range sources {
+		for _, entry := range source.CatalogEntries {
+			entryIDs[entry.EntryID] = struct{}{}
+		}
+	}
+	missing := 0
+	for _, entry := range previous.Entries {
+		for _, source := range entry.Sources {
+			if _, ok := entryIDs[source.EntryID]; !ok {
+				missing++
+			}
+		}
+	}
+	return missing
+}
+
+// catalogReuse decides which clusters an incremental build copies from the
+// previous catalog.
+type catalogReuse struct {
+	previous        map[string]CatalogEntry
+	changedEntries  map[string]struct{}
+	changedMessages map[string]struct{}
+	reused          int
+}
+
+// newCatalogReuse returns nil, with the reason in stats.FullRebuild, when
+// the previous catalog cannot be trusted for these exports and options.
+// older are the exports the previous catalog was built from.
+func newCatalogReuse(previous Catalog, older, changed []sourceExport, options BuildOptions, stats *IncrementalStats) *catalogReuse {
+	_, previousErr := time.Parse(time.RFC3339Nano, previous.ExportedAt)
+	reuse := &catalogReuse{
+		previous:        make(map[string]CatalogEntry, len(previous.Entries)),
+		changedEntries:  make(map[string]struct{}),
+		changedMessages: make(map[string]struct{}),
+	}
+	olderMessages := make(map[string]struct{})
+	olderEntries := make(map[string]struct{})
+	for _, source := range older {
+		for _, message := range source.Messages {
+			olderMessages[message.MessageID] = struct{}{}
+		}
+		for _, entry := range source.CatalogEntries {
+			olderEntries[entry.EntryID] = struct{}{}
+		}
+	}
+	stats.ChangedExports = len(changed)
+	for _, source := range changed {
+		for _, message := range source.Messages {
+			reuse.changedMessages[message.MessageID] = struct{}{}
+		}
+		for _, entry := range source.CatalogEntries {
+			reuse.changedEntries[entry.EntryID] = struct{}{}
+		}
+	}
+
+	switch {
+	case previousErr != nil:
+		stats.FullRebuild = "previous catalog has no valid exported_at"
+	case previous.BuildFingerprint != buildFingerprint(options):
+		stats.FullRebuild = "build settings changed since the previous catalog"
+	case len(olderMessages) != previous.Stats.Messages || len(olderEntries) != previous.Stats.SourceEntries:
+		stats.FullRebuild = "previous catalog was not built from the older exports"
+	}
+	if stats.FullRebuild != "" {
+		return nil
+	}
+	for _, entry := range previous.Entries {
+		reuse.previous[entry.ID] = entry
+	}
+	return reuse
+}
+
+// reusableEntries returns the previous entry for every cluster root whose
+// members, member order and links are unchanged, leaving out clusters of
+// authors that have any rebuilt or vanished cluster.
+func (reuse *catalogReuse) reusableEntries(
+	source sourceExport,
+	clusters *disjointSet,
+	canonicalIndexes map[int]int,
+	canonicalIdentityKeys map[int]string,
+	entryLinks [][]CatalogLink,
+) map[int]CatalogEntry {
+	roots := make([]int, 0, len(canonicalIndexes))
+	members := make(map[int][]int, len(canonicalIndexes))
+	for index := range source.CatalogEntries {
+		root := clusters.find(index)
+		if _, exists := members[root]; !exists {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], index)
+	}
+
+	reusable := make(map[int]CatalogEntry, len(roots))
+	reusedIDs := make(map[string]struct{}, len(roots))
+	dirtyAuthors := make(map[string]struct{})
+	for _, root := range roots {
+		canonical := canonicalIndexes[root]
+		order := append([]int{canonical}, slices.DeleteFunc(slices.Clone(members[root]), func(index int) bool {
+			return index == canonical
+		})...)
+		previous, ok := reuse.previous[courseID(canonicalIdentityKeys[root])]
+		if ok && reuse.unchangedCluster(source, order, previous, entryLinks) {
+			reusable[root] = previous
+			reusedIDs[previous.ID] = struct{}{}
+			continue
+		}
+		displayEntry, _ := repairLegacyDateRangeHeading(source.CatalogEntries[canonical])
+		if author := cleanOptionalString(displayEntry.Credit.Author); author != nil {
+			dirtyAuthors[normalizeSearchText(*author)] = struct{}{}
+		}
+	}
+	for id, previous := range reuse.previous {
+		if _, exists := reusedIDs[id]; !exists {
+			dirtyAuthors[normalizedEntryAuthor(previous)] = struct{}{}
+		}
+	}
+	delete(dirtyAuthors, "")
+	for root, previous := range reusable {
+		if _, dirty := dirtyAuthors[normalizedEntryAuthor(previous)]; dirty {
+			delete(reusable, root)
+		}
+	}
+	return reusable
+}
+
+// unchangedCluster reports whether the cluster, with its canonical member
+// first, would merge into previous again.
+func (reuse *catalogReuse) unchangedCluster(
+	source sourceExport,
+	order []int,
+	previous CatalogEntry,
+	entryLinks [][]CatalogLink,
+) bool {
+	if len(previous.Sources) != len(order) {
+		return false
+	}
+	var links []CatalogLink
+	for position, index := range order {
+		entry := source.CatalogEntries[index]
+		if _, changed := reuse.changedEntries[entry.EntryID]; changed {
+			return false
+		}
+		if _, changed := reuse.changedMessages[entry.MessageID]; changed {
+			return false
+		}
+		if previous.Sources[position].EntryID != entry.EntryID {
+			return false
+		}
+		links = mergeLinks(links, entryLinks[index])
+	}
+	// Tombstones, suppressions, enrichment and torrent files are not part of
+	// the fingerprint; comparing the merged links covers them per cluster.
+	merged, err := json.Marshal(links)
+	if err != nil {
+		return false
+	}
+	previousLinks, err := json.Marshal(previous.Links)
+	return err == nil && bytes.Equal(merged, previousLinks)
+}
+
+func buildFingerprint(options BuildOptions) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n", catalogBuildVersion)
+	if options.TitleRules != nil {
+		hash.Write([]byte(options.TitleRules.digest))
+	}
+	fmt.Fprintf(
+		hash,
+		"\n%t\n%s\n%s\n%g",
+		options.CrossChannelDedup,
+		options.taxonomy().digest,
+		options.formatRules().digest,
+		options.ClassifierThreshold,
+	)
+	if options.EntryOverrides != nil {
+		hash.Write([]byte(options.EntryOverrides.digest))
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+func describeCatalogDifference(got, want Catalog) string {
+	if len(got.Entries) != len(want.Entries) {
+		return fmt.Sprintf(": %d entries, want %d", len(got.Entries), len(want.Entries))
+	}
+	for index := range got.Entries {
+		left, _ := json.Marshal(got.Entries[index])
+		right, _ := json.Marshal(want.Entries[index])
+		if !bytes.Equal(left, right) {
+			return fmt.Sprintf(": entry %q", want.Entries[index].ID)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,284 @@
+package courses
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildGzipIncrementalMatchesFullRebuild(t *testing.T) {
+	older, newer := incrementalTestExports(t)
+	previous := buildIncrementalTestPrevious(t, older, BuildOptions{})
+
+	var incremental bytes.Buffer
+	_, stats, err := BuildGzipIncremental(
+		[]SourceInput{{Reader: sourceReader(t, older), Name: "older"}, {Reader: sourceReader(t, newer), Name: "newer"}},
+		&incremental,
+		BuildOptions{},
+		IncrementalOptions{Previous: bytes.NewReader(previous), Verify: true},
+	)
+	if err != nil {
+		t.Fatalf("incremental build: %v", err)
+	}
+	// Only the untouched author's entry is reused: the repost touches one
+	// cluster and the new entry changes the topic propagated to another.
+	if stats != (IncrementalStats{ChangedExports: 1, ReusedEntries: 1, RebuiltEntries: 4, Verified: true}) {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	var full bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, older)}, {Reader: sourceReader(t, newer)}},
+		&full,
+		BuildOptions{},
+	); err != nil {
+		t.Fatalf("full build: %v", err)
+	}
+	got := decodeBuiltCatalog(t, &incremental)
+	want := decodeBuiltCatalog(t, &full)
+	if mustMarshalCatalog(t, got) != mustMarshalCatalog(t, want) {
+		t.Fatalf("incremental catalog differs from full rebuild")
+	}
+	for _, entry := range got.Entries {
+		if entry.Title == "Нейтральная практика" && slices.Equal(entry.Categories, []string{"other"}) {
+			t.Fatalf("entry %q kept stale author topic", entry.Title)
+		}
+	}
+}
+
+func TestBuildGzipIncrementalFromSourceStateTakesOnlyNewExports(t *testing.T) {
+	older, newer := incrementalTestExports(t)
+	var previous, previousSources bytes.Buffer
+	if _, err := BuildGzipWithSources([]SourceInput{{Reader: sourceReader(t, older)}}, &previous, &previousSources, BuildOptions{}); err != nil {
+		t.Fatalf("build previous catalog: %v", err)
+	}
+	var full bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, older)}, {Reader: sourceReader(t, newer)}},
+		&full,
+		BuildOptions{},
+	); err != nil {
+		t.Fatalf("full build: %v", err)
+	}
+	want := mustMarshalCatalog(t, decodeBuiltCatalog(t, &full))
+
+	tests := map[string]struct {
+		inputs []sourceExport
+		stats  IncrementalStats
+	}{
+		"new exports only": {[]sourceExport{newer}, IncrementalStats{ChangedExports: 1, ReusedEntries: 1, RebuiltEntries: 4, Verified: true}},
+		"every export":     {[]sourceExport{older, newer}, IncrementalStats{ChangedExports: 1, SkippedExports: 1, ReusedEntries: 1, RebuiltEntries: 4, Verified: true}},
+	}
+	for name, test := range tests {
+		var inputs []SourceInput
+		for _, source := range test.inputs {
+			inputs = append(inputs, SourceInput{Reader: sourceReader(t, source)})
+		}
+		var output, sources bytes.Buffer
+		_, stats, err := BuildGzipIncremental(inputs, &output, BuildOptions{}, IncrementalOptions{
+			Previous:        bytes.NewReader(previous.Bytes()),
+			PreviousSources: bytes.NewReader(previousSources.Bytes()),
+			Sources:         &sources,
+			Verify:          true,
+		})
+		if err != nil {
+			t.Fatalf("%s: incremental build: %v", name, err)
+		}
+		if stats != test.stats {
+			t.Fatalf("%s: stats = %+v", name, stats)
+		}
+		built := bytes.Clone(output.Bytes())
+		if got := mustMarshalCatalog(t, decodeBuiltCatalog(t, &output)); got != want {
+			t.Fatalf("%s: incremental catalog differs from full rebuild", name)
+		}
+
+		// The new source state carries the whole history forward.
+		var next bytes.Buffer
+		_, stats, err = BuildGzipIncremental([]SourceInput{{Reader: sourceReader(t, newer)}}, &next, BuildOptions{}, IncrementalOptions{
+			Previous:        bytes.NewReader(built),
+			PreviousSources: &sources,
+			Verify:          true,
+		})
+		if err != nil {
+			t.Fatalf("%s: next incremental build: %v", name, err)
+		}
+		if stats.ChangedExports != 0 || stats.SkippedExports != 1 || stats.RebuiltEntries != 0 {
+			t.Fatalf("%s: next stats = %+v", name, stats)
+		}
+		if got := mustMarshalCatalog(t, decodeBuiltCatalog(t, &next)); got != want {
+			t.Fatalf("%s: next incremental catalog differs from full rebuild", name)
+		}
+	}
+}
+
+func TestBuildGzipIncrementalRejectsStaleSourceState(t *testing.T) {
+	older, newer := incrementalTestExports(t)
+	var stale, sources bytes.Buffer
+	if _, err := BuildGzipWithSources([]SourceInput{{Reader: sourceReader(t, older)}}, &stale, &sources, BuildOptions{}); err != nil {
+		t.Fatalf("build stale catalog: %v", err)
+	}
+	var previous bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, older)}, {Reader: sourceReader(t, newer)}},
+		&previous,
+		BuildOptions{},
+	); err != nil {
+		t.Fatalf("build previous catalog: %v", err)
+	}
+
+	var output bytes.Buffer
+	_, _, err := BuildGzipIncremental([]SourceInput{{Reader: sourceReader(t, newer)}}, &output, BuildOptions{}, IncrementalOptions{
+		Previous:        &previous,
+		PreviousSources: &sources,
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not match the previous catalog") {
+		t.Fatalf("incremental build error = %v, want stale source state", err)
+	}
+	if output.Len() != 0 {
+		t.Fatalf("catalog written from a stale source state")
+	}
+}
+
+func TestBuildGzipIncrementalRebuildsEverythingWhenSettingsChange(t *testing.T) {
+	older, newer := incrementalTestExports(t)
+	previous := buildIncrementalTestPrevious(t, older, BuildOptions{})
+	rules := titleRulesForTest(t, titleRulesTestPayload(`[]`, `[]`, `[]`, `[]`, `[]`))
+
+	var output bytes.Buffer
+	_, stats, err := BuildGzipIncremental(
+		[]SourceInput{{Reader: sourceReader(t, older)}, {Reader: sourceReader(t, newer)}},
+		&output,
+		BuildOptions{TitleRules: rules},
+		IncrementalOptions{Previous: bytes.NewReader(previous), Verify: true},
+	)
+	if err != nil {
+		t.Fatalf("incremental build: %v", err)
+	}
+	if stats.FullRebuild == "" || stats.ReusedEntries != 0 || !stats.Verified {
+		t.Fatalf("stats = %+v, want full rebuild", stats)
+	}
+}
+
+func TestBuildGzipIncrementalRejectsInputsMissingOlderExports(t *testing.T) {
+	older, newer := incrementalTestExports(t)
+	previous := buildIncrementalTestPrevious(t, older, BuildOptions{})
+	rules := titleRulesForTest(t, titleRulesTestPayload(`[]`, `[]`, `[]`, `[]`, `[]`))
+
+	for name, options := range map[string]BuildOptions{"same settings": {}, "changed settings": {TitleRules: rules}} {
+		var output bytes.Buffer
+		_, _, err := BuildGzipIncremental(
+			[]SourceInput{{Reader: sourceReader(t, newer)}},
+			&output,
+			options,
+			IncrementalOptions{Previous: bytes.NewReader(previous)},
+		)
+		if err == nil || !strings.Contains(err.Error(), "4 source entries of the previous catalog are missing") {
+			t.Fatalf("%s: error = %v, want missing older exports", name, err)
+		}
+		if output.Len() != 0 {
+			t.Fatalf("%s: catalog written from a subset of the history", name)
+		}
+	}
+
+	var output bytes.Buffer
+	if _, _, err := BuildGzipIncremental(
+		[]SourceInput{{Reader: sourceReader(t, older)}, {Reader: sourceReader(t, newer)}},
+		&output,
+		BuildOptions{},
+		IncrementalOptions{Previous: bytes.NewReader(previous)},
+	); err != nil {
+		t.Fatalf("incremental build with the full history: %v", err)
+	}
+	ids := make(map[string]struct{})
+	for _, entry := range decodeBuiltCatalog(t, &output).Entries {
+		ids[entry.ID] = struct{}{}
+	}
+	var previousBuffer bytes.Buffer
+	previousBuffer.Write(previous)
+	for _, entry := range decodeBuiltCatalog(t, &previousBuffer).Entries {
+		if _, ok := ids[entry.ID]; !ok {
+			t.Fatalf("older entry %q was lost", entry.Title)
+		}
+	}
+}
+
+func TestBuildGzipIncrementalVerifyRejectsStalePreviousEntry(t *testing.T) {
+	older, newer := incrementalTestExports(t)
+	previousData := buildIncrementalTestPrevious(t, older, BuildOptions{})
+	previous := decodeBuiltCatalog(t, bytes.NewBuffer(previousData))
+	for index := range previous.Entries {
+		if previous.Entries[index].Title == "Инвестиции и крипта" {
+			previous.Entries[index].Title = "Edited by hand"
+		}
+	}
+	var tampered bytes.Buffer
+	writer := gzip.NewWriter(&tampered)
+	if err := json.NewEncoder(writer).Encode(previous); err != nil {
+		t.Fatalf("encode previous catalog: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close previous catalog: %v", err)
+	}
+
+	var output bytes.Buffer
+	_, _, err := BuildGzipIncremental(
+		[]SourceInput{{Reader: sourceReader(t, older)}, {Reader: sourceReader(t, newer)}},
+		&output,
+		BuildOptions{},
+		IncrementalOptions{Previous: &tampered, Verify: true},
+	)
+	if err == nil || !strings.Contains(err.Error(), "differs from full rebuild") {
+		t.Fatalf("incremental build error = %v, want verification failure", err)
+	}
+	if output.Len() != 0 {
+		t.Fatalf("output written despite failed verification")
+	}
+}
+
+func incrementalTestExports(t *testing.T) (sourceExport, sourceExport) {
+	t.Helper()
+
+	older := validSource(t, validSourceEntry())
+	older.ExportedAt = "2026-07-01T00:00:00Z"
+	older.Messages = []sourceMessage{
+		{MessageID: "1:1", TelegramMessageID: 1, URL: "https://messages.example.test/source/1"},
+		{MessageID: "1:2", TelegramMessageID: 2, URL: "https://messages.example.test/source/2"},
+		{MessageID: "1:3", TelegramMessageID: 3, URL: "https://messages.example.test/source/3"},
+		{MessageID: "1:4", TelegramMessageID: 4, URL: "https://messages.example.test/source/4"},
+	}
+	older.CatalogEntries = []sourceEntry{
+		sourceEntryWithIdentity("1:1:0", "1:1", "Python backend", "Fixture Author"),
+		sourceEntryWithIdentity("1:2:0", "1:2", "Нейтральная практика", "Fixture Author"),
+		sourceEntryWithIdentity("1:3:0", "1:3", "Инвестиции и крипта", "Other Author"),
+		sourceEntryWithIdentity("1:4:0", "1:4", "Маркетинг и реклама", "Third Author"),
+	}
+	setSourceCounts(&older)
+
+	newer := validSource(t, validSourceEntry())
+	newer.ExportedAt = "2026-08-01T00:00:00Z"
+	newer.Messages = []sourceMessage{
+		{MessageID: "1:5", TelegramMessageID: 5, URL: "https://messages.example.test/source/5"},
+		{MessageID: "1:6", TelegramMessageID: 6, URL: "https://messages.example.test/source/6"},
+	}
+	repost := sourceEntryWithIdentity("1:6:0", "1:6", "Маркетинг и реклама", "Third Author")
+	repost.AddedAt = "2024-02-01T00:00:00Z"
+	newer.CatalogEntries = []sourceEntry{
+		sourceEntryWithIdentity("1:5:0", "1:5", "Разработка на Go", "Fixture Author"),
+		repost,
+	}
+	setSourceCounts(&newer)
+	return older, newer
+}
+
+func buildIncrementalTestPrevious(t *testing.T, source sourceExport, options BuildOptions) []byte {
+	t.Helper()
+
+	var output bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions([]SourceInput{{Reader: sourceReader(t, source)}}, &output, options); err != nil {
+		t.Fatalf("build previous catalog: %v", err)
+	}
+	return output.Bytes()
+}
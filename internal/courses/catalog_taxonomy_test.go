@@ -52,7 +52,7 @@ func TestClassifyNewTopicTable(t *testing.T) {
 			entry := validSourceEntry()
 			entry.Title = test.title
 
-			got := classify(entry)
+			got := defaultTaxonomy.classify(entry)
 			if !slices.Contains(got, test.want) {
 				t.Fatalf("categories = %v, want %q", got, test.want)
 			}
@@ -81,7 +81,7 @@ func TestClassifyTopicFalsePositiveGuards(t *testing.T) {
 			entry := validSourceEntry()
 			entry.Title = test.text
 
-			got := classify(entry)
+			got := defaultTaxonomy.classify(entry)
 			if slices.Contains(got, test.not) {
 				t.Fatalf("categories = %v, must not contain %q", got, test.not)
 			}
@@ -94,7 +94,7 @@ func TestClassifyTelegramSourceNoiseStaysOther(t *testing.T) {
 	entry.Title = "Источник Telegram канала"
 	entry.RawBlock = "Исходный пост без тематических слов"
 
-	got := classify(entry)
+	got := defaultTaxonomy.classify(entry)
 	if !slices.Equal(got, []string{"other"}) {
 		t.Fatalf("categories = %v, want [other]", got)
 	}
@@ -107,7 +107,7 @@ func TestClassifyDoesNotUseLinkProviderDomains(t *testing.T) {
 	entry.Links[0].Host = "python-design-ai.example.test"
 	entry.Links[0].Provider = "python_design_ai"
 
-	got := classify(entry)
+	got := defaultTaxonomy.classify(entry)
 	if !slices.Equal(got, []string{"other"}) {
 		t.Fatalf("categories = %v, want [other]", got)
 	}
@@ -99,7 +99,7 @@ func TestClassifyDoesNotUseURLFragments(t *testing.T) {
 	raw := "https://example.test/path/gpt/python/design"
 	entry := sourceEntry{Title: title, RawBlock: raw}
 
-	categories := classify(entry)
+	categories := defaultTaxonomy.classify(entry)
 	if len(categories) != 1 || categories[0] != "other" {
 		t.Fatalf("categories = %v, want [other]", categories)
 	}
@@ -1333,7 +1333,7 @@ func TestBuildGzipClassifiesFormatsConservatively(t *testing.T) {
 		rawBlock       string
 		formats        []string
 		primaryFormat  string
-		formatSource   string
+		formatSources  []string
 		metadataCounts map[string]int
 	}{
 		{
@@ -1341,7 +1341,7 @@ func TestBuildGzipClassifiesFormatsConservatively(t *testing.T) {
 			title:          "Курс-практикум по AI",
 			formats:        []string{"course", "workshop"},
 			primaryFormat:  "course",
-			formatSource:   "title",
+			formatSources:  []string{"title:course", "title:practicum"},
 			metadataCounts: map[string]int{"workshop": 1, "course": 1},
 		},
 		{
@@ -1349,7 +1349,7 @@ func TestBuildGzipClassifiesFormatsConservatively(t *testing.T) {
 			title:         "Яндекс.Практикум - Python",
 			formats:       []string{"unspecified"},
 			primaryFormat: "unspecified",
-			formatSource:  "fallback",
+			formatSources: []string{"fallback"},
 		},
 		{
 			name:          "author and raw block are ignored",
@@ -1358,70 +1358,70 @@ func TestBuildGzipClassifiesFormatsConservatively(t *testing.T) {
 			rawBlock:      "Вебинар и полный курс",
 			formats:       []string{"unspecified"},
 			primaryFormat: "unspecified",
-			formatSource:  "fallback",
+			formatSources: []string{"fallback"},
 		},
 		{
 			name:          "manual therapy is not a manual",
 			title:         "Мануальная терапия позвоночника",
 			formats:       []string{"unspecified"},
 			primaryFormat: "unspecified",
-			formatSource:  "fallback",
+			formatSources: []string{"fallback"},
 		},
 		{
 			name:          "professional adjective is not a profession",
 			title:         "AI для профессионального создания задников",
 			formats:       []string{"unspecified"},
 			primaryFormat: "unspecified",
-			formatSource:  "fallback",
+			formatSources: []string{"fallback"},
 		},
 		{
 			name:          "book and templates are both retained",
 			title:         "Книга стиля для женщин. Готовый шаблон для работы",
 			formats:       []string{"book_guide", "templates_assets"},
 			primaryFormat: "book_guide",
-			formatSource:  "title",
+			formatSources: []string{"title:book_guide", "title:templates_assets"},
 		},
 		{
 			name:          "live recording",
 			title:         "Запись вебинара по коммуникации",
 			formats:       []string{"live_recording"},
 			primaryFormat: "live_recording",
-			formatSource:  "title",
+			formatSources: []string{"title:live_recording"},
 		},
 		{
 			name:          "marathon",
 			title:         "Марафон привычек на месяц",
 			formats:       []string{"marathon"},
 			primaryFormat: "marathon",
-			formatSource:  "title",
+			formatSources: []string{"title:marathon"},
 		},
 		{
 			name:          "bundle library",
 			title:         "Библиотека шаблонов для проекта",
 			formats:       []string{"templates_assets", "bundle_library"},
 			primaryFormat: "templates_assets",
-			formatSource:  "title",
+			formatSources: []string{"title:templates_assets", "title:bundle_library"},
 		},
 		{
 			name:          "club membership",
 			title:         "Клуб с ежемесячной подпиской",
 			formats:       []string{"club_membership"},
 			primaryFormat: "club_membership",
-			formatSource:  "title",
+			formatSources: []string{"title:club_membership"},
 		},
 		{
 			name:          "audio book is audio",
 			title:         "Развитие памяти (Аудиокнига)",
 			formats:       []string{"audio"},
 			primaryFormat: "audio",
-			formatSource:  "title",
+			formatSources: []string{"title:audio"},
 		},
 		{
 			name:          "course remains primary over supplemental templates",
 			title:         "Вводное обучение SERM + полезные материалы",
 			formats:       []string{"course", "templates_assets"},
 			primaryFormat: "course",
-			formatSource:  "title",
+			formatSources: []string{"title:course", "title:templates_assets"},
 		},
 	}
 
@@ -1449,8 +1449,8 @@ func TestBuildGzipClassifiesFormatsConservatively(t *testing.T) {
 			if got.PrimaryFormat != test.primaryFormat {
 				t.Fatalf("primary format = %q, want %q", got.PrimaryFormat, test.primaryFormat)
 			}
-			if !slices.Equal(got.FormatSources, []string{test.formatSource}) {
-				t.Fatalf("format sources = %v, want [%s]", got.FormatSources, test.formatSource)
+			if !slices.Equal(got.FormatSources, test.formatSources) {
+				t.Fatalf("format sources = %v, want %v", got.FormatSources, test.formatSources)
 			}
 			if test.metadataCounts != nil {
 				counts := make(map[string]int, len(catalog.Formats))
@@ -1473,21 +1473,21 @@ func TestBuildGzipClassifiesTorrentAttachmentFromMessageMedia(t *testing.T) {
 		title         string
 		formats       []string
 		primaryFormat string
-		formatSource  string
+		formatSources []string
 	}{
 		{
 			name:          "attachment is the only format signal",
 			title:         "OutBlock",
 			formats:       []string{"torrent_attachment"},
 			primaryFormat: "torrent_attachment",
-			formatSource:  "availability",
+			formatSources: []string{"document:torrent_mime_type"},
 		},
 		{
 			name:          "attachment supplements explicit course",
 			title:         "Полный курс Go",
 			formats:       []string{"course", "torrent_attachment"},
 			primaryFormat: "course",
-			formatSource:  "title",
+			formatSources: []string{"title:course", "document:torrent_mime_type"},
 		},
 	}
 
@@ -1530,8 +1530,8 @@ func TestBuildGzipClassifiesTorrentAttachmentFromMessageMedia(t *testing.T) {
 			if !slices.Equal(got.Formats, test.formats) {
 				t.Fatalf("formats = %v, want %v", got.Formats, test.formats)
 			}
-			if got.PrimaryFormat != test.primaryFormat || !slices.Equal(got.FormatSources, []string{test.formatSource}) {
-				t.Fatalf("primary/sources = %q/%v, want %q/[%s]", got.PrimaryFormat, got.FormatSources, test.primaryFormat, test.formatSource)
+			if got.PrimaryFormat != test.primaryFormat || !slices.Equal(got.FormatSources, test.formatSources) {
+				t.Fatalf("primary/sources = %q/%v, want %q/%v", got.PrimaryFormat, got.FormatSources, test.primaryFormat, test.formatSources)
 			}
 		})
 	}
@@ -0,0 +1,195 @@
+package courses
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Category sources recorded on catalog entries.
+const (
+	categorySourceKeywords    = "keywords"
+	categorySourceAuthorTopic = "author_topic"
+	categorySourceClassifier  = "classifier"
+	categorySourceFallback    = "fallback"
+)
+
+// classifierMinExamples is how many confidently labeled entries a category
+// needs before the classifier proposes it.
+const classifierMinExamples = 3
+
+// categoryClassifier is a multinomial naive Bayes model over title and
+// author tokens. It is trained on the entries of the build being run, so it
+// needs no network or stored model, and every sum runs in taxonomy and token
+// order, so the same catalog always yields the same proposals.
+type categoryClassifier struct {
+	categories []string
+	documents  []int
+	tokenCount []int
+	counts     []map[string]int
+	vocabulary map[string]struct{}
+	total      int
+}
+
+func keywordCategorySource(categories []string) string {
+	if slices.Equal(categories, []string{"other"}) {
+		return categorySourceFallback
+	}
+	return categorySourceKeywords
+}
+
+// classifyOtherEntries proposes a category for every entry classified only
+// as "other", taking the classifier's best category when its posterior
+// reaches threshold.
+func classifyOtherEntries(entries []CatalogEntry, order []string, threshold float64) {
+	classifier := trainCategoryClassifier(entries, order)
+	if classifier == nil {
+		return
+	}
+	for index := range entries {
+		entry := &entries[index]
+		if entry.CategorySource != categorySourceFallback {
+			continue
+		}
+		category, confidence := classifier.predict(classifierTokens(*entry))
+		if category == "" || confidence < threshold {
+			continue
+		}
+		entry.Categories = []string{category}
+		entry.PrimaryCategory = category
+		entry.CategorySource = categorySourceClassifier
+		entry.CategoryConfidence = confidence
+	}
+}
+
+// resetClassifiedEntries returns entries the classifier labeled, such as
+// ones reused from a previous catalog, to "other" so that a new model
+// decides them again.
+func resetClassifiedEntries(entries []CatalogEntry) {
+	for index := range entries {
+		entry := &entries[index]
+		if entry.CategorySource != categorySourceClassifier {
+			continue
+		}
+		entry.Categories = []string{"other"}
+		entry.PrimaryCategory = "other"
+		entry.CategorySource = categorySourceFallback
+		entry.CategoryConfidence = 0
+	}
+}
+
+// trainCategoryClassifier learns from entries whose categories came from
+// keywords or curator overrides, counting each entry once for every topic it
+// has. It returns nil unless at least two categories have enough examples.
+func trainCategoryClassifier(entries []CatalogEntry, order []string) *categoryClassifier {
+	positions := make(map[string]int, len(order))
+	classifier := &categoryClassifier{vocabulary: make(map[string]struct{})}
+	for _, category := range order {
+		if category == "other" || category == "service" {
+			continue
+		}
+		positions[category] = len(classifier.categories)
+		classifier.categories = append(classifier.categories, category)
+	}
+	classifier.documents = make([]int, len(classifier.categories))
+	classifier.tokenCount = make([]int, len(classifier.categories))
+	classifier.counts = make([]map[string]int, len(classifier.categories))
+	for index := range classifier.counts {
+		classifier.counts[index] = make(map[string]int)
+	}
+
+	for _, entry := range entries {
+		if (entry.CategorySource != categorySourceKeywords && entry.CategorySource != categorySourceOverride) ||
+			slices.Contains(entry.Categories, "service") {
+			continue
+		}
+		tokens := classifierTokens(entry)
+		if len(tokens) == 0 {
+			continue
+		}
+		for _, category := range entry.Categories {
+			position, ok := positions[category]
+			if !ok {
+				continue
+			}
+			classifier.documents[position]++
+			classifier.total++
+			classifier.tokenCount[position] += len(tokens)
+			for _, token := range tokens {
+				classifier.counts[position][token]++
+				classifier.vocabulary[token] = struct{}{}
+			}
+		}
+	}
+
+	trained := 0
+	for position := range classifier.categories {
+		if classifier.documents[position] < classifierMinExamples {
+			classifier.total -= classifier.documents[position]
+			classifier.documents[position] = 0
+			continue
+		}
+		trained++
+	}
+	if trained < 2 {
+		return nil
+	}
+	return classifier
+}
+
+// predict returns the most likely category and its posterior probability,
+// rounded to three decimals. Entries with no known token get no category.
+func (classifier *categoryClassifier) predict(tokens []string) (string, float64) {
+	known := tokens[:0:0]
+	for _, token := range tokens {
+		if _, ok := classifier.vocabulary[token]; ok {
+			known = append(known, token)
+		}
+	}
+	if len(known) == 0 {
+		return "", 0
+	}
+
+	scores := make([]float64, len(classifier.categories))
+	best := -1
+	vocabulary := float64(len(classifier.vocabulary))
+	for position := range classifier.categories {
+		if classifier.documents[position] == 0 {
+			continue
+		}
+		score := math.Log(float64(classifier.documents[position]) / float64(classifier.total))
+		denominator := float64(classifier.tokenCount[position]) + vocabulary
+		for _, token := range known {
+			score += math.Log((float64(classifier.counts[position][token]) + 1) / denominator)
+		}
+		scores[position] = score
+		if best < 0 || score > scores[best] {
+			best = position
+		}
+	}
+
+	sum := 0.0
+	for position, score := range scores {
+		if classifier.documents[position] != 0 {
+			sum += math.Exp(score - scores[best])
+		}
+	}
+	return classifier.categories[best], math.Round(1000/sum) / 1000
+}
+
+// classifierTokens returns the distinct title and author tokens of at
+// least three characters, in order of first appearance.
+func classifierTokens(entry CatalogEntry) []string {
+	text := entry.Title
+	if entry.Author != nil {
+		text += " " + *entry.Author
+	}
+	var tokens []string
+	for _, token := range strings.Fields(normalizeSearchText(text)) {
+		if utf8.RuneCountInString(token) >= 3 && !slices.Contains(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,144 @@
+package courses
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestBuildGzipClassifiesOtherEntriesAboveThreshold(t *testing.T) {
+	source := classifierTestSource(t)
+
+	var output bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, source)}},
+		&output,
+		BuildOptions{ClassifierThreshold: 0.8},
+	); err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	entries := classifierTestEntries(decodeBuiltCatalog(t, &output))
+
+	proposed := entries["Зюзя глубокое погружение"]
+	if !slices.Equal(proposed.Categories, []string{"development"}) || proposed.CategorySource != "classifier" ||
+		proposed.CategoryConfidence < 0.8 || proposed.CategoryConfidence > 1 {
+		t.Fatalf("proposed entry = %+v", proposed)
+	}
+	if unknown := entries["Совсем нейтральное"]; !slices.Equal(unknown.Categories, []string{"other"}) ||
+		unknown.CategorySource != "fallback" || unknown.CategoryConfidence != 0 {
+		t.Fatalf("entry without known tokens = %+v", unknown)
+	}
+	if labeled := entries["Python зюзя основы"]; labeled.CategorySource != "keywords" || labeled.CategoryConfidence != 0 {
+		t.Fatalf("keyword entry = %+v", labeled)
+	}
+
+	var again bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, source)}},
+		&again,
+		BuildOptions{ClassifierThreshold: 0.8},
+	); err != nil {
+		t.Fatalf("rebuild catalog: %v", err)
+	}
+	if !bytes.Equal(output.Bytes(), again.Bytes()) {
+		t.Fatal("classifier output is not deterministic")
+	}
+
+	var disabled bytes.Buffer
+	if _, err := BuildGzip(sourceReader(t, source), &disabled); err != nil {
+		t.Fatalf("build catalog without classifier: %v", err)
+	}
+	if entry := classifierTestEntries(decodeBuiltCatalog(t, &disabled))["Зюзя глубокое погружение"]; entry.CategorySource != "fallback" {
+		t.Fatalf("entry without classifier = %+v", entry)
+	}
+
+	var strict bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, source)}},
+		&strict,
+		BuildOptions{ClassifierThreshold: 1.1},
+	); err != nil {
+		t.Fatalf("build catalog with unreachable threshold: %v", err)
+	}
+	if entry := classifierTestEntries(decodeBuiltCatalog(t, &strict))["Зюзя глубокое погружение"]; entry.CategorySource != "fallback" {
+		t.Fatalf("entry below threshold = %+v", entry)
+	}
+}
+
+func TestBuildGzipIncrementalReclassifiesReusedClassifierEntries(t *testing.T) {
+	source := classifierTestSource(t)
+	options := BuildOptions{ClassifierThreshold: 0.8}
+	previous := buildIncrementalTestPrevious(t, source, options)
+
+	var output bytes.Buffer
+	_, stats, err := BuildGzipIncremental(
+		[]SourceInput{{Reader: sourceReader(t, source)}},
+		&output,
+		options,
+		IncrementalOptions{Previous: bytes.NewReader(previous), Verify: true},
+	)
+	if err != nil {
+		t.Fatalf("incremental build: %v", err)
+	}
+	if stats.ReusedEntries == 0 || !stats.Verified {
+		t.Fatalf("incremental stats = %+v", stats)
+	}
+	if entry := classifierTestEntries(decodeBuiltCatalog(t, &output))["Зюзя глубокое погружение"]; entry.CategorySource != "classifier" {
+		t.Fatalf("reused entry = %+v", entry)
+	}
+}
+
+func TestCategoryClassifierNeedsTwoTrainedCategories(t *testing.T) {
+	var entries []CatalogEntry
+	for index := range classifierMinExamples {
+		entries = append(entries, CatalogEntry{
+			Title:          fmt.Sprintf("Python зюзя %d", index),
+			Categories:     []string{"development"},
+			CategorySource: categorySourceKeywords,
+		})
+	}
+	entries = append(entries, CatalogEntry{Title: "Маркетинг бармаглот", Categories: []string{"marketing_ads_smm"}, CategorySource: categorySourceKeywords})
+	if classifier := trainCategoryClassifier(entries, defaultTaxonomy.categoryIDs()); classifier != nil {
+		t.Fatalf("classifier trained with one category of enough examples: %+v", classifier)
+	}
+}
+
+func classifierTestSource(t *testing.T) sourceExport {
+	t.Helper()
+
+	titles := []string{
+		"Python зюзя основы",
+		"Python зюзя практика",
+		"Python зюзя проекты",
+		"SMM бармаглот старт",
+		"SMM бармаглот кейсы",
+		"SMM бармаглот стратегия",
+		"Зюзя глубокое погружение",
+		"Совсем нейтральное",
+	}
+	source := validSource(t, validSourceEntry())
+	source.Messages = nil
+	source.CatalogEntries = nil
+	for index, title := range titles {
+		messageID := fmt.Sprintf("1:%d", index+1)
+		source.Messages = append(source.Messages, sourceMessage{
+			MessageID:         messageID,
+			TelegramMessageID: int64(index + 1),
+			URL:               fmt.Sprintf("https://messages.example.test/source/%d", index+1),
+		})
+		source.CatalogEntries = append(source.CatalogEntries, sourceEntryWithIdentity(
+			messageID+":0", messageID, title, fmt.Sprintf("Автор %d", index+1),
+		))
+	}
+	setSourceCounts(&source)
+	return source
+}
+
+func classifierTestEntries(catalog Catalog) map[string]CatalogEntry {
+	entries := make(map[string]CatalogEntry, len(catalog.Entries))
+	for _, entry := range catalog.Entries {
+		entries[entry.Title] = entry
+	}
+	return entries
+}
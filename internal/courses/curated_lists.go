@@ -0,0 +1,369 @@
+package courses
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source formats accepted in SourceInput.Format besides Telegram exports.
+const (
+	SourceFormatCSV      = "csv"
+	SourceFormatMarkdown = "markdown"
+)
+
+var (
+	markdownBulletPattern   = regexp.MustCompile(`^[-*+]\s+`)
+	markdownHeadingPattern  = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+	markdownLinkPattern     = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+	curatedPasswordPattern  = regexp.MustCompile(`(?i)(?:пароль|password)\s*[:：]\s*(\S+)`)
+	curatedYearPattern      = regexp.MustCompile(`^(\d{4})$`)
+	curatedYearRangePattern = regexp.MustCompile(`^(\d{4})\s*[-–—]\s*(\d{4})$`)
+)
+
+// SourceFormatForPath picks the SourceInput format from a file extension.
+// JSON and anything unknown are read as Telegram exports.
+func SourceFormatForPath(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".csv"):
+		return SourceFormatCSV
+	case strings.HasSuffix(lower, ".md"), strings.HasSuffix(lower, ".markdown"):
+		return SourceFormatMarkdown
+	}
+	return ""
+}
+
+// curatedList collects the entries of a CSV or Markdown list under a
+// synthetic channel. Every entry gets its own message, whose URL is the
+// entry's first link because a list has no posts to point at.
+type curatedList struct {
+	source sourceExport
+	keys   map[string]int
+	latest time.Time
+}
+
+func newCuratedList(channel string) *curatedList {
+	return &curatedList{
+		source: sourceExport{
+			SchemaVersion: sourceSchema,
+			Source: catalogSource{
+				ChannelID: syntheticChannelID(channel),
+				Title:     channel,
+			},
+		},
+		keys: make(map[string]int),
+	}
+}
+
+// syntheticChannelID derives a stable channel ID from a list's channel
+// name. It is negative, so it never equals a Telegram channel ID.
+func syntheticChannelID(channel string) int64 {
+	digest := sha256.Sum256([]byte("curated-list\x00" + channel))
+	return -int64(binary.BigEndian.Uint64(digest[:8])>>1) - 1
+}
+
+// add appends entry under a stable key: the explicit id when the list has
+// one, otherwise a digest of title, author and year with a counter for
+// repeats.
+func (list *curatedList) add(id string, entry sourceEntry, addedAt time.Time) error {
+	key := strings.TrimSpace(id)
+	if key == "" {
+		author := ""
+		if entry.Credit.Author != nil {
+			author = normalizeSearchText(*entry.Credit.Author)
+		}
+		year := ""
+		if entry.Year != nil {
+			year = strconv.Itoa(*entry.Year)
+		}
+		digest := sha256.Sum256([]byte(normalizeSearchText(entry.Title) + "\x1f" + author + "\x1f" + year))
+		key = hex.EncodeToString(digest[:8])
+		list.keys[key]++
+		if count := list.keys[key]; count > 1 {
+			key = fmt.Sprintf("%s-%d", key, count)
+		}
+	} else {
+		if _, exists := list.keys["id:"+key]; exists {
+			return fmt.Errorf("duplicate id %q", key)
+		}
+		list.keys["id:"+key] = 1
+	}
+
+	messageID := fmt.Sprintf("%d:%s", list.source.Source.ChannelID, key)
+	message := sourceMessage{MessageID: messageID}
+	if len(entry.Links) > 0 {
+		message.URL = entry.Links[0].URL
+	}
+	entry.EntryID = messageID + ":0"
+	entry.MessageID = messageID
+	entry.SourceMessageIDs = []string{messageID}
+	entry.AddedAt = addedAt.Format(time.RFC3339)
+	entry.Origin = "curated_list"
+	entry.Availability = "download_link"
+	for index := range entry.Links {
+		entry.Links[index].Role = "mirror"
+		if index == 0 {
+			entry.Links[index].Role = "primary"
+			entry.Links[index].Primary = true
+		}
+	}
+	list.source.Messages = append(list.source.Messages, message)
+	list.source.CatalogEntries = append(list.source.CatalogEntries, entry)
+	if addedAt.After(list.latest) {
+		list.latest = addedAt
+	}
+	return nil
+}
+
+func (list *curatedList) export() (sourceExport, error) {
+	if len(list.source.CatalogEntries) == 0 {
+		return sourceExport{}, errors.New("source export has no catalog entries")
+	}
+	list.source.ExportedAt = list.latest.Format(time.RFC3339)
+	recomputeSourceStats(&list.source)
+	return list.source, nil
+}
+
+// decodeCSVSource reads a curated list with a header row. Recognized
+// columns are title (required), added_at (required, RFC 3339 or
+// YYYY-MM-DD), id, author, year (2024 or 2020-2022), links, passwords and
+// notes. Links and passwords hold several values separated by whitespace.
+// An id keeps entry IDs stable when titles are edited.
+func decodeCSVSource(input io.Reader, channel string) (sourceExport, error) {
+	reader := csv.NewReader(input)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return sourceExport{}, fmt.Errorf("read csv header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for index, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "id", "title", "author", "year", "added_at", "links", "passwords", "notes":
+		default:
+			return sourceExport{}, fmt.Errorf("csv header: unknown column %q", name)
+		}
+		if _, exists := columns[name]; exists {
+			return sourceExport{}, fmt.Errorf("csv header: duplicate column %q", name)
+		}
+		columns[name] = index
+	}
+	for _, required := range []string{"title", "added_at"} {
+		if _, ok := columns[required]; !ok {
+			return sourceExport{}, fmt.Errorf("csv header: missing %s column", required)
+		}
+	}
+
+	list := newCuratedList(channel)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sourceExport{}, fmt.Errorf("read csv: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if index, ok := columns[name]; ok {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+
+		entry := sourceEntry{
+			Title:    field("title"),
+			RawBlock: strings.Join(record, "\t"),
+		}
+		if entry.Title == "" {
+			return sourceExport{}, fmt.Errorf("csv line %d: title is required", line)
+		}
+		if author := field("author"); author != "" {
+			entry.Credit.Author = &author
+		}
+		if entry.Year, entry.YearRange, err = parseCuratedYear(field("year")); err != nil {
+			return sourceExport{}, fmt.Errorf("csv line %d: %w", line, err)
+		}
+		addedAt, err := parseCuratedDate(field("added_at"))
+		if err != nil {
+			return sourceExport{}, fmt.Errorf("csv line %d: %w", line, err)
+		}
+		for _, raw := range strings.Fields(field("links")) {
+			entry.Links = append(entry.Links, newSourceLink(raw, "csv", "standalone_link"))
+		}
+		entry.Passwords = strings.Fields(field("passwords"))
+		if notes := field("notes"); notes != "" {
+			entry.Notes = &notes
+		}
+		if err := list.add(field("id"), entry, addedAt); err != nil {
+			return sourceExport{}, fmt.Errorf("csv line %d: %w", line, err)
+		}
+	}
+	return list.export()
+}
+
+// decodeMarkdownSource reads a curated list of bullets grouped under date
+// headings:
+//
+//	## 2024-05-01
+//	- [Author] Title (2024) https://files.example/course password: secret
+//	  - [mirror](https://mirror.example/course)
+//
+// Indented lines continue the bullet above them. Links may be bare URLs or
+// Markdown links; a bullet with no title of its own uses its first link
+// label. Other headings and text are ignored.
+func decodeMarkdownSource(input io.Reader, channel string) (sourceExport, error) {
+	list := newCuratedList(channel)
+	var addedAt time.Time
+	var item []string
+	itemLine := 0
+	flush := func() error {
+		if item == nil {
+			return nil
+		}
+		defer func() { item = nil }()
+		if addedAt.IsZero() {
+			return fmt.Errorf("markdown line %d: bullet before a date heading", itemLine)
+		}
+		entry, err := parseMarkdownItem(item)
+		if err != nil {
+			return fmt.Errorf("markdown line %d: %w", itemLine, err)
+		}
+		if err := list.add("", entry, addedAt); err != nil {
+			return fmt.Errorf("markdown line %d: %w", itemLine, err)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(input)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), " \t")
+		trimmed := strings.TrimSpace(line)
+		if item != nil && trimmed != "" && line != trimmed {
+			item = append(item, trimmed)
+			continue
+		}
+		if err := flush(); err != nil {
+			return sourceExport{}, err
+		}
+		if match := markdownHeadingPattern.FindStringSubmatch(trimmed); match != nil {
+			if date, err := parseCuratedDate(strings.TrimSpace(match[1])); err == nil {
+				addedAt = date
+			}
+			continue
+		}
+		if markdownBulletPattern.MatchString(line) {
+			item = []string{markdownBulletPattern.ReplaceAllString(line, "")}
+			itemLine = lineNumber
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sourceExport{}, fmt.Errorf("read markdown: %w", err)
+	}
+	if err := flush(); err != nil {
+		return sourceExport{}, err
+	}
+	return list.export()
+}
+
+func parseMarkdownItem(lines []string) (sourceEntry, error) {
+	entry := sourceEntry{RawBlock: strings.Join(lines, "\n")}
+	var firstLabel string
+	for index, line := range lines {
+		line = markdownBulletPattern.ReplaceAllString(line, "")
+		line = markdownLinkPattern.ReplaceAllStringFunc(line, func(match string) string {
+			parts := markdownLinkPattern.FindStringSubmatch(match)
+			link := newSourceLink(parts[2], "markdown", "standalone_link")
+			if label := strings.TrimSpace(parts[1]); label != "" {
+				link.Label = &label
+				if firstLabel == "" {
+					firstLabel = label
+				}
+			}
+			entry.Links = append(entry.Links, link)
+			return " "
+		})
+		line = curatedPasswordPattern.ReplaceAllStringFunc(line, func(match string) string {
+			entry.Passwords = append(entry.Passwords, curatedPasswordPattern.FindStringSubmatch(match)[1])
+			return " "
+		})
+		line = urlPattern.ReplaceAllStringFunc(line, func(match string) string {
+			raw := strings.TrimRight(match, ".,;:)>]}'\"")
+			entry.Links = append(entry.Links, newSourceLink(raw, "markdown", "standalone_link"))
+			return " "
+		})
+		if index == 0 {
+			heading := strings.Trim(collapseCourseHeadingWhitespace(line), " —–-|:,")
+			entry.Title, entry.Credit.Author, entry.Year, entry.YearRange = parseCourseHeading(heading)
+		}
+	}
+	if strings.TrimSpace(entry.Title) == "" {
+		entry.Title = firstLabel
+	}
+	if strings.TrimSpace(entry.Title) == "" {
+		return sourceEntry{}, errors.New("bullet has no title")
+	}
+	return entry, nil
+}
+
+func parseCuratedYear(value string) (*int, *yearRange, error) {
+	if value == "" {
+		return nil, nil, nil
+	}
+	if match := curatedYearPattern.FindStringSubmatch(value); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		return &year, nil, nil
+	}
+	if match := curatedYearRangePattern.FindStringSubmatch(value); match != nil {
+		from, _ := strconv.Atoi(match[1])
+		to, _ := strconv.Atoi(match[2])
+		return nil, &yearRange{From: from, To: to}, nil
+	}
+	return nil, nil, fmt.Errorf("invalid year %q", value)
+}
+
+func parseCuratedDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date.UTC(), nil
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid added_at %q", value)
+	}
+	return date, nil
+}
+
+// newSourceLink builds a sourceLink for a URL as written in a list or
+// message. Links without a scheme are read as https, as Telegram does.
+func newSourceLink(raw string, sources ...string) sourceLink {
+	raw = strings.TrimSpace(raw)
+	link := sourceLink{URL: raw, Kind: "external", Sources: sources}
+	if strings.HasPrefix(strings.ToLower(raw), "magnet:") {
+		link.Provider = "magnet"
+		link.Kind = "torrent"
+		return link
+	}
+	if !strings.Contains(raw, "://") {
+		link.URL = "https://" + raw
+		link.NormalizedFrom = raw
+	}
+	if parsed, err := url.Parse(link.URL); err == nil {
+		link.Host = strings.ToLower(parsed.Hostname())
+		link.Provider = strings.TrimPrefix(link.Host, "www.")
+	}
+	return link
+}
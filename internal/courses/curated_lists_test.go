@@ -0,0 +1,119 @@
+package courses
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const curatedCSVFixture = `id,title,author,year,added_at,links,passwords,notes
+go-1,Practical Go,Fixture Author,2024,2024-03-01,https://files.example.test/go files.example.test/go-mirror,secret,
+,Python backend,,2020-2022,2024-03-02T10:00:00Z,https://files.example.test/py,,Частично
+`
+
+const curatedMarkdownFixture = `# Curated courses
+
+## 2024-04-01
+- [Fixture Author] Practical Go (2024) https://files.example.test/go-v2 password: other
+  - [mirror](https://mirror.example.test/go)
+- [Курс по маркетингу](https://files.example.test/marketing)
+
+Some prose that is not a bullet.
+`
+
+func TestDecodeCSVSourceMapsColumnsToEntries(t *testing.T) {
+	source, err := decodeCSVSource(strings.NewReader(curatedCSVFixture), "curated")
+	if err != nil {
+		t.Fatalf("decode csv: %v", err)
+	}
+	channelID := syntheticChannelID("curated")
+	if source.Source.ChannelID != channelID || channelID >= 0 || source.ExportedAt != "2024-03-02T10:00:00Z" {
+		t.Fatalf("source = %+v, exported_at %q", source.Source, source.ExportedAt)
+	}
+	if len(source.CatalogEntries) != 2 || len(source.Messages) != 2 {
+		t.Fatalf("entries = %+v", source.CatalogEntries)
+	}
+
+	first := source.CatalogEntries[0]
+	if !strings.HasSuffix(first.EntryID, ":go-1:0") || first.Title != "Practical Go" || *first.Credit.Author != "Fixture Author" ||
+		*first.Year != 2024 || first.AddedAt != "2024-03-01T00:00:00Z" || !slices.Equal(first.Passwords, []string{"secret"}) {
+		t.Fatalf("first entry = %+v", first)
+	}
+	if len(first.Links) != 2 || !first.Links[0].Primary || first.Links[1].URL != "https://files.example.test/go-mirror" {
+		t.Fatalf("first entry links = %+v", first.Links)
+	}
+	if source.Messages[0].URL != "https://files.example.test/go" {
+		t.Fatalf("message url = %q", source.Messages[0].URL)
+	}
+
+	second := source.CatalogEntries[1]
+	if second.YearRange == nil || *second.YearRange != (yearRange{From: 2020, To: 2022}) || second.Notes == nil {
+		t.Fatalf("second entry = %+v", second)
+	}
+
+	again, err := decodeCSVSource(strings.NewReader(curatedCSVFixture), "curated")
+	if err != nil || again.CatalogEntries[1].EntryID != second.EntryID {
+		t.Fatalf("entry id is not stable: %v", err)
+	}
+}
+
+func TestDecodeCSVSourceRejectsInvalidRows(t *testing.T) {
+	tests := map[string]string{
+		"unknown column": "title,added_at,price\nGo,2024-01-01,10\n",
+		"missing date":   "title\nGo\n",
+		"invalid date":   "title,added_at\nGo,yesterday\n",
+		"invalid year":   "title,added_at,year\nGo,2024-01-01,soon\n",
+		"duplicate id":   "id,title,added_at\na,Go,2024-01-01\na,Rust,2024-01-01\n",
+	}
+	for name, payload := range tests {
+		if _, err := decodeCSVSource(strings.NewReader(payload), "curated"); err == nil {
+			t.Fatalf("%s: decode csv error = nil", name)
+		}
+	}
+}
+
+func TestDecodeMarkdownSourceParsesBulletsUnderDateHeadings(t *testing.T) {
+	source, err := decodeMarkdownSource(strings.NewReader(curatedMarkdownFixture), "curated")
+	if err != nil {
+		t.Fatalf("decode markdown: %v", err)
+	}
+	if len(source.CatalogEntries) != 2 {
+		t.Fatalf("entries = %+v", source.CatalogEntries)
+	}
+	first := source.CatalogEntries[0]
+	if first.Title != "Practical Go" || *first.Credit.Author != "Fixture Author" || *first.Year != 2024 ||
+		first.AddedAt != "2024-04-01T00:00:00Z" || !slices.Equal(first.Passwords, []string{"other"}) {
+		t.Fatalf("first entry = %+v", first)
+	}
+	if len(first.Links) != 2 || first.Links[1].Label == nil || *first.Links[1].Label != "mirror" {
+		t.Fatalf("first entry links = %+v", first.Links)
+	}
+	if second := source.CatalogEntries[1]; second.Title != "Курс по маркетингу" || len(second.Links) != 1 {
+		t.Fatalf("second entry = %+v", second)
+	}
+
+	if _, err := decodeMarkdownSource(strings.NewReader("- Go https://files.example.test/go\n"), "curated"); err == nil {
+		t.Fatal("decode markdown accepted a bullet without a date heading")
+	}
+}
+
+func TestBuildGzipDedupsCuratedListsSharingAChannel(t *testing.T) {
+	var output bytes.Buffer
+	stats, err := BuildGzipFromSources([]SourceInput{
+		{Reader: strings.NewReader(curatedCSVFixture), Name: "list.csv", Format: SourceFormatCSV, Channel: "curated"},
+		{Reader: strings.NewReader(curatedMarkdownFixture), Name: "list.md", Format: SourceFormatMarkdown, Channel: "curated"},
+	}, &output, "")
+	if err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	if stats.SourceEntries != 4 || stats.Entries != 3 {
+		t.Fatalf("stats = %+v, want the Go course from both lists merged", stats)
+	}
+	catalog := decodeBuiltCatalog(t, &output)
+	for _, entry := range catalog.Entries {
+		if entry.Title == "Practical Go" && (len(entry.Sources) != 2 || !slices.Contains(entry.Categories, "development")) {
+			t.Fatalf("merged entry = %+v", entry)
+		}
+	}
+}
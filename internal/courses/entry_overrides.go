@@ -0,0 +1,232 @@
+package courses
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const entryOverridesSchema = "entry-overrides/v1"
+
+const categorySourceOverride = "override"
+
+// EntryOverrides holds curator corrections to single catalog entries. Each
+// override is keyed by a source entry ID, which selects the course that entry
+// merged into, or by a course ID. Overrides apply in file order.
+type EntryOverrides struct {
+	records []entryOverrideRecord
+	// digest is the SHA-256 of the overrides file, part of a catalog's build
+	// fingerprint.
+	digest string
+}
+
+type entryOverridesFile struct {
+	SchemaVersion string            `json:"schema_version"`
+	Overrides     []json.RawMessage `json:"overrides"`
+}
+
+// entryOverrideRecord replaces the fields it sets. An empty author clears
+// the author.
+type entryOverrideRecord struct {
+	SourceEntryID string   `json:"source_entry_id"`
+	CourseID      string   `json:"course_id"`
+	Title         *string  `json:"title"`
+	Author        *string  `json:"author"`
+	Year          *int     `json:"year"`
+	Categories    []string `json:"categories"`
+	Formats       []string `json:"formats"`
+	Hidden        *bool    `json:"hidden"`
+	Reason        string   `json:"reason"`
+}
+
+func LoadEntryOverrides(r io.Reader) (*EntryOverrides, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read entry overrides: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("read entry overrides: invalid utf-8")
+	}
+	if err := rejectDuplicateTopLevelKeys(data); err != nil {
+		return nil, fmt.Errorf("decode entry overrides: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file entryOverridesFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode entry overrides: %w", err)
+	}
+	var extra json.RawMessage
+	if err := decoder.Decode(&extra); err != io.EOF {
+		if err == nil {
+			return nil, fmt.Errorf("decode entry overrides: multiple json values")
+		}
+		return nil, fmt.Errorf("decode entry overrides: %w", err)
+	}
+	if file.SchemaVersion != entryOverridesSchema {
+		return nil, fmt.Errorf("decode entry overrides: unsupported schema_version %q", file.SchemaVersion)
+	}
+	if file.Overrides == nil {
+		return nil, fmt.Errorf("decode entry overrides: overrides is required")
+	}
+
+	digest := sha256.Sum256(data)
+	overrides := &EntryOverrides{
+		records: make([]entryOverrideRecord, 0, len(file.Overrides)),
+		digest:  hex.EncodeToString(digest[:]),
+	}
+	keys := make(map[string]struct{}, len(file.Overrides))
+	for index, raw := range file.Overrides {
+		if err := rejectDuplicateTopLevelKeys(raw); err != nil {
+			return nil, fmt.Errorf("decode entry overrides: overrides[%d]: %w", index, err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		var record entryOverrideRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("decode entry overrides: overrides[%d]: %w", index, err)
+		}
+		if err := validateEntryOverride(record); err != nil {
+			return nil, fmt.Errorf("decode entry overrides: overrides[%d]: %w", index, err)
+		}
+		if _, exists := keys[record.key()]; exists {
+			return nil, fmt.Errorf("decode entry overrides: duplicate override for %q", record.key())
+		}
+		keys[record.key()] = struct{}{}
+		overrides.records = append(overrides.records, record)
+	}
+	return overrides, nil
+}
+
+func validateEntryOverride(record entryOverrideRecord) error {
+	switch {
+	case (record.SourceEntryID == "") == (record.CourseID == ""):
+		return fmt.Errorf("exactly one of source_entry_id and course_id is required")
+	case strings.TrimSpace(record.SourceEntryID) != record.SourceEntryID:
+		return fmt.Errorf("invalid source_entry_id %q", record.SourceEntryID)
+	case record.CourseID != "" && !courseIDPattern.MatchString(record.CourseID):
+		return fmt.Errorf("invalid course_id %q", record.CourseID)
+	case strings.TrimSpace(record.Reason) == "":
+		return fmt.Errorf("reason is required")
+	}
+	if record.Title == nil && record.Author == nil && record.Year == nil &&
+		record.Categories == nil && record.Formats == nil && record.Hidden == nil {
+		return fmt.Errorf("%s: no field is overridden", record.key())
+	}
+	if record.Title != nil && (*record.Title == "" || strings.TrimSpace(*record.Title) != *record.Title) {
+		return fmt.Errorf("%s: title must be non-empty and trimmed", record.key())
+	}
+	if record.Author != nil && strings.TrimSpace(*record.Author) != *record.Author {
+		return fmt.Errorf("%s: author must be trimmed", record.key())
+	}
+	if record.Year != nil && (*record.Year < 1000 || *record.Year > 9999) {
+		return fmt.Errorf("%s: invalid year %d", record.key(), *record.Year)
+	}
+	for field, ids := range map[string][]string{"categories": record.Categories, "formats": record.Formats} {
+		if ids == nil {
+			continue
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("%s: %s cannot be empty", record.key(), field)
+		}
+		for index, id := range ids {
+			if !categoryIDPattern.MatchString(id) || slices.Contains(ids[:index], id) {
+				return fmt.Errorf("%s: invalid or duplicate %s value %q", record.key(), field, id)
+			}
+		}
+	}
+	return nil
+}
+
+func (record entryOverrideRecord) key() string {
+	if record.CourseID != "" {
+		return record.CourseID
+	}
+	return record.SourceEntryID
+}
+
+func (overrides *EntryOverrides) Len() int {
+	if overrides == nil {
+		return 0
+	}
+	return len(overrides.records)
+}
+
+// apply applies the overrides to entries, marking the fields each one
+// replaced, and returns the keys of overrides that matched no entry.
+func (overrides *EntryOverrides) apply(entries []CatalogEntry, taxonomy *Taxonomy, formatRules *FormatRules) ([]string, error) {
+	if overrides == nil {
+		return nil, nil
+	}
+	indexes := make(map[string]int, len(entries))
+	for index, entry := range entries {
+		indexes[entry.ID] = index
+		for _, source := range entry.Sources {
+			indexes[source.EntryID] = index
+		}
+	}
+
+	var stale []string
+	for _, record := range overrides.records {
+		index, ok := indexes[record.key()]
+		if !ok {
+			stale = append(stale, record.key())
+			continue
+		}
+		entry := &entries[index]
+		var fields []string
+		if record.Title != nil {
+			if entry.Title != *record.Title && entry.TitleOriginal == nil {
+				original := entry.Title
+				entry.TitleOriginal = &original
+			}
+			entry.Title = *record.Title
+			fields = append(fields, "title")
+		}
+		if record.Author != nil {
+			entry.Author = cleanOptionalString(record.Author)
+			fields = append(fields, "author")
+		}
+		if record.Year != nil {
+			year := *record.Year
+			entry.Year = &year
+			fields = append(fields, "year")
+		}
+		if record.Categories != nil {
+			for _, category := range record.Categories {
+				if taxonomy.category(category).ID == "" {
+					return nil, fmt.Errorf("entry override %q: unknown category %q", record.key(), category)
+				}
+			}
+			entry.Categories = slices.Clone(record.Categories)
+			entry.PrimaryCategory = entry.Categories[0]
+			entry.CategorySource = categorySourceOverride
+			entry.CategoryConfidence = 0
+			fields = append(fields, "categories")
+		}
+		if record.Formats != nil {
+			for _, format := range record.Formats {
+				if _, ok := formatRules.format(format); !ok {
+					return nil, fmt.Errorf("entry override %q: unknown format %q", record.key(), format)
+				}
+			}
+			entry.Formats = slices.Clone(record.Formats)
+			entry.PrimaryFormat = entry.Formats[0]
+			entry.FormatSources = []string{categorySourceOverride}
+			fields = append(fields, "formats")
+		}
+		if record.Hidden != nil {
+			entry.Hidden = *record.Hidden
+			fields = append(fields, "hidden")
+		}
+		entry.Overrides = appendUnique(entry.Overrides, fields)
+	}
+	return stale, nil
+}
@@ -0,0 +1,128 @@
+package courses
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildGzipAppliesEntryOverrides(t *testing.T) {
+	source := validSource(t, sourceEntryWithIdentity("1:1:0", "1:1", "Python backend", "Fixture Author"))
+	source.Messages = append(source.Messages,
+		sourceMessage{MessageID: "1:2", TelegramMessageID: 2, URL: "https://messages.example.test/source/2"},
+	)
+	source.CatalogEntries = append(source.CatalogEntries,
+		sourceEntryWithIdentity("1:2:0", "1:2", "Нейтральная практика", "Second Author"),
+	)
+	setSourceCounts(&source)
+
+	var plain bytes.Buffer
+	if _, err := BuildGzip(sourceReader(t, source), &plain); err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	secondID := decodeBuiltCatalog(t, &plain).Entries[1].ID
+
+	overrides := entryOverridesForTest(t, entryOverridesTestPayload(fmt.Sprintf(`
+		{"source_entry_id": "1:1:0", "title": "Python для бэкенда", "hidden": true, "reason": "typo"},
+		{"course_id": %q, "author": "", "year": 2024, "categories": ["psychology_selfdev"], "formats": ["workshop", "course"], "reason": "curated"},
+		{"source_entry_id": "9:9:0", "title": "Удалённая запись", "reason": "gone"}
+	`, secondID)))
+
+	var output bytes.Buffer
+	stats, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, source)}},
+		&output,
+		BuildOptions{EntryOverrides: overrides},
+	)
+	if err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	if stats.OverriddenEntries != 2 || stats.StaleOverrides != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	catalog := decodeBuiltCatalog(t, &output)
+	if !slices.Equal(catalog.StaleOverrides, []string{"9:9:0"}) {
+		t.Fatalf("stale overrides = %v", catalog.StaleOverrides)
+	}
+
+	first, second := catalog.Entries[0], catalog.Entries[1]
+	if first.Title != "Python для бэкенда" || first.TitleOriginal == nil || *first.TitleOriginal != "Python backend" ||
+		!first.Hidden || !slices.Equal(first.Overrides, []string{"title", "hidden"}) || first.CategorySource != "keywords" {
+		t.Fatalf("first entry = %+v", first)
+	}
+	if second.Author != nil || second.Year == nil || *second.Year != 2024 ||
+		!slices.Equal(second.Categories, []string{"psychology_selfdev"}) || second.CategorySource != "override" ||
+		second.PrimaryFormat != "workshop" || !slices.Equal(second.FormatSources, []string{"override"}) ||
+		!slices.Equal(second.Overrides, []string{"author", "year", "categories", "formats"}) {
+		t.Fatalf("second entry = %+v", second)
+	}
+	for _, category := range catalog.Categories {
+		if category.ID == "psychology_selfdev" && category.Count != 1 {
+			t.Fatalf("psychology_selfdev count = %d, want the override counted", category.Count)
+		}
+	}
+	if catalog.BuildFingerprint == buildFingerprint(BuildOptions{}) {
+		t.Fatal("build fingerprint ignores the entry overrides")
+	}
+
+	previous := output.Bytes()
+	var incremental bytes.Buffer
+	if _, _, err := BuildGzipIncremental(
+		[]SourceInput{{Reader: sourceReader(t, source)}},
+		&incremental,
+		BuildOptions{EntryOverrides: overrides},
+		IncrementalOptions{Previous: bytes.NewReader(previous), Verify: true},
+	); err != nil {
+		t.Fatalf("incremental build with overrides: %v", err)
+	}
+
+	unknown := entryOverridesForTest(t, entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "categories": ["gardening"], "reason": "typo"}`))
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, source)}},
+		&bytes.Buffer{},
+		BuildOptions{EntryOverrides: unknown},
+	); err == nil || !strings.Contains(err.Error(), `unknown category "gardening"`) {
+		t.Fatalf("unknown category error = %v", err)
+	}
+}
+
+func TestLoadEntryOverridesRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]string{
+		"schema":         strings.Replace(entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "hidden": true, "reason": "x"}`), "/v1", "/v0", 1),
+		"missing list":   `{"schema_version": "entry-overrides/v1"}`,
+		"unknown field":  entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "hidden": true, "reason": "x", "links": []}`),
+		"no key":         entryOverridesTestPayload(`{"hidden": true, "reason": "x"}`),
+		"both keys":      entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "course_id": "course:00000000000000000000000000000000", "hidden": true, "reason": "x"}`),
+		"bad course id":  entryOverridesTestPayload(`{"course_id": "course:xyz", "hidden": true, "reason": "x"}`),
+		"no reason":      entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "hidden": true}`),
+		"no field":       entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "reason": "x"}`),
+		"empty title":    entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "title": "", "reason": "x"}`),
+		"untrimmed":      entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "author": " A", "reason": "x"}`),
+		"bad year":       entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "year": 24, "reason": "x"}`),
+		"empty formats":  entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "formats": [], "reason": "x"}`),
+		"duplicate id":   entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "categories": ["other", "other"], "reason": "x"}`),
+		"duplicate key":  entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "hidden": true, "reason": "x"}, {"source_entry_id": "1:1:0", "hidden": false, "reason": "y"}`),
+		"trailing value": entryOverridesTestPayload(`{"source_entry_id": "1:1:0", "hidden": true, "reason": "x"}`) + " {}",
+	}
+	for name, payload := range tests {
+		if _, err := LoadEntryOverrides(strings.NewReader(payload)); err == nil {
+			t.Fatalf("%s: LoadEntryOverrides() error = nil", name)
+		}
+	}
+}
+
+func entryOverridesForTest(t *testing.T, payload string) *EntryOverrides {
+	t.Helper()
+
+	overrides, err := LoadEntryOverrides(strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("load entry overrides: %v", err)
+	}
+	return overrides
+}
+
+func entryOverridesTestPayload(overrides string) string {
+	return fmt.Sprintf(`{"schema_version": "entry-overrides/v1", "overrides": [%s]}`, overrides)
+}
@@ -0,0 +1,112 @@
+package courses
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClassificationExplanation explains how one source entry was classified.
+// Categories and Formats are the entry's own classification; the course it
+// merged into may carry more, and CourseCategories also reflects author-topic
+// propagation. CourseID is empty when the entry did not reach the catalog.
+type ClassificationExplanation struct {
+	EntryID          string                  `json:"entry_id"`
+	CourseID         string                  `json:"course_id,omitempty"`
+	Title            string                  `json:"title"`
+	Author           *string                 `json:"author"`
+	Categories       []string                `json:"categories"`
+	Formats          []string                `json:"formats"`
+	FormatSources    []string                `json:"format_sources"`
+	CategoryMatches  []CategoryMatch         `json:"category_matches"`
+	FormatMatches    []FormatRuleMatch       `json:"format_matches"`
+	AuthorTopic      *AuthorTopicPropagation `json:"author_topic,omitempty"`
+	CourseCategories []string                `json:"course_categories,omitempty"`
+	CourseFormats    []string                `json:"course_formats,omitempty"`
+
+	// CategorySource and CategoryConfidence describe the course, as in
+	// CatalogEntry.
+	CategorySource     string  `json:"category_source,omitempty"`
+	CategoryConfidence float64 `json:"category_confidence,omitempty"`
+}
+
+// AuthorTopicPropagation records a course moved from "other" into Topic
+// because TopicCount of its author's Classified entries share that topic.
+type AuthorTopicPropagation struct {
+	Author     string `json:"author"`
+	Topic      string `json:"topic"`
+	TopicCount int    `json:"topic_count"`
+	Classified int    `json:"classified"`
+}
+
+// ExplainClassification builds the catalog and explains the classification
+// of every source entry whose entry ID, course ID or title matches query.
+// Titles match after search normalization, against either the source or the
+// display title.
+func ExplainClassification(inputs []SourceInput, query string, options BuildOptions) ([]ClassificationExplanation, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("explain query is required")
+	}
+	sources, err := decodeSourceInputs(inputs)
+	if err != nil {
+		return nil, err
+	}
+	source, err := mergeSourceExports(sources)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := buildCatalog(source, options, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	coursesByEntry := make(map[string]*CatalogEntry, len(source.CatalogEntries))
+	for index := range catalog.Entries {
+		for _, entrySource := range catalog.Entries[index].Sources {
+			coursesByEntry[entrySource.EntryID] = &catalog.Entries[index]
+		}
+	}
+	messages := make(map[string]sourceMessage, len(source.Messages))
+	for _, message := range source.Messages {
+		messages[message.MessageID] = message
+	}
+	taxonomy := options.taxonomy()
+	formatRules := options.formatRules()
+	queryTitle := normalizeSearchText(query)
+
+	var explanations []ClassificationExplanation
+	for _, entry := range source.CatalogEntries {
+		displayEntry, _ := displaySourceEntry(entry, options.TitleRules)
+		course := coursesByEntry[entry.EntryID]
+		if entry.EntryID != query && (course == nil || course.ID != query) &&
+			normalizeSearchText(displayEntry.Title) != queryTitle && normalizeSearchText(entry.Title) != queryTitle {
+			continue
+		}
+
+		message := messages[entry.MessageID]
+		explanation := ClassificationExplanation{
+			EntryID:         entry.EntryID,
+			Title:           displayEntry.Title,
+			Author:          cleanOptionalString(displayEntry.Credit.Author),
+			Categories:      taxonomy.classify(displayEntry),
+			CategoryMatches: taxonomy.explain(displayEntry),
+			FormatMatches:   formatRules.explain(displayEntry, message),
+		}
+		explanation.Formats, explanation.FormatSources = formatRules.classify(displayEntry, message)
+		if course != nil {
+			explanation.CourseID = course.ID
+			explanation.CourseCategories = course.Categories
+			explanation.CourseFormats = course.Formats
+			explanation.CategorySource = course.CategorySource
+			explanation.CategoryConfidence = course.CategoryConfidence
+			if propagation, ok := catalog.authorTopics[course.ID]; ok {
+				explanation.AuthorTopic = &propagation
+			}
+		}
+		explanations = append(explanations, explanation)
+	}
+	if len(explanations) == 0 {
+		return nil, fmt.Errorf("no source entry matches %q", query)
+	}
+	return explanations, nil
+}
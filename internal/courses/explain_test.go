@@ -0,0 +1,124 @@
+package courses
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExplainClassificationReportsMatchesAndAuthorTopic(t *testing.T) {
+	source := validSource(t, sourceEntryWithIdentity("1:1:0", "1:1", "Python backend", "Fixture Author"))
+	source.Messages = append(source.Messages,
+		sourceMessage{MessageID: "1:2", TelegramMessageID: 2, URL: "https://messages.example.test/source/2"},
+		sourceMessage{MessageID: "1:3", TelegramMessageID: 3, URL: "https://messages.example.test/source/3"},
+	)
+	source.CatalogEntries = []sourceEntry{
+		sourceEntryWithIdentity("1:1:0", "1:1", "Python backend курс", "Fixture Author"),
+		sourceEntryWithIdentity("1:2:0", "1:2", "Разработка на Go", "Fixture Author"),
+		sourceEntryWithIdentity("1:3:0", "1:3", "Нейтральная практика", "Fixture Author"),
+	}
+	setSourceCounts(&source)
+	inputs := func() []SourceInput {
+		return []SourceInput{{Reader: sourceReader(t, source)}}
+	}
+
+	explanations, err := ExplainClassification(inputs(), "1:1:0", BuildOptions{})
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if len(explanations) != 1 {
+		t.Fatalf("explanations = %+v", explanations)
+	}
+	explanation := explanations[0]
+	if !slices.Equal(explanation.Categories, []string{"development"}) || explanation.AuthorTopic != nil {
+		t.Fatalf("explanation = %+v", explanation)
+	}
+	if len(explanation.CategoryMatches) != 1 || !explanation.CategoryMatches[0].Applied ||
+		!slices.Contains(explanation.CategoryMatches[0].Keywords, "python") {
+		t.Fatalf("category matches = %+v", explanation.CategoryMatches)
+	}
+	if len(explanation.FormatMatches) != 1 || explanation.FormatMatches[0].Rule != "course" ||
+		!slices.Equal(explanation.FormatMatches[0].Matched, []string{"курс"}) ||
+		!slices.Equal(explanation.FormatSources, []string{"title:course"}) {
+		t.Fatalf("format matches = %+v, sources = %v", explanation.FormatMatches, explanation.FormatSources)
+	}
+
+	explanations, err = ExplainClassification(inputs(), "  нейтральная ПРАКТИКА ", BuildOptions{})
+	if err != nil {
+		t.Fatalf("explain by title: %v", err)
+	}
+	explanation = explanations[0]
+	if explanation.EntryID != "1:3:0" || !slices.Equal(explanation.Categories, []string{"other"}) ||
+		!slices.Equal(explanation.CourseCategories, []string{"development"}) {
+		t.Fatalf("explanation = %+v", explanation)
+	}
+	if explanation.AuthorTopic == nil || *explanation.AuthorTopic != (AuthorTopicPropagation{
+		Author: "fixture author", Topic: "development", TopicCount: 2, Classified: 2,
+	}) {
+		t.Fatalf("author topic = %+v", explanation.AuthorTopic)
+	}
+
+	byCourse, err := ExplainClassification(inputs(), explanation.CourseID, BuildOptions{})
+	if err != nil || len(byCourse) != 1 || byCourse[0].EntryID != "1:3:0" {
+		t.Fatalf("explain by course ID = %+v, %v", byCourse, err)
+	}
+	if _, err := ExplainClassification(inputs(), "missing", BuildOptions{}); err == nil ||
+		!strings.Contains(err.Error(), "no source entry matches") {
+		t.Fatalf("explain missing error = %v", err)
+	}
+}
+
+func TestExplainClassificationReportsVetoesAndOverrides(t *testing.T) {
+	taxonomy := taxonomyForTest(t, taxonomyTestPayload(`{
+		"id": "gardening",
+		"label": "Сад",
+		"keywords": ["огород"],
+		"stems": ["рассад"],
+		"blocked_matches": [{"sequences": [["рассада", "идей"]], "unless_keywords": ["теплица"]}]
+	}`))
+	rules := formatRulesForTest(t, formatRulesTestPayload(`{
+		"id": "podcast",
+		"label": "Подкаст",
+		"rules": [{"id": "podcast_title", "tokens": ["подкаст"], "unless_sequences": [["не", "подкаст"]]}]
+	}`))
+
+	tests := []struct {
+		title    string
+		blocked  []BlockedCategoryMatch
+		applied  bool
+		override string
+	}{
+		{title: "Рассада идей, не подкаст", blocked: []BlockedCategoryMatch{{Matched: []string{"рассада идей"}, Vetoed: true}}},
+		{
+			title:   "Рассада идей и теплица",
+			blocked: []BlockedCategoryMatch{{Matched: []string{"рассада идей"}, Unless: []string{"теплица"}}},
+			applied: true,
+		},
+		{title: "Огород: инструкция как скачать", override: "service"},
+	}
+	for _, test := range tests {
+		entry := validSourceEntry()
+		entry.Title = test.title
+		match := taxonomy.explain(entry)[0]
+		if match.Category != "gardening" || match.Applied != test.applied || match.OverriddenBy != test.override ||
+			len(match.Blocked) != len(test.blocked) {
+			t.Fatalf("explain(%q) = %+v", test.title, match)
+		}
+		for index, blocked := range test.blocked {
+			got := match.Blocked[index]
+			if !slices.Equal(got.Matched, blocked.Matched) || !slices.Equal(got.Unless, blocked.Unless) || got.Vetoed != blocked.Vetoed {
+				t.Fatalf("explain(%q) blocked = %+v, want %+v", test.title, got, blocked)
+			}
+		}
+		if applied := slices.Contains(taxonomy.classify(entry), "gardening"); applied != match.Applied {
+			t.Fatalf("classify(%q) applied gardening = %t, explain says %t", test.title, applied, match.Applied)
+		}
+	}
+
+	entry := validSourceEntry()
+	entry.Title = "Рассада идей, не подкаст"
+	matches := rules.explain(entry, sourceMessage{})
+	if len(matches) != 1 || matches[0].Fired || !slices.Equal(matches[0].Unless, []string{"не подкаст"}) {
+		t.Fatalf("format matches = %+v", matches)
+	}
+}
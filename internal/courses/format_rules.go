@@ -0,0 +1,312 @@
+package courses
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const formatRulesSchema = "course-format-rules/v1"
+
+// defaultFormatRulesData holds the formats used when BuildOptions has no
+// format rules.
+//
+//go:embed format_rules_default.json
+var defaultFormatRulesData []byte
+
+var defaultFormatRules = mustLoadDefaultFormatRules()
+
+// FormatRules holds the material formats entries are classified into, in
+// priority order: an entry's primary format is the first one that matches.
+// Entries no rule matches get the "unspecified" format.
+type FormatRules struct {
+	formats []formatDefinition
+	// digest is the SHA-256 of the rules file, part of a catalog's build
+	// fingerprint.
+	digest string
+}
+
+// formatRule matches on title tokens or on the attached document. A title
+// rule fires when one of its tokens or sequences occurs, one of WithTokens
+// also occurs if any are set, and none of UnlessSequences does.
+type formatRule struct {
+	ID              string
+	Tokens          []string
+	Sequences       [][]string
+	WithTokens      []string
+	UnlessSequences [][]string
+	MIMETypes       []string
+	Extensions      []string
+}
+
+type formatRulesFile struct {
+	SchemaVersion string             `json:"schema_version"`
+	Formats       []formatRuleFormat `json:"formats"`
+}
+
+type formatRuleFormat struct {
+	ID    string           `json:"id"`
+	Label string           `json:"label"`
+	Rules []formatRuleFile `json:"rules"`
+}
+
+type formatRuleFile struct {
+	ID              string     `json:"id"`
+	Tokens          []string   `json:"tokens"`
+	Sequences       [][]string `json:"sequences"`
+	WithTokens      []string   `json:"with_tokens"`
+	UnlessSequences [][]string `json:"unless_sequences"`
+	MIMETypes       []string   `json:"mime_types"`
+	Extensions      []string   `json:"extensions"`
+}
+
+func LoadFormatRules(r io.Reader) (*FormatRules, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read format rules: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("read format rules: invalid utf-8")
+	}
+	if err := rejectDuplicateTopLevelKeys(data); err != nil {
+		return nil, fmt.Errorf("decode format rules: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file formatRulesFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode format rules: %w", err)
+	}
+	var extra json.RawMessage
+	if err := decoder.Decode(&extra); err != io.EOF {
+		if err == nil {
+			return nil, fmt.Errorf("decode format rules: multiple json values")
+		}
+		return nil, fmt.Errorf("decode format rules: %w", err)
+	}
+	if file.SchemaVersion != formatRulesSchema {
+		return nil, fmt.Errorf("decode format rules: unsupported schema_version %q", file.SchemaVersion)
+	}
+	if len(file.Formats) == 0 {
+		return nil, fmt.Errorf("decode format rules: formats are required")
+	}
+
+	digest := sha256.Sum256(data)
+	rules := &FormatRules{
+		formats: make([]formatDefinition, 0, len(file.Formats)),
+		digest:  hex.EncodeToString(digest[:]),
+	}
+	formatIDs := make(map[string]struct{}, len(file.Formats))
+	ruleIDs := make(map[string]struct{})
+	for index, format := range file.Formats {
+		if !categoryIDPattern.MatchString(format.ID) {
+			return nil, fmt.Errorf("decode format rules: formats[%d]: invalid id %q", index, format.ID)
+		}
+		if _, exists := formatIDs[format.ID]; exists {
+			return nil, fmt.Errorf("decode format rules: duplicate format id %q", format.ID)
+		}
+		formatIDs[format.ID] = struct{}{}
+		if strings.TrimSpace(format.Label) == "" || strings.TrimSpace(format.Label) != format.Label {
+			return nil, fmt.Errorf("decode format rules: %s: label must be non-empty and trimmed", format.ID)
+		}
+		if format.Rules == nil {
+			return nil, fmt.Errorf("decode format rules: %s: rules are required", format.ID)
+		}
+		definition := formatDefinition{ID: format.ID, Label: format.Label}
+		for ruleIndex, ruleFile := range format.Rules {
+			rule, err := formatRuleFromFile(ruleFile)
+			if err != nil {
+				return nil, fmt.Errorf("decode format rules: %s: rules[%d]: %w", format.ID, ruleIndex, err)
+			}
+			if _, exists := ruleIDs[rule.ID]; exists {
+				return nil, fmt.Errorf("decode format rules: duplicate rule id %q", rule.ID)
+			}
+			ruleIDs[rule.ID] = struct{}{}
+			definition.Rules = append(definition.Rules, rule)
+		}
+		rules.formats = append(rules.formats, definition)
+	}
+	unspecified, ok := rules.format("unspecified")
+	if !ok {
+		return nil, fmt.Errorf("decode format rules: format \"unspecified\" is required")
+	}
+	if len(unspecified.Rules) != 0 {
+		return nil, fmt.Errorf("decode format rules: format \"unspecified\" cannot have rules")
+	}
+	return rules, nil
+}
+
+func formatRuleFromFile(file formatRuleFile) (formatRule, error) {
+	if !categoryIDPattern.MatchString(file.ID) {
+		return formatRule{}, fmt.Errorf("invalid id %q", file.ID)
+	}
+	titleRule := len(file.Tokens) != 0 || len(file.Sequences) != 0
+	documentRule := len(file.MIMETypes) != 0 || len(file.Extensions) != 0
+	if titleRule == documentRule {
+		return formatRule{}, fmt.Errorf("%s: needs either tokens or sequences, or mime_types or extensions", file.ID)
+	}
+	if documentRule && (len(file.WithTokens) != 0 || len(file.UnlessSequences) != 0) {
+		return formatRule{}, fmt.Errorf("%s: with_tokens and unless_sequences only apply to title rules", file.ID)
+	}
+	for _, tokens := range append(append([][]string{file.Tokens, file.WithTokens}, file.Sequences...), file.UnlessSequences...) {
+		for _, token := range tokens {
+			if err := validateTaxonomyToken(token); err != nil {
+				return formatRule{}, fmt.Errorf("%s: %w", file.ID, err)
+			}
+		}
+	}
+	for _, sequences := range [][][]string{file.Sequences, file.UnlessSequences} {
+		for _, sequence := range sequences {
+			if len(sequence) == 0 {
+				return formatRule{}, fmt.Errorf("%s: sequences cannot be empty", file.ID)
+			}
+		}
+	}
+	for _, mimeType := range file.MIMETypes {
+		if mimeType != strings.ToLower(strings.TrimSpace(mimeType)) || strings.Count(mimeType, "/") != 1 {
+			return formatRule{}, fmt.Errorf("%s: mime type %q must be a lowercase type/subtype", file.ID, mimeType)
+		}
+	}
+	for _, extension := range file.Extensions {
+		if extension != strings.ToLower(strings.TrimSpace(extension)) || path.Ext(extension) != extension || len(extension) < 2 {
+			return formatRule{}, fmt.Errorf("%s: extension %q must be a lowercase \".ext\"", file.ID, extension)
+		}
+	}
+	return formatRule(file), nil
+}
+
+func mustLoadDefaultFormatRules() *FormatRules {
+	rules, err := LoadFormatRules(bytes.NewReader(defaultFormatRulesData))
+	if err != nil {
+		panic(fmt.Sprintf("load default format rules: %v", err))
+	}
+	return rules
+}
+
+func (options BuildOptions) formatRules() *FormatRules {
+	if options.FormatRules == nil {
+		return defaultFormatRules
+	}
+	return options.FormatRules
+}
+
+func (rules *FormatRules) format(id string) (formatDefinition, bool) {
+	for _, definition := range rules.formats {
+		if definition.ID == id {
+			return definition, true
+		}
+	}
+	return formatDefinition{}, false
+}
+
+func (rules *FormatRules) formatIDs() []string {
+	ids := make([]string, 0, len(rules.formats))
+	for _, definition := range rules.formats {
+		ids = append(ids, definition.ID)
+	}
+	return ids
+}
+
+// FormatRuleMatch explains a format rule whose tokens, sequences or document
+// types matched an entry. It did not fire when With is required but empty,
+// or when Unless is non-empty.
+type FormatRuleMatch struct {
+	Format  string   `json:"format"`
+	Rule    string   `json:"rule"`
+	Signal  string   `json:"signal"`
+	Matched []string `json:"matched"`
+	With    []string `json:"with,omitempty"`
+	Unless  []string `json:"unless,omitempty"`
+	Fired   bool     `json:"fired"`
+}
+
+// classify returns the matching formats in priority order and, for each
+// rule that fired, a source naming the signal and the rule, such as
+// "title:practicum" or "document:torrent_mime_type".
+func (rules *FormatRules) classify(entry sourceEntry, message sourceMessage) ([]string, []string) {
+	formats := make([]string, 0, 2)
+	var sources []string
+	for _, match := range rules.explain(entry, message) {
+		if !match.Fired {
+			continue
+		}
+		if !slices.Contains(formats, match.Format) {
+			formats = append(formats, match.Format)
+		}
+		sources = append(sources, match.Signal+":"+match.Rule)
+	}
+	if len(formats) == 0 {
+		return []string{"unspecified"}, []string{"fallback"}
+	}
+	return formats, sources
+}
+
+// explain returns every rule that matched the entry, in priority order,
+// including the ones with_tokens or unless_sequences kept from firing.
+func (rules *FormatRules) explain(entry sourceEntry, message sourceMessage) []FormatRuleMatch {
+	tokens := strings.Fields(normalizeSearchText(entry.Title))
+	var matches []FormatRuleMatch
+	for _, definition := range rules.formats {
+		for _, rule := range definition.Rules {
+			if match, ok := rule.match(tokens, message); ok {
+				match.Format = definition.ID
+				matches = append(matches, match)
+			}
+		}
+	}
+	return matches
+}
+
+func (rule formatRule) match(tokens []string, message sourceMessage) (FormatRuleMatch, bool) {
+	match := FormatRuleMatch{Rule: rule.ID}
+	if len(rule.MIMETypes) != 0 || len(rule.Extensions) != 0 {
+		if message.Media.Type != "messageMediaDocument" {
+			return match, false
+		}
+		document := message.Media.Document
+		if mimeType := strings.ToLower(strings.TrimSpace(document.MIMEType)); slices.Contains(rule.MIMETypes, mimeType) {
+			match.Matched = append(match.Matched, mimeType)
+		}
+		if extension := strings.ToLower(path.Ext(document.FileName)); slices.Contains(rule.Extensions, extension) {
+			match.Matched = append(match.Matched, extension)
+		}
+		match.Signal = "document"
+		match.Fired = len(match.Matched) != 0
+		return match, match.Fired
+	}
+
+	match.Signal = "title"
+	match.Matched = matchedTokens(tokens, rule.Tokens, rule.Sequences)
+	if len(match.Matched) == 0 {
+		return match, false
+	}
+	match.With = matchedTokens(tokens, rule.WithTokens, nil)
+	match.Unless = matchedTokens(tokens, nil, rule.UnlessSequences)
+	match.Fired = (len(rule.WithTokens) == 0 || len(match.With) != 0) && len(match.Unless) == 0
+	return match, true
+}
+
+// matchedTokens lists the values and space-joined sequences found in tokens.
+func matchedTokens(tokens, values []string, sequences [][]string) []string {
+	var matched []string
+	for _, value := range values {
+		if slices.Contains(tokens, value) {
+			matched = append(matched, value)
+		}
+	}
+	for _, sequence := range sequences {
+		if containsTokenSequence(tokens, sequence...) {
+			matched = append(matched, strings.Join(sequence, " "))
+		}
+	}
+	return matched
+}
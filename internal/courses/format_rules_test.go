@@ -0,0 +1,145 @@
+package courses
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadFormatRulesClassifiesWithCustomRules(t *testing.T) {
+	rules := formatRulesForTest(t, formatRulesTestPayload(`{
+		"id": "podcast",
+		"label": "Подкаст",
+		"rules": [
+			{"id": "podcast_title", "tokens": ["подкаст"], "unless_sequences": [["не", "подкаст"]]},
+			{"id": "podcast_episode", "sequences": [["выпуск", "подкаста"]], "with_tokens": ["полный"]},
+			{"id": "podcast_file", "extensions": [".mp3"]}
+		]
+	}`))
+
+	audio := sourceMessage{}
+	audio.Media.Type = "messageMediaDocument"
+	audio.Media.Document.FileName = "Episode.MP3"
+	tests := []struct {
+		title   string
+		message sourceMessage
+		formats []string
+		sources []string
+	}{
+		{title: "Подкаст о дизайне", formats: []string{"podcast"}, sources: []string{"title:podcast_title"}},
+		{title: "Это не подкаст", formats: []string{"unspecified"}, sources: []string{"fallback"}},
+		{title: "Выпуск подкаста", formats: []string{"unspecified"}, sources: []string{"fallback"}},
+		{title: "Полный выпуск подкаста", formats: []string{"podcast"}, sources: []string{"title:podcast_episode"}},
+		{
+			title:   "Подкаст о дизайне",
+			message: audio,
+			formats: []string{"podcast"},
+			sources: []string{"title:podcast_title", "document:podcast_file"},
+		},
+	}
+	for _, test := range tests {
+		entry := validSourceEntry()
+		entry.Title = test.title
+		formats, sources := rules.classify(entry, test.message)
+		if !slices.Equal(formats, test.formats) || !slices.Equal(sources, test.sources) {
+			t.Fatalf("classify(%q) = %v/%v, want %v/%v", test.title, formats, sources, test.formats, test.sources)
+		}
+	}
+
+	entry := validSourceEntry()
+	entry.Title = "Подкаст о дизайне"
+	var output bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, validSource(t, entry))}},
+		&output,
+		BuildOptions{FormatRules: rules},
+	); err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	catalog := decodeBuiltCatalog(t, &output)
+	if len(catalog.Formats) != 2 || catalog.Formats[0] != (FormatMetadata{ID: "podcast", Label: "Подкаст", Count: 1}) {
+		t.Fatalf("formats = %+v", catalog.Formats)
+	}
+	if catalog.BuildFingerprint == buildFingerprint(BuildOptions{}) {
+		t.Fatal("build fingerprint ignores the format rules")
+	}
+}
+
+func TestDefaultFormatRulesMatchEmbeddedFile(t *testing.T) {
+	rules, err := LoadFormatRules(bytes.NewReader(defaultFormatRulesData))
+	if err != nil {
+		t.Fatalf("load default format rules: %v", err)
+	}
+	want := []string{
+		"course", "workshop", "live_recording", "marathon", "book_guide", "templates_assets",
+		"bundle_library", "club_membership", "audio", "torrent_attachment", "unspecified",
+	}
+	if !slices.Equal(rules.formatIDs(), want) || !slices.Equal(BuildOptions{}.formatRules().formatIDs(), want) {
+		t.Fatalf("default format IDs = %v", rules.formatIDs())
+	}
+	if torrent, _ := rules.format("torrent_attachment"); len(torrent.Rules) != 1 || len(torrent.Rules[0].MIMETypes) != 1 {
+		t.Fatalf("torrent_attachment rules = %+v", torrent.Rules)
+	}
+}
+
+func TestLoadFormatRulesRejectsInvalidFiles(t *testing.T) {
+	valid := `{"id": "podcast", "label": "Подкаст", "rules": [{"id": "podcast_title", "tokens": ["подкаст"]}]}`
+	tests := map[string]string{
+		"schema":        strings.Replace(formatRulesTestPayload(valid), "course-format-rules/v1", "course-format-rules/v0", 1),
+		"unknown field": formatRulesTestPayload(`{"id": "podcast", "label": "Подкаст", "rules": [], "priority": 1}`),
+		"duplicate format": formatRulesTestPayload(
+			valid + `, {"id": "podcast", "label": "Подкаст", "rules": []}`,
+		),
+		"duplicate rule": formatRulesTestPayload(
+			valid + `, {"id": "episode", "label": "Выпуск", "rules": [{"id": "podcast_title", "tokens": ["выпуск"]}]}`,
+		),
+		"missing rules":  formatRulesTestPayload(`{"id": "podcast", "label": "Подкаст"}`),
+		"empty rule":     formatRulesTestPayload(`{"id": "podcast", "label": "Подкаст", "rules": [{"id": "podcast_title"}]}`),
+		"unnormalized":   formatRulesTestPayload(`{"id": "podcast", "label": "Подкаст", "rules": [{"id": "podcast_title", "tokens": ["Подкаст"]}]}`),
+		"empty sequence": formatRulesTestPayload(`{"id": "podcast", "label": "Подкаст", "rules": [{"id": "podcast_title", "sequences": [[]]}]}`),
+		"mixed rule": formatRulesTestPayload(
+			`{"id": "podcast", "label": "Подкаст", "rules": [{"id": "podcast_title", "tokens": ["подкаст"], "extensions": [".mp3"]}]}`,
+		),
+		"document with tokens": formatRulesTestPayload(
+			`{"id": "podcast", "label": "Подкаст", "rules": [{"id": "podcast_file", "extensions": [".mp3"], "with_tokens": ["подкаст"]}]}`,
+		),
+		"uppercase mime": formatRulesTestPayload(
+			`{"id": "podcast", "label": "Подкаст", "rules": [{"id": "podcast_file", "mime_types": ["Audio/MPEG"]}]}`,
+		),
+		"extension without dot": formatRulesTestPayload(
+			`{"id": "podcast", "label": "Подкаст", "rules": [{"id": "podcast_file", "extensions": ["mp3"]}]}`,
+		),
+		"missing unspecified": `{"schema_version": "course-format-rules/v1", "formats": [` + valid + `]}`,
+		"unspecified with rules": `{"schema_version": "course-format-rules/v1", "formats": [
+			{"id": "unspecified", "label": "Тип не указан", "rules": [{"id": "anything", "tokens": ["все"]}]}
+		]}`,
+		"trailing value": formatRulesTestPayload(valid) + " {}",
+	}
+	for name, payload := range tests {
+		if _, err := LoadFormatRules(strings.NewReader(payload)); err == nil {
+			t.Fatalf("%s: LoadFormatRules() error = nil", name)
+		}
+	}
+}
+
+func formatRulesForTest(t *testing.T, payload string) *FormatRules {
+	t.Helper()
+
+	rules, err := LoadFormatRules(strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("load format rules: %v", err)
+	}
+	return rules
+}
+
+func formatRulesTestPayload(formats string) string {
+	return fmt.Sprintf(`{
+		"schema_version": "course-format-rules/v1",
+		"formats": [
+			%s,
+			{"id": "unspecified", "label": "Тип не указан", "rules": []}
+		]
+	}`, formats)
+}
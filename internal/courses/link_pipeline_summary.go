@@ -0,0 +1,96 @@
+package courses
+
+import (
+	"time"
+)
+
+const unknownLinkHost = "unknown"
+
+type LinkAuditSummary struct {
+	GeneratedAt string                            `json:"generated_at"`
+	Results     int                               `json:"results"`
+	ByState     map[LinkAuditState]int            `json:"by_state"`
+	ByHost      map[string]map[LinkAuditState]int `json:"by_host"`
+}
+
+type LinkEnrichmentFreshness struct {
+	GeneratedAt     string         `json:"generated_at"`
+	Entries         int            `json:"entries"`
+	ByState         map[string]int `json:"by_state"`
+	Fresh           int            `json:"fresh"`
+	Stale           int            `json:"stale"`
+	OldestCheckedAt string         `json:"oldest_checked_at,omitempty"`
+	NewestCheckedAt string         `json:"newest_checked_at,omitempty"`
+}
+
+// SummarizeLinkAudit counts audit results by state and by host. Reports keep
+// link hashes only, so hosts come from the catalog links with the same hash;
+// results for links no longer in the catalog are counted as "unknown".
+func SummarizeLinkAudit(report *LinkAuditReport, catalog Catalog) LinkAuditSummary {
+	summary := LinkAuditSummary{
+		ByState: make(map[LinkAuditState]int),
+		ByHost:  make(map[string]map[LinkAuditState]int),
+	}
+	if report == nil {
+		return summary
+	}
+	hosts := make(map[string]string)
+	for _, entry := range catalog.Entries {
+		for _, link := range entry.Links {
+			if hash, err := linkHash(link.URL); err == nil && link.Host != "" {
+				hosts[hash] = link.Host
+			}
+		}
+	}
+
+	summary.GeneratedAt = report.GeneratedAt
+	summary.Results = len(report.Results)
+	for _, result := range report.Results {
+		summary.ByState[result.State]++
+		host, ok := hosts[result.SHA256]
+		if !ok {
+			host = unknownLinkHost
+		}
+		if summary.ByHost[host] == nil {
+			summary.ByHost[host] = make(map[LinkAuditState]int)
+		}
+		summary.ByHost[host][result.State]++
+	}
+	return summary
+}
+
+// Freshness reports how many cache entries were checked within staleAfter of
+// now. A zero staleAfter counts every entry as stale, as IsFreshURL does.
+func (cache *LinkEnrichmentCache) Freshness(now time.Time, staleAfter time.Duration) LinkEnrichmentFreshness {
+	freshness := LinkEnrichmentFreshness{ByState: make(map[string]int)}
+	if cache == nil {
+		return freshness
+	}
+	freshness.GeneratedAt = cache.GeneratedAt
+	freshness.Entries = len(cache.Entries)
+	var oldest, newest time.Time
+	for _, entry := range cache.Entries {
+		freshness.ByState[entry.State]++
+		checkedAt, err := time.Parse(time.RFC3339, entry.CheckedAt)
+		if err != nil {
+			freshness.Stale++
+			continue
+		}
+		if staleAfter > 0 && now.Before(checkedAt.Add(staleAfter)) {
+			freshness.Fresh++
+		} else {
+			freshness.Stale++
+		}
+		if oldest.IsZero() || checkedAt.Before(oldest) {
+			oldest = checkedAt
+		}
+		if checkedAt.After(newest) {
+			newest = checkedAt
+		}
+	}
+	if !oldest.IsZero() {
+		freshness.OldestCheckedAt = oldest.UTC().Format(time.RFC3339)
+		freshness.NewestCheckedAt = newest.UTC().Format(time.RFC3339)
+	}
+	return freshness
+}
@@ -0,0 +1,63 @@
+package courses
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeLinkAuditGroupsByStateAndHost(t *testing.T) {
+	live, err := linkHash("https://files.example/a")
+	if err != nil {
+		t.Fatalf("linkHash() error = %v", err)
+	}
+	expired, err := linkHash("https://files.example/b")
+	if err != nil {
+		t.Fatalf("linkHash() error = %v", err)
+	}
+	catalog := Catalog{Entries: []CatalogEntry{{Links: []CatalogLink{
+		{URL: "https://files.example/a", Host: "files.example"},
+		{URL: "https://files.example/b", Host: "files.example"},
+	}}}}
+	report := &LinkAuditReport{
+		GeneratedAt: "2026-07-26T00:00:00Z",
+		Results: []LinkAuditResult{
+			{SHA256: live, State: LinkAuditStateLive},
+			{SHA256: expired, State: LinkAuditStateExpired},
+			{SHA256: "gone", State: LinkAuditStateExpired},
+		},
+	}
+
+	summary := SummarizeLinkAudit(report, catalog)
+	if summary.Results != 3 || summary.ByState[LinkAuditStateExpired] != 2 || summary.ByState[LinkAuditStateLive] != 1 {
+		t.Fatalf("summary by state = %+v", summary)
+	}
+	if got := summary.ByHost["files.example"]; got[LinkAuditStateLive] != 1 || got[LinkAuditStateExpired] != 1 {
+		t.Fatalf("files.example = %v", got)
+	}
+	if got := summary.ByHost[unknownLinkHost][LinkAuditStateExpired]; got != 1 {
+		t.Fatalf("unknown host expired = %d, want 1", got)
+	}
+}
+
+func TestLinkEnrichmentCacheFreshness(t *testing.T) {
+	now := time.Date(2026, 7, 26, 0, 0, 0, 0, time.UTC)
+	cache := &LinkEnrichmentCache{
+		GeneratedAt: "2026-07-25T00:00:00Z",
+		Entries: []LinkEnrichmentEntry{
+			{State: "extracted", CheckedAt: "2026-07-25T00:00:00Z"},
+			{State: "extracted", CheckedAt: "2026-06-01T00:00:00Z"},
+			{State: "not_found", CheckedAt: "invalid"},
+		},
+	}
+
+	freshness := cache.Freshness(now, 7*24*time.Hour)
+	if freshness.Entries != 3 || freshness.Fresh != 1 || freshness.Stale != 2 || freshness.ByState["extracted"] != 2 {
+		t.Fatalf("freshness = %+v", freshness)
+	}
+	if freshness.OldestCheckedAt != "2026-06-01T00:00:00Z" || freshness.NewestCheckedAt != "2026-07-25T00:00:00Z" {
+		t.Fatalf("checked range = %s..%s", freshness.OldestCheckedAt, freshness.NewestCheckedAt)
+	}
+	if stale := cache.Freshness(now, 0); stale.Fresh != 0 {
+		t.Fatalf("zero staleAfter fresh = %d, want 0", stale.Fresh)
+	}
+}
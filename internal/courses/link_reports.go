@@ -0,0 +1,339 @@
+package courses
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	linkReportsSchema     = "link-reports/v1"
+	maxLinkReportsBytes   = 16 << 20
+	maxPendingLinkReports = 10_000
+)
+
+var (
+	linkReportIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+	courseIDPattern     = regexp.MustCompile(`^course:[0-9a-f]{32}$`)
+
+	ErrLinkReportNotFound   = errors.New("link report not found")
+	ErrLinkReportResolved   = errors.New("link report is already resolved")
+	ErrLinkReportsQueueFull = errors.New("link report queue is full")
+)
+
+type LinkReportReason string
+
+const (
+	LinkReportReasonDead         LinkReportReason = "dead"
+	LinkReportReasonWrongContent LinkReportReason = "wrong_content"
+	LinkReportReasonSpam         LinkReportReason = "spam"
+)
+
+type LinkReportStatus string
+
+const (
+	LinkReportStatusPending   LinkReportStatus = "pending"
+	LinkReportStatusApproved  LinkReportStatus = "approved"
+	LinkReportStatusDismissed LinkReportStatus = "dismissed"
+)
+
+type LinkReportResolution string
+
+const (
+	LinkReportResolutionNone        LinkReportResolution = ""
+	LinkReportResolutionTombstone   LinkReportResolution = "tombstone"
+	LinkReportResolutionSuppression LinkReportResolution = "suppression"
+)
+
+type LinkReports struct {
+	records map[string]LinkReport
+}
+
+type LinkReport struct {
+	ID              string               `json:"id"`
+	CourseID        string               `json:"course_id"`
+	SHA256          string               `json:"sha256"`
+	Reason          LinkReportReason     `json:"reason"`
+	Status          LinkReportStatus     `json:"status"`
+	Reports         int                  `json:"reports"`
+	FirstReportedAt string               `json:"first_reported_at"`
+	LastReportedAt  string               `json:"last_reported_at"`
+	Resolution      LinkReportResolution `json:"resolution,omitempty"`
+	ResolvedAt      string               `json:"resolved_at,omitempty"`
+}
+
+type linkReportsFile struct {
+	SchemaVersion           string            `json:"schema_version"`
+	CanonicalizationVersion int               `json:"canonicalization_version"`
+	Reports                 []json.RawMessage `json:"reports"`
+}
+
+func NewLinkReports() *LinkReports {
+	return &LinkReports{records: make(map[string]LinkReport)}
+}
+
+func LoadLinkReports(r io.Reader) (*LinkReports, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxLinkReportsBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read link reports: %w", err)
+	}
+	if len(data) > maxLinkReportsBytes {
+		return nil, errors.New("read link reports: file exceeds size limit")
+	}
+	if !utf8.Valid(data) {
+		return nil, errors.New("read link reports: invalid utf-8")
+	}
+	if err := rejectDuplicateTopLevelKeys(data); err != nil {
+		return nil, fmt.Errorf("decode link reports: %w", err)
+	}
+
+	var file linkReportsFile
+	if err := decodeSingleJSONValue(data, &file); err != nil {
+		return nil, fmt.Errorf("decode link reports: %w", err)
+	}
+	if file.SchemaVersion != linkReportsSchema {
+		return nil, fmt.Errorf("decode link reports: unsupported schema_version %q", file.SchemaVersion)
+	}
+	if file.CanonicalizationVersion != 1 {
+		return nil, fmt.Errorf("decode link reports: unsupported canonicalization_version %d", file.CanonicalizationVersion)
+	}
+	if file.Reports == nil {
+		return nil, errors.New("decode link reports: reports is required")
+	}
+
+	reports := NewLinkReports()
+	for index, raw := range file.Reports {
+		if err := rejectDuplicateTopLevelKeys(raw); err != nil {
+			return nil, fmt.Errorf("decode link reports: reports[%d]: %w", index, err)
+		}
+		var record LinkReport
+		if err := decodeSingleJSONValue(raw, &record); err != nil {
+			return nil, fmt.Errorf("decode link reports: reports[%d]: %w", index, err)
+		}
+		if err := validateLinkReport(record); err != nil {
+			return nil, fmt.Errorf("decode link reports: reports[%d]: %w", index, err)
+		}
+		if _, exists := reports.records[record.ID]; exists {
+			return nil, fmt.Errorf("decode link reports: duplicate id %q", record.ID)
+		}
+		reports.records[record.ID] = record
+	}
+	return reports, nil
+}
+
+// Submit queues a user report, folding repeated reports of the same link,
+// course and reason into the pending record instead of growing the queue.
+func (r *LinkReports) Submit(courseID, rawURL string, reason LinkReportReason, now time.Time) (LinkReport, error) {
+	if r.records == nil {
+		r.records = make(map[string]LinkReport)
+	}
+	if !courseIDPattern.MatchString(courseID) {
+		return LinkReport{}, errors.New("invalid course id")
+	}
+	if !validLinkReportReason(reason) {
+		return LinkReport{}, fmt.Errorf("invalid reason %q", reason)
+	}
+	hash, err := linkHash(rawURL)
+	if err != nil {
+		return LinkReport{}, fmt.Errorf("invalid link: %w", err)
+	}
+
+	timestamp := now.UTC().Format(time.RFC3339)
+	pending := 0
+	for id, record := range r.records {
+		if record.Status != LinkReportStatusPending {
+			continue
+		}
+		if record.CourseID == courseID && record.SHA256 == hash && record.Reason == reason {
+			record.Reports++
+			record.LastReportedAt = timestamp
+			r.records[id] = record
+			return record, nil
+		}
+		pending++
+	}
+	if pending >= maxPendingLinkReports {
+		return LinkReport{}, ErrLinkReportsQueueFull
+	}
+
+	record := LinkReport{
+		ID:              linkReportID(courseID, hash, reason, timestamp),
+		CourseID:        courseID,
+		SHA256:          hash,
+		Reason:          reason,
+		Status:          LinkReportStatusPending,
+		Reports:         1,
+		FirstReportedAt: timestamp,
+		LastReportedAt:  timestamp,
+	}
+	if _, exists := r.records[record.ID]; exists {
+		return LinkReport{}, fmt.Errorf("link report id collision for %q", record.ID)
+	}
+	r.records[record.ID] = record
+	return record, nil
+}
+
+func (r *LinkReports) Get(id string) (LinkReport, bool) {
+	if r == nil {
+		return LinkReport{}, false
+	}
+	record, ok := r.records[id]
+	return record, ok
+}
+
+func (r *LinkReports) Pending() []LinkReport {
+	if r == nil {
+		return nil
+	}
+	pending := make([]LinkReport, 0)
+	for _, record := range r.records {
+		if record.Status == LinkReportStatusPending {
+			pending = append(pending, record)
+		}
+	}
+	sortLinkReports(pending)
+	return pending
+}
+
+func (r *LinkReports) Approve(id string, resolution LinkReportResolution, now time.Time) (LinkReport, error) {
+	if resolution != LinkReportResolutionTombstone && resolution != LinkReportResolutionSuppression {
+		return LinkReport{}, fmt.Errorf("invalid resolution %q", resolution)
+	}
+	return r.resolve(id, LinkReportStatusApproved, resolution, now)
+}
+
+func (r *LinkReports) Dismiss(id string, now time.Time) (LinkReport, error) {
+	return r.resolve(id, LinkReportStatusDismissed, LinkReportResolutionNone, now)
+}
+
+func (r *LinkReports) resolve(
+	id string,
+	status LinkReportStatus,
+	resolution LinkReportResolution,
+	now time.Time,
+) (LinkReport, error) {
+	record, ok := r.Get(id)
+	if !ok {
+		return LinkReport{}, ErrLinkReportNotFound
+	}
+	if record.Status != LinkReportStatusPending {
+		return LinkReport{}, ErrLinkReportResolved
+	}
+	record.Status = status
+	record.Resolution = resolution
+	record.ResolvedAt = now.UTC().Format(time.RFC3339)
+	r.records[id] = record
+	return record, nil
+}
+
+func (r LinkReports) MarshalJSON() ([]byte, error) {
+	records := make([]LinkReport, 0, len(r.records))
+	for _, record := range r.records {
+		records = append(records, record)
+	}
+	sortLinkReports(records)
+	return json.Marshal(struct {
+		SchemaVersion           string       `json:"schema_version"`
+		CanonicalizationVersion int          `json:"canonicalization_version"`
+		Reports                 []LinkReport `json:"reports"`
+	}{
+		SchemaVersion:           linkReportsSchema,
+		CanonicalizationVersion: 1,
+		Reports:                 records,
+	})
+}
+
+func (report LinkReport) MatchesURL(rawURL string) bool {
+	hash, err := linkHash(rawURL)
+	return err == nil && hash == report.SHA256
+}
+
+// CourseSourceEntryIDs returns the source entry IDs merged into a catalog
+// course, which is the key space occurrence-specific suppressions use.
+func CourseSourceEntryIDs(catalog Catalog, courseID string) []string {
+	for _, entry := range catalog.Entries {
+		if entry.ID != courseID {
+			continue
+		}
+		ids := make([]string, 0, len(entry.Sources))
+		for _, source := range entry.Sources {
+			ids = append(ids, source.EntryID)
+		}
+		return uniqueStrings(ids)
+	}
+	return nil
+}
+
+func validateLinkReport(record LinkReport) error {
+	if !linkReportIDPattern.MatchString(record.ID) {
+		return errors.New("invalid id")
+	}
+	if !courseIDPattern.MatchString(record.CourseID) {
+		return errors.New("invalid course_id")
+	}
+	if !linkTombstoneHashPattern.MatchString(record.SHA256) {
+		return errors.New("invalid sha256")
+	}
+	if !validLinkReportReason(record.Reason) {
+		return fmt.Errorf("invalid reason %q", record.Reason)
+	}
+	if record.Reports < 1 {
+		return errors.New("reports must be positive")
+	}
+	for _, timestamp := range []string{record.FirstReportedAt, record.LastReportedAt} {
+		if _, err := time.Parse(time.RFC3339, timestamp); err != nil {
+			return fmt.Errorf("invalid report timestamp %q: %w", timestamp, err)
+		}
+	}
+	switch record.Status {
+	case LinkReportStatusPending:
+		if record.Resolution != LinkReportResolutionNone || record.ResolvedAt != "" {
+			return errors.New("pending report must not be resolved")
+		}
+		return nil
+	case LinkReportStatusApproved:
+		if record.Resolution != LinkReportResolutionTombstone && record.Resolution != LinkReportResolutionSuppression {
+			return fmt.Errorf("invalid resolution %q", record.Resolution)
+		}
+	case LinkReportStatusDismissed:
+		if record.Resolution != LinkReportResolutionNone {
+			return errors.New("dismissed report must not have a resolution")
+		}
+	default:
+		return fmt.Errorf("invalid status %q", record.Status)
+	}
+	if _, err := time.Parse(time.RFC3339, record.ResolvedAt); err != nil {
+		return fmt.Errorf("invalid resolved_at %q: %w", record.ResolvedAt, err)
+	}
+	return nil
+}
+
+func validLinkReportReason(reason LinkReportReason) bool {
+	switch reason {
+	case LinkReportReasonDead, LinkReportReasonWrongContent, LinkReportReasonSpam:
+		return true
+	default:
+		return false
+	}
+}
+
+func linkReportID(courseID, hash string, reason LinkReportReason, reportedAt string) string {
+	digest := sha256.Sum256([]byte(courseID + "\x1f" + hash + "\x1f" + string(reason) + "\x1f" + reportedAt))
+	return hex.EncodeToString(digest[:8])
+}
+
+func sortLinkReports(records []LinkReport) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].FirstReportedAt != records[j].FirstReportedAt {
+			return records[i].FirstReportedAt < records[j].FirstReportedAt
+		}
+		return records[i].ID < records[j].ID
+	})
+}
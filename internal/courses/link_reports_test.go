@@ -0,0 +1,195 @@
+package courses
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testReportCourseID = "course:0123456789abcdef0123456789abcdef"
+
+func TestLinkReportsSubmitFoldsRepeatedPendingReports(t *testing.T) {
+	reports := NewLinkReports()
+	first := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+
+	record, err := reports.Submit(testReportCourseID, "https://example.test/course", LinkReportReasonDead, first)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	repeated, err := reports.Submit(testReportCourseID, "HTTPS://Example.Test/course#part", LinkReportReasonDead, first.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Submit() repeated error = %v", err)
+	}
+	if repeated.ID != record.ID || repeated.Reports != 2 || repeated.LastReportedAt != "2026-07-26T13:00:00Z" {
+		t.Fatalf("repeated report = %#v, want folded into %q", repeated, record.ID)
+	}
+	if _, err := reports.Submit(testReportCourseID, "https://example.test/course", LinkReportReasonSpam, first); err != nil {
+		t.Fatalf("Submit() other reason error = %v", err)
+	}
+	if pending := reports.Pending(); len(pending) != 2 {
+		t.Fatalf("Pending() = %d reports, want 2", len(pending))
+	}
+	if !record.MatchesURL("https://example.test/course") || record.SHA256 != hashForTest(t, "https://example.test/course") {
+		t.Fatalf("report hash = %q, want canonical link hash", record.SHA256)
+	}
+}
+
+func TestLinkReportsSubmitRejectsInvalidInput(t *testing.T) {
+	now := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		courseID string
+		url      string
+		reason   LinkReportReason
+	}{
+		{"invalid course id", "course:bad", "https://example.test/course", LinkReportReasonDead},
+		{"invalid reason", testReportCourseID, "https://example.test/course", "broken"},
+		{"invalid link", testReportCourseID, "not a link", LinkReportReasonDead},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewLinkReports().Submit(test.courseID, test.url, test.reason, now); err == nil {
+				t.Fatal("Submit() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestLinkReportsApproveFeedsTombstonesAndSuppressions(t *testing.T) {
+	now := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+	reports := NewLinkReports()
+	dead, err := reports.Submit(testReportCourseID, "https://example.test/dead", LinkReportReasonDead, now)
+	if err != nil {
+		t.Fatalf("Submit() dead error = %v", err)
+	}
+	wrong, err := reports.Submit(testReportCourseID, "https://example.test/wrong", LinkReportReasonWrongContent, now)
+	if err != nil {
+		t.Fatalf("Submit() wrong error = %v", err)
+	}
+
+	approvedDead, err := reports.Approve(dead.ID, LinkReportResolutionTombstone, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Approve() tombstone error = %v", err)
+	}
+	tombstones := NewLinkTombstones()
+	if !tombstones.AddLinkReport(approvedDead, now.Add(time.Hour)) {
+		t.Fatal("AddLinkReport() = false, want tombstone added")
+	}
+	if !tombstones.ContainsURL("https://example.test/dead") {
+		t.Fatal("tombstones do not contain approved report link")
+	}
+	if tombstones.AddLinkReport(approvedDead, now.Add(time.Hour)) {
+		t.Fatal("AddLinkReport() duplicate = true, want false")
+	}
+
+	approvedWrong, err := reports.Approve(wrong.ID, LinkReportResolutionSuppression, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Approve() suppression error = %v", err)
+	}
+	if tombstones.AddLinkReport(approvedWrong, now) {
+		t.Fatal("AddLinkReport() accepted suppression resolution as tombstone")
+	}
+	suppressions := NewLinkSuppressions()
+	if added := suppressions.AddLinkReport(approvedWrong, []string{"entry:a", "entry:b"}, now); added != 2 {
+		t.Fatalf("AddLinkReport() suppressions added = %d, want 2", added)
+	}
+	if !suppressions.ContainsURL("entry:b", "https://example.test/wrong") || suppressions.ContainsURL("entry:c", "https://example.test/wrong") {
+		t.Fatal("suppressions do not match approved report occurrences")
+	}
+
+	if _, err := reports.Dismiss(dead.ID, now); !errors.Is(err, ErrLinkReportResolved) {
+		t.Fatalf("Dismiss() resolved error = %v, want ErrLinkReportResolved", err)
+	}
+	if _, err := reports.Dismiss("0000000000000000", now); !errors.Is(err, ErrLinkReportNotFound) {
+		t.Fatalf("Dismiss() missing error = %v, want ErrLinkReportNotFound", err)
+	}
+	if len(reports.Pending()) != 0 {
+		t.Fatal("Pending() not empty after approvals")
+	}
+
+	data, err := json.Marshal(tombstones)
+	if err != nil {
+		t.Fatalf("json.Marshal(tombstones) error = %v", err)
+	}
+	if _, err := LoadLinkTombstones(strings.NewReader(string(data))); err != nil {
+		t.Fatalf("LoadLinkTombstones() round trip error = %v", err)
+	}
+	data, err = json.Marshal(suppressions)
+	if err != nil {
+		t.Fatalf("json.Marshal(suppressions) error = %v", err)
+	}
+	if _, err := LoadLinkSuppressions(strings.NewReader(string(data))); err != nil {
+		t.Fatalf("LoadLinkSuppressions() round trip error = %v", err)
+	}
+}
+
+func TestLoadLinkReportsRoundTrip(t *testing.T) {
+	now := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+	reports := NewLinkReports()
+	pending, err := reports.Submit(testReportCourseID, "https://example.test/pending", LinkReportReasonSpam, now)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	dismissed, err := reports.Submit(testReportCourseID, "https://example.test/dismissed", LinkReportReasonDead, now)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if _, err := reports.Dismiss(dismissed.ID, now); err != nil {
+		t.Fatalf("Dismiss() error = %v", err)
+	}
+
+	data, err := json.Marshal(reports)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if strings.Contains(string(data), "example.test") {
+		t.Fatalf("marshaled reports leak raw URLs: %s", data)
+	}
+	loaded, err := LoadLinkReports(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("LoadLinkReports() error = %v", err)
+	}
+	if got, ok := loaded.Get(pending.ID); !ok || got != pending {
+		t.Fatalf("Get(%q) = %#v, %v; want %#v", pending.ID, got, ok, pending)
+	}
+	if got, _ := loaded.Get(dismissed.ID); got.Status != LinkReportStatusDismissed || got.ResolvedAt == "" {
+		t.Fatalf("dismissed report = %#v", got)
+	}
+}
+
+func TestLoadLinkReportsRejectsInvalidFiles(t *testing.T) {
+	hash := hashForTest(t, "https://example.test/course")
+	record := func(fields string) string {
+		return `{"schema_version":"link-reports/v1","canonicalization_version":1,"reports":[{` + fields + `}]}`
+	}
+	valid := `"id":"0123456789abcdef","course_id":"` + testReportCourseID + `","sha256":"` + hash + `","reason":"dead","reports":1,"first_reported_at":"2026-07-26T00:00:00Z","last_reported_at":"2026-07-26T00:00:00Z"`
+	if _, err := LoadLinkReports(strings.NewReader(record(valid + `,"status":"pending"`))); err != nil {
+		t.Fatalf("LoadLinkReports() valid error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"wrong schema", `{"schema_version":"bad","canonicalization_version":1,"reports":[]}`},
+		{"missing reports", `{"schema_version":"link-reports/v1","canonicalization_version":1}`},
+		{"unknown field", `{"schema_version":"link-reports/v1","canonicalization_version":1,"reports":[],"extra":true}`},
+		{"raw url field", record(valid + `,"status":"pending","url":"https://example.test/course"`)},
+		{"unknown status", record(valid + `,"status":"open"`)},
+		{"pending with resolution", record(valid + `,"status":"pending","resolution":"tombstone"`)},
+		{"approved without resolution", record(valid + `,"status":"approved","resolved_at":"2026-07-26T00:00:00Z"`)},
+		{"dismissed without resolved_at", record(valid + `,"status":"dismissed"`)},
+		{"duplicate record key", record(valid + `,"status":"pending","status":"pending"`)},
+		{"zero reports", record(strings.Replace(valid, `"reports":1`, `"reports":0`, 1) + `,"status":"pending"`)},
+		{"invalid course id", record(strings.Replace(valid, testReportCourseID, "course:x", 1) + `,"status":"pending"`)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := LoadLinkReports(strings.NewReader(test.json)); err == nil {
+				t.Fatal("LoadLinkReports() error = nil, want error")
+			}
+		})
+	}
+}
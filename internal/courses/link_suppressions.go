@@ -73,7 +73,7 @@ func LoadLinkSuppressions(r io.Reader) (*LinkSuppressions, error) {
 		return nil, fmt.Errorf("decode link suppressions: suppressions is required")
 	}
 
-	suppressions := &LinkSuppressions{records: make(map[linkSuppressionKey]linkSuppressionRecord)}
+	suppressions := NewLinkSuppressions()
 	for index, raw := range file.Suppressions {
 		if err := rejectDuplicateTopLevelKeys(raw); err != nil {
 			return nil, fmt.Errorf("decode link suppressions: suppressions[%d]: %w", index, err)
@@ -109,6 +109,48 @@ func LoadLinkSuppressions(r io.Reader) (*LinkSuppressions, error) {
 	return suppressions, nil
 }
 
+func NewLinkSuppressions() *LinkSuppressions {
+	return &LinkSuppressions{records: make(map[linkSuppressionKey]linkSuppressionRecord)}
+}
+
+func (s *LinkSuppressions) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.records)
+}
+
+func (s *LinkSuppressions) AddLinkReport(report LinkReport, sourceEntryIDs []string, confirmedAt time.Time) int {
+	if s == nil || report.Status != LinkReportStatusApproved || report.Resolution != LinkReportResolutionSuppression {
+		return 0
+	}
+	if !linkSuppressionHashPattern.MatchString(report.SHA256) {
+		return 0
+	}
+	if s.records == nil {
+		s.records = make(map[linkSuppressionKey]linkSuppressionRecord)
+	}
+	timestamp := confirmedAt.UTC().Format(time.RFC3339)
+	added := 0
+	for _, sourceEntryID := range sourceEntryIDs {
+		if sourceEntryID == "" || strings.TrimSpace(sourceEntryID) != sourceEntryID {
+			continue
+		}
+		key := linkSuppressionKey{SourceEntryID: sourceEntryID, SHA256: report.SHA256}
+		if _, exists := s.records[key]; exists {
+			continue
+		}
+		s.records[key] = linkSuppressionRecord{
+			SourceEntryID: sourceEntryID,
+			SHA256:        report.SHA256,
+			Reason:        "reported",
+			ConfirmedAt:   timestamp,
+		}
+		added++
+	}
+	return added
+}
+
 func (s *LinkSuppressions) ContainsURL(sourceEntryID, rawURL string) bool {
 	if s == nil || len(s.records) == 0 {
 		return false
@@ -145,7 +187,7 @@ func (s LinkSuppressions) MarshalJSON() ([]byte, error) {
 
 func validLinkSuppressionReason(reason string) bool {
 	switch reason {
-	case "content_mismatch", "manual":
+	case "content_mismatch", "manual", "reported":
 		return true
 	default:
 		return false
@@ -151,6 +151,31 @@ func (t *LinkTombstones) MergeEligibleAuditResults(report *LinkAuditReport, requ
 	return added
 }
 
+func (t *LinkTombstones) AddLinkReport(report LinkReport, confirmedAt time.Time) bool {
+	if t == nil || report.Status != LinkReportStatusApproved || report.Resolution != LinkReportResolutionTombstone {
+		return false
+	}
+	if !linkTombstoneHashPattern.MatchString(report.SHA256) {
+		return false
+	}
+	if t.hashes == nil {
+		t.hashes = make(map[string]struct{})
+	}
+	if t.records == nil {
+		t.records = make(map[string]linkTombstoneRecord)
+	}
+	if _, exists := t.hashes[report.SHA256]; exists {
+		return false
+	}
+	t.hashes[report.SHA256] = struct{}{}
+	t.records[report.SHA256] = linkTombstoneRecord{
+		SHA256:      report.SHA256,
+		Reason:      "reported",
+		ConfirmedAt: confirmedAt.UTC().Format(time.RFC3339),
+	}
+	return true
+}
+
 func (t LinkTombstones) MarshalJSON() ([]byte, error) {
 	records := make([]linkTombstoneRecord, 0, len(t.records))
 	for _, record := range t.records {
@@ -184,7 +209,7 @@ func (t *LinkTombstones) ContainsURL(rawURL string) bool {
 
 func validLinkTombstoneReason(reason string) bool {
 	switch reason {
-	case "expired", "content_mismatch", "manual":
+	case "expired", "content_mismatch", "manual", "reported":
 		return true
 	default:
 		return false
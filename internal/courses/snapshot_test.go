@@ -192,17 +192,18 @@ func mustMarshalCatalog(t *testing.T, catalog Catalog) string {
 	return string(payload)
 }
 
-func TestBuildGzipFromSourcesRejectsConflictingSourceIdentity(t *testing.T) {
+func TestBuildGzipFromSourcesRejectsMessageSharedByChannels(t *testing.T) {
 	first := validSource(t, validSourceEntry())
 	second := validSource(t, validSourceEntry())
 	second.Source.ChannelID = 2
 
 	var output bytes.Buffer
-	if _, err := BuildGzipFromSources([]SourceInput{
+	_, err := BuildGzipFromSources([]SourceInput{
 		{Reader: sourceReader(t, first), Name: "first.json"},
 		{Reader: sourceReader(t, second), Name: "second.json"},
-	}, &output, ""); err == nil {
-		t.Fatal("conflicting source identity was accepted")
+	}, &output, "")
+	if err == nil || !strings.Contains(err.Error(), "appears in channels 1 and 2") {
+		t.Fatalf("build error = %v, want conflicting message identity", err)
 	}
 }
 
@@ -0,0 +1,57 @@
+package courses
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SourceInputPaths lists the Telegram exports and curated lists in dir, in
+// name order.
+func SourceInputPaths(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read input directory: %w", err)
+	}
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.ToLower(entry.Name())
+		if entry.IsDir() || (!strings.HasSuffix(name, ".json") && SourceFormatForPath(name) == "") {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// OpenSourceInputs opens every path as a SourceInput, picking the format
+// from its extension. The returned func closes all of them.
+func OpenSourceInputs(paths []string) ([]SourceInput, func(), error) {
+	inputs := make([]SourceInput, 0, len(paths))
+	closers := make([]io.Closer, 0, len(paths))
+	closeAll := func() {
+		for _, closer := range closers {
+			_ = closer.Close()
+		}
+	}
+	for _, path := range paths {
+		input, err := os.Open(path)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("open source export %q: %w", path, err)
+		}
+		closers = append(closers, input)
+		// Curated lists are named after their file, so each is its own channel.
+		inputs = append(inputs, SourceInput{
+			Reader:  input,
+			Name:    path,
+			Format:  SourceFormatForPath(path),
+			Channel: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		})
+	}
+	return inputs, closeAll, nil
+}
@@ -0,0 +1,93 @@
+package courses
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const sourceStateSchema = "courses-sources/v1"
+
+// SourceStatePath returns where the source state of the catalog at
+// catalogPath is kept.
+func SourceStatePath(catalogPath string) string {
+	return strings.TrimSuffix(catalogPath, ".json.gz") + ".sources.json.gz"
+}
+
+// sourceState keeps every export merged into a catalog, so an incremental
+// build needs only the exports added or changed since. Each channel holds
+// its exports merged into one, and Inputs lists the digests of the inputs
+// they came from.
+type sourceState struct {
+	SchemaVersion string               `json:"schema_version"`
+	Inputs        []string             `json:"inputs"`
+	Channels      []sourceStateChannel `json:"channels"`
+}
+
+type sourceStateChannel struct {
+	Key    catalogSource `json:"key"`
+	Export sourceExport  `json:"export"`
+}
+
+// encodeSourceState serializes channels after mergeSourceChannels has left
+// each with its merged export. It must run before the build, which may
+// rewrite the merged entries in place.
+func encodeSourceState(channels []sourceChannel, inputs []string) ([]byte, error) {
+	state := sourceState{
+		SchemaVersion: sourceStateSchema,
+		Inputs:        inputs,
+		Channels:      make([]sourceStateChannel, 0, len(channels)),
+	}
+	for _, channel := range channels {
+		state.Channels = append(state.Channels, sourceStateChannel{Key: channel.key, Export: channel.exports[0]})
+	}
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(state); err != nil {
+		return nil, fmt.Errorf("encode source state: %w", err)
+	}
+	return data.Bytes(), nil
+}
+
+func decodeSourceState(input io.Reader) (sourceState, error) {
+	reader, err := gzip.NewReader(input)
+	if err != nil {
+		return sourceState{}, fmt.Errorf("read source state: %w", err)
+	}
+	defer reader.Close()
+	var state sourceState
+	if err := json.NewDecoder(reader).Decode(&state); err != nil {
+		return sourceState{}, fmt.Errorf("decode source state: %w", err)
+	}
+	if state.SchemaVersion != sourceStateSchema {
+		return sourceState{}, fmt.Errorf("source state schema %q, want %q", state.SchemaVersion, sourceStateSchema)
+	}
+	if len(state.Channels) == 0 {
+		return sourceState{}, errors.New("source state has no channels")
+	}
+	return state, nil
+}
+
+func (state sourceState) sourceChannels() []sourceChannel {
+	channels := make([]sourceChannel, 0, len(state.Channels))
+	for _, channel := range state.Channels {
+		channels = append(channels, sourceChannel{key: channel.Key, exports: []sourceExport{channel.Export}})
+	}
+	return channels
+}
+
+// appendSourceDigests adds the digests of sources not already in digests.
+func appendSourceDigests(digests []string, sources []sourceExport) []string {
+	for _, source := range sources {
+		if !slices.Contains(digests, source.digest) {
+			digests = append(digests, source.digest)
+		}
+	}
+	return digests
+}
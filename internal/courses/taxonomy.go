@@ -0,0 +1,371 @@
+package courses
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const taxonomySchema = "course-taxonomy/v1"
+
+// defaultTaxonomyData holds the categories used when BuildOptions has no
+// taxonomy.
+//
+//go:embed taxonomy_default.json
+var defaultTaxonomyData []byte
+
+var (
+	defaultTaxonomy   = mustLoadDefaultTaxonomy()
+	categoryIDPattern = regexp.MustCompile(`^[a-z0-9]+(?:_[a-z0-9]+)*$`)
+)
+
+// Taxonomy holds the category definitions entries are classified into. The
+// "other" category collects entries nothing else matches, and entries
+// matching "service" are classified as service only.
+type Taxonomy struct {
+	categories []categoryDefinition
+	// digest is the SHA-256 of the taxonomy file, part of a catalog's build
+	// fingerprint.
+	digest string
+}
+
+// blockedCategoryRule vetoes a category match. Unless uses the
+// matchesCategory keyword form.
+type blockedCategoryRule struct {
+	Sequences [][]string
+	Tokens    []string
+	Unless    []string
+}
+
+type taxonomyFile struct {
+	SchemaVersion string                 `json:"schema_version"`
+	Categories    []taxonomyCategoryFile `json:"categories"`
+}
+
+type taxonomyCategoryFile struct {
+	ID             string                  `json:"id"`
+	Label          string                  `json:"label"`
+	Hidden         bool                    `json:"hidden"`
+	Keywords       []string                `json:"keywords"`
+	Stems          []string                `json:"stems"`
+	BlockedMatches []taxonomyBlockRuleFile `json:"blocked_matches"`
+}
+
+// taxonomyBlockRuleFile vetoes a category match when the entry contains one
+// of the token sequences or tokens, unless it also matches one of the
+// unless keywords or stems.
+type taxonomyBlockRuleFile struct {
+	Sequences      [][]string `json:"sequences"`
+	Tokens         []string   `json:"tokens"`
+	UnlessKeywords []string   `json:"unless_keywords"`
+	UnlessStems    []string   `json:"unless_stems"`
+}
+
+func LoadTaxonomy(r io.Reader) (*Taxonomy, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read taxonomy: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("read taxonomy: invalid utf-8")
+	}
+	if err := rejectDuplicateTopLevelKeys(data); err != nil {
+		return nil, fmt.Errorf("decode taxonomy: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file taxonomyFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode taxonomy: %w", err)
+	}
+	var extra json.RawMessage
+	if err := decoder.Decode(&extra); err != io.EOF {
+		if err == nil {
+			return nil, fmt.Errorf("decode taxonomy: multiple json values")
+		}
+		return nil, fmt.Errorf("decode taxonomy: %w", err)
+	}
+	if file.SchemaVersion != taxonomySchema {
+		return nil, fmt.Errorf("decode taxonomy: unsupported schema_version %q", file.SchemaVersion)
+	}
+	if len(file.Categories) == 0 {
+		return nil, fmt.Errorf("decode taxonomy: categories are required")
+	}
+
+	digest := sha256.Sum256(data)
+	taxonomy := &Taxonomy{
+		categories: make([]categoryDefinition, 0, len(file.Categories)),
+		digest:     hex.EncodeToString(digest[:]),
+	}
+	ids := make(map[string]struct{}, len(file.Categories))
+	for index, category := range file.Categories {
+		definition, err := taxonomyCategory(category)
+		if err != nil {
+			return nil, fmt.Errorf("decode taxonomy: categories[%d]: %w", index, err)
+		}
+		if _, exists := ids[definition.ID]; exists {
+			return nil, fmt.Errorf("decode taxonomy: duplicate category id %q", definition.ID)
+		}
+		ids[definition.ID] = struct{}{}
+		taxonomy.categories = append(taxonomy.categories, definition)
+	}
+	for _, required := range []string{"other", "service"} {
+		if _, exists := ids[required]; !exists {
+			return nil, fmt.Errorf("decode taxonomy: category %q is required", required)
+		}
+	}
+	if other := taxonomy.category("other"); len(other.Keywords) != 0 || len(other.Blocked) != 0 {
+		return nil, fmt.Errorf("decode taxonomy: category \"other\" cannot have keywords, stems or blocked matches")
+	}
+	return taxonomy, nil
+}
+
+func taxonomyCategory(file taxonomyCategoryFile) (categoryDefinition, error) {
+	if !categoryIDPattern.MatchString(file.ID) {
+		return categoryDefinition{}, fmt.Errorf("invalid id %q", file.ID)
+	}
+	if strings.TrimSpace(file.Label) == "" || strings.TrimSpace(file.Label) != file.Label {
+		return categoryDefinition{}, fmt.Errorf("%s: label must be non-empty and trimmed", file.ID)
+	}
+	if file.Keywords == nil || file.Stems == nil {
+		return categoryDefinition{}, fmt.Errorf("%s: keywords and stems are required", file.ID)
+	}
+	keywords, err := taxonomyKeywords(file.Keywords, file.Stems)
+	if err != nil {
+		return categoryDefinition{}, fmt.Errorf("%s: %w", file.ID, err)
+	}
+	definition := categoryDefinition{
+		ID:       file.ID,
+		Label:    file.Label,
+		Hidden:   file.Hidden,
+		Keywords: keywords,
+	}
+	for index, rule := range file.BlockedMatches {
+		blocked, err := taxonomyBlockRule(rule)
+		if err != nil {
+			return categoryDefinition{}, fmt.Errorf("%s: blocked_matches[%d]: %w", file.ID, index, err)
+		}
+		definition.Blocked = append(definition.Blocked, blocked)
+	}
+	return definition, nil
+}
+
+// taxonomyKeywords merges keywords and stems into the matchesCategory form,
+// where a trailing "*" marks a stem.
+func taxonomyKeywords(keywords, stems []string) ([]string, error) {
+	result := make([]string, 0, len(keywords)+len(stems))
+	seen := make(map[string]struct{}, len(keywords)+len(stems))
+	add := func(field, value, suffix string) error {
+		if value == "" || strings.TrimSpace(value) != value {
+			return fmt.Errorf("%s value %q must be non-empty and trimmed", field, value)
+		}
+		if strings.Contains(value, "*") {
+			return fmt.Errorf("%s value %q cannot contain \"*\"", field, value)
+		}
+		if normalizeSearchText(value) == "" {
+			return fmt.Errorf("%s value %q has no searchable characters", field, value)
+		}
+		if _, exists := seen[value+suffix]; exists {
+			return fmt.Errorf("duplicate %s value %q", field, value)
+		}
+		seen[value+suffix] = struct{}{}
+		result = append(result, value+suffix)
+		return nil
+	}
+	for _, keyword := range keywords {
+		if err := add("keywords", keyword, ""); err != nil {
+			return nil, err
+		}
+	}
+	for _, stem := range stems {
+		if err := add("stems", stem, "*"); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func taxonomyBlockRule(file taxonomyBlockRuleFile) (blockedCategoryRule, error) {
+	if len(file.Sequences) == 0 && len(file.Tokens) == 0 {
+		return blockedCategoryRule{}, fmt.Errorf("sequences or tokens are required")
+	}
+	for _, sequence := range file.Sequences {
+		if len(sequence) == 0 {
+			return blockedCategoryRule{}, fmt.Errorf("sequences cannot be empty")
+		}
+		for _, token := range sequence {
+			if err := validateTaxonomyToken(token); err != nil {
+				return blockedCategoryRule{}, err
+			}
+		}
+	}
+	for _, token := range file.Tokens {
+		if err := validateTaxonomyToken(token); err != nil {
+			return blockedCategoryRule{}, err
+		}
+	}
+	unless, err := taxonomyKeywords(file.UnlessKeywords, file.UnlessStems)
+	if err != nil {
+		return blockedCategoryRule{}, err
+	}
+	return blockedCategoryRule{
+		Sequences: file.Sequences,
+		Tokens:    file.Tokens,
+		Unless:    unless,
+	}, nil
+}
+
+// validateTaxonomyToken rejects tokens that normalized entry text can never
+// contain, since a rule using them would silently never fire.
+func validateTaxonomyToken(token string) error {
+	if token == "" || normalizeSearchText(token) != token || strings.Contains(token, " ") {
+		return fmt.Errorf("token %q must be a single normalized search token", token)
+	}
+	return nil
+}
+
+func mustLoadDefaultTaxonomy() *Taxonomy {
+	taxonomy, err := LoadTaxonomy(bytes.NewReader(defaultTaxonomyData))
+	if err != nil {
+		panic(fmt.Sprintf("load default taxonomy: %v", err))
+	}
+	return taxonomy
+}
+
+func (options BuildOptions) taxonomy() *Taxonomy {
+	if options.Taxonomy == nil {
+		return defaultTaxonomy
+	}
+	return options.Taxonomy
+}
+
+func (taxonomy *Taxonomy) category(id string) categoryDefinition {
+	for _, definition := range taxonomy.categories {
+		if definition.ID == id {
+			return definition
+		}
+	}
+	return categoryDefinition{}
+}
+
+func (taxonomy *Taxonomy) categoryIDs() []string {
+	ids := make([]string, 0, len(taxonomy.categories))
+	for _, definition := range taxonomy.categories {
+		ids = append(ids, definition.ID)
+	}
+	return ids
+}
+
+func (taxonomy *Taxonomy) classify(entry sourceEntry) []string {
+	author := ""
+	if entry.Credit.Author != nil {
+		author = *entry.Credit.Author
+	}
+	text := normalizeSearchText(strings.Join([]string{entry.Title, author, entry.RawBlock}, " "))
+	tokens := strings.Fields(text)
+	if matchesCategory(text, taxonomy.category("service").Keywords) {
+		return []string{"service"}
+	}
+
+	var categories []string
+	for _, definition := range taxonomy.categories {
+		if definition.ID == "other" || definition.ID == "service" {
+			continue
+		}
+		if matchesCategory(text, definition.Keywords) && !blockedCategoryMatch(definition, text, tokens) {
+			categories = append(categories, definition.ID)
+		}
+	}
+	if len(categories) == 0 {
+		return []string{"other"}
+	}
+	return categories
+}
+
+func blockedCategoryMatch(definition categoryDefinition, text string, tokens []string) bool {
+	for _, rule := range definition.Blocked {
+		matched := containsAnyToken(tokens, rule.Tokens...)
+		for _, sequence := range rule.Sequences {
+			matched = matched || containsTokenSequence(tokens, sequence...)
+		}
+		if matched && !matchesCategory(text, rule.Unless) {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoryMatch explains a category whose keywords matched an entry. It was
+// not applied when a blocked match vetoed it or "service" overrode it.
+type CategoryMatch struct {
+	Category     string                 `json:"category"`
+	Keywords     []string               `json:"keywords"`
+	Blocked      []BlockedCategoryMatch `json:"blocked,omitempty"`
+	OverriddenBy string                 `json:"overridden_by,omitempty"`
+	Applied      bool                   `json:"applied"`
+}
+
+// BlockedCategoryMatch explains a blocked match whose tokens or sequences
+// occurred. Unless lists the keywords that lifted its veto.
+type BlockedCategoryMatch struct {
+	Matched []string `json:"matched"`
+	Unless  []string `json:"unless,omitempty"`
+	Vetoed  bool     `json:"vetoed"`
+}
+
+// explain returns every category whose keywords matched the entry, in
+// taxonomy order, with the rules classify applied to them.
+func (taxonomy *Taxonomy) explain(entry sourceEntry) []CategoryMatch {
+	author := ""
+	if entry.Credit.Author != nil {
+		author = *entry.Credit.Author
+	}
+	text := normalizeSearchText(strings.Join([]string{entry.Title, author, entry.RawBlock}, " "))
+	tokens := strings.Fields(text)
+	service := matchesCategory(text, taxonomy.category("service").Keywords)
+
+	var matches []CategoryMatch
+	for _, definition := range taxonomy.categories {
+		keywords := matchedCategoryKeywords(text, tokens, definition.Keywords)
+		if len(keywords) == 0 {
+			continue
+		}
+		match := CategoryMatch{Category: definition.ID, Keywords: keywords, Applied: true}
+		if definition.ID != "service" {
+			for _, rule := range definition.Blocked {
+				matched := matchedTokens(tokens, rule.Tokens, rule.Sequences)
+				if len(matched) == 0 {
+					continue
+				}
+				blocked := BlockedCategoryMatch{Matched: matched, Unless: matchedCategoryKeywords(text, tokens, rule.Unless)}
+				blocked.Vetoed = len(blocked.Unless) == 0
+				match.Applied = match.Applied && !blocked.Vetoed
+				match.Blocked = append(match.Blocked, blocked)
+			}
+			if service {
+				match.OverriddenBy = "service"
+				match.Applied = false
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+func matchedCategoryKeywords(text string, tokens, keywords []string) []string {
+	var matched []string
+	for _, keyword := range keywords {
+		if matchesCategoryKeyword(text, tokens, keyword) {
+			matched = append(matched, keyword)
+		}
+	}
+	return matched
+}
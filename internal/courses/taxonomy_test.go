@@ -0,0 +1,118 @@
+package courses
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadTaxonomyClassifiesWithCustomCategories(t *testing.T) {
+	taxonomy := taxonomyForTest(t, taxonomyTestPayload(`{
+		"id": "gardening",
+		"label": "Сад",
+		"keywords": ["огород"],
+		"stems": ["рассад"],
+		"blocked_matches": [{"sequences": [["рассада", "идей"]]}]
+	}`))
+
+	tests := map[string][]string{
+		"Огород за выходные":         {"gardening"},
+		"Рассадами заняться":         {"gardening"},
+		"Рассада идей для стартапа":  {"other"},
+		"Инструкция как скачать":     {"service"},
+		"Python backend разработчик": {"other"},
+	}
+	for title, want := range tests {
+		entry := validSourceEntry()
+		entry.Title = title
+		if got := taxonomy.classify(entry); !slices.Equal(got, want) {
+			t.Fatalf("classify(%q) = %v, want %v", title, got, want)
+		}
+	}
+
+	entry := validSourceEntry()
+	entry.Title = "Огород за выходные"
+	var output bytes.Buffer
+	if _, err := BuildGzipFromSourcesWithOptions(
+		[]SourceInput{{Reader: sourceReader(t, validSource(t, entry))}},
+		&output,
+		BuildOptions{Taxonomy: taxonomy},
+	); err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	catalog := decodeBuiltCatalog(t, &output)
+	if len(catalog.Categories) != 3 || catalog.Categories[0] != (CategoryMetadata{ID: "gardening", Label: "Сад", Count: 1}) {
+		t.Fatalf("categories = %+v", catalog.Categories)
+	}
+	if catalog.BuildFingerprint == buildFingerprint(BuildOptions{}) {
+		t.Fatal("build fingerprint ignores the taxonomy")
+	}
+}
+
+func TestDefaultTaxonomyMatchesEmbeddedFile(t *testing.T) {
+	taxonomy, err := LoadTaxonomy(bytes.NewReader(defaultTaxonomyData))
+	if err != nil {
+		t.Fatalf("load default taxonomy: %v", err)
+	}
+	if !slices.Equal(taxonomy.categoryIDs(), BuildOptions{}.taxonomy().categoryIDs()) ||
+		taxonomy.categoryIDs()[0] != "development" {
+		t.Fatalf("default category IDs = %v", taxonomy.categoryIDs())
+	}
+	if blocked := taxonomy.category("photo_video").Blocked; len(blocked) != 2 || len(blocked[1].Unless) == 0 {
+		t.Fatalf("photo_video blocked matches = %+v", blocked)
+	}
+}
+
+func TestLoadTaxonomyRejectsInvalidFiles(t *testing.T) {
+	valid := `{"id": "gardening", "label": "Сад", "keywords": ["огород"], "stems": []}`
+	tests := map[string]string{
+		"schema":           strings.Replace(taxonomyTestPayload(valid), "course-taxonomy/v1", "course-taxonomy/v0", 1),
+		"unknown field":    taxonomyTestPayload(`{"id": "gardening", "label": "Сад", "keywords": [], "stems": [], "weight": 1}`),
+		"duplicate id":     taxonomyTestPayload(valid + ", " + valid),
+		"invalid id":       taxonomyTestPayload(`{"id": "Garden", "label": "Сад", "keywords": [], "stems": []}`),
+		"empty label":      taxonomyTestPayload(`{"id": "gardening", "label": "", "keywords": [], "stems": []}`),
+		"missing stems":    taxonomyTestPayload(`{"id": "gardening", "label": "Сад", "keywords": []}`),
+		"wildcard keyword": taxonomyTestPayload(`{"id": "gardening", "label": "Сад", "keywords": ["огород*"], "stems": []}`),
+		"untrimmed stem":   taxonomyTestPayload(`{"id": "gardening", "label": "Сад", "keywords": [], "stems": [" сад"]}`),
+		"duplicate stem":   taxonomyTestPayload(`{"id": "gardening", "label": "Сад", "keywords": [], "stems": ["сад", "сад"]}`),
+		"empty block rule": taxonomyTestPayload(`{"id": "gardening", "label": "Сад", "keywords": [], "stems": [], "blocked_matches": [{}]}`),
+		"unnormalized token": taxonomyTestPayload(
+			`{"id": "gardening", "label": "Сад", "keywords": [], "stems": [], "blocked_matches": [{"tokens": ["Сад"]}]}`,
+		),
+		"missing other": `{"schema_version": "course-taxonomy/v1", "categories": [
+			{"id": "service", "label": "Служебное", "keywords": [], "stems": []}
+		]}`,
+		"other with keywords": strings.Replace(
+			taxonomyTestPayload(valid), `"id": "other", "label": "Другое", "keywords": []`, `"id": "other", "label": "Другое", "keywords": ["прочее"]`, 1,
+		),
+		"trailing value": taxonomyTestPayload(valid) + " {}",
+	}
+	for name, payload := range tests {
+		if _, err := LoadTaxonomy(strings.NewReader(payload)); err == nil {
+			t.Fatalf("%s: LoadTaxonomy() error = nil", name)
+		}
+	}
+}
+
+func taxonomyForTest(t *testing.T, payload string) *Taxonomy {
+	t.Helper()
+
+	taxonomy, err := LoadTaxonomy(strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("load taxonomy: %v", err)
+	}
+	return taxonomy
+}
+
+func taxonomyTestPayload(categories string) string {
+	return fmt.Sprintf(`{
+		"schema_version": "course-taxonomy/v1",
+		"categories": [
+			%s,
+			{"id": "other", "label": "Другое", "keywords": [], "stems": []},
+			{"id": "service", "label": "Служебное", "hidden": true, "keywords": ["инструкция как скачать"], "stems": []}
+		]
+	}`, categories)
+}
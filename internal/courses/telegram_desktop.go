@@ -0,0 +1,370 @@
+package courses
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// desktopExport is the result.json Telegram Desktop writes when a single
+// channel is exported as machine-readable JSON.
+type desktopExport struct {
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`
+	ID       int64            `json:"id"`
+	Messages []desktopMessage `json:"messages"`
+}
+
+type desktopMessage struct {
+	ID           int64           `json:"id"`
+	Type         string          `json:"type"`
+	Date         string          `json:"date"`
+	DateUnix     string          `json:"date_unixtime"`
+	Text         json.RawMessage `json:"text"`
+	TextEntities []desktopEntity `json:"text_entities"`
+	File         string          `json:"file"`
+	FileName     string          `json:"file_name"`
+	MIMEType     string          `json:"mime_type"`
+	Photo        string          `json:"photo"`
+}
+
+type desktopEntity struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	Href string `json:"href"`
+}
+
+// desktopLink is a link entity located in the flattened message text.
+type desktopLink struct {
+	start, end int
+	link       sourceLink
+}
+
+var (
+	desktopPasswordPattern  = regexp.MustCompile(`(?i)^(?:пароль|password|pass|pw)\s*[:：=-]\s*(\S.*)$`)
+	headingYearPattern      = regexp.MustCompile(`\s*\((\d{4})\)$`)
+	headingYearRangePattern = regexp.MustCompile(`\s*\((\d{4})\s*[-–—]\s*(\d{4})\)$`)
+	desktopMagnetPattern    = regexp.MustCompile(`(?i)magnet:\?\S+`)
+	desktopBlankLinePattern = regexp.MustCompile(`\n[ \t]*\n`)
+)
+
+// isDesktopExport reports whether data looks like a Telegram Desktop
+// result.json rather than a versioned export.
+func isDesktopExport(data []byte) bool {
+	var probe struct {
+		SchemaVersion *string         `json:"schema_version"`
+		Type          string          `json:"type"`
+		Messages      json.RawMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.SchemaVersion == nil && probe.Messages != nil && strings.HasSuffix(probe.Type, "channel")
+}
+
+// decodeDesktopExport maps a Telegram Desktop channel export onto the
+// sourceExport model. Catalog entries are parsed from message text: blocks
+// separated by blank lines that carry a link become text_block entries, and
+// a message with a document becomes a single document entry.
+func decodeDesktopExport(data []byte) (sourceExport, error) {
+	var export desktopExport
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&export); err != nil {
+		return sourceExport{}, fmt.Errorf("decode telegram desktop export: %w", err)
+	}
+	if export.ID == 0 {
+		return sourceExport{}, errors.New("telegram desktop export has no channel id")
+	}
+
+	source := sourceExport{
+		SchemaVersion: sourceSchema,
+		Source: catalogSource{
+			ChannelID: export.ID,
+			Title:     strings.TrimSpace(export.Name),
+		},
+	}
+	var latest time.Time
+	for _, message := range export.Messages {
+		if message.Type != "message" {
+			continue
+		}
+		addedAt, err := message.addedAt()
+		if err != nil {
+			return sourceExport{}, fmt.Errorf("telegram desktop message %d: %w", message.ID, err)
+		}
+		if addedAt.After(latest) {
+			latest = addedAt
+		}
+		messageID := fmt.Sprintf("%d:%d", export.ID, message.ID)
+		mapped := sourceMessage{
+			MessageID:         messageID,
+			TelegramMessageID: message.ID,
+			URL:               fmt.Sprintf("https://t.me/c/%d/%d", export.ID, message.ID),
+		}
+		if message.File != "" || message.FileName != "" {
+			mapped.Media.Type = "messageMediaDocument"
+			mapped.Media.Document.FileName = message.documentName()
+			mapped.Media.Document.MIMEType = message.MIMEType
+		} else if message.Photo != "" {
+			mapped.Media.Type = "messageMediaPhoto"
+		}
+		source.Messages = append(source.Messages, mapped)
+
+		text, links, err := message.flatten()
+		if err != nil {
+			return sourceExport{}, fmt.Errorf("telegram desktop message %d: %w", message.ID, err)
+		}
+		source.CatalogEntries = append(source.CatalogEntries,
+			desktopMessageEntries(messageID, addedAt.Format(time.RFC3339), mapped, text, links)...)
+	}
+	if len(source.CatalogEntries) == 0 {
+		return sourceExport{}, errors.New("source export has no catalog entries")
+	}
+	source.ExportedAt = latest.Format(time.RFC3339)
+	recomputeSourceStats(&source)
+	return source, nil
+}
+
+func (message desktopMessage) addedAt() (time.Time, error) {
+	if message.DateUnix != "" {
+		seconds, err := strconv.ParseInt(message.DateUnix, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date_unixtime %q", message.DateUnix)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	// Older exports only have the local wall clock time; it is read as UTC.
+	value, err := time.Parse("2006-01-02T15:04:05", message.Date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", message.Date)
+	}
+	return value, nil
+}
+
+func (message desktopMessage) documentName() string {
+	if message.FileName != "" {
+		return message.FileName
+	}
+	return path.Base(message.File)
+}
+
+// flatten joins the message text and locates its link entities. Newer
+// exports carry text_entities; older ones only the mixed text array.
+func (message desktopMessage) flatten() (string, []desktopLink, error) {
+	entities := message.TextEntities
+	if entities == nil && len(message.Text) > 0 {
+		var plain string
+		if err := json.Unmarshal(message.Text, &plain); err == nil {
+			entities = []desktopEntity{{Type: "plain", Text: plain}}
+		} else {
+			var parts []json.RawMessage
+			if err := json.Unmarshal(message.Text, &parts); err != nil {
+				return "", nil, fmt.Errorf("decode text: %w", err)
+			}
+			for _, part := range parts {
+				var entity desktopEntity
+				if err := json.Unmarshal(part, &entity.Text); err == nil {
+					entity.Type = "plain"
+				} else if err := json.Unmarshal(part, &entity); err != nil {
+					return "", nil, fmt.Errorf("decode text: %w", err)
+				}
+				entities = append(entities, entity)
+			}
+		}
+	}
+
+	var text strings.Builder
+	var links []desktopLink
+	for _, entity := range entities {
+		start := text.Len()
+		text.WriteString(entity.Text)
+		if link, ok := desktopEntityLink(entity); ok {
+			links = append(links, desktopLink{start: start, end: text.Len(), link: link})
+		}
+	}
+	flat := text.String()
+	for _, match := range desktopMagnetPattern.FindAllStringIndex(flat, -1) {
+		links = append(links, desktopLink{
+			start: match[0],
+			end:   match[1],
+			link:  newSourceLink(flat[match[0]:match[1]], "magnet_text"),
+		})
+	}
+	return flat, links, nil
+}
+
+func desktopEntityLink(entity desktopEntity) (sourceLink, bool) {
+	switch entity.Type {
+	case "link":
+		return newSourceLink(entity.Text, "link"), strings.TrimSpace(entity.Text) != ""
+	case "text_link":
+		href := strings.TrimSpace(entity.Href)
+		link := newSourceLink(href, "text_link")
+		if label := strings.TrimSpace(entity.Text); label != "" && label != href {
+			link.Label = &label
+		}
+		return link, href != ""
+	}
+	return sourceLink{}, false
+}
+
+func desktopMessageEntries(messageID, addedAt string, message sourceMessage, text string, links []desktopLink) []sourceEntry {
+	type block struct{ start, end int }
+	var blocks []block
+	if message.Media.Type == "messageMediaDocument" {
+		blocks = []block{{0, len(text)}}
+	} else {
+		start := 0
+		for _, separator := range desktopBlankLinePattern.FindAllStringIndex(text, -1) {
+			blocks = append(blocks, block{start, separator[0]})
+			start = separator[1]
+		}
+		blocks = append(blocks, block{start, len(text)})
+	}
+
+	var entries []sourceEntry
+	for _, current := range blocks {
+		var blockLinks []desktopLink
+		for _, link := range links {
+			if link.start >= current.start && link.start < current.end {
+				blockLinks = append(blockLinks, link)
+			}
+		}
+		entry, ok := desktopBlockEntry(text, current.start, current.end, blockLinks, message)
+		if !ok {
+			continue
+		}
+		entry.EntryID = fmt.Sprintf("%s:%d", messageID, len(entries))
+		entry.MessageID = messageID
+		entry.SourceMessageIDs = []string{messageID}
+		entry.AddedAt = addedAt
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// desktopBlockEntry parses one block. Its heading is the first line that is
+// neither a password nor only a link, in the "[Author] Title (Year)" form
+// channel posts use.
+func desktopBlockEntry(text string, start, end int, links []desktopLink, message sourceMessage) (sourceEntry, bool) {
+	document := message.Media.Type == "messageMediaDocument"
+	if len(links) == 0 && !document {
+		return sourceEntry{}, false
+	}
+	entry := sourceEntry{
+		Origin:       "text_block",
+		Availability: "download_link",
+		RawBlock:     strings.TrimSpace(text[start:end]),
+	}
+	if document {
+		entry.Origin = "document"
+		entry.Availability = "document_attachment"
+	}
+
+	headingStart, headingEnd := -1, -1
+	lineStart := start
+	for lineStart <= end {
+		lineEnd := strings.IndexByte(text[lineStart:end], '\n')
+		if lineEnd < 0 {
+			lineEnd = end
+		} else {
+			lineEnd += lineStart
+		}
+		line := strings.TrimSpace(text[lineStart:lineEnd])
+		if match := desktopPasswordPattern.FindStringSubmatch(line); match != nil {
+			entry.Passwords = append(entry.Passwords, strings.TrimSpace(match[1]))
+		} else if headingStart < 0 && line != "" && !desktopLinkOnlyLine(text, lineStart, lineEnd, links) {
+			headingStart, headingEnd = lineStart, lineEnd
+			entry.Title, entry.Credit.Author, entry.Year, entry.YearRange = parseCourseHeading(line)
+		}
+		lineStart = lineEnd + 1
+	}
+	if strings.TrimSpace(entry.Title) == "" && document {
+		name := message.Media.Document.FileName
+		entry.Title = strings.TrimSuffix(name, path.Ext(name))
+	}
+	if strings.TrimSpace(entry.Title) == "" {
+		return sourceEntry{}, false
+	}
+
+	primary := false
+	for _, located := range links {
+		link := located.link
+		link.Sources = append([]string(nil), link.Sources...)
+		switch {
+		case located.start >= headingStart && located.start < headingEnd:
+			link.Role = "reference"
+			link.Sources = append(link.Sources, "title")
+		case !primary:
+			primary = true
+			link.Primary = true
+			link.Role = "primary"
+		default:
+			link.Role = "mirror"
+		}
+		if lineStart, lineEnd := desktopLineAt(text, located.start); desktopLinkOnlyLine(text, lineStart, lineEnd, links) {
+			link.Sources = append(link.Sources, "standalone_link")
+		}
+		entry.Links = append(entry.Links, link)
+	}
+	return entry, true
+}
+
+func desktopLineAt(text string, offset int) (int, int) {
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	end := strings.IndexByte(text[offset:], '\n')
+	if end < 0 {
+		return start, len(text)
+	}
+	return start, end + offset
+}
+
+// desktopLinkOnlyLine reports whether the line holds nothing but links.
+func desktopLinkOnlyLine(text string, start, end int, links []desktopLink) bool {
+	if strings.TrimSpace(text[start:end]) == "" {
+		return false
+	}
+	rest := []byte(text[start:end])
+	for _, link := range links {
+		if link.start < start || link.end > end {
+			continue
+		}
+		for index := link.start; index < link.end; index++ {
+			rest[index-start] = ' '
+		}
+	}
+	return strings.TrimSpace(string(rest)) == ""
+}
+
+// parseCourseHeading splits a "[Author] Title (Year)" heading. The year may
+// also be a range such as (2020-2022).
+func parseCourseHeading(line string) (string, *string, *int, *yearRange) {
+	heading := strings.TrimLeft(line, " \t-–—•*")
+	var author *string
+	if strings.HasPrefix(heading, "[") {
+		if end := strings.IndexByte(heading, ']'); end > 1 {
+			name := strings.TrimSpace(heading[1:end])
+			author = &name
+			heading = strings.TrimSpace(heading[end+1:])
+		}
+	}
+	var year *int
+	var years *yearRange
+	if match := headingYearRangePattern.FindStringSubmatch(heading); match != nil {
+		from, _ := strconv.Atoi(match[1])
+		to, _ := strconv.Atoi(match[2])
+		years = &yearRange{From: from, To: to}
+		heading = heading[:len(heading)-len(match[0])]
+	} else if match := headingYearPattern.FindStringSubmatch(heading); match != nil {
+		value, _ := strconv.Atoi(match[1])
+		year = &value
+		heading = heading[:len(heading)-len(match[0])]
+	}
+	return strings.TrimSpace(heading), author, year, years
+}
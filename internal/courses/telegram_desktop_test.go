@@ -0,0 +1,109 @@
+package courses
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const desktopExportFixture = `{
+	"name": "Course Export",
+	"type": "private_channel",
+	"id": 1,
+	"messages": [
+		{"id": 9, "type": "service", "date": "2024-01-01T00:00:00", "date_unixtime": "1704067200", "action": "create_channel", "text": "", "text_entities": []},
+		{
+			"id": 10,
+			"type": "message",
+			"date": "2024-01-02T03:00:00",
+			"date_unixtime": "1704164400",
+			"text": "",
+			"text_entities": [
+				{"type": "plain", "text": "[Автор Пример] Практическая астрология (2024)\n"},
+				{"type": "link", "text": "https://files.example.test/course-a"},
+				{"type": "plain", "text": "\nПароль: fixture-password-a\n\nВторой курс\n"},
+				{"type": "text_link", "text": "Скачать", "href": "https://files.example.test/course-b"}
+			]
+		},
+		{
+			"id": 11,
+			"type": "message",
+			"date": "2024-01-03T00:00:00",
+			"text": ["Python backend (2020–2022)\n", {"type": "link", "text": "files.example.test/py"}]
+		},
+		{
+			"id": 12,
+			"type": "message",
+			"date": "2024-01-04T00:00:00",
+			"date_unixtime": "1704326400",
+			"file": "files/Go Course.torrent",
+			"mime_type": "application/x-bittorrent",
+			"text": "",
+			"text_entities": []
+		},
+		{"id": 13, "type": "message", "date": "2024-01-05T00:00:00", "date_unixtime": "1704412800", "text": "Без ссылок", "text_entities": [{"type": "plain", "text": "Без ссылок"}]}
+	]
+}`
+
+func TestDecodeSourceExportReadsTelegramDesktopExport(t *testing.T) {
+	source, err := decodeSourceExport(strings.NewReader(desktopExportFixture))
+	if err != nil {
+		t.Fatalf("decode desktop export: %v", err)
+	}
+	if source.SchemaVersion != sourceSchema || source.Source.ChannelID != 1 || source.Source.Title != "Course Export" {
+		t.Fatalf("source = %+v", source.Source)
+	}
+	if source.ExportedAt != "2024-01-05T00:00:00Z" || len(source.Messages) != 4 {
+		t.Fatalf("exported_at = %q, messages = %d", source.ExportedAt, len(source.Messages))
+	}
+	if len(source.CatalogEntries) != 4 {
+		t.Fatalf("entries = %+v, want 4", source.CatalogEntries)
+	}
+
+	first := source.CatalogEntries[0]
+	if first.EntryID != "1:10:0" || first.MessageID != "1:10" || first.AddedAt != "2024-01-02T03:00:00Z" ||
+		first.Title != "Практическая астрология" || first.Credit.Author == nil || *first.Credit.Author != "Автор Пример" ||
+		first.Year == nil || *first.Year != 2024 || !slices.Equal(first.Passwords, []string{"fixture-password-a"}) {
+		t.Fatalf("first entry = %+v", first)
+	}
+	if len(first.Links) != 1 || !first.Links[0].Primary || first.Links[0].Host != "files.example.test" ||
+		!slices.Contains(first.Links[0].Sources, "standalone_link") {
+		t.Fatalf("first entry links = %+v", first.Links)
+	}
+
+	second := source.CatalogEntries[1]
+	if second.EntryID != "1:10:1" || second.Title != "Второй курс" || len(second.Links) != 1 ||
+		second.Links[0].Label == nil || *second.Links[0].Label != "Скачать" {
+		t.Fatalf("second entry = %+v", second)
+	}
+
+	third := source.CatalogEntries[2]
+	if third.Title != "Python backend" || third.YearRange == nil || *third.YearRange != (yearRange{From: 2020, To: 2022}) ||
+		third.Links[0].URL != "https://files.example.test/py" || third.Links[0].NormalizedFrom != "files.example.test/py" {
+		t.Fatalf("third entry = %+v", third)
+	}
+
+	document := source.CatalogEntries[3]
+	if document.Title != "Go Course" || document.Origin != "document" || document.Availability != "document_attachment" {
+		t.Fatalf("document entry = %+v", document)
+	}
+	if media := source.Messages[2].Media; media.Type != "messageMediaDocument" || media.Document.FileName != "Go Course.torrent" {
+		t.Fatalf("document media = %+v", media)
+	}
+}
+
+func TestBuildGzipAcceptsTelegramDesktopExport(t *testing.T) {
+	var output bytes.Buffer
+	stats, err := BuildGzip(strings.NewReader(desktopExportFixture), &output)
+	if err != nil {
+		t.Fatalf("build catalog: %v", err)
+	}
+	if stats.SourceEntries != 4 || stats.Entries != 3 || stats.EntriesWithoutLinksRemoved != 1 || stats.Passwords != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	catalog := decodeBuiltCatalog(t, &output)
+	if catalog.Entries[0].Sources[0].MessageURL != "https://t.me/c/1/10" {
+		t.Fatalf("message url = %q", catalog.Entries[0].Sources[0].MessageURL)
+	}
+}
@@ -2,6 +2,8 @@ package courses
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,6 +20,9 @@ type TitleRules struct {
 	forceNormalizeTokensCI  map[string]struct{}
 	forceNormalizePhrasesCI []string
 	structuralCleanup       titleStructuralCleanup
+	// digest is the SHA-256 of the rules file, part of a catalog's build
+	// fingerprint.
+	digest string
 }
 
 type titleRulesFile struct {
@@ -96,7 +101,9 @@ func LoadTitleRules(r io.Reader) (*TitleRules, error) {
 		return nil, fmt.Errorf("decode title rules: all structural_cleanup booleans are required")
 	}
 
+	digest := sha256.Sum256(data)
 	rules := &TitleRules{
+		digest:                  hex.EncodeToString(digest[:]),
 		protectedTokensCI:       make(map[string]struct{}, len(file.ProtectedTokensCI)),
 		protectedTokensExact:    make(map[string]struct{}, len(file.ProtectedTokensExact)),
 		protectedSubstringsCI:   make([]string, 0, len(file.ProtectedSubstringsCI)),
@@ -262,6 +269,7 @@ func cloneTitleRules(rules *TitleRules) *TitleRules {
 		forceNormalizeTokensCI:  make(map[string]struct{}, len(rules.forceNormalizeTokensCI)),
 		forceNormalizePhrasesCI: append([]string(nil), rules.forceNormalizePhrasesCI...),
 		structuralCleanup:       rules.structuralCleanup,
+		digest:                  rules.digest,
 	}
 	for token := range rules.protectedTokensCI {
 		clone.protectedTokensCI[token] = struct{}{}
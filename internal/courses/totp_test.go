@@ -0,0 +1,118 @@
+package courses
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPMatchesRFC6238Vectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1 secret "12345678901234567890", truncated
+	// to the 6 digits authenticator apps show.
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		if got != test.want {
+			t.Fatalf("TOTPCode(%d) = %s, want %s", test.unix, got, test.want)
+		}
+	}
+}
+
+func TestVerifyTOTPHonorsSkew(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret() error = %v", err)
+	}
+	now := time.Unix(1_800_000_000, 0)
+	previous, _ := TOTPCode(secret, TOTPStep(now)-1)
+
+	if _, ok := VerifyTOTP(secret, previous, now, 0); ok {
+		t.Fatal("previous code accepted without skew")
+	}
+	step, ok := VerifyTOTP(secret, previous, now, 1)
+	if !ok || step != TOTPStep(now)-1 {
+		t.Fatalf("VerifyTOTP() = %d, %v; want previous step", step, ok)
+	}
+	if _, ok := VerifyTOTP(secret, "12345", now, 1); ok {
+		t.Fatal("short code accepted")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	got := TOTPProvisioningURI("JBSWY3DPEHPK3PXP", "Dummy Page", "alice@example.test")
+	want := "otpauth://totp/Dummy%20Page:alice@example.test?algorithm=SHA1&digits=6&issuer=Dummy+Page&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Fatalf("TOTPProvisioningURI() = %s, want %s", got, want)
+	}
+}
+
+func TestUnlockAccountsEnrollmentRoundTrip(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	accounts := NewUnlockAccounts()
+	if _, err := accounts.Add(" Alice "); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	account, codes, err := accounts.EnrollTOTP("alice", now)
+	if err != nil {
+		t.Fatalf("EnrollTOTP() error = %v", err)
+	}
+	if account.TOTPEnabled || len(codes) != unlockRecoveryCodes || len(account.RecoveryCodes) != unlockRecoveryCodes {
+		t.Fatalf("enrolled account = %+v, codes = %v", account, codes)
+	}
+	code, _ := TOTPCode(account.TOTPSecret, TOTPStep(now))
+	if err := accounts.ConfirmTOTP("alice", code, now.Add(time.Hour), 1); !errors.Is(err, ErrTOTPInvalidCode) {
+		t.Fatalf("ConfirmTOTP() stale code error = %v, want %v", err, ErrTOTPInvalidCode)
+	}
+	if err := accounts.ConfirmTOTP("ALICE", code, now, 1); err != nil {
+		t.Fatalf("ConfirmTOTP() error = %v", err)
+	}
+
+	if !accounts.UseRecoveryCode("alice", strings.ToUpper(codes[3])) {
+		t.Fatal("recovery code rejected")
+	}
+	if accounts.UseRecoveryCode("alice", codes[3]) {
+		t.Fatal("recovery code accepted twice")
+	}
+
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		t.Fatalf("marshal accounts: %v", err)
+	}
+	loaded, err := LoadUnlockAccounts(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("LoadUnlockAccounts() error = %v", err)
+	}
+	reloaded, ok := loaded.Get("alice")
+	if !ok || !reloaded.TOTPEnabled || len(reloaded.RecoveryCodes) != unlockRecoveryCodes-1 || strings.Contains(string(data), codes[0]) {
+		t.Fatalf("reloaded account = %+v", reloaded)
+	}
+}
+
+func TestLoadUnlockAccountsRejectsInvalidAccounts(t *testing.T) {
+	for _, input := range []string{
+		`{"schema_version":"unlock-accounts/v1"}`,
+		`{"schema_version":"unlock-accounts/v1","accounts":[{"name":"Alice","totp_enabled":false}]}`,
+		`{"schema_version":"unlock-accounts/v1","accounts":[{"name":"alice","totp_enabled":true}]}`,
+		`{"schema_version":"unlock-accounts/v1","accounts":[{"name":"alice","totp_enabled":false},{"name":"alice","totp_enabled":false}]}`,
+		`{"schema_version":"unlock-accounts/v1","accounts":[{"name":"alice","totp_enabled":false,"recovery_codes":["plain"]}]}`,
+	} {
+		if _, err := LoadUnlockAccounts(strings.NewReader(input)); err == nil {
+			t.Fatalf("LoadUnlockAccounts(%s) error = nil", input)
+		}
+	}
+}
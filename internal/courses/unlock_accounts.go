@@ -0,0 +1,276 @@
+package courses
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	unlockAccountsSchema   = "unlock-accounts/v1"
+	maxUnlockAccountsBytes = 1 << 20
+	unlockRecoveryCodes    = 10
+)
+
+var (
+	unlockAccountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._@-]{0,63}$`)
+	recoveryCodeHashPattern  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+	ErrUnlockAccountNotFound = errors.New("unlock account not found")
+	ErrTOTPNotEnrolled       = errors.New("totp is not enrolled")
+	ErrTOTPInvalidCode       = errors.New("invalid totp code")
+)
+
+// UnlockAccounts is the optional list of people allowed to unlock the
+// catalog. An account with TOTP enabled must also present a current code or
+// one of its single-use recovery codes.
+type UnlockAccounts struct {
+	accounts map[string]UnlockAccount
+}
+
+type UnlockAccount struct {
+	Name          string   `json:"name"`
+	TOTPSecret    string   `json:"totp_secret,omitempty"`
+	TOTPEnabled   bool     `json:"totp_enabled"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	EnrolledAt    string   `json:"enrolled_at,omitempty"`
+}
+
+type unlockAccountsFile struct {
+	SchemaVersion string            `json:"schema_version"`
+	Accounts      []json.RawMessage `json:"accounts"`
+}
+
+func NewUnlockAccounts() *UnlockAccounts {
+	return &UnlockAccounts{accounts: make(map[string]UnlockAccount)}
+}
+
+func LoadUnlockAccounts(r io.Reader) (*UnlockAccounts, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxUnlockAccountsBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read unlock accounts: %w", err)
+	}
+	if len(data) > maxUnlockAccountsBytes {
+		return nil, errors.New("read unlock accounts: file exceeds size limit")
+	}
+	if !utf8.Valid(data) {
+		return nil, errors.New("read unlock accounts: invalid utf-8")
+	}
+	if err := rejectDuplicateTopLevelKeys(data); err != nil {
+		return nil, fmt.Errorf("decode unlock accounts: %w", err)
+	}
+
+	var file unlockAccountsFile
+	if err := decodeSingleJSONValue(data, &file); err != nil {
+		return nil, fmt.Errorf("decode unlock accounts: %w", err)
+	}
+	if file.SchemaVersion != unlockAccountsSchema {
+		return nil, fmt.Errorf("decode unlock accounts: unsupported schema_version %q", file.SchemaVersion)
+	}
+	if file.Accounts == nil {
+		return nil, errors.New("decode unlock accounts: accounts is required")
+	}
+
+	accounts := NewUnlockAccounts()
+	for index, raw := range file.Accounts {
+		if err := rejectDuplicateTopLevelKeys(raw); err != nil {
+			return nil, fmt.Errorf("decode unlock accounts: accounts[%d]: %w", index, err)
+		}
+		var account UnlockAccount
+		if err := decodeSingleJSONValue(raw, &account); err != nil {
+			return nil, fmt.Errorf("decode unlock accounts: accounts[%d]: %w", index, err)
+		}
+		if err := validateUnlockAccount(account); err != nil {
+			return nil, fmt.Errorf("decode unlock accounts: accounts[%d]: %w", index, err)
+		}
+		if _, exists := accounts.accounts[account.Name]; exists {
+			return nil, fmt.Errorf("decode unlock accounts: duplicate name %q", account.Name)
+		}
+		accounts.accounts[account.Name] = account
+	}
+	return accounts, nil
+}
+
+// NormalizeUnlockAccountName maps user input to the stored account name.
+func NormalizeUnlockAccountName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func (a *UnlockAccounts) Get(name string) (UnlockAccount, bool) {
+	account, ok := a.accounts[NormalizeUnlockAccountName(name)]
+	return account, ok
+}
+
+func (a *UnlockAccounts) List() []UnlockAccount {
+	accounts := make([]UnlockAccount, 0, len(a.accounts))
+	for _, account := range a.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+	return accounts
+}
+
+func (a *UnlockAccounts) Add(name string) (UnlockAccount, error) {
+	account := UnlockAccount{Name: NormalizeUnlockAccountName(name)}
+	if err := validateUnlockAccount(account); err != nil {
+		return UnlockAccount{}, err
+	}
+	if _, exists := a.accounts[account.Name]; exists {
+		return UnlockAccount{}, fmt.Errorf("unlock account %q already exists", account.Name)
+	}
+	a.accounts[account.Name] = account
+	return account, nil
+}
+
+func (a *UnlockAccounts) Remove(name string) error {
+	name = NormalizeUnlockAccountName(name)
+	if _, ok := a.accounts[name]; !ok {
+		return ErrUnlockAccountNotFound
+	}
+	delete(a.accounts, name)
+	return nil
+}
+
+// EnrollTOTP replaces the account's secret and recovery codes. TOTP stays
+// disabled until ConfirmTOTP sees a code from the new secret, so a failed
+// enrollment never locks the account out. The recovery codes are returned in
+// plain text once; only their hashes are stored.
+func (a *UnlockAccounts) EnrollTOTP(name string, now time.Time) (UnlockAccount, []string, error) {
+	account, ok := a.Get(name)
+	if !ok {
+		return UnlockAccount{}, nil, ErrUnlockAccountNotFound
+	}
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return UnlockAccount{}, nil, err
+	}
+	codes := make([]string, 0, unlockRecoveryCodes)
+	hashes := make([]string, 0, unlockRecoveryCodes)
+	for range unlockRecoveryCodes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return UnlockAccount{}, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, recoveryCodeHash(code))
+	}
+	account.TOTPSecret = secret
+	account.TOTPEnabled = false
+	account.RecoveryCodes = hashes
+	account.EnrolledAt = now.UTC().Format(time.RFC3339)
+	a.accounts[account.Name] = account
+	return account, codes, nil
+}
+
+func (a *UnlockAccounts) ConfirmTOTP(name, code string, now time.Time, skew int) error {
+	account, ok := a.Get(name)
+	if !ok {
+		return ErrUnlockAccountNotFound
+	}
+	if account.TOTPSecret == "" {
+		return ErrTOTPNotEnrolled
+	}
+	if _, ok := VerifyTOTP(account.TOTPSecret, code, now, skew); !ok {
+		return ErrTOTPInvalidCode
+	}
+	account.TOTPEnabled = true
+	a.accounts[account.Name] = account
+	return nil
+}
+
+func (a *UnlockAccounts) DisableTOTP(name string) error {
+	account, ok := a.Get(name)
+	if !ok {
+		return ErrUnlockAccountNotFound
+	}
+	account.TOTPSecret = ""
+	account.TOTPEnabled = false
+	account.RecoveryCodes = nil
+	account.EnrolledAt = ""
+	a.accounts[account.Name] = account
+	return nil
+}
+
+// UseRecoveryCode consumes a matching recovery code and reports whether one
+// matched.
+func (a *UnlockAccounts) UseRecoveryCode(name, code string) bool {
+	account, ok := a.Get(name)
+	if !ok {
+		return false
+	}
+	hash := recoveryCodeHash(code)
+	for index, stored := range account.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			account.RecoveryCodes = append(account.RecoveryCodes[:index:index], account.RecoveryCodes[index+1:]...)
+			a.accounts[account.Name] = account
+			return true
+		}
+	}
+	return false
+}
+
+func (a UnlockAccounts) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		SchemaVersion string          `json:"schema_version"`
+		Accounts      []UnlockAccount `json:"accounts"`
+	}{
+		SchemaVersion: unlockAccountsSchema,
+		Accounts:      a.List(),
+	})
+}
+
+func validateUnlockAccount(account UnlockAccount) error {
+	if !unlockAccountNamePattern.MatchString(account.Name) {
+		return fmt.Errorf("invalid name %q", account.Name)
+	}
+	if account.TOTPSecret != "" {
+		if _, err := decodeTOTPSecret(account.TOTPSecret); err != nil {
+			return err
+		}
+	} else if account.TOTPEnabled {
+		return errors.New("totp_enabled requires totp_secret")
+	}
+	for _, hash := range account.RecoveryCodes {
+		if !recoveryCodeHashPattern.MatchString(hash) {
+			return errors.New("invalid recovery code hash")
+		}
+	}
+	if account.EnrolledAt != "" {
+		if _, err := time.Parse(time.RFC3339, account.EnrolledAt); err != nil {
+			return fmt.Errorf("invalid enrolled_at %q: %w", account.EnrolledAt, err)
+		}
+	}
+	return nil
+}
+
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(raw))[:12]
+	return code[:4] + "-" + code[4:8] + "-" + code[8:], nil
+}
+
+func recoveryCodeHash(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte("unlock-recovery-code\x1f" + normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,9 @@
+// Package filelock serializes read-modify-write cycles on files shared by the
+// server and the moderation CLIs, which run as separate processes.
+package filelock
+
+// Path returns the sidecar lock file guarding path. The data file itself is
+// replaced by rename on every write, so it cannot carry the lock.
+func Path(path string) string {
+	return path + ".lock"
+}
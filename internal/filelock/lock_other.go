@@ -0,0 +1,9 @@
+//go:build !unix
+
+package filelock
+
+// Lock is a no-op where advisory file locks are unavailable; the server's
+// in-process mutexes still serialize its own writers.
+func Lock(string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	historySchema   = "job-history/v1"
+	maxHistoryRuns  = 200
+	maxHistoryBytes = 4 << 20
+)
+
+type historyFile struct {
+	SchemaVersion string `json:"schema_version"`
+	Runs          []Run  `json:"runs"`
+}
+
+func loadHistory(path string) ([]Run, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxHistoryBytes {
+		return nil, errors.New("job history exceeds size limit")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file historyFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode job history: %w", err)
+	}
+	if file.SchemaVersion != historySchema {
+		return nil, fmt.Errorf("job history schema %q, want %q", file.SchemaVersion, historySchema)
+	}
+	runs := file.Runs
+	if len(runs) > maxHistoryRuns {
+		runs = runs[len(runs)-maxHistoryRuns:]
+	}
+	// A run still marked running was interrupted by a restart.
+	for i := range runs {
+		if runs[i].Status == StatusRunning {
+			runs[i].Status = StatusFailed
+			runs[i].Error = "interrupted"
+		}
+	}
+	return runs, nil
+}
+
+func saveHistory(path string, runs []Run) error {
+	if strings.TrimSpace(path) == "" {
+		return nil
+	}
+	finished := make([]Run, 0, len(runs))
+	for _, run := range runs {
+		if run.Status != StatusRunning {
+			finished = append(finished, run)
+		}
+	}
+	data, err := json.MarshalIndent(historyFile{SchemaVersion: historySchema, Runs: finished}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomicFile(path, append(data, '\n'))
+}
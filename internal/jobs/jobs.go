@@ -0,0 +1,398 @@
+// Package jobs runs the catalog pipeline (link audit, link enrichment and
+// catalog rebuild) on schedules inside the server process.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phuslu/log"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+type Name string
+
+const (
+	Audit   Name = "audit"
+	Enrich  Name = "enrich"
+	Rebuild Name = "rebuild"
+)
+
+type Trigger string
+
+const (
+	TriggerSchedule Trigger = "schedule"
+	TriggerManual   Trigger = "manual"
+)
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+)
+
+// ErrBusy is returned when a manual run is requested while another job holds
+// the pipeline lock.
+var ErrBusy = errors.New("another job is running")
+
+// Config enables a job by giving it a non-zero interval. Paths and build
+// options mirror the flags of the courses-data, courses-link-audit and
+// courses-link-enrich commands.
+type Config struct {
+	AuditEvery   time.Duration
+	EnrichEvery  time.Duration
+	RebuildEvery time.Duration
+
+	SourceDir            string
+	TorrentDir           string
+	TitleRulesFile       string
+	TaxonomyFile         string
+	FormatRulesFile      string
+	EntryOverridesFile   string
+	LinkTombstonesFile   string
+	LinkSuppressionsFile string
+	AuditPolicyFile      string
+	AuditReportFile      string
+	EnrichmentPolicyFile string
+	EnrichmentCacheFile  string
+	HistoryFile          string
+	LockFile             string
+	CrossChannelDedup    bool
+	ClassifierThreshold  float64
+}
+
+func (c Config) Enabled() bool {
+	return c.AuditEvery > 0 || c.EnrichEvery > 0 || c.RebuildEvery > 0
+}
+
+// Validate reports every path a scheduled job needs but is missing, and
+// every configured rebuild file that does not load.
+func (c Config) Validate() error {
+	var problems []error
+	require := func(job Name, field, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, fmt.Errorf("%s job requires %s", job, field))
+		}
+	}
+	for _, every := range []time.Duration{c.AuditEvery, c.EnrichEvery, c.RebuildEvery} {
+		if every < 0 {
+			problems = append(problems, fmt.Errorf("job interval %s is negative", every))
+		}
+	}
+	if c.AuditEvery > 0 {
+		require(Audit, "AuditPolicyFile", c.AuditPolicyFile)
+		require(Audit, "AuditReportFile", c.AuditReportFile)
+		require(Audit, "LinkTombstonesFile", c.LinkTombstonesFile)
+	}
+	if c.EnrichEvery > 0 {
+		require(Enrich, "AuditPolicyFile", c.AuditPolicyFile)
+		require(Enrich, "EnrichmentPolicyFile", c.EnrichmentPolicyFile)
+		require(Enrich, "EnrichmentCacheFile", c.EnrichmentCacheFile)
+	}
+	if c.RebuildEvery > 0 {
+		require(Rebuild, "SourceDir", c.SourceDir)
+	}
+	if c.ClassifierThreshold < 0 || c.ClassifierThreshold > 1 {
+		problems = append(problems, errors.New("ClassifierThreshold must be between 0 and 1"))
+	}
+	if _, err := courses.LoadBuildOptions(c.buildFiles()); err != nil {
+		problems = append(problems, err)
+	}
+	return errors.Join(problems...)
+}
+
+// buildFiles maps the rebuild settings onto the files courses-data takes,
+// so rebuild and Validate load the same options as a manual build.
+func (c Config) buildFiles() courses.BuildFiles {
+	return courses.BuildFiles{
+		TorrentDir:       c.TorrentDir,
+		TitleRules:       c.TitleRulesFile,
+		Taxonomy:         c.TaxonomyFile,
+		FormatRules:      c.FormatRulesFile,
+		LinkTombstones:   c.LinkTombstonesFile,
+		LinkSuppressions: c.LinkSuppressionsFile,
+		LinkEnrichment:   c.EnrichmentCacheFile,
+		EntryOverrides:   c.EntryOverridesFile,
+
+		CrossChannelDedup:     c.CrossChannelDedup,
+		ClassifierThreshold:   c.ClassifierThreshold,
+		MissingLinkFilesEmpty: true,
+	}
+}
+
+type Run struct {
+	ID         string    `json:"id"`
+	Job        Name      `json:"job"`
+	Trigger    Trigger   `json:"trigger"`
+	Status     Status    `json:"status"`
+	Stage      string    `json:"stage,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Summary    string    `json:"summary,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Scheduler serializes pipeline jobs behind one lock, because they read and
+// write each other's files, and records every run in its history.
+type Scheduler struct {
+	cfg         Config
+	catalogPath string
+	logger      *log.Logger
+	now         func() time.Time
+	newClient   func(*courses.LinkAuditPolicy) (courses.LinkAuditHTTPClient, error)
+
+	pipeline sync.Mutex
+
+	mu      sync.Mutex
+	base    context.Context
+	history []Run
+	catalog catalogMemo
+}
+
+func New(cfg Config, catalogPath string, logger *log.Logger) *Scheduler {
+	s := &Scheduler{
+		cfg:         cfg,
+		catalogPath: catalogPath,
+		logger:      logger,
+		now:         time.Now,
+		newClient:   courses.NewSafeLinkAuditClient,
+		base:        context.Background(),
+	}
+	history, err := loadHistory(cfg.HistoryFile)
+	if err != nil {
+		logger.Error().Err(err).Str("file", cfg.HistoryFile).Msg("Job history unavailable, starting empty")
+	}
+	s.history = history
+	return s
+}
+
+func (s *Scheduler) Enabled() bool {
+	return s.cfg.Enabled()
+}
+
+// Run starts every scheduled job and blocks until ctx is done. Runs started
+// with Start are cancelled with ctx as well.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.base = ctx
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for job, every := range map[Name]time.Duration{
+		Audit:   s.cfg.AuditEvery,
+		Enrich:  s.cfg.EnrichEvery,
+		Rebuild: s.cfg.RebuildEvery,
+	} {
+		if every <= 0 {
+			continue
+		}
+		wg.Go(func() {
+			ticker := time.NewTicker(every)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					_, _ = s.run(ctx, job, TriggerSchedule)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// RunNow runs job immediately and returns ErrBusy instead of waiting when
+// another job is in progress.
+func (s *Scheduler) RunNow(ctx context.Context, job Name) (Run, error) {
+	return s.run(ctx, job, TriggerManual)
+}
+
+// Start is RunNow in the background: it returns the run as soon as it holds
+// the lock, and Get reports its progress.
+func (s *Scheduler) Start(job Name) (Run, error) {
+	execute, err := s.job(job)
+	if err != nil {
+		return Run{}, err
+	}
+	run, unlock, err := s.begin(job, TriggerManual)
+	if err != nil {
+		return run, err
+	}
+	s.mu.Lock()
+	ctx := s.base
+	s.mu.Unlock()
+	go func() {
+		defer unlock()
+		summary, err := execute(ctx, s.progress(run.ID))
+		_, _ = s.finish(run, summary, err)
+	}()
+	return run, nil
+}
+
+// Get returns the recorded run with the given ID.
+func (s *Scheduler) Get(id string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.history {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	return Run{}, false
+}
+
+// History returns the recorded runs, oldest first.
+func (s *Scheduler) History() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Run(nil), s.history...)
+}
+
+type jobFunc func(ctx context.Context, progress func(stage string)) (string, error)
+
+func (s *Scheduler) job(job Name) (jobFunc, error) {
+	execute, ok := map[Name]jobFunc{
+		Audit:   s.audit,
+		Enrich:  s.enrich,
+		Rebuild: s.rebuild,
+	}[job]
+	if !ok {
+		return nil, fmt.Errorf("unknown job %q", job)
+	}
+	return execute, nil
+}
+
+func (s *Scheduler) run(ctx context.Context, job Name, trigger Trigger) (Run, error) {
+	execute, err := s.job(job)
+	if err != nil {
+		return Run{}, err
+	}
+	run, unlock, err := s.begin(job, trigger)
+	if err != nil {
+		return run, err
+	}
+	defer unlock()
+	summary, err := execute(ctx, s.progress(run.ID))
+	return s.finish(run, summary, err)
+}
+
+func (s *Scheduler) begin(job Name, trigger Trigger) (Run, func(), error) {
+	run := Run{ID: newRunID(), Job: job, Trigger: trigger, StartedAt: s.now().UTC()}
+	unlock, err := s.lock(trigger)
+	if err != nil {
+		run.Status = StatusSkipped
+		run.Error = err.Error()
+		run.FinishedAt = run.StartedAt
+		s.record(run)
+		return run, nil, err
+	}
+	run.Status = StatusRunning
+	s.record(run)
+	return run, unlock, nil
+}
+
+func (s *Scheduler) finish(run Run, summary string, err error) (Run, error) {
+	if current, ok := s.Get(run.ID); ok {
+		run.Stage = current.Stage
+	}
+	run.FinishedAt = s.now().UTC()
+	run.Summary = summary
+	run.Status = StatusSucceeded
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+	}
+	s.record(run)
+	return run, err
+}
+
+// progress returns a callback that publishes the current stage of a run.
+func (s *Scheduler) progress(id string) func(string) {
+	return func(stage string) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i := range s.history {
+			if s.history[i].ID == id {
+				s.history[i].Stage = stage
+				return
+			}
+		}
+	}
+}
+
+// lock makes scheduled runs wait for the in-process lock, while manual runs
+// fail fast. Another process holding the lock file always skips the run.
+func (s *Scheduler) lock(trigger Trigger) (func(), error) {
+	if trigger == TriggerManual {
+		if !s.pipeline.TryLock() {
+			return nil, ErrBusy
+		}
+	} else {
+		s.pipeline.Lock()
+	}
+	release, err := lockFile(s.cfg.LockFile)
+	if err != nil {
+		s.pipeline.Unlock()
+		return nil, err
+	}
+	return func() {
+		release()
+		s.pipeline.Unlock()
+	}, nil
+}
+
+func (s *Scheduler) record(run Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	replaced := false
+	for i := range s.history {
+		if s.history[i].ID == run.ID {
+			s.history[i] = run
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		s.history = append(s.history, run)
+		if len(s.history) > maxHistoryRuns {
+			s.history = s.history[len(s.history)-maxHistoryRuns:]
+		}
+	}
+
+	event := s.logger.Info()
+	if run.Status == StatusFailed || run.Status == StatusSkipped {
+		event = s.logger.Warn()
+	}
+	event.Str("job", string(run.Job)).
+		Str("run_id", run.ID).
+		Str("trigger", string(run.Trigger)).
+		Str("status", string(run.Status)).
+		Str("summary", run.Summary).
+		Str("error", run.Error).
+		Msg("Catalog job")
+
+	if run.Status == StatusRunning {
+		return
+	}
+	if err := saveHistory(s.cfg.HistoryFile, s.history); err != nil {
+		s.logger.Error().Err(err).Str("file", s.cfg.HistoryFile).Msg("Save job history")
+	}
+}
+
+func newRunID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,344 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phuslu/log"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+func TestRebuildSwapsValidatedCatalog(t *testing.T) {
+	scheduler, cfg := newTestScheduler(t)
+	writeTestSource(t, cfg.SourceDir, "a.json", "1:1:0", "1:1", 1, "Practical Go")
+
+	run, err := scheduler.RunNow(context.Background(), Rebuild)
+	if err != nil {
+		t.Fatalf("RunNow() error = %v", err)
+	}
+	if run.Status != StatusSucceeded || !strings.Contains(run.Summary, "courses=1") {
+		t.Fatalf("run = %+v", run)
+	}
+	catalog, err := loadCatalogFile(scheduler.catalogPath)
+	if err != nil {
+		t.Fatalf("load rebuilt catalog: %v", err)
+	}
+	if len(catalog.Entries) != 1 || catalog.Entries[0].Title != "Practical Go" {
+		t.Fatalf("catalog entries = %+v", catalog.Entries)
+	}
+	for _, path := range []string{scheduler.catalogPath, courses.SourceStatePath(scheduler.catalogPath)} {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode().Perm() != 0o600 {
+			t.Fatalf("%s mode = %v, %v", filepath.Base(path), info, err)
+		}
+	}
+}
+
+func TestRebuildReadsCuratedLists(t *testing.T) {
+	scheduler, cfg := newTestScheduler(t)
+	writeTestSource(t, cfg.SourceDir, "a.json", "1:1:0", "1:1", 1, "Practical Go")
+	lists := map[string]string{
+		"curated.csv": "title,author,added_at,links\nCurated CSV Course,Fixture Author,2024-01-01,https://files.example.test/csv\n",
+		"notes.md":    "## 2024-01-02\n- [Curated Markdown Course](https://files.example.test/md)\n",
+		"ignore.txt":  "- [Ignored Course](https://files.example.test/txt)\n",
+	}
+	for name, list := range lists {
+		if err := os.WriteFile(filepath.Join(cfg.SourceDir, name), []byte(list), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	run, err := scheduler.RunNow(context.Background(), Rebuild)
+	if err != nil || run.Status != StatusSucceeded {
+		t.Fatalf("RunNow() = %+v, %v", run, err)
+	}
+	catalog, err := loadCatalogFile(scheduler.catalogPath)
+	if err != nil {
+		t.Fatalf("load rebuilt catalog: %v", err)
+	}
+	titles := make([]string, 0, len(catalog.Entries))
+	for _, entry := range catalog.Entries {
+		titles = append(titles, entry.Title)
+	}
+	slices.Sort(titles)
+	if want := []string{"Curated CSV Course", "Curated Markdown Course", "Practical Go"}; !slices.Equal(titles, want) {
+		t.Fatalf("catalog titles = %v, want %v", titles, want)
+	}
+}
+
+func TestRebuildPassesBuildOptions(t *testing.T) {
+	baseline := rebuildTestFingerprint(t, func(*testing.T, *Config) {})
+	tests := map[string]func(t *testing.T, cfg *Config){
+		"CrossChannelDedup": func(_ *testing.T, cfg *Config) { cfg.CrossChannelDedup = true },
+		"TaxonomyFile": func(t *testing.T, cfg *Config) {
+			cfg.TaxonomyFile = writeTestRulesFile(t, cfg, "taxonomy.json", "../courses/taxonomy_default.json")
+		},
+		"FormatRulesFile": func(t *testing.T, cfg *Config) {
+			cfg.FormatRulesFile = writeTestRulesFile(t, cfg, "format-rules.json", "../courses/format_rules_default.json")
+		},
+		"ClassifierThreshold": func(_ *testing.T, cfg *Config) { cfg.ClassifierThreshold = 0.8 },
+	}
+	for name, configure := range tests {
+		if rebuildTestFingerprint(t, configure) == baseline {
+			t.Fatalf("%s does not reach the rebuild", name)
+		}
+	}
+}
+
+func TestRebuildAppliesEntryOverrides(t *testing.T) {
+	scheduler, cfg := newTestScheduler(t)
+	writeTestSource(t, cfg.SourceDir, "a.json", "1:1:0", "1:1", 1, "Practical Go")
+	scheduler.cfg.EntryOverridesFile = filepath.Join(filepath.Dir(cfg.SourceDir), "entry-overrides.json")
+	overrides := `{"schema_version":"entry-overrides/v1","overrides":[
+		{"source_entry_id":"1:1:0","hidden":true,"reason":"duplicate"},
+		{"source_entry_id":"9:9:0","title":"Gone","reason":"typo"}
+	]}`
+	if err := os.WriteFile(scheduler.cfg.EntryOverridesFile, []byte(overrides), 0o600); err != nil {
+		t.Fatalf("write entry overrides: %v", err)
+	}
+
+	run, err := scheduler.RunNow(context.Background(), Rebuild)
+	if err != nil || !strings.Contains(run.Summary, "overridden=1 stale_overrides=1") {
+		t.Fatalf("RunNow() = %+v, %v", run, err)
+	}
+	catalog, err := loadCatalogFile(scheduler.catalogPath)
+	if err != nil {
+		t.Fatalf("load rebuilt catalog: %v", err)
+	}
+	if !catalog.Entries[0].Hidden || !slices.Equal(catalog.StaleOverrides, []string{"9:9:0"}) {
+		t.Fatalf("catalog entry = %+v, stale overrides = %v", catalog.Entries[0], catalog.StaleOverrides)
+	}
+}
+
+func TestRebuildKeepsPreviousCatalogOnFailure(t *testing.T) {
+	scheduler, cfg := newTestScheduler(t)
+	if err := os.MkdirAll(filepath.Dir(scheduler.catalogPath), 0o700); err != nil {
+		t.Fatalf("create data dir: %v", err)
+	}
+	if err := os.WriteFile(scheduler.catalogPath, []byte("previous"), 0o600); err != nil {
+		t.Fatalf("write previous catalog: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.SourceDir, "broken.json"), []byte(`{"schema_version":`), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	run, err := scheduler.RunNow(context.Background(), Rebuild)
+	if err == nil || run.Status != StatusFailed {
+		t.Fatalf("RunNow() = %+v, %v; want failure", run, err)
+	}
+	if data, _ := os.ReadFile(scheduler.catalogPath); string(data) != "previous" {
+		t.Fatalf("catalog replaced after failed rebuild: %q", data)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(scheduler.catalogPath), ".courses-*"))
+	if len(leftovers) != 0 {
+		t.Fatalf("temporary catalogs left behind: %v", leftovers)
+	}
+}
+
+func TestRunNowSkipsWhileAnotherJobRuns(t *testing.T) {
+	scheduler, _ := newTestScheduler(t)
+	scheduler.pipeline.Lock()
+	run, err := scheduler.RunNow(context.Background(), Audit)
+	scheduler.pipeline.Unlock()
+	if !errors.Is(err, ErrBusy) || run.Status != StatusSkipped {
+		t.Fatalf("RunNow() = %+v, %v; want skipped busy run", run, err)
+	}
+
+	release, err := lockFile(scheduler.cfg.LockFile)
+	if err != nil {
+		t.Fatalf("lockFile() error = %v", err)
+	}
+	defer release()
+	if _, err := lockFile(scheduler.cfg.LockFile); !errors.Is(err, ErrBusy) {
+		t.Fatalf("second lockFile() error = %v, want ErrBusy", err)
+	}
+}
+
+func TestHistoryPersistsAcrossRestarts(t *testing.T) {
+	scheduler, cfg := newTestScheduler(t)
+	if _, err := scheduler.RunNow(context.Background(), Rebuild); err == nil {
+		t.Fatal("rebuild without sources succeeded")
+	}
+
+	reloaded := New(cfg, scheduler.catalogPath, testLogger())
+	history := reloaded.History()
+	if len(history) != 1 || history[0].Job != Rebuild || history[0].Status != StatusFailed ||
+		!strings.Contains(history[0].Error, "no exports") {
+		t.Fatalf("history = %+v", history)
+	}
+
+	interrupted := fmt.Sprintf(`{"schema_version":"job-history/v1","runs":[{"id":"x","job":"audit","trigger":"schedule","status":"running","started_at":%q}]}`, time.Now().UTC().Format(time.RFC3339))
+	if err := os.WriteFile(cfg.HistoryFile, []byte(interrupted), 0o600); err != nil {
+		t.Fatalf("write history: %v", err)
+	}
+	history = New(cfg, scheduler.catalogPath, testLogger()).History()
+	if len(history) != 1 || history[0].Status != StatusFailed || history[0].Error != "interrupted" {
+		t.Fatalf("interrupted history = %+v", history)
+	}
+}
+
+func TestConfigValidateRequiresJobInputs(t *testing.T) {
+	if err := (Config{}).Validate(); err != nil {
+		t.Fatalf("disabled Validate() error = %v", err)
+	}
+	err := Config{AuditEvery: time.Hour, EnrichEvery: time.Hour, RebuildEvery: -time.Minute, ClassifierThreshold: 1.5}.Validate()
+	if err == nil {
+		t.Fatal("Validate() error = nil")
+	}
+	for _, want := range []string{"audit job requires AuditPolicyFile", "enrich job requires EnrichmentCacheFile", "negative", "ClassifierThreshold"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("Validate() error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestConfigValidateLoadsRebuildFiles(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`{"schema_version":"unknown/v0"}`), 0o600); err != nil {
+		t.Fatalf("write malformed file: %v", err)
+	}
+	tests := map[string]Config{
+		"load title rules":     {TitleRulesFile: malformed},
+		"load taxonomy":        {TaxonomyFile: malformed},
+		"load format rules":    {FormatRulesFile: malformed},
+		"load entry overrides": {EntryOverridesFile: malformed},
+	}
+	for want, cfg := range tests {
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Validate() error = %v, want %s problem", err, want)
+		}
+	}
+
+	// Link files other jobs produce may not exist yet.
+	missing := filepath.Join(dir, "missing.json")
+	cfg := Config{LinkTombstonesFile: missing, LinkSuppressionsFile: missing, EnrichmentCacheFile: missing}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v, want missing link files accepted", err)
+	}
+}
+
+func newTestScheduler(t *testing.T) (*Scheduler, Config) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := Config{
+		RebuildEvery: time.Hour,
+		SourceDir:    filepath.Join(dir, "exports"),
+		HistoryFile:  filepath.Join(dir, "job-history.json"),
+		LockFile:     filepath.Join(dir, "jobs.lock"),
+	}
+	if err := os.Mkdir(cfg.SourceDir, 0o700); err != nil {
+		t.Fatalf("create source dir: %v", err)
+	}
+	return New(cfg, filepath.Join(dir, "data", "catalog.json.gz"), testLogger()), cfg
+}
+
+// writeTestRulesFile copies a built-in rules file next to the source
+// directory. The extra newline keeps it valid but changes its digest.
+func writeTestRulesFile(t *testing.T, cfg *Config, name, builtin string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(builtin)
+	if err != nil {
+		t.Fatalf("read %s: %v", builtin, err)
+	}
+	path := filepath.Join(filepath.Dir(cfg.SourceDir), name)
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// rebuildTestFingerprint rebuilds one export with the configuration
+// configure sets up and returns the build fingerprint of the catalog.
+func rebuildTestFingerprint(t *testing.T, configure func(t *testing.T, cfg *Config)) string {
+	t.Helper()
+
+	scheduler, cfg := newTestScheduler(t)
+	writeTestSource(t, cfg.SourceDir, "a.json", "1:1:0", "1:1", 1, "Practical Go")
+	configure(t, &scheduler.cfg)
+	if run, err := scheduler.RunNow(context.Background(), Rebuild); err != nil {
+		t.Fatalf("RunNow() = %+v, %v", run, err)
+	}
+	catalog, err := loadCatalogFile(scheduler.catalogPath)
+	if err != nil {
+		t.Fatalf("load rebuilt catalog: %v", err)
+	}
+	return catalog.BuildFingerprint
+}
+
+func testLogger() *log.Logger {
+	return &log.Logger{Writer: log.IOWriter{Writer: io.Discard}}
+}
+
+func writeTestSource(t *testing.T, dir, name, entryID, messageID string, telegramID int, title string) {
+	t.Helper()
+
+	source := fmt.Sprintf(`{
+		"schema_version":"telegram-webk-channel-export/v3",
+		"exported_at":"2026-07-26T00:00:00Z",
+		"source":{"channel_id":1,"title":"Course Export","web_url":"https://example.test"},
+		"stats":{
+			"retrieval":{"exported_message_count":1},
+			"parsing":{"catalog_entry_count":1,"parsed_link_count":1,"password_value_count":0}
+		},
+		"messages":[{"message_id":%q,"telegram_message_id":%d,"url":"https://example.test/messages/%d"}],
+		"catalog_entries":[{
+			"entry_id":%q,
+			"message_id":%q,
+			"source_message_ids":[%q],
+			"added_at":"2024-01-01T00:00:00Z",
+			"origin":"text_block",
+			"title":%q,
+			"year":null,
+			"year_range":null,
+			"availability":"download_link",
+			"links":[{"url":"https://example.test/course/%s","host":"example.test","provider":"example","kind":"file_host","role":"primary","primary":true,"label":null}],
+			"passwords":[],
+			"notes":null,
+			"raw_block":%q
+		}]
+	}`, messageID, telegramID, telegramID, entryID, messageID, messageID, title, messageID, title)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+}
+
+func TestStartReportsProgressAndState(t *testing.T) {
+	scheduler, cfg := newTestScheduler(t)
+	writeTestSource(t, cfg.SourceDir, "a.json", "1:1:0", "1:1", 1, "Practical Go")
+
+	run, err := scheduler.Start(Rebuild)
+	if err != nil || run.Status != StatusRunning {
+		t.Fatalf("Start() = %+v, %v", run, err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for run.Status == StatusRunning {
+		if time.Now().After(deadline) {
+			t.Fatalf("run did not finish: %+v", run)
+		}
+		time.Sleep(10 * time.Millisecond)
+		run, _ = scheduler.Get(run.ID)
+	}
+	if run.Status != StatusSucceeded || run.Stage != "publishing" {
+		t.Fatalf("finished run = %+v", run)
+	}
+
+	state := scheduler.State()
+	if state.Catalog == nil || state.Catalog.Stats.Entries != 1 || len(state.Errors) != 0 {
+		t.Fatalf("state = %+v", state)
+	}
+	if state.Audit != nil || state.Tombstones != nil || state.Enrichment != nil {
+		t.Fatalf("unconfigured sections = %+v", state)
+	}
+}
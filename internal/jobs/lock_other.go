@@ -0,0 +1,9 @@
+//go:build !unix
+
+package jobs
+
+// lockFile is a no-op where advisory file locks are unavailable; the
+// in-process lock still keeps runs from overlapping.
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
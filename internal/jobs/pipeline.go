@@ -0,0 +1,344 @@
+package jobs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+const maxCatalogBytes = 512 << 20
+
+func (s *Scheduler) audit(ctx context.Context, progress func(string)) (string, error) {
+	progress("loading inputs")
+	catalog, err := loadCatalogFile(s.catalogPath)
+	if err != nil {
+		return "", fmt.Errorf("load catalog: %w", err)
+	}
+	policy, err := loadRequired(s.cfg.AuditPolicyFile, courses.LoadLinkAuditPolicy)
+	if err != nil {
+		return "", fmt.Errorf("load audit policy: %w", err)
+	}
+	previous, err := loadOptional(s.cfg.AuditReportFile, courses.LoadLinkAuditReport)
+	if err != nil {
+		return "", fmt.Errorf("load previous report: %w", err)
+	}
+	tombstones, err := loadOptional(s.cfg.LinkTombstonesFile, courses.LoadLinkTombstones)
+	if err != nil {
+		return "", fmt.Errorf("load tombstones: %w", err)
+	}
+	if tombstones == nil {
+		tombstones = courses.NewLinkTombstones()
+	}
+
+	client, err := s.newClient(policy)
+	if err != nil {
+		return "", fmt.Errorf("create safe audit client: %w", err)
+	}
+	progress("auditing links")
+	now := s.now()
+	report, err := courses.AuditCatalogLinks(ctx, catalog, policy, previous, client, now)
+	if err != nil {
+		return "", fmt.Errorf("audit catalog links: %w", err)
+	}
+	added := tombstones.MergeEligibleAuditResults(report, policy.ConfirmationsRequired, now)
+
+	reportJSON, err := marshalPrivateJSON(report)
+	if err != nil {
+		return "", fmt.Errorf("encode audit report: %w", err)
+	}
+	tombstonesJSON, err := marshalPrivateJSON(tombstones)
+	if err != nil {
+		return "", fmt.Errorf("encode tombstones: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	progress("publishing")
+	if err := writeAtomicFile(s.cfg.LinkTombstonesFile, tombstonesJSON); err != nil {
+		return "", fmt.Errorf("publish tombstones: %w", err)
+	}
+	if err := writeAtomicFile(s.cfg.AuditReportFile, reportJSON); err != nil {
+		return "", fmt.Errorf("publish audit report: %w", err)
+	}
+
+	counts := make(map[courses.LinkAuditState]int)
+	for _, result := range report.Results {
+		counts[result.State]++
+	}
+	return fmt.Sprintf(
+		"audited=%d live=%d expired=%d content_mismatch=%d blocked=%d transient=%d unknown=%d tombstones_added=%d tombstones_total=%d",
+		len(report.Results),
+		counts[courses.LinkAuditStateLive],
+		counts[courses.LinkAuditStateExpired],
+		counts[courses.LinkAuditStateContentMismatch],
+		counts[courses.LinkAuditStateBlocked],
+		counts[courses.LinkAuditStateTransient],
+		counts[courses.LinkAuditStateUnknown],
+		added,
+		tombstones.Len(),
+	), nil
+}
+
+func (s *Scheduler) enrich(ctx context.Context, progress func(string)) (string, error) {
+	progress("loading inputs")
+	catalog, err := loadCatalogFile(s.catalogPath)
+	if err != nil {
+		return "", fmt.Errorf("load catalog: %w", err)
+	}
+	auditPolicy, err := loadRequired(s.cfg.AuditPolicyFile, courses.LoadLinkAuditPolicy)
+	if err != nil {
+		return "", fmt.Errorf("load audit policy: %w", err)
+	}
+	enrichmentPolicy, err := loadRequired(s.cfg.EnrichmentPolicyFile, courses.LoadLinkEnrichmentPolicy)
+	if err != nil {
+		return "", fmt.Errorf("load enrichment policy: %w", err)
+	}
+	previous, err := loadOptional(s.cfg.EnrichmentCacheFile, courses.LoadLinkEnrichmentCache)
+	if err != nil {
+		return "", fmt.Errorf("load enrichment cache: %w", err)
+	}
+
+	client, err := s.newClient(auditPolicy)
+	if err != nil {
+		return "", fmt.Errorf("create safe link client: %w", err)
+	}
+	progress("enriching links")
+	cache, stats, err := courses.EnrichCatalogLinks(
+		ctx,
+		catalog,
+		enrichmentPolicy,
+		previous,
+		client,
+		s.now(),
+		false,
+		auditPolicy.Concurrency,
+	)
+	if err != nil {
+		return "", fmt.Errorf("enrich catalog links: %w", err)
+	}
+	var output bytes.Buffer
+	if err := courses.WriteLinkEnrichmentCache(&output, cache); err != nil {
+		return "", fmt.Errorf("encode enrichment cache: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	progress("publishing")
+	if err := writeAtomicFile(s.cfg.EnrichmentCacheFile, output.Bytes()); err != nil {
+		return "", fmt.Errorf("publish enrichment cache: %w", err)
+	}
+	return fmt.Sprintf(
+		"candidates=%d skipped_fresh=%d fetched=%d extracted=%d not_found=%d failed=%d",
+		stats.Candidates,
+		stats.SkippedFresh,
+		stats.Fetched,
+		stats.Extracted,
+		stats.NotFound,
+		stats.Failed,
+	), nil
+}
+
+// rebuild writes the new catalog next to the live one and renames it into
+// place only after it decodes as a complete, non-empty catalog, so the server
+// keeps serving the previous catalog when a build goes wrong.
+func (s *Scheduler) rebuild(ctx context.Context, progress func(string)) (string, error) {
+	progress("loading inputs")
+	inputPaths, err := courses.SourceInputPaths(s.cfg.SourceDir)
+	if err != nil {
+		return "", err
+	}
+	if len(inputPaths) == 0 {
+		return "", errors.New("source directory has no exports")
+	}
+	options, err := courses.LoadBuildOptions(s.cfg.buildFiles())
+	if err != nil {
+		return "", err
+	}
+
+	inputs, closeInputs, err := courses.OpenSourceInputs(inputPaths)
+	if err != nil {
+		return "", err
+	}
+	defer closeInputs()
+
+	outputDir := filepath.Dir(s.catalogPath)
+	if err := os.MkdirAll(outputDir, 0o700); err != nil {
+		return "", fmt.Errorf("create output directory: %w", err)
+	}
+	temp, err := os.CreateTemp(outputDir, ".courses-catalog-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("create temporary catalog: %w", err)
+	}
+	tempPath := temp.Name()
+	defer os.Remove(tempPath)
+	sourcesTemp, err := os.CreateTemp(outputDir, ".courses-sources-*.tmp")
+	if err != nil {
+		_ = temp.Close()
+		return "", fmt.Errorf("create temporary source state: %w", err)
+	}
+	sourcesTempPath := sourcesTemp.Name()
+	defer os.Remove(sourcesTempPath)
+
+	progress("building")
+	stats, buildErr := courses.BuildGzipWithSources(inputs, temp, sourcesTemp, options)
+	closeErr := errors.Join(temp.Close(), sourcesTemp.Close())
+	if buildErr != nil {
+		return "", buildErr
+	}
+	if closeErr != nil {
+		return "", fmt.Errorf("close temporary catalog: %w", closeErr)
+	}
+	progress("validating")
+	built, err := loadCatalogFile(tempPath)
+	if err != nil {
+		return "", fmt.Errorf("validate built catalog: %w", err)
+	}
+	if len(built.Entries) == 0 || len(built.Entries) != stats.Entries {
+		return "", fmt.Errorf("validate built catalog: %d entries, build reported %d", len(built.Entries), stats.Entries)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	progress("publishing")
+	if err := os.Chmod(tempPath, 0o600); err != nil {
+		return "", fmt.Errorf("set catalog permissions: %w", err)
+	}
+	if err := os.Chmod(sourcesTempPath, 0o600); err != nil {
+		return "", fmt.Errorf("set source state permissions: %w", err)
+	}
+	if err := os.Rename(tempPath, s.catalogPath); err != nil {
+		return "", fmt.Errorf("publish catalog: %w", err)
+	}
+	// Keep the source state in step with the catalog so courses-data can
+	// build incrementally from either.
+	if err := os.Rename(sourcesTempPath, courses.SourceStatePath(s.catalogPath)); err != nil {
+		return "", fmt.Errorf("publish source state: %w", err)
+	}
+	summary := fmt.Sprintf(
+		"courses=%d source_entries=%d normalized_titles=%d enriched_links=%d links=%d passwords=%d",
+		stats.Entries,
+		stats.SourceEntries,
+		stats.NormalizedTitles,
+		stats.EnrichedLinks,
+		stats.Links,
+		stats.Passwords,
+	)
+	if options.EntryOverrides != nil {
+		summary += fmt.Sprintf(" overridden=%d stale_overrides=%d", stats.OverriddenEntries, stats.StaleOverrides)
+	}
+	if len(built.StaleOverrides) != 0 {
+		s.logger.Warn().
+			Str("file", s.cfg.EntryOverridesFile).
+			Strs("keys", built.StaleOverrides).
+			Msg("Entry overrides match no course")
+	}
+	return summary, nil
+}
+
+func loadCatalogFile(path string) (courses.Catalog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return courses.Catalog{}, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return courses.Catalog{}, err
+	}
+	defer reader.Close()
+
+	limited := &io.LimitedReader{R: reader, N: maxCatalogBytes + 1}
+	decoder := json.NewDecoder(limited)
+	decoder.DisallowUnknownFields()
+	var catalog courses.Catalog
+	if err := decoder.Decode(&catalog); err != nil {
+		return courses.Catalog{}, err
+	}
+	var extra json.RawMessage
+	if err := decoder.Decode(&extra); err != io.EOF {
+		if err == nil {
+			return courses.Catalog{}, errors.New("multiple catalog values")
+		}
+		return courses.Catalog{}, err
+	}
+	if limited.N <= 0 {
+		return courses.Catalog{}, errors.New("catalog exceeds size limit")
+	}
+	if catalog.SchemaVersion != "courses-catalog/v2" || catalog.Entries == nil {
+		return courses.Catalog{}, errors.New("unsupported catalog")
+	}
+	return catalog, nil
+}
+
+// loadRequired decodes the file at path, or returns nil when no path is
+// configured.
+func loadRequired[T any](path string, load func(io.Reader) (*T, error)) (*T, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return load(file)
+}
+
+// loadOptional is loadRequired for files another job produces, which do not
+// exist until that job first runs.
+func loadOptional[T any](path string, load func(io.Reader) (*T, error)) (*T, error) {
+	value, err := loadRequired(path, load)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func marshalPrivateJSON(value any) ([]byte, error) {
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+func writeAtomicFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".courses-job-*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
+	if err := file.Chmod(0o600); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
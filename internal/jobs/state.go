@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"os"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+// State is a snapshot of the pipeline files as the next job would see them.
+// Each section is nil when its file is not configured or does not exist yet,
+// and Errors lists the files that could not be read.
+type State struct {
+	Catalog      *CatalogState                    `json:"catalog"`
+	Audit        *courses.LinkAuditSummary        `json:"audit"`
+	Tombstones   *int                             `json:"tombstones"`
+	Suppressions *int                             `json:"suppressions"`
+	Enrichment   *courses.LinkEnrichmentFreshness `json:"enrichment"`
+	Errors       map[string]string                `json:"errors,omitempty"`
+	Runs         []Run                            `json:"runs"`
+}
+
+type CatalogState struct {
+	UpdatedAt  time.Time            `json:"updated_at"`
+	Bytes      int64                `json:"bytes"`
+	ExportedAt string               `json:"exported_at"`
+	Stats      courses.CatalogStats `json:"stats"`
+}
+
+// catalogMemo keeps the last decoded catalog until the file changes, because
+// the catalog is by far the largest pipeline file.
+type catalogMemo struct {
+	info    os.FileInfo
+	catalog courses.Catalog
+}
+
+func (s *Scheduler) State() State {
+	state := State{Errors: make(map[string]string), Runs: s.History()}
+	fail := func(part string, err error) {
+		state.Errors[part] = err.Error()
+	}
+
+	catalog, info, err := s.loadCatalog()
+	if err != nil {
+		fail("catalog", err)
+	} else {
+		state.Catalog = &CatalogState{
+			UpdatedAt:  info.ModTime().UTC(),
+			Bytes:      info.Size(),
+			ExportedAt: catalog.ExportedAt,
+			Stats:      catalog.Stats,
+		}
+	}
+
+	if report, err := loadOptional(s.cfg.AuditReportFile, courses.LoadLinkAuditReport); err != nil {
+		fail("audit", err)
+	} else if report != nil {
+		summary := courses.SummarizeLinkAudit(report, catalog)
+		state.Audit = &summary
+	}
+	if tombstones, err := loadOptional(s.cfg.LinkTombstonesFile, courses.LoadLinkTombstones); err != nil {
+		fail("tombstones", err)
+	} else if tombstones != nil {
+		count := tombstones.Len()
+		state.Tombstones = &count
+	}
+	if suppressions, err := loadOptional(s.cfg.LinkSuppressionsFile, courses.LoadLinkSuppressions); err != nil {
+		fail("suppressions", err)
+	} else if suppressions != nil {
+		count := suppressions.Len()
+		state.Suppressions = &count
+	}
+
+	var staleAfter time.Duration
+	if policy, err := loadRequired(s.cfg.EnrichmentPolicyFile, courses.LoadLinkEnrichmentPolicy); err != nil {
+		fail("enrichment_policy", err)
+	} else if policy != nil {
+		staleAfter = policy.StaleAfter
+	}
+	if cache, err := loadOptional(s.cfg.EnrichmentCacheFile, courses.LoadLinkEnrichmentCache); err != nil {
+		fail("enrichment", err)
+	} else if cache != nil {
+		freshness := cache.Freshness(s.now(), staleAfter)
+		state.Enrichment = &freshness
+	}
+	return state
+}
+
+func (s *Scheduler) loadCatalog() (courses.Catalog, os.FileInfo, error) {
+	info, err := os.Stat(s.catalogPath)
+	if err != nil {
+		return courses.Catalog{}, nil, err
+	}
+	s.mu.Lock()
+	memo := s.catalog
+	s.mu.Unlock()
+	if memo.info != nil && os.SameFile(memo.info, info) &&
+		memo.info.Size() == info.Size() && memo.info.ModTime().Equal(info.ModTime()) {
+		return memo.catalog, memo.info, nil
+	}
+
+	catalog, err := loadCatalogFile(s.catalogPath)
+	if err != nil {
+		return courses.Catalog{}, nil, err
+	}
+	s.mu.Lock()
+	s.catalog = catalogMemo{info: info, catalog: catalog}
+	s.mu.Unlock()
+	return catalog, info, nil
+}
@@ -12,6 +12,24 @@ func WithLogger(ctx context.Context, logger *log.Logger) context.Context {
 	return context.WithValue(ctx, LoggerKey{}, logger)
 }
 
+// GetLogger returns the logger stored in ctx, or log.DefaultLogger when there
+// is none. Entries carry the fields of the Request in ctx, if any.
 func GetLogger(ctx context.Context) *log.Logger {
-	return ctx.Value(LoggerKey{}).(*log.Logger)
-}
\ No newline at end of file
+	logger, ok := LookupLogger(ctx)
+	if !ok {
+		logger = &log.DefaultLogger
+	}
+	if request := GetRequest(ctx); request != nil {
+		return request.logger(logger)
+	}
+	return logger
+}
+
+// LookupLogger returns the logger stored in ctx without a fallback.
+func LookupLogger(ctx context.Context) (*log.Logger, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	logger, ok := ctx.Value(LoggerKey{}).(*log.Logger)
+	return logger, ok && logger != nil
+}
@@ -0,0 +1,112 @@
+package meta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phuslu/log"
+)
+
+func TestGetLoggerWithoutLogger(t *testing.T) {
+	if GetLogger(context.Background()) != &log.DefaultLogger {
+		t.Fatal("GetLogger without a logger is not the default logger")
+	}
+	if _, ok := LookupLogger(context.Background()); ok {
+		t.Fatal("LookupLogger found a logger in an empty context")
+	}
+}
+
+func TestGetLoggerTagsRequest(t *testing.T) {
+	var output bytes.Buffer
+	base := &log.Logger{Writer: log.IOWriter{Writer: &output}, Context: log.NewContext(nil).Str("service", "site").Value()}
+	request := &Request{ID: "req-1", Route: "/courses/api/catalog"}
+	ctx := WithRequest(WithLogger(context.Background(), base), request)
+	SetUser(ctx, "alice")
+
+	GetLogger(ctx).Info().Msg("first")
+	GetLogger(ctx).Info().Msg("second")
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decode %s: %v", line, err)
+		}
+		if entry["service"] != "site" || entry["request_id"] != "req-1" ||
+			entry["route"] != "/courses/api/catalog" || entry["user"] != "alice" {
+			t.Fatalf("entry = %v", entry)
+		}
+	}
+	if len(base.Context) != len(log.NewContext(nil).Str("service", "site").Value()) {
+		t.Fatalf("base logger context changed: %s", base.Context)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if _, ok := ParseTraceParent(test.header); ok != test.ok {
+			t.Fatalf("ParseTraceParent(%q) ok = %t, want %t", test.header, ok, test.ok)
+		}
+	}
+}
+
+func TestNewTraceContextContinuesParent(t *testing.T) {
+	trace := NewTraceContext("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || trace.ParentID != "00f067aa0ba902b7" ||
+		trace.SpanID == trace.ParentID || trace.Flags != 0x01 {
+		t.Fatalf("trace = %+v", trace)
+	}
+	if parsed, ok := ParseTraceParent(trace.TraceParent()); !ok || parsed.SpanID != trace.SpanID {
+		t.Fatalf("TraceParent() = %q", trace.TraceParent())
+	}
+
+	fresh := NewTraceContext("garbage")
+	if !fresh.Valid() || fresh.ParentID != "" || len(fresh.TraceID) != 32 || len(fresh.SpanID) != 16 {
+		t.Fatalf("fresh trace = %+v", fresh)
+	}
+}
+
+func TestSpansGoToSpanFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	spans, err := OpenSpanFile(path)
+	if err != nil {
+		t.Fatalf("open span file: %v", err)
+	}
+	trace := NewTraceContext("")
+	ctx := WithRequest(context.Background(), &Request{ID: "req-1", Trace: trace, Spans: spans})
+	StartSpan(ctx, "bcrypt").End()
+	StartSpan(context.Background(), "ignored").End()
+	if err := spans.Close(); err != nil {
+		t.Fatalf("close span file: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read span file: %v", err)
+	}
+	var record SpanRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	if record.Name != "bcrypt" || record.RequestID != "req-1" || record.TraceID != trace.TraceID ||
+		record.ParentID != trace.SpanID || record.SpanID == "" || record.Duration < 0 {
+		t.Fatalf("span = %+v", record)
+	}
+}
@@ -0,0 +1,64 @@
+package meta
+
+import (
+	"context"
+
+	"github.com/phuslu/log"
+)
+
+type requestKey struct{}
+
+// Request describes the request being served. The access log middleware
+// creates it; handlers fill in what they learn later, such as the user.
+type Request struct {
+	ID string
+	// Route is the matched route path. The access log sets it once the
+	// handlers return; a handler that logs it earlier sets it itself.
+	Route string
+	User  string
+	// Trace is zero unless traceparent propagation is enabled.
+	Trace TraceContext
+	// Spans receives finished spans. When nil they are logged at debug
+	// level instead.
+	Spans SpanWriter
+}
+
+func WithRequest(ctx context.Context, request *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, request)
+}
+
+// GetRequest returns the Request in ctx or nil.
+func GetRequest(ctx context.Context) *Request {
+	if ctx == nil {
+		return nil
+	}
+	request, _ := ctx.Value(requestKey{}).(*Request)
+	return request
+}
+
+// SetUser records who the request acts for, once a handler knows it.
+func SetUser(ctx context.Context, user string) {
+	if request := GetRequest(ctx); request != nil {
+		request.User = user
+	}
+}
+
+func (r *Request) logger(base *log.Logger) *log.Logger {
+	// Clip the base context so appending never writes into its array.
+	fields := log.NewContext(base.Context[:len(base.Context):len(base.Context)])
+	if r.ID != "" {
+		fields = fields.Str("request_id", r.ID)
+	}
+	if r.Route != "" {
+		fields = fields.Str("route", r.Route)
+	}
+	if r.User != "" {
+		fields = fields.Str("user", r.User)
+	}
+	if r.Trace.Valid() {
+		fields = fields.Str("trace_id", r.Trace.TraceID).Str("span_id", r.Trace.SpanID)
+	}
+	logger := *base
+	logger.Context = fields.Value()
+	return &logger
+}
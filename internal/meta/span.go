@@ -0,0 +1,94 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// SpanRecord is a finished span, one line of a trace file.
+type SpanRecord struct {
+	Name      string        `json:"name"`
+	RequestID string        `json:"request_id,omitempty"`
+	TraceID   string        `json:"trace_id,omitempty"`
+	SpanID    string        `json:"span_id,omitempty"`
+	ParentID  string        `json:"parent_id,omitempty"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration_ns"`
+}
+
+type SpanWriter interface {
+	WriteSpan(SpanRecord)
+}
+
+// Span times one phase of a request.
+type Span struct {
+	ctx     context.Context
+	request *Request
+	record  SpanRecord
+}
+
+// StartSpan starts timing name as a child of the request in ctx. Without a
+// request it returns nil, and ending a nil span does nothing, so callers
+// need no checks.
+func StartSpan(ctx context.Context, name string) *Span {
+	request := GetRequest(ctx)
+	if request == nil {
+		return nil
+	}
+	record := SpanRecord{Name: name, RequestID: request.ID, Start: time.Now()}
+	if request.Trace.Valid() {
+		record.TraceID = request.Trace.TraceID
+		record.SpanID = randomHex(8)
+		record.ParentID = request.Trace.SpanID
+	}
+	return &Span{ctx: ctx, request: request, record: record}
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.record.Duration = time.Since(s.record.Start)
+	if s.request.Spans != nil {
+		s.request.Spans.WriteSpan(s.record)
+		return
+	}
+	GetLogger(s.ctx).Debug().
+		Str("span", s.record.Name).
+		Dur("duration", s.record.Duration).
+		Msg("Span")
+}
+
+// SpanFile appends spans to a local file as JSON lines.
+type SpanFile struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func OpenSpanFile(path string) (*SpanFile, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &SpanFile{file: file}, nil
+}
+
+// WriteSpan drops the span on write errors; tracing must not fail requests.
+func (f *SpanFile) WriteSpan(record SpanRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, _ = f.file.Write(append(line, '\n'))
+}
+
+func (f *SpanFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
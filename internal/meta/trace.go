@@ -0,0 +1,76 @@
+package meta
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const TraceParentHeader = "traceparent"
+
+// TraceContext is this server's position in a W3C Trace Context trace:
+// https://www.w3.org/TR/trace-context/.
+type TraceContext struct {
+	TraceID string
+	// SpanID identifies the server span; ParentID is the caller's span and
+	// is empty when the trace starts here.
+	SpanID   string
+	ParentID string
+	Flags    byte
+}
+
+// NewTraceContext continues the trace in a traceparent header, or starts a
+// sampled trace when the header is missing or invalid.
+func NewTraceContext(traceParent string) TraceContext {
+	if parent, ok := ParseTraceParent(traceParent); ok {
+		return TraceContext{
+			TraceID:  parent.TraceID,
+			SpanID:   randomHex(8),
+			ParentID: parent.SpanID,
+			Flags:    parent.Flags,
+		}
+	}
+	return TraceContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: 0x01}
+}
+
+// ParseTraceParent parses a version 00 header. Headers from later versions
+// are read as version 00, as the specification asks.
+func ParseTraceParent(header string) (TraceContext, bool) {
+	header = strings.TrimSpace(header)
+	if len(header) < 55 || (len(header) > 55 && (header[:2] == "00" || header[55] != '-')) {
+		return TraceContext{}, false
+	}
+	version, traceID, spanID, flags := header[0:2], header[3:35], header[36:52], header[53:55]
+	if header[2] != '-' || header[35] != '-' || header[52] != '-' || version == "ff" ||
+		!lowerHex(version) || !lowerHex(traceID) || !lowerHex(spanID) || !lowerHex(flags) ||
+		strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return TraceContext{}, false
+	}
+	decoded, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Flags: decoded[0]}, true
+}
+
+func (t TraceContext) Valid() bool {
+	return t.TraceID != ""
+}
+
+// TraceParent formats the header that hands the server span to the next hop.
+func (t TraceContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", t.TraceID, t.SpanID, t.Flags)
+}
+
+func lowerHex(value string) bool {
+	for _, r := range value {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(size int) string {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
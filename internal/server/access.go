@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/phuslu/log"
+)
+
+const (
+	accessListCheckInterval = time.Second
+	maxAccessListFileSize   = 1 << 20
+)
+
+// accessRule restricts a route prefix to, or away from, a set of networks.
+// Rules are written as "<prefix> <allow|deny> <source>...", where a source is
+// a CIDR, a bare address or "@path" naming a file with one entry per line.
+type accessRule struct {
+	raw    string
+	prefix string
+	allow  bool
+	static []netip.Prefix
+	files  []*accessListFile
+}
+
+type accessControl struct {
+	logger *log.Logger
+	rules  []*accessRule
+}
+
+// newAccessControl parses every rule. A rule that fails to parse still guards
+// its prefix as an empty allow list, so a typo closes the route rather than
+// opening it.
+func newAccessControl(rules []string, logger *log.Logger) (*accessControl, error) {
+	control := &accessControl{logger: logger}
+	files := make(map[string]*accessListFile)
+	var errs []error
+	for _, raw := range rules {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		rule, err := parseAccessRule(raw, files)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("access rule %q: %w", raw, err))
+		}
+		control.rules = append(control.rules, rule)
+	}
+	return control, errors.Join(errs...)
+}
+
+func parseAccessRule(raw string, files map[string]*accessListFile) (*accessRule, error) {
+	fields := strings.Fields(raw)
+	rule := &accessRule{raw: raw, prefix: "/", allow: true}
+	if prefix, ok := normalizeAccessPrefix(fields[0]); ok {
+		rule.prefix = prefix
+	} else {
+		return rule, errors.New("prefix must start with /")
+	}
+	if len(fields) < 3 {
+		return rule, errors.New("want <prefix> <allow|deny> <source>...")
+	}
+
+	var parsed accessRule
+	switch fields[1] {
+	case "allow":
+		parsed.allow = true
+	case "deny":
+	default:
+		return rule, fmt.Errorf("unknown action %q", fields[1])
+	}
+	for _, source := range fields[2:] {
+		if name, ok := strings.CutPrefix(source, "@"); ok {
+			if name == "" {
+				return rule, errors.New("empty list file")
+			}
+			file, ok := files[name]
+			if !ok {
+				file = &accessListFile{path: name, interval: accessListCheckInterval, now: time.Now}
+				files[name] = file
+			}
+			parsed.files = append(parsed.files, file)
+			continue
+		}
+		prefix, err := parseAddrOrPrefix(source)
+		if err != nil {
+			return rule, err
+		}
+		parsed.static = append(parsed.static, prefix)
+	}
+	rule.allow, rule.static, rule.files = parsed.allow, parsed.static, parsed.files
+	return rule, nil
+}
+
+func normalizeAccessPrefix(prefix string) (string, bool) {
+	if !strings.HasPrefix(prefix, "/") {
+		return "", false
+	}
+	return path.Clean(strings.ToLower(prefix)), true
+}
+
+func (r *accessRule) covers(requestPath string) bool {
+	return r.prefix == "/" || requestPath == r.prefix || strings.HasPrefix(requestPath, r.prefix+"/")
+}
+
+// match reports the entry of the rule that contains addr.
+func (r *accessRule) match(addr netip.Addr, logger *log.Logger) (string, bool) {
+	if !addr.IsValid() {
+		return "", false
+	}
+	for _, prefix := range r.static {
+		if prefix.Contains(addr) {
+			return prefix.String(), true
+		}
+	}
+	for _, file := range r.files {
+		for _, prefix := range file.current(logger) {
+			if prefix.Contains(addr) {
+				return "@" + file.path + ":" + prefix.String(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// check applies every deny rule covering the path, then the allow rules of
+// the longest covering prefix that has any. It returns the rule and entry
+// responsible for a denial.
+func (a *accessControl) check(requestPath string, addr netip.Addr) (*accessRule, string, bool) {
+	var allowPrefix string
+	for _, rule := range a.rules {
+		if !rule.covers(requestPath) {
+			continue
+		}
+		if rule.allow {
+			if len(rule.prefix) > len(allowPrefix) {
+				allowPrefix = rule.prefix
+			}
+			continue
+		}
+		if entry, ok := rule.match(addr, a.logger); ok {
+			return rule, entry, false
+		}
+	}
+	if allowPrefix == "" {
+		return nil, "", true
+	}
+
+	var first *accessRule
+	for _, rule := range a.rules {
+		if !rule.allow || rule.prefix != allowPrefix {
+			continue
+		}
+		if _, ok := rule.match(addr, a.logger); ok {
+			return nil, "", true
+		}
+		if first == nil {
+			first = rule
+		}
+	}
+	return first, "", false
+}
+
+func (a *accessControl) middleware(ctx fiber.Ctx) error {
+	if len(a.rules) == 0 {
+		return ctx.Next()
+	}
+	ip := clientIP(ctx)
+	addr, _ := netip.ParseAddr(ip)
+	requestPath := path.Clean("/" + strings.ToLower(ctx.Path()))
+	rule, entry, ok := a.check(requestPath, addr.Unmap())
+	if ok {
+		return ctx.Next()
+	}
+
+	event := a.logger.Warn().
+		Str("event", "access_denied").
+		Str("rule", rule.raw).
+		Str("ip", ip).
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path())
+	if entry != "" {
+		event = event.Str("matched", entry)
+	}
+	event.Msg("Access denied")
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return errAccessDenied
+}
+
+// accessListFile holds the entries of a list file and reloads them when the
+// file changes. A list that fails to load keeps its previous entries.
+type accessListFile struct {
+	path     string
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	info      os.FileInfo
+	lastErr   string
+	prefixes  []netip.Prefix
+}
+
+func (f *accessListFile) current(logger *log.Logger) []netip.Prefix {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	if !f.checkedAt.IsZero() && now.Sub(f.checkedAt) < f.interval {
+		return f.prefixes
+	}
+	f.checkedAt = now
+
+	info, err := os.Stat(f.path)
+	if err == nil && f.info != nil &&
+		f.info.Size() == info.Size() &&
+		f.info.ModTime().Equal(info.ModTime()) &&
+		os.SameFile(f.info, info) {
+		return f.prefixes
+	}
+	f.info = info
+
+	var prefixes []netip.Prefix
+	if err == nil {
+		prefixes, err = loadAccessListFile(f.path, info)
+	}
+	if err != nil {
+		if err.Error() != f.lastErr {
+			f.lastErr = err.Error()
+			logger.Error().Err(err).Str("file", f.path).Msg("Access list unavailable, keeping previous entries")
+		}
+		return f.prefixes
+	}
+	f.lastErr = ""
+	f.prefixes = prefixes
+	logger.Info().Str("file", f.path).Int("entries", len(prefixes)).Msg("Access list loaded")
+	return f.prefixes
+}
+
+func loadAccessListFile(name string, info os.FileInfo) ([]netip.Prefix, error) {
+	if !info.Mode().IsRegular() || info.Size() > maxAccessListFileSize {
+		return nil, errors.New("access list must be a regular file up to 1 MiB")
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := parseAddrOrPrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, scanner.Err()
+}
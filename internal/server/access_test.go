@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phuslu/log"
+)
+
+func TestAccessControlCheck(t *testing.T) {
+	control, err := newAccessControl([]string{
+		"/ deny 203.0.113.0/24",
+		"/large allow 10.0.0.0/8 192.168.1.10",
+		"/metrics allow 10.1.0.0/16",
+		"/metrics allow 172.16.0.0/12",
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("newAccessControl() error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		addr string
+		want bool
+		rule string
+	}{
+		{"/", "198.51.100.7", true, ""},
+		{"/", "203.0.113.9", false, "/ deny 203.0.113.0/24"},
+		{"/large", "10.2.3.4", true, ""},
+		{"/large/video.mp4", "192.168.1.10", true, ""},
+		{"/large/video.mp4", "192.168.1.11", false, "/large allow 10.0.0.0/8 192.168.1.10"},
+		{"/large/video.mp4", "203.0.113.9", false, "/ deny 203.0.113.0/24"},
+		{"/largest", "198.51.100.7", true, ""},
+		{"/metrics", "172.16.5.5", true, ""},
+		{"/metrics", "10.2.0.1", false, "/metrics allow 10.1.0.0/16"},
+		{"/metrics", "", false, "/metrics allow 10.1.0.0/16"},
+	}
+	for _, test := range tests {
+		addr, _ := netip.ParseAddr(test.addr)
+		rule, _, ok := control.check(test.path, addr)
+		if ok != test.want {
+			t.Fatalf("check(%q, %q) = %v, want %v", test.path, test.addr, ok, test.want)
+		}
+		if !ok && rule.raw != test.rule {
+			t.Fatalf("check(%q, %q) rule = %q, want %q", test.path, test.addr, rule.raw, test.rule)
+		}
+	}
+}
+
+func TestAccessControlInvalidRuleClosesPrefix(t *testing.T) {
+	control, err := newAccessControl([]string{"/metrics allow 10.0.0.0/33"}, testLogger())
+	if err == nil {
+		t.Fatal("newAccessControl() error = nil, want error")
+	}
+	if _, _, ok := control.check("/metrics", netip.MustParseAddr("10.0.0.1")); ok {
+		t.Fatal("invalid rule left /metrics open")
+	}
+	if _, _, ok := control.check("/", netip.MustParseAddr("10.0.0.1")); !ok {
+		t.Fatal("invalid rule closed unrelated prefix")
+	}
+}
+
+func TestAccessListFileReloadsOnChange(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "blocked.txt")
+	if err := os.WriteFile(list, []byte("# abusive ranges\n203.0.113.0/24\n"), 0o600); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+	control, err := newAccessControl([]string{"/ deny @" + list}, testLogger())
+	if err != nil {
+		t.Fatalf("newAccessControl() error = %v", err)
+	}
+	control.rules[0].files[0].interval = 0
+
+	blocked := netip.MustParseAddr("203.0.113.9")
+	other := netip.MustParseAddr("198.51.100.7")
+	if _, entry, ok := control.check("/", blocked); ok || entry != "@"+list+":203.0.113.0/24" {
+		t.Fatalf("check() = %v, %q; want denial by list entry", ok, entry)
+	}
+
+	if err := os.WriteFile(list, []byte("198.51.100.0/24 # moved\n"), 0o600); err != nil {
+		t.Fatalf("rewrite list: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(list, future, future); err != nil {
+		t.Fatalf("touch list: %v", err)
+	}
+	if _, _, ok := control.check("/", blocked); !ok {
+		t.Fatal("reloaded list still blocks old range")
+	}
+	if _, _, ok := control.check("/", other); ok {
+		t.Fatal("reloaded list does not block new range")
+	}
+
+	if err := os.WriteFile(list, []byte("not-a-network\n"), 0o600); err != nil {
+		t.Fatalf("corrupt list: %v", err)
+	}
+	if err := os.Chtimes(list, future.Add(time.Minute), future.Add(time.Minute)); err != nil {
+		t.Fatalf("touch list: %v", err)
+	}
+	if _, _, ok := control.check("/", other); ok {
+		t.Fatal("invalid list dropped previous entries")
+	}
+}
+
+func TestAccessControlRunsBeforeCache(t *testing.T) {
+	var output bytes.Buffer
+	app := New(Config{
+		TrustedProxies: []string{"0.0.0.0/32"},
+		AccessRules:    []string{"/ deny 203.0.113.0/24"},
+	}, &log.Logger{Writer: log.IOWriter{Writer: &output}})
+
+	get := func(client string) *http.Response {
+		request := httptest.NewRequest(http.MethodGet, "/version", nil)
+		request.Header.Set("X-Forwarded-For", client)
+		response, err := app.Test(request)
+		if err != nil {
+			t.Fatalf("test request: %v", err)
+		}
+		return response
+	}
+	for range 2 {
+		response := get("198.51.100.7")
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("allowed status = %d, want %d", response.StatusCode, http.StatusOK)
+		}
+	}
+	response := get("203.0.113.9")
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("denied status = %d, want %d", response.StatusCode, http.StatusForbidden)
+	}
+	if got := response.Header.Get("X-Cache"); got != "" {
+		t.Fatalf("denied response X-Cache = %q, want none", got)
+	}
+
+	logged := output.String()
+	for _, want := range []string{`"event":"access_denied"`, `"rule":"/ deny 203.0.113.0/24"`, `"matched":"203.0.113.0/24"`, `"ip":"203.0.113.9"`} {
+		if !strings.Contains(logged, want) {
+			t.Fatalf("denial log missing %s:\n%s", want, logged)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+var (
+	errUnlockAccountUnknown = errors.New("unknown account")
+	errUnlockCodeRequired   = errors.New("code required")
+	errUnlockCodeInvalid    = errors.New("invalid code")
+)
+
+// unlockAccounts enforces CoursesAccountsFile. The file is read on every
+// unlock, so accounts enrolled with courses-accounts apply without a
+// restart, and it is rewritten only when a recovery code is consumed. The
+// server and courses-accounts both hold the file's filelock while they read
+// and rewrite it, so a concurrent CLI change never brings a used recovery
+// code back.
+type unlockAccounts struct {
+	path string
+	skew int
+	now  func() time.Time
+
+	mu sync.Mutex
+	// lastSteps remembers the newest TOTP step each account used, so a code
+	// cannot be replayed within its validity window.
+	lastSteps map[string]int64
+}
+
+func newUnlockAccounts(path string, skew int) *unlockAccounts {
+	return &unlockAccounts{
+		path:      path,
+		skew:      skew,
+		now:       time.Now,
+		lastSteps: make(map[string]int64),
+	}
+}
+
+// verify checks the account and its second factor. A six digit code is a
+// TOTP code; anything else is tried as a recovery code.
+func (a *unlockAccounts) verify(name, code string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	unlock, err := filelock.Lock(a.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accounts, err := loadUnlockAccountsFile(a.path)
+	if err != nil {
+		return err
+	}
+	account, ok := accounts.Get(name)
+	if !ok {
+		return errUnlockAccountUnknown
+	}
+	if !account.TOTPEnabled {
+		return nil
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return errUnlockCodeRequired
+	}
+	if len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		step, ok := courses.VerifyTOTP(account.TOTPSecret, code, a.now(), a.skew)
+		if !ok || step <= a.lastSteps[account.Name] {
+			return errUnlockCodeInvalid
+		}
+		a.lastSteps[account.Name] = step
+		return nil
+	}
+	if !accounts.UseRecoveryCode(account.Name, code) {
+		return errUnlockCodeInvalid
+	}
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(a.path, ".unlock-accounts-*.tmp", data)
+}
+
+func loadUnlockAccountsFile(path string) (*courses.UnlockAccounts, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return courses.LoadUnlockAccounts(file)
+}
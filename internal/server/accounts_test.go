@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+func TestCoursesCatalogRequiresAccountSecondFactor(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	accountsPath, account, recoveryCodes := writeTestAccounts(t, now)
+	password := "correct horse battery staple"
+	app := New(Config{
+		CoursesCatalog:      writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2","entries":[]}`),
+		CoursesPasswordHash: hashTestPassword(t, password),
+		CoursesAccountsFile: accountsPath,
+		CoursesTOTPSkew:     1,
+	}, testLogger())
+	app.accounts.now = func() time.Time { return now }
+
+	unlock := func(request coursesUnlockRequest) (int, string) {
+		body, _ := json.Marshal(request)
+		httpRequest := httptest.NewRequest(http.MethodPost, "/courses/api/catalog", strings.NewReader(string(body)))
+		httpRequest.Header.Set("Content-Type", "application/json")
+		httpRequest.Header.Set("X-Forwarded-For", "198.51.100.7")
+		response, err := app.Test(httpRequest)
+		if err != nil {
+			t.Fatalf("test request: %v", err)
+		}
+		defer response.Body.Close()
+		var result struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(response.Body).Decode(&result)
+		return response.StatusCode, result.Error
+	}
+	code, _ := courses.TOTPCode(account.TOTPSecret, courses.TOTPStep(now)-1)
+
+	tests := []struct {
+		name      string
+		request   coursesUnlockRequest
+		status    int
+		errorText string
+	}{
+		{"no account", coursesUnlockRequest{Password: password}, http.StatusUnauthorized, "account required"},
+		{"unknown account", coursesUnlockRequest{Password: password, Account: "bob"}, http.StatusUnauthorized, "invalid password"},
+		{"no code", coursesUnlockRequest{Password: password, Account: "alice"}, http.StatusUnauthorized, "code required"},
+		{"totp within skew", coursesUnlockRequest{Password: password, Account: "Alice", Code: code}, http.StatusOK, ""},
+		{"replayed totp", coursesUnlockRequest{Password: password, Account: "alice", Code: code}, http.StatusUnauthorized, "invalid code"},
+		{"recovery code", coursesUnlockRequest{Password: password, Account: "alice", Code: recoveryCodes[0]}, http.StatusOK, ""},
+		{"reused recovery code", coursesUnlockRequest{Password: password, Account: "alice", Code: recoveryCodes[0]}, http.StatusUnauthorized, "invalid code"},
+	}
+	for _, test := range tests {
+		status, errorText := unlock(test.request)
+		if status != test.status || errorText != test.errorText {
+			t.Fatalf("%s: unlock = %d %q, want %d %q", test.name, status, errorText, test.status, test.errorText)
+		}
+	}
+
+	accounts, err := loadUnlockAccountsFile(accountsPath)
+	if err != nil {
+		t.Fatalf("reload accounts: %v", err)
+	}
+	if stored, _ := accounts.Get("alice"); len(stored.RecoveryCodes) != len(recoveryCodes)-1 {
+		t.Fatalf("stored recovery codes = %d, want %d", len(stored.RecoveryCodes), len(recoveryCodes)-1)
+	}
+	if info, err := os.Stat(accountsPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("accounts file mode = %v, %v", info, err)
+	}
+}
+
+func writeTestAccounts(t *testing.T, now time.Time) (string, courses.UnlockAccount, []string) {
+	t.Helper()
+
+	accounts := courses.NewUnlockAccounts()
+	if _, err := accounts.Add("alice"); err != nil {
+		t.Fatalf("add account: %v", err)
+	}
+	account, codes, err := accounts.EnrollTOTP("alice", now)
+	if err != nil {
+		t.Fatalf("enroll totp: %v", err)
+	}
+	code, _ := courses.TOTPCode(account.TOTPSecret, courses.TOTPStep(now))
+	if err := accounts.ConfirmTOTP("alice", code, now, 0); err != nil {
+		t.Fatalf("confirm totp: %v", err)
+	}
+	account, _ = accounts.Get("alice")
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		t.Fatalf("encode accounts: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write accounts: %v", err)
+	}
+	return path, account, codes
+}
@@ -0,0 +1,59 @@
+//go:build unix
+
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+func TestUnlockAccountsWaitsForAccountsCLILock(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	path, _, recoveryCodes := writeTestAccounts(t, now)
+	verifier := newUnlockAccounts(path, 1)
+
+	// Play courses-accounts: add an account under the file lock while a
+	// recovery code is used.
+	unlock, err := filelock.Lock(path)
+	if err != nil {
+		t.Fatalf("lock accounts: %v", err)
+	}
+	accounts, err := loadUnlockAccountsFile(path)
+	if err != nil {
+		t.Fatalf("load accounts: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- verifier.verify("alice", recoveryCodes[0]) }()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := accounts.Add("bob"); err != nil {
+		t.Fatalf("add account: %v", err)
+	}
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		t.Fatalf("encode accounts: %v", err)
+	}
+	if err := writePrivateFile(path, ".unlock-accounts-*.tmp", data); err != nil {
+		t.Fatalf("write accounts: %v", err)
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("verify() error = %v", err)
+	}
+
+	accounts, err = loadUnlockAccountsFile(path)
+	if err != nil {
+		t.Fatalf("reload accounts: %v", err)
+	}
+	if _, ok := accounts.Get("bob"); !ok {
+		t.Fatal("added account is missing")
+	}
+	if stored, _ := accounts.Get("alice"); len(stored.RecoveryCodes) != len(recoveryCodes)-1 {
+		t.Fatalf("stored recovery codes = %d, want %d", len(stored.RecoveryCodes), len(recoveryCodes)-1)
+	}
+	if err := verifier.verify("alice", recoveryCodes[0]); err != errUnlockCodeInvalid {
+		t.Fatalf("reused recovery code error = %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/limiter"
+
+	"github.com/xenking/dummypage/internal/jobs"
+	"github.com/xenking/dummypage/internal/meta"
+)
+
+const adminAPIPrefix = "/admin/api"
+
+func (s *Server) registerAdminRoutes() {
+	admin := s.Group(adminAPIPrefix, adminHandlerErrors(), limiter.New(limiter.Config{
+		Max:                    5,
+		Expiration:             15 * time.Minute,
+		SkipSuccessfulRequests: true,
+		KeyGenerator:           clientIP,
+		LimitReached:           limitReached,
+		LimiterMiddleware:      limiter.FixedWindow{},
+	}), adminAuth(s.cfg))
+	admin.Get("/pipeline", handleAdminPipeline(s.jobs))
+	admin.Get("/jobs", handleAdminJobs(s.jobs))
+	admin.Post("/jobs/rebuild", handleAdminStartJob(s.jobs, jobs.Rebuild))
+	admin.Get("/jobs/runs/:id", handleAdminJob(s.jobs))
+}
+
+// adminAuth accepts "Authorization: Bearer <token>" matching the
+// AdminTokenHash bcrypt hash. Without a hash the admin API does not exist.
+func adminAuth(cfg Config) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		ctx.Set(fiber.HeaderCacheControl, "no-store")
+		if strings.TrimSpace(cfg.AdminTokenHash) == "" {
+			return errNotFound
+		}
+		token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || len(token) == 0 || len(token) > maxPasswordLength ||
+			!coursesPasswordMatches(ctx.Context(), cfg.AdminTokenHash, token) {
+			ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="admin"`)
+			return errInvalidToken
+		}
+		meta.SetUser(ctx.Context(), "admin")
+		// The limiter counts every failure it sees, so keep the errors of
+		// authenticated requests from it; adminHandlerErrors returns them.
+		if err := ctx.Next(); err != nil {
+			ctx.Locals(adminHandlerErrorLocal, err)
+		}
+		return nil
+	}
+}
+
+// adminHandlerErrors returns the error adminAuth held back from the admin
+// limiter, so the limiter counts only authentication failures and an admin
+// polling an unknown run or a busy job is never locked out.
+func adminHandlerErrors() fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		if err := ctx.Next(); err != nil {
+			return err
+		}
+		if err, ok := ctx.Locals(adminHandlerErrorLocal).(error); ok {
+			return err
+		}
+		return nil
+	}
+}
+
+func handleAdminPipeline(scheduler *jobs.Scheduler) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		return ctx.JSON(scheduler.State())
+	}
+}
+
+func handleAdminJobs(scheduler *jobs.Scheduler) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		return ctx.JSON(fiber.Map{
+			"runs": scheduler.History(),
+		})
+	}
+}
+
+// handleAdminStartJob starts job in the background and points the client at
+// the run to poll for its progress. A run that could not start is still
+// recorded, and the error names it in run_id.
+func handleAdminStartJob(scheduler *jobs.Scheduler, job jobs.Name) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		run, err := scheduler.Start(job)
+		if errors.Is(err, jobs.ErrBusy) {
+			return errJobRunning.withRunID(run.ID).withCause(err)
+		}
+		if err != nil {
+			return errJobsUnavailable.withRunID(run.ID).withCause(err)
+		}
+		ctx.Set(fiber.HeaderLocation, adminAPIPrefix+"/jobs/runs/"+run.ID)
+		return ctx.Status(fiber.StatusAccepted).JSON(run)
+	}
+}
+
+func handleAdminJob(scheduler *jobs.Scheduler) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		run, ok := scheduler.Get(ctx.Params("id"))
+		if !ok {
+			return errUnknownRun
+		}
+		return ctx.JSON(run)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/jobs"
+)
+
+func TestAdminAPIIsDisabledWithoutTokenHash(t *testing.T) {
+	app := New(Config{}, testLogger())
+	response := adminTestRequest(t, app, http.MethodGet, "/admin/api/pipeline", "anything")
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", response.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestAdminAPIRejectsWrongToken(t *testing.T) {
+	app := New(Config{AdminTokenHash: hashTestPassword(t, "admin-token")}, testLogger())
+	for _, token := range []string{"", "wrong"} {
+		response := adminTestRequest(t, app, http.MethodGet, "/admin/api/pipeline", token)
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("token %q status = %d, want %d", token, response.StatusCode, http.StatusUnauthorized)
+		}
+		if response.Header.Get("WWW-Authenticate") == "" {
+			t.Fatalf("token %q response has no WWW-Authenticate", token)
+		}
+	}
+}
+
+func TestAdminAPILimitsOnlyAuthenticationFailures(t *testing.T) {
+	cfg := Config{
+		AdminTokenHash: hashTestPassword(t, "admin-token"),
+		Jobs:           jobs.Config{HistoryFile: filepath.Join(t.TempDir(), "history.json")},
+	}
+
+	app := New(cfg, testLogger())
+	for range 6 {
+		response := adminTestRequest(t, app, http.MethodGet, "/admin/api/jobs/runs/unknown", "admin-token")
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusNotFound {
+			t.Fatalf("unknown run status = %d, want %d", response.StatusCode, http.StatusNotFound)
+		}
+	}
+
+	app = New(cfg, testLogger())
+	for range 5 {
+		response := adminTestRequest(t, app, http.MethodGet, "/admin/api/pipeline", "wrong")
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("wrong token status = %d, want %d", response.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	response := adminTestRequest(t, app, http.MethodGet, "/admin/api/pipeline", "admin-token")
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status after failed logins = %d, want %d", response.StatusCode, http.StatusTooManyRequests)
+	}
+}
+
+func TestAdminAPIReportsPipelineAndRebuilds(t *testing.T) {
+	dir := t.TempDir()
+	catalogPath := filepath.Join(dir, "catalog.json.gz")
+	writeTestCoursesContent(t, catalogPath, `{"schema_version":"courses-catalog/v2","stats":{"entries":3,"tombstoned_links_removed":2},"entries":[]}`)
+	tombstones := filepath.Join(dir, "tombstones.json")
+	if err := os.WriteFile(tombstones, []byte(`{"schema_version":"link-tombstones/v1","canonicalization_version":1,"links":[]}`), 0o600); err != nil {
+		t.Fatalf("write tombstones: %v", err)
+	}
+	app := New(Config{
+		CoursesCatalog: catalogPath,
+		AdminTokenHash: hashTestPassword(t, "admin-token"),
+		Jobs: jobs.Config{
+			SourceDir:          filepath.Join(dir, "missing"),
+			LinkTombstonesFile: tombstones,
+			HistoryFile:        filepath.Join(dir, "history.json"),
+		},
+	}, testLogger())
+
+	response := adminTestRequest(t, app, http.MethodGet, "/admin/api/pipeline", "admin-token")
+	var state jobs.State
+	decodeAdminResponse(t, response, http.StatusOK, &state)
+	if state.Catalog == nil || state.Catalog.Stats.Entries != 3 || state.Catalog.Stats.TombstonedLinksRemoved != 2 {
+		t.Fatalf("catalog state = %+v", state.Catalog)
+	}
+	if state.Tombstones == nil || *state.Tombstones != 0 || state.Suppressions != nil {
+		t.Fatalf("tombstones = %v, suppressions = %v", state.Tombstones, state.Suppressions)
+	}
+	if cached := response.Header.Get("X-Cache"); response.Header.Get("Cache-Control") != "no-store" || cached == "hit" || cached == "miss" {
+		t.Fatalf("admin response cache headers = %v", response.Header)
+	}
+
+	response = adminTestRequest(t, app, http.MethodPost, "/admin/api/jobs/rebuild", "admin-token")
+	location := response.Header.Get("Location")
+	var run jobs.Run
+	decodeAdminResponse(t, response, http.StatusAccepted, &run)
+	if run.Job != jobs.Rebuild || location != "/admin/api/jobs/runs/"+run.ID {
+		t.Fatalf("started run = %+v at %q", run, location)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for run.Status == jobs.StatusRunning {
+		if time.Now().After(deadline) {
+			t.Fatalf("rebuild did not finish: %+v", run)
+		}
+		time.Sleep(10 * time.Millisecond)
+		decodeAdminResponse(t, adminTestRequest(t, app, http.MethodGet, location, "admin-token"), http.StatusOK, &run)
+	}
+	if run.Status != jobs.StatusFailed || run.Error == "" {
+		t.Fatalf("rebuild without sources = %+v, want failure", run)
+	}
+}
+
+func adminTestRequest(t *testing.T, app *Server, method, target, token string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	return response
+}
+
+func decodeAdminResponse(t *testing.T, response *http.Response, status int, value any) {
+	t.Helper()
+
+	defer response.Body.Close()
+	if response.StatusCode != status {
+		t.Fatalf("%s status = %d, want %d", response.Request.URL, response.StatusCode, status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/xenking/dummypage/internal/jobs"
+)
+
+func TestAdminAPIStartJobReportsBusyLockAsAPIError(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "jobs.lock")
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		t.Fatalf("open lock file: %v", err)
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	app := New(Config{
+		AdminTokenHash: hashTestPassword(t, "admin-token"),
+		Jobs: jobs.Config{
+			SourceDir:   filepath.Join(dir, "missing"),
+			LockFile:    lockPath,
+			HistoryFile: filepath.Join(dir, "history.json"),
+		},
+	}, testLogger())
+
+	response := adminTestRequest(t, app, http.MethodPost, "/admin/api/jobs/rebuild", "admin-token")
+	var body errorResponse
+	decodeAdminResponse(t, response, http.StatusConflict, &body)
+	if body.Code != "job_running" || body.RunID == "" {
+		t.Fatalf("busy response = %+v", body)
+	}
+
+	var run jobs.Run
+	decodeAdminResponse(t, adminTestRequest(t, app, http.MethodGet, "/admin/api/jobs/runs/"+body.RunID, "admin-token"), http.StatusOK, &run)
+	if run.Status != jobs.StatusSkipped || run.Job != jobs.Rebuild {
+		t.Fatalf("skipped run = %+v", run)
+	}
+}
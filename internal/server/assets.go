@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gofiber/fiber/v3"
+)
+
+const immutableAssetCacheControl = "public, max-age=31536000, immutable"
+
+// fingerprintedAssets lists the static files referenced by templates. Other
+// static files keep being served by the static middleware.
+var fingerprintedAssets = []string{
+	"css/404.css",
+	"css/courses.css",
+	"css/icons.css",
+	"css/styles.css",
+	"js/courses-search-worker.js",
+	"js/courses.js",
+	"js/vendor/minisearch.min.js",
+	"js/worker.js",
+}
+
+type staticAsset struct {
+	url         string
+	integrity   string
+	contentType string
+	body        []byte
+
+	compressOnce sync.Once
+	brotli       []byte
+	gzip         []byte
+}
+
+// assetManifest snapshots fingerprinted assets at startup, so a URL always
+// serves the exact bytes its hash and integrity were computed from.
+type assetManifest struct {
+	prefix string
+	byName map[string]*staticAsset
+	byURL  map[string]*staticAsset
+}
+
+func loadAssetManifest(files fs.FS, staticPrefix string) *assetManifest {
+	manifest := &assetManifest{
+		prefix: staticPrefix,
+		byName: make(map[string]*staticAsset),
+		byURL:  make(map[string]*staticAsset),
+	}
+	for _, name := range fingerprintedAssets {
+		body, err := fs.ReadFile(files, name)
+		if err != nil {
+			continue
+		}
+		asset := newStaticAsset(staticPrefix, name, body)
+		if precompressed, err := fs.ReadFile(files, name+".br"); err == nil {
+			asset.brotli = precompressed
+		}
+		if precompressed, err := fs.ReadFile(files, name+".gz"); err == nil {
+			asset.gzip = precompressed
+		}
+		manifest.byName[name] = asset
+		manifest.byURL[asset.url] = asset
+	}
+	return manifest
+}
+
+func newStaticAsset(staticPrefix, name string, body []byte) *staticAsset {
+	digest := sha256.Sum256(body)
+	integrity := sha512.Sum384(body)
+	ext := path.Ext(name)
+	fingerprinted := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(digest[:8]) + ext
+
+	return &staticAsset{
+		url:         assetURL(staticPrefix, fingerprinted),
+		integrity:   "sha384-" + base64.StdEncoding.EncodeToString(integrity[:]),
+		contentType: ext,
+		body:        body,
+	}
+}
+
+func (a *staticAsset) compressed() (brotliBody, gzipBody []byte) {
+	a.compressOnce.Do(func() {
+		var compressed bytes.Buffer
+		if a.brotli == nil {
+			brotliWriter := brotli.NewWriterLevel(&compressed, brotli.BestCompression)
+			if _, err := brotliWriter.Write(a.body); err == nil && brotliWriter.Close() == nil {
+				a.brotli = bytes.Clone(compressed.Bytes())
+			}
+		}
+		compressed.Reset()
+		if a.gzip == nil {
+			gzipWriter, _ := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+			if _, err := gzipWriter.Write(a.body); err == nil && gzipWriter.Close() == nil {
+				a.gzip = bytes.Clone(compressed.Bytes())
+			}
+		}
+	})
+	return a.brotli, a.gzip
+}
+
+// URL returns the fingerprinted URL for a template asset, falling back to the
+// plain static path when the file was not available at startup.
+func (m *assetManifest) URL(name string) string {
+	if asset, ok := m.byName[name]; ok {
+		return asset.url
+	}
+	return assetURL(m.prefix, name)
+}
+
+func (m *assetManifest) Integrity(name string) string {
+	if asset, ok := m.byName[name]; ok {
+		return asset.integrity
+	}
+	return ""
+}
+
+func (m *assetManifest) owns(urlPath string) bool {
+	_, ok := m.byURL[urlPath]
+	return ok
+}
+
+func (m *assetManifest) handler(ctx fiber.Ctx) error {
+	asset, ok := m.byURL[ctx.Path()]
+	if !ok || (ctx.Method() != fiber.MethodGet && ctx.Method() != fiber.MethodHead) {
+		return ctx.Next()
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, immutableAssetCacheControl)
+	ctx.Set(fiber.HeaderVary, fiber.HeaderAcceptEncoding)
+	ctx.Type(asset.contentType, "utf-8")
+	body := asset.body
+	encoding := ""
+	if ctx.Get(fiber.HeaderAcceptEncoding) != "" {
+		encoding = ctx.AcceptsEncodings("br", "gzip")
+	}
+	if encoding != "" {
+		brotliBody, gzipBody := asset.compressed()
+		switch {
+		case encoding == "br" && brotliBody != nil:
+			ctx.Set(fiber.HeaderContentEncoding, "br")
+			body = brotliBody
+		case encoding == "gzip" && gzipBody != nil:
+			ctx.Set(fiber.HeaderContentEncoding, "gzip")
+			body = gzipBody
+		}
+	}
+	return ctx.Send(body)
+}
+
+func assetURL(staticPrefix, name string) string {
+	return path.Join("/", staticPrefix, name)
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	unlockChallengeTTL          = 5 * time.Minute
+	unlockChallengeNonceSize    = 16
+	unlockChallengePayloadSize  = unlockChallengeNonceSize + 8 + 1
+	unlockChallengeMaxSolution  = 32
+	unlockFailureWindow         = 10 * time.Minute
+	unlockFailureBuckets        = 10
+	unlockFailuresPerDifficulty = 10
+	maxUnlockChallengeBits      = 32
+)
+
+var (
+	errChallengeMalformed = errors.New("malformed challenge")
+	errChallengeSignature = errors.New("challenge signature mismatch")
+	errChallengeExpired   = errors.New("challenge expired")
+	errChallengeUnsolved  = errors.New("challenge solution does not meet difficulty")
+	errChallengeReplayed  = errors.New("challenge already used")
+)
+
+type unlockChallengeResponse struct {
+	Required   bool      `json:"required"`
+	Challenge  string    `json:"challenge,omitempty"`
+	Difficulty int       `json:"difficulty,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+}
+
+// unlockChallenges issues proof-of-work puzzles for the unlock endpoint. A
+// challenge is an HMAC-signed nonce, expiry and difficulty; a solution is any
+// string s such that SHA-256(challenge + ":" + s) starts with difficulty zero
+// bits. Challenges are stateless until used, and each can be used once.
+//
+// Difficulty starts at minBits and gains one bit every time the number of
+// failed unlocks across all clients in the last unlockFailureWindow doubles,
+// so distributed guessing gets slower for everyone instead of per address.
+type unlockChallenges struct {
+	key     []byte
+	minBits int
+	maxBits int
+	now     func() time.Time
+
+	mu       sync.Mutex
+	used     map[string]time.Time
+	failures [unlockFailureBuckets]struct {
+		slot  int64
+		count int
+	}
+}
+
+func newUnlockChallenges(secret string, minBits, maxBits int) *unlockChallenges {
+	key := []byte(secret)
+	if len(key) == 0 {
+		// Without a shared secret challenges only verify on the instance
+		// that issued them, which is fine for a single server.
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+	minBits = min(max(minBits, 1), maxUnlockChallengeBits)
+	return &unlockChallenges{
+		key:     key,
+		minBits: minBits,
+		maxBits: min(max(maxBits, minBits), maxUnlockChallengeBits),
+		now:     time.Now,
+		used:    make(map[string]time.Time),
+	}
+}
+
+func (c *unlockChallenges) difficulty() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := c.failureSlot(c.now())
+	recent := 0
+	for _, bucket := range c.failures {
+		if current-bucket.slot < unlockFailureBuckets {
+			recent += bucket.count
+		}
+	}
+	return min(c.minBits+bits.Len(uint(recent/unlockFailuresPerDifficulty)), c.maxBits)
+}
+
+func (c *unlockChallenges) fail() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	slot := c.failureSlot(c.now())
+	bucket := &c.failures[slot%unlockFailureBuckets]
+	if bucket.slot != slot {
+		bucket.slot = slot
+		bucket.count = 0
+	}
+	bucket.count++
+}
+
+func (c *unlockChallenges) failureSlot(now time.Time) int64 {
+	return now.UnixNano() / int64(unlockFailureWindow/unlockFailureBuckets)
+}
+
+func (c *unlockChallenges) issue() unlockChallengeResponse {
+	difficulty := c.difficulty()
+	expiresAt := c.now().Add(unlockChallengeTTL).UTC().Truncate(time.Second)
+
+	payload := make([]byte, unlockChallengePayloadSize, unlockChallengePayloadSize+sha256.Size)
+	_, _ = rand.Read(payload[:unlockChallengeNonceSize])
+	binary.BigEndian.PutUint64(payload[unlockChallengeNonceSize:], uint64(expiresAt.Unix()))
+	payload[unlockChallengePayloadSize-1] = byte(difficulty)
+	return unlockChallengeResponse{
+		Required:   true,
+		Challenge:  base64.RawURLEncoding.EncodeToString(c.sign(payload)),
+		Difficulty: difficulty,
+		ExpiresAt:  expiresAt,
+	}
+}
+
+func (c *unlockChallenges) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(payload)
+}
+
+func (c *unlockChallenges) verify(challenge, solution string) error {
+	token, err := base64.RawURLEncoding.DecodeString(challenge)
+	if err != nil || len(token) != unlockChallengePayloadSize+sha256.Size ||
+		len(solution) == 0 || len(solution) > unlockChallengeMaxSolution {
+		return errChallengeMalformed
+	}
+	payload := token[:unlockChallengePayloadSize]
+	if !hmac.Equal(c.sign(payload[:len(payload):len(payload)]), token) {
+		return errChallengeSignature
+	}
+	now := c.now()
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[unlockChallengeNonceSize:])), 0)
+	if !now.Before(expiresAt) {
+		return errChallengeExpired
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(challenge+":"+solution))) < int(payload[unlockChallengePayloadSize-1]) {
+		return errChallengeUnsolved
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for used, expiry := range c.used {
+		if !now.Before(expiry) {
+			delete(c.used, used)
+		}
+	}
+	if _, ok := c.used[challenge]; ok {
+		return errChallengeReplayed
+	}
+	c.used[challenge] = expiresAt
+	return nil
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+	return zeros
+}
+
+func handleCoursesChallenge(challenges *unlockChallenges) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		ctx.Set(fiber.HeaderCacheControl, "no-store")
+		if challenges == nil {
+			return ctx.JSON(unlockChallengeResponse{Required: false})
+		}
+		return ctx.JSON(challenges.issue())
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnlockChallengeVerify(t *testing.T) {
+	now := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+	challenges := newUnlockChallenges("secret", 8, 16)
+	challenges.now = func() time.Time { return now }
+
+	issued := challenges.issue()
+	if !issued.Required || issued.Difficulty != 8 || !issued.ExpiresAt.Equal(now.Add(unlockChallengeTTL)) {
+		t.Fatalf("issue() = %+v", issued)
+	}
+	solution := solveTestChallenge(issued.Challenge, issued.Difficulty)
+
+	other := newUnlockChallenges("other secret", 8, 16)
+	other.now = challenges.now
+	if err := other.verify(issued.Challenge, solution); !errors.Is(err, errChallengeSignature) {
+		t.Fatalf("verify() with other key error = %v, want %v", err, errChallengeSignature)
+	}
+	if err := challenges.verify(issued.Challenge, "not-a-solution-"+solution); !errors.Is(err, errChallengeUnsolved) {
+		t.Fatalf("verify() wrong solution error = %v, want %v", err, errChallengeUnsolved)
+	}
+	if err := challenges.verify(issued.Challenge[:20], solution); !errors.Is(err, errChallengeMalformed) {
+		t.Fatalf("verify() truncated error = %v, want %v", err, errChallengeMalformed)
+	}
+	if err := challenges.verify(issued.Challenge, solution); err != nil {
+		t.Fatalf("verify() error = %v", err)
+	}
+	if err := challenges.verify(issued.Challenge, solution); !errors.Is(err, errChallengeReplayed) {
+		t.Fatalf("verify() replay error = %v, want %v", err, errChallengeReplayed)
+	}
+
+	expired := challenges.issue()
+	now = now.Add(unlockChallengeTTL)
+	if err := challenges.verify(expired.Challenge, solveTestChallenge(expired.Challenge, expired.Difficulty)); !errors.Is(err, errChallengeExpired) {
+		t.Fatalf("verify() expired error = %v, want %v", err, errChallengeExpired)
+	}
+}
+
+func TestUnlockChallengeDifficultyFollowsRecentFailures(t *testing.T) {
+	now := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+	challenges := newUnlockChallenges("secret", 10, 13)
+	challenges.now = func() time.Time { return now }
+
+	tests := []struct {
+		failures int
+		want     int
+	}{
+		{9, 10},
+		{1, 11},
+		{10, 12},
+		{20, 13},
+		{1000, 13},
+	}
+	for _, test := range tests {
+		for range test.failures {
+			challenges.fail()
+		}
+		if got := challenges.difficulty(); got != test.want {
+			t.Fatalf("difficulty after %d more failures = %d, want %d", test.failures, got, test.want)
+		}
+	}
+
+	now = now.Add(unlockFailureWindow)
+	if got := challenges.difficulty(); got != 10 {
+		t.Fatalf("difficulty after failure window = %d, want 10", got)
+	}
+}
+
+func TestCoursesCatalogRequiresSolvedChallenge(t *testing.T) {
+	catalogPath := writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2","entries":[]}`)
+	password := "correct horse battery staple"
+	app := New(Config{
+		CoursesCatalog:          catalogPath,
+		CoursesPasswordHash:     hashTestPassword(t, password),
+		CoursesChallenge:        true,
+		CoursesChallengeMinBits: 4,
+		CoursesChallengeMaxBits: 8,
+	}, testLogger())
+
+	unlock := func(body string) int {
+		request := httptest.NewRequest(http.MethodPost, "/courses/api/catalog", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		response, err := app.Test(request)
+		if err != nil {
+			t.Fatalf("test request: %v", err)
+		}
+		_ = response.Body.Close()
+		return response.StatusCode
+	}
+	if status := unlock(`{"password":"` + password + `"}`); status != http.StatusPreconditionRequired {
+		t.Fatalf("unlock without challenge status = %d, want %d", status, http.StatusPreconditionRequired)
+	}
+
+	response, err := app.Test(httptest.NewRequest(http.MethodGet, "/courses/api/challenge", nil))
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	defer response.Body.Close()
+	var issued unlockChallengeResponse
+	if err := json.NewDecoder(response.Body).Decode(&issued); err != nil {
+		t.Fatalf("decode challenge: %v", err)
+	}
+	if !issued.Required || issued.Difficulty != 4 || response.Header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("challenge = %+v, headers %v", issued, response.Header)
+	}
+
+	body, _ := json.Marshal(coursesUnlockRequest{
+		Password:  password,
+		Challenge: issued.Challenge,
+		Solution:  solveTestChallenge(issued.Challenge, issued.Difficulty),
+	})
+	if status := unlock(string(body)); status != http.StatusOK {
+		t.Fatalf("unlock with solution status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestCoursesChallengeNotRequiredByDefault(t *testing.T) {
+	app := New(Config{}, testLogger())
+	response, err := app.Test(httptest.NewRequest(http.MethodGet, "/courses/api/challenge", nil))
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	defer response.Body.Close()
+	var issued unlockChallengeResponse
+	if err := json.NewDecoder(response.Body).Decode(&issued); err != nil {
+		t.Fatalf("decode challenge: %v", err)
+	}
+	if issued.Required || issued.Challenge != "" {
+		t.Fatalf("challenge = %+v, want not required", issued)
+	}
+}
+
+func solveTestChallenge(challenge string, difficulty int) string {
+	for counter := 0; ; counter++ {
+		solution := strconv.Itoa(counter)
+		if leadingZeroBits(sha256.Sum256([]byte(challenge+":"+solution))) >= difficulty {
+			return solution
+		}
+	}
+}
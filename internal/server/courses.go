@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
@@ -19,6 +20,9 @@ import (
 
 	"github.com/gofiber/fiber/v3"
 	"golang.org/x/crypto/bcrypt"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/meta"
 )
 
 const (
@@ -29,7 +33,11 @@ const (
 )
 
 type coursesUnlockRequest struct {
-	Password string `json:"password"`
+	Password  string `json:"password"`
+	Challenge string `json:"challenge"`
+	Solution  string `json:"solution"`
+	Account   string `json:"account"`
+	Code      string `json:"code"`
 }
 
 type coursesMetaResponse struct {
@@ -53,18 +61,6 @@ var coursesMetaCache = struct {
 }
 
 func coursesSecurityHeaders(ctx fiber.Ctx) error {
-	ctx.Set("Content-Security-Policy", strings.Join([]string{
-		"default-src 'self'",
-		"script-src 'self'",
-		"style-src 'self'",
-		"img-src 'self' data:",
-		"connect-src 'self'",
-		"worker-src 'self'",
-		"object-src 'none'",
-		"base-uri 'none'",
-		"frame-ancestors 'none'",
-		"form-action 'self'",
-	}, "; "))
 	ctx.Set(fiber.HeaderReferrerPolicy, "no-referrer")
 	ctx.Set(fiber.HeaderXFrameOptions, "DENY")
 	ctx.Set(fiber.HeaderXContentTypeOptions, "nosniff")
@@ -98,7 +94,7 @@ func readCoursesCatalogMeta(path string) (coursesMetaResponse, error) {
 		return cached.meta, nil
 	}
 
-	_, meta, err := readCoursesCatalog(path)
+	_, meta, err := readCoursesCatalog(context.Background(), path)
 	if err != nil {
 		return coursesMetaResponse{}, err
 	}
@@ -116,41 +112,68 @@ func readCoursesCatalogMeta(path string) (coursesMetaResponse, error) {
 	return meta, nil
 }
 
-func handleCoursesCatalog(cfg Config) fiber.Handler {
+func handleCoursesCatalog(cfg Config, sessions *coursesSessions, challenges *unlockChallenges, accounts *unlockAccounts) fiber.Handler {
 	return func(ctx fiber.Ctx) error {
 		ctx.Set(fiber.HeaderCacheControl, "no-store, private")
 		ctx.Set(fiber.HeaderPragma, "no-cache")
 
 		if !sameOriginRequest(ctx) {
-			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "request forbidden",
-			})
+			return errRequestForbidden
 		}
 		if !strings.HasPrefix(strings.ToLower(ctx.Get(fiber.HeaderContentType)), fiber.MIMEApplicationJSON) {
-			return unauthorizedCourses(ctx)
+			return errInvalidPassword
 		}
 		if len(ctx.Body()) > 1024 {
-			return unauthorizedCourses(ctx)
+			return errInvalidPassword
 		}
 
 		var request coursesUnlockRequest
 		if err := ctx.Bind().JSON(&request); err != nil {
-			return unauthorizedCourses(ctx)
+			return errInvalidPassword
 		}
 		if len(request.Password) == 0 || len(request.Password) > maxPasswordLength {
-			return unauthorizedCourses(ctx)
+			return errInvalidPassword
+		}
+		if accounts != nil && strings.TrimSpace(request.Account) == "" {
+			return errAccountRequired
+		}
+		if challenges != nil {
+			if err := challenges.verify(request.Challenge, request.Solution); err != nil {
+				return errChallengeRequired
+			}
+		}
+		if !coursesPasswordMatches(ctx.Context(), coursesPasswordHash(cfg), request.Password) {
+			if challenges != nil {
+				challenges.fail()
+			}
+			return errInvalidPassword
 		}
-		if !coursesPasswordMatches(coursesPasswordHash(cfg), request.Password) {
-			return unauthorizedCourses(ctx)
+		if accounts != nil {
+			switch err := accounts.verify(request.Account, request.Code); {
+			case errors.Is(err, errUnlockAccountUnknown):
+				if challenges != nil {
+					challenges.fail()
+				}
+				return errInvalidPassword
+			case errors.Is(err, errUnlockCodeRequired):
+				return errCodeRequired
+			case errors.Is(err, errUnlockCodeInvalid):
+				if challenges != nil {
+					challenges.fail()
+				}
+				return errInvalidCode
+			case err != nil:
+				return errAccountsUnavailable.withCause(err)
+			}
+			meta.SetUser(ctx.Context(), courses.NormalizeUnlockAccountName(request.Account))
 		}
 
-		catalog, meta, err := readCoursesCatalog(cfg.CoursesCatalog)
+		catalog, meta, err := readCoursesCatalog(ctx.Context(), cfg.CoursesCatalog)
 		if err != nil {
-			return ctx.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-				"error": "catalog unavailable",
-			})
+			return errCatalogUnavailable.withCause(err)
 		}
 
+		sessions.issue(ctx)
 		ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
 		ctx.Set(fiber.HeaderContentEncoding, "gzip")
 		ctx.Set(fiber.HeaderContentDisposition, `attachment; filename="courses-catalog.json"`)
@@ -175,13 +198,9 @@ func coursesPasswordHash(cfg Config) string {
 	return string(hash)
 }
 
-func unauthorizedCourses(ctx fiber.Ctx) error {
-	return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-		"error": "invalid password",
-	})
-}
+func coursesPasswordMatches(ctx context.Context, encodedHash, password string) bool {
+	defer meta.StartSpan(ctx, "bcrypt").End()
 
-func coursesPasswordMatches(encodedHash, password string) bool {
 	hash, err := base64.RawStdEncoding.DecodeString(strings.TrimSpace(encodedHash))
 	if err != nil {
 		hash = nil
@@ -198,19 +217,24 @@ func sameOriginRequest(ctx fiber.Ctx) bool {
 		return true
 	}
 	parsed, err := url.Parse(origin)
-	return err == nil && strings.EqualFold(parsed.Host, ctx.Host())
+	return err == nil && strings.EqualFold(parsed.Host, requestOrigin(ctx).Host)
 }
 
-func readCoursesCatalog(path string) ([]byte, coursesMetaResponse, error) {
+func readCoursesCatalog(ctx context.Context, path string) ([]byte, coursesMetaResponse, error) {
+	span := meta.StartSpan(ctx, "catalog_read")
 	info, err := statCoursesCatalog(path)
 	if err != nil {
+		span.End()
 		return nil, coursesMetaResponse{}, err
 	}
-
 	catalog, err := os.ReadFile(path)
+	span.End()
 	if err != nil {
 		return nil, coursesMetaResponse{}, fmt.Errorf("read catalog: %w", err)
 	}
+
+	span = meta.StartSpan(ctx, "catalog_validate")
+	defer span.End()
 	if len(catalog) < 2 || catalog[0] != 0x1f || catalog[1] != 0x8b {
 		return nil, coursesMetaResponse{}, errors.New("catalog is not gzip data")
 	}
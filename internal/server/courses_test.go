@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -15,6 +16,8 @@ import (
 
 	"github.com/phuslu/log"
 	"golang.org/x/crypto/bcrypt"
+
+	"github.com/xenking/dummypage/internal/meta"
 )
 
 func TestCoursesCatalogRequiresPassword(t *testing.T) {
@@ -229,6 +232,58 @@ func TestCoursesCatalogDownload(t *testing.T) {
 	}
 }
 
+func TestCoursesCatalogUnlockIsTraced(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	password := "correct horse battery staple"
+	tracePath := filepath.Join(t.TempDir(), "spans.jsonl")
+	var logs bytes.Buffer
+	app := New(Config{
+		CoursesCatalog:      writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2","entries":[]}`),
+		CoursesPasswordHash: hashTestPassword(t, password),
+		TraceParent:         true,
+		TraceFile:           tracePath,
+	}, &log.Logger{Writer: log.IOWriter{Writer: &logs}})
+
+	request := httptest.NewRequest(http.MethodPost, "/courses/api/catalog", strings.NewReader(`{"password":"`+password+`"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("traceparent", parent)
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	_ = response.Body.Close()
+
+	trace, ok := meta.ParseTraceParent(response.Header.Get("traceparent"))
+	if response.StatusCode != http.StatusOK || !ok || trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("response = %d, traceparent %q", response.StatusCode, response.Header.Get("traceparent"))
+	}
+	requestID := response.Header.Get("X-Request-ID")
+	if !strings.Contains(logs.String(), `"request_id":"`+requestID+`"`) ||
+		!strings.Contains(logs.String(), `"route":"/courses/api/catalog"`) ||
+		!strings.Contains(logs.String(), `"trace_id":"`+trace.TraceID+`"`) {
+		t.Fatalf("access log = %s", logs.String())
+	}
+
+	data, err := os.ReadFile(tracePath)
+	if err != nil {
+		t.Fatalf("read trace file: %v", err)
+	}
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var span meta.SpanRecord
+		if err := json.Unmarshal([]byte(line), &span); err != nil {
+			t.Fatalf("decode span %s: %v", line, err)
+		}
+		if span.TraceID != trace.TraceID || span.ParentID != trace.SpanID || span.RequestID != requestID {
+			t.Fatalf("span = %+v, want trace %+v", span, trace)
+		}
+		names = append(names, span.Name)
+	}
+	if strings.Join(names, ",") != "bcrypt,catalog_read,catalog_validate" {
+		t.Fatalf("spans = %v", names)
+	}
+}
+
 func TestCoursesCatalogUnlocksWithPasswordHashFile(t *testing.T) {
 	const catalogJSON = `{"schema_version":"courses-catalog/v2","entries":[{"title":"Fixture Course"}]}`
 	catalogPath := writeTestCoursesFile(t, catalogJSON)
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/phuslu/log"
+)
+
+const (
+	cspReportsPath        = "/csp-reports"
+	cspReportEndpointName = "csp-endpoint"
+	maxCSPReportSize      = 16 << 10
+	maxCSPReportsPerBody  = 20
+	maxCSPReportFieldLen  = 512
+	cspReportDedupWindow  = 10 * time.Minute
+	maxCSPReportDedupKeys = 1024
+)
+
+var defaultCoursesContentSecurityPolicy = strings.Join([]string{
+	"default-src 'self'",
+	"script-src 'self'",
+	"style-src 'self'",
+	"img-src 'self' data:",
+	"connect-src 'self'",
+	"worker-src 'self'",
+	"object-src 'none'",
+	"base-uri 'none'",
+	"frame-ancestors 'none'",
+	"form-action 'self'",
+}, "; ")
+
+type cspViolation struct {
+	DocumentURL        string
+	Referrer           string
+	EffectiveDirective string
+	BlockedURL         string
+	SourceFile         string
+	LineNumber         int
+	ColumnNumber       int
+	Disposition        string
+	StatusCode         int
+}
+
+type legacyCSPReport struct {
+	CSPReport *struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		ColumnNumber       int    `json:"column-number"`
+		Disposition        string `json:"disposition"`
+		StatusCode         int    `json:"status-code"`
+	} `json:"csp-report"`
+}
+
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		Referrer           string `json:"referrer"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		ColumnNumber       int    `json:"columnNumber"`
+		Disposition        string `json:"disposition"`
+		StatusCode         int    `json:"statusCode"`
+	} `json:"body"`
+}
+
+// contentSecurityPolicy sets the policy, or its report-only variant, with the
+// report endpoint appended. An empty policy leaves responses untouched.
+func contentSecurityPolicy(policy string, reportOnly bool) fiber.Handler {
+	policy = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(policy), ";"))
+	header, other := "Content-Security-Policy", "Content-Security-Policy-Report-Only"
+	if reportOnly {
+		header, other = other, header
+	}
+	if policy != "" {
+		policy += "; report-uri " + cspReportsPath + "; report-to " + cspReportEndpointName
+	}
+	return func(ctx fiber.Ctx) error {
+		if policy != "" {
+			ctx.Response().Header.Del(other)
+			ctx.Set(header, policy)
+			ctx.Set("Reporting-Endpoints", cspReportEndpointName+`="`+cspReportsPath+`"`)
+		}
+		return ctx.Next()
+	}
+}
+
+type cspReportDeduper struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	seen map[cspViolation]time.Time
+}
+
+func newCSPReportDeduper() *cspReportDeduper {
+	return &cspReportDeduper{now: time.Now, seen: make(map[cspViolation]time.Time)}
+}
+
+func (d *cspReportDeduper) first(violation cspViolation) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if seenAt, ok := d.seen[violation]; ok && now.Sub(seenAt) < cspReportDedupWindow {
+		return false
+	}
+	if len(d.seen) >= maxCSPReportDedupKeys {
+		for key, seenAt := range d.seen {
+			if now.Sub(seenAt) >= cspReportDedupWindow {
+				delete(d.seen, key)
+			}
+		}
+		if len(d.seen) >= maxCSPReportDedupKeys {
+			return false
+		}
+	}
+	d.seen[violation] = now
+	return true
+}
+
+func handleCSPReport(logger *log.Logger, deduper *cspReportDeduper) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		if len(ctx.Body()) > maxCSPReportSize {
+			return errReportTooLarge
+		}
+		violations, ok := parseCSPReports(ctx.Get(fiber.HeaderContentType), ctx.Body())
+		if !ok {
+			return errInvalidReport
+		}
+		for _, violation := range violations {
+			if !deduper.first(violation) {
+				continue
+			}
+			requestLogger(ctx, logger).Warn().
+				Str("event", "csp_violation").
+				Str("document_url", violation.DocumentURL).
+				Str("referrer", violation.Referrer).
+				Str("directive", violation.EffectiveDirective).
+				Str("blocked_url", violation.BlockedURL).
+				Str("source_file", violation.SourceFile).
+				Int("line", violation.LineNumber).
+				Int("column", violation.ColumnNumber).
+				Str("disposition", violation.Disposition).
+				Int("status_code", violation.StatusCode).
+				Str("ip", clientIP(ctx)).
+				Msg("CSP violation")
+		}
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func parseCSPReports(contentType string, body []byte) ([]cspViolation, bool) {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	switch strings.TrimSpace(mediaType) {
+	case "application/csp-report", fiber.MIMEApplicationJSON:
+		var report legacyCSPReport
+		if err := json.Unmarshal(body, &report); err != nil || report.CSPReport == nil {
+			return nil, false
+		}
+		legacy := report.CSPReport
+		directive := legacy.EffectiveDirective
+		if directive == "" {
+			directive = legacy.ViolatedDirective
+		}
+		violation, ok := normalizeCSPViolation(cspViolation{
+			DocumentURL:        legacy.DocumentURI,
+			Referrer:           legacy.Referrer,
+			EffectiveDirective: directive,
+			BlockedURL:         legacy.BlockedURI,
+			SourceFile:         legacy.SourceFile,
+			LineNumber:         legacy.LineNumber,
+			ColumnNumber:       legacy.ColumnNumber,
+			Disposition:        legacy.Disposition,
+			StatusCode:         legacy.StatusCode,
+		})
+		if !ok {
+			return nil, false
+		}
+		return []cspViolation{violation}, true
+	case "application/reports+json":
+		var reports []reportingAPIReport
+		if err := json.Unmarshal(body, &reports); err != nil || len(reports) == 0 || len(reports) > maxCSPReportsPerBody {
+			return nil, false
+		}
+		violations := make([]cspViolation, 0, len(reports))
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			violation, ok := normalizeCSPViolation(cspViolation{
+				DocumentURL:        report.Body.DocumentURL,
+				Referrer:           report.Body.Referrer,
+				EffectiveDirective: report.Body.EffectiveDirective,
+				BlockedURL:         report.Body.BlockedURL,
+				SourceFile:         report.Body.SourceFile,
+				LineNumber:         report.Body.LineNumber,
+				ColumnNumber:       report.Body.ColumnNumber,
+				Disposition:        report.Body.Disposition,
+				StatusCode:         report.Body.StatusCode,
+			})
+			if !ok {
+				return nil, false
+			}
+			violations = append(violations, violation)
+		}
+		return violations, true
+	default:
+		return nil, false
+	}
+}
+
+func normalizeCSPViolation(violation cspViolation) (cspViolation, bool) {
+	violation.EffectiveDirective = strings.ToLower(truncateCSPField(violation.EffectiveDirective))
+	if violation.EffectiveDirective == "" || violation.DocumentURL == "" {
+		return cspViolation{}, false
+	}
+	switch violation.Disposition {
+	case "", "enforce", "report":
+	default:
+		return cspViolation{}, false
+	}
+	if violation.LineNumber < 0 || violation.ColumnNumber < 0 || violation.StatusCode < 0 {
+		return cspViolation{}, false
+	}
+	violation.DocumentURL = redactCSPURL(violation.DocumentURL)
+	violation.Referrer = redactCSPURL(violation.Referrer)
+	violation.BlockedURL = redactCSPURL(violation.BlockedURL)
+	violation.SourceFile = redactCSPURL(violation.SourceFile)
+	return violation, true
+}
+
+// redactCSPURL drops query strings and fragments, which can carry tokens,
+// before reports reach the logs. Keywords such as "inline" pass through.
+func redactCSPURL(raw string) string {
+	raw = truncateCSPField(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme == "" {
+		if before, _, found := strings.Cut(raw, "?"); found {
+			return before
+		}
+		return raw
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	parsed.User = nil
+	return parsed.String()
+}
+
+func truncateCSPField(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) <= maxCSPReportFieldLen {
+		return value
+	}
+	return strings.ToValidUTF8(value[:maxCSPReportFieldLen], "")
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/phuslu/log"
+)
+
+func TestContentSecurityPolicyIsConfigurable(t *testing.T) {
+	app := New(Config{
+		ContentSecurityPolicy:                  "default-src 'self'; style-src 'self' 'unsafe-inline';",
+		ContentSecurityPolicyReportOnly:        true,
+		CoursesContentSecurityPolicyReportOnly: false,
+	}, testLogger())
+
+	site := cspTestRequest(t, app, http.MethodGet, "/version", "", "")
+	defer site.Body.Close()
+	if got := site.Header.Get("Content-Security-Policy-Report-Only"); got != "default-src 'self'; style-src 'self' 'unsafe-inline'; report-uri /csp-reports; report-to csp-endpoint" {
+		t.Fatalf("site Content-Security-Policy-Report-Only = %q", got)
+	}
+	if got := site.Header.Get("Content-Security-Policy"); got != "" {
+		t.Fatalf("site Content-Security-Policy = %q, want report-only", got)
+	}
+	if got := site.Header.Get("Reporting-Endpoints"); got != `csp-endpoint="/csp-reports"` {
+		t.Fatalf("Reporting-Endpoints = %q", got)
+	}
+
+	courses := cspTestRequest(t, app, http.MethodGet, "/courses/api/meta", "", "")
+	defer courses.Body.Close()
+	if got := courses.Header.Get("Content-Security-Policy"); !strings.HasPrefix(got, defaultCoursesContentSecurityPolicy+"; report-uri /csp-reports") {
+		t.Fatalf("courses Content-Security-Policy = %q", got)
+	}
+	if got := courses.Header.Get("Content-Security-Policy-Report-Only"); got != "" {
+		t.Fatalf("courses kept site report-only policy %q", got)
+	}
+}
+
+func TestContentSecurityPolicyDefaultsLeaveSiteUntouched(t *testing.T) {
+	response := cspTestRequest(t, New(Config{}, testLogger()), http.MethodGet, "/version", "", "")
+	defer response.Body.Close()
+	if got := response.Header.Get("Content-Security-Policy"); got != "" {
+		t.Fatalf("site Content-Security-Policy = %q, want none by default", got)
+	}
+}
+
+func TestCSPReportEndpointLogsDedupedViolations(t *testing.T) {
+	var output bytes.Buffer
+	app := New(Config{}, &log.Logger{Writer: log.IOWriter{Writer: &output}})
+
+	legacy := `{"csp-report":{"document-uri":"https://example.test/courses?token=secret","violated-directive":"script-src-elem","blocked-uri":"https://cdn.example/x.js?sig=1","disposition":"enforce"}}`
+	for range 2 {
+		response := cspTestRequest(t, app, http.MethodPost, cspReportsPath, "application/csp-report", legacy)
+		if response.StatusCode != http.StatusNoContent {
+			t.Fatalf("legacy status = %d, want %d", response.StatusCode, http.StatusNoContent)
+		}
+		_ = response.Body.Close()
+	}
+	reporting := `[{"type":"csp-violation","url":"https://example.test/","body":{"documentURL":"https://example.test/","effectiveDirective":"style-src-elem","blockedURL":"inline","disposition":"report"}},{"type":"deprecation","body":{}}]`
+	response := cspTestRequest(t, app, http.MethodPost, cspReportsPath, "application/reports+json", reporting)
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("reporting API status = %d, want %d", response.StatusCode, http.StatusNoContent)
+	}
+
+	logged := output.String()
+	if got := strings.Count(logged, `"event":"csp_violation"`); got != 2 {
+		t.Fatalf("logged %d violations, want 2 after dedup:\n%s", got, logged)
+	}
+	if strings.Contains(logged, "secret") || strings.Contains(logged, "sig=1") {
+		t.Fatalf("violation log leaks query strings:\n%s", logged)
+	}
+	for _, want := range []string{`"directive":"script-src-elem"`, `"directive":"style-src-elem"`, `"blocked_url":"inline"`} {
+		if !strings.Contains(logged, want) {
+			t.Fatalf("violation log missing %s:\n%s", want, logged)
+		}
+	}
+}
+
+func TestCSPReportEndpointRejectsInvalidReports(t *testing.T) {
+	app := New(Config{}, testLogger())
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"wrong content type", "text/plain", `{"csp-report":{"document-uri":"https://example.test/","violated-directive":"img-src"}}`, http.StatusBadRequest},
+		{"malformed json", "application/csp-report", `{`, http.StatusBadRequest},
+		{"missing report", "application/csp-report", `{}`, http.StatusBadRequest},
+		{"missing directive", "application/csp-report", `{"csp-report":{"document-uri":"https://example.test/"}}`, http.StatusBadRequest},
+		{"bad disposition", "application/reports+json", `[{"type":"csp-violation","body":{"documentURL":"https://example.test/","effectiveDirective":"img-src","disposition":"block"}}]`, http.StatusBadRequest},
+		{"empty batch", "application/reports+json", `[]`, http.StatusBadRequest},
+		{"oversized", "application/csp-report", `{"csp-report":{"document-uri":"` + strings.Repeat("x", maxCSPReportSize) + `"}}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := cspTestRequest(t, app, http.MethodPost, cspReportsPath, test.contentType, test.body)
+			defer response.Body.Close()
+			if response.StatusCode != test.want {
+				t.Fatalf("status = %d, want %d", response.StatusCode, test.want)
+			}
+		})
+	}
+}
+
+func cspTestRequest(t *testing.T, app *Server, method, path, contentType, body string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	return response
+}
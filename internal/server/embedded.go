@@ -0,0 +1,25 @@
+package server
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/xenking/dummypage/static"
+)
+
+// siteFS returns the configured folder when set, so local development can
+// edit files on disk, and the embedded copy otherwise.
+func siteFS(folder, embeddedDir string) fs.FS {
+	if strings.TrimSpace(folder) != "" {
+		return os.DirFS(folder)
+	}
+	if embeddedDir == "." {
+		return static.Files
+	}
+	sub, err := fs.Sub(static.Files, embeddedDir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
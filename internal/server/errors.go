@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"github.com/phuslu/log"
+
+	"github.com/xenking/dummypage/internal/meta"
+)
+
+// apiError is the error every handler and middleware returns instead of
+// writing its own response. Code is stable and meant for programs; Message
+// is for people and may change. errorHandler renders it.
+type apiError struct {
+	Status     int
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+	// RunID names the admin job run the error refers to.
+	RunID string
+	// Err is the cause. It is logged but never sent to clients.
+	Err error
+}
+
+var (
+	errRequestForbidden       = newAPIError(fiber.StatusForbidden, "request_forbidden", "request forbidden")
+	errAccessDenied           = newAPIError(fiber.StatusForbidden, "access_denied", "access denied")
+	errInvalidPassword        = newAPIError(fiber.StatusUnauthorized, "invalid_password", "invalid password")
+	errAccountRequired        = newAPIError(fiber.StatusUnauthorized, "account_required", "account required")
+	errCodeRequired           = newAPIError(fiber.StatusUnauthorized, "code_required", "code required")
+	errInvalidCode            = newAPIError(fiber.StatusUnauthorized, "invalid_code", "invalid code")
+	errChallengeRequired      = newAPIError(fiber.StatusPreconditionRequired, "challenge_required", "challenge required")
+	errAccountsUnavailable    = newAPIError(fiber.StatusServiceUnavailable, "accounts_unavailable", "accounts unavailable")
+	errCatalogUnavailable     = newAPIError(fiber.StatusServiceUnavailable, "catalog_unavailable", "catalog unavailable")
+	errSessionRequired        = newAPIError(fiber.StatusUnauthorized, "session_required", "session required")
+	errInvalidReport          = newAPIError(fiber.StatusBadRequest, "invalid_report", "invalid report")
+	errReportTooLarge         = newAPIError(fiber.StatusRequestEntityTooLarge, "report_too_large", "report too large")
+	errReportQueueFull        = newAPIError(fiber.StatusServiceUnavailable, "report_queue_full", "report queue full")
+	errReportQueueUnavailable = newAPIError(fiber.StatusServiceUnavailable, "report_queue_unavailable", "report queue unavailable")
+	errEventStreamsBusy       = newAPIError(fiber.StatusServiceUnavailable, "event_streams_busy", "too many event streams")
+	errEventStreamsPerClient  = newAPIError(fiber.StatusTooManyRequests, "event_streams_per_client", "too many event streams from this client")
+	errInvalidToken           = newAPIError(fiber.StatusUnauthorized, "invalid_token", "invalid token")
+	errUnknownRun             = newAPIError(fiber.StatusNotFound, "unknown_run", "unknown run")
+	errJobRunning             = newAPIError(fiber.StatusConflict, "job_running", "another job is running")
+	errJobsUnavailable        = newAPIError(fiber.StatusServiceUnavailable, "jobs_unavailable", "jobs unavailable")
+	errRateLimited            = newAPIError(fiber.StatusTooManyRequests, "rate_limited", "too many requests")
+	errNotFound               = newAPIError(fiber.StatusNotFound, "not_found", "not found")
+	errInternal               = newAPIError(fiber.StatusInternalServerError, "internal_error", "internal server error")
+)
+
+func newAPIError(status int, code, message string) *apiError {
+	return &apiError{Status: status, Code: code, Message: message}
+}
+
+func (e *apiError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap exposes the status as a *fiber.Error, so middleware such as the
+// limiter sees the final status before the error is rendered.
+func (e *apiError) Unwrap() []error {
+	return []error{fiber.NewError(e.Status, e.Message), e.Err}
+}
+
+func (e *apiError) withCause(err error) *apiError {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+func (e *apiError) withRunID(id string) *apiError {
+	copied := *e
+	copied.RunID = id
+	return &copied
+}
+
+func (e *apiError) withRetryAfter(d time.Duration) *apiError {
+	copied := *e
+	copied.RetryAfter = d
+	return &copied
+}
+
+type errorResponse struct {
+	// Error keeps the message under the key clients read before codes
+	// existed.
+	Error      string `json:"error"`
+	Code       string `json:"code"`
+	RequestID  string `json:"request_id,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+	RunID      string `json:"run_id,omitempty"`
+}
+
+// asAPIError maps any error to an apiError. Plain fiber errors keep their
+// status; anything else is an internal error whose text stays in the logs.
+func asAPIError(err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) && fiberErr.Code < fiber.StatusInternalServerError {
+		message := strings.ToLower(http.StatusText(fiberErr.Code))
+		return newAPIError(fiberErr.Code, strings.ReplaceAll(message, " ", "_"), message).withCause(err)
+	}
+	return errInternal.withCause(err)
+}
+
+// errorHandler is the app's ErrorHandler. It renders an error page for
+// clients that prefer HTML and the JSON errorResponse for everyone else,
+// and logs server errors with their cause.
+func errorHandler(logger *log.Logger) fiber.ErrorHandler {
+	return func(ctx fiber.Ctx, err error) error {
+		apiErr := asAPIError(err)
+		response := errorResponse{
+			Error:     apiErr.Message,
+			Code:      apiErr.Code,
+			RequestID: requestid.FromContext(ctx),
+			RunID:     apiErr.RunID,
+		}
+		if apiErr.Status >= fiber.StatusInternalServerError {
+			requestLogger(ctx, logger).Error().
+				Err(err).
+				Str("code", apiErr.Code).
+				Str("method", ctx.Method()).
+				Str("path", ctx.Path()).
+				Msg("Request failed")
+		}
+		if apiErr.RetryAfter > 0 {
+			response.RetryAfter = int((apiErr.RetryAfter + time.Second - 1) / time.Second)
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(response.RetryAfter))
+		} else if seconds, err := strconv.Atoi(ctx.GetRespHeader(fiber.HeaderRetryAfter)); err == nil && seconds > 0 {
+			// The limiter sets Retry-After itself before calling LimitReached.
+			response.RetryAfter = seconds
+		}
+
+		ctx.Vary(fiber.HeaderAccept)
+		ctx.Status(apiErr.Status)
+		if !prefersHTML(ctx) {
+			return ctx.JSON(response)
+		}
+		view := "error"
+		if apiErr.Status == fiber.StatusNotFound {
+			view = "404"
+		}
+		if err := ctx.Render(view, fiber.Map{
+			"Status":    apiErr.Status,
+			"Message":   apiErr.Message,
+			"RequestID": response.RequestID,
+		}); err != nil {
+			return ctx.SendString(apiErr.Message)
+		}
+		return nil
+	}
+}
+
+// prefersHTML follows Accept, defaulting to JSON under API paths and to
+// HTML for pages.
+func prefersHTML(ctx fiber.Ctx) bool {
+	offers := []string{fiber.MIMETextHTML, fiber.MIMEApplicationJSON}
+	if strings.Contains(ctx.Path(), "/api/") {
+		offers[0], offers[1] = offers[1], offers[0]
+	}
+	return ctx.Accepts(offers...) == fiber.MIMETextHTML
+}
+
+// requestLogger returns the request-scoped logger for ctx, tagged with the
+// matched route. Middleware that runs before the access log has none yet,
+// so it falls back to logger with the request ID.
+func requestLogger(ctx fiber.Ctx, logger *log.Logger) *log.Logger {
+	request := meta.GetRequest(ctx.Context())
+	if request == nil {
+		request = &meta.Request{ID: requestid.FromContext(ctx)}
+		return meta.GetLogger(meta.WithRequest(meta.WithLogger(ctx.Context(), logger), request))
+	}
+	request.Route = ctx.Route().Path
+	return meta.GetLogger(ctx.Context())
+}
+
+func limitReached(fiber.Ctx) error {
+	return errRateLimited
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/phuslu/log"
+)
+
+func TestErrorResponsesFollowAccept(t *testing.T) {
+	app := New(Config{ViewsExt: ".html"}, testLogger())
+
+	tests := []struct {
+		path        string
+		accept      string
+		contentType string
+	}{
+		{"/missing", "text/html", "text/html"},
+		{"/missing", "", "text/html"},
+		{"/missing", "application/json", "application/json"},
+		{"/courses/api/missing", "", "application/json"},
+		{"/courses/api/missing", "text/html,application/xhtml+xml", "text/html"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.accept != "" {
+			request.Header.Set("Accept", test.accept)
+		}
+		response, err := app.Test(request)
+		if err != nil {
+			t.Fatalf("%s %q: test request: %v", test.path, test.accept, err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s %q: status = %d, want %d", test.path, test.accept, response.StatusCode, http.StatusNotFound)
+		}
+		if got := response.Header.Get("Content-Type"); !strings.HasPrefix(got, test.contentType) {
+			t.Fatalf("%s %q: content type = %q, want %q", test.path, test.accept, got, test.contentType)
+		}
+		if !strings.Contains(response.Header.Get("Vary"), "Accept") {
+			t.Fatalf("%s %q: Vary = %q", test.path, test.accept, response.Header.Get("Vary"))
+		}
+		if test.contentType != "application/json" {
+			continue
+		}
+		var result errorResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Fatalf("%s %q: decode %s: %v", test.path, test.accept, body, err)
+		}
+		if result.Code != "not_found" || result.Error != "not found" {
+			t.Fatalf("%s %q: response = %+v", test.path, test.accept, result)
+		}
+		if result.RequestID == "" || result.RequestID != response.Header.Get("X-Request-ID") {
+			t.Fatalf("%s %q: request_id = %q, header %q", test.path, test.accept, result.RequestID, response.Header.Get("X-Request-ID"))
+		}
+	}
+}
+
+func TestRateLimitedErrorCarriesRetryAfter(t *testing.T) {
+	app := testCoursesApp(t, writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2"}`), "correct horse battery staple")
+
+	for attempt := 1; attempt <= 6; attempt++ {
+		request := httptest.NewRequest(http.MethodPost, "/courses/api/catalog", strings.NewReader(`{"password":"wrong"}`))
+		request.Header.Set("Content-Type", "application/json")
+		response, err := app.Test(request)
+		if err != nil {
+			t.Fatalf("attempt %d: test request: %v", attempt, err)
+		}
+		var result errorResponse
+		_ = json.NewDecoder(response.Body).Decode(&result)
+		_ = response.Body.Close()
+
+		if attempt <= 5 && result.Code != "invalid_password" {
+			t.Fatalf("attempt %d: response = %+v, want invalid_password", attempt, result)
+		}
+		if attempt == 6 && (result.Code != "rate_limited" || result.RetryAfter <= 0 || response.Header.Get("Retry-After") == "") {
+			t.Fatalf("attempt %d: response = %+v, Retry-After %q", attempt, result, response.Header.Get("Retry-After"))
+		}
+	}
+}
+
+func TestServerErrorsAreLoggedWithoutLeakingCause(t *testing.T) {
+	var logs bytes.Buffer
+	logger := &log.Logger{Writer: log.IOWriter{Writer: &logs}}
+	app := New(Config{
+		CoursesCatalog:      writeRawTestCoursesFile(t, "not gzip"),
+		CoursesPasswordHash: hashTestPassword(t, "correct horse battery staple"),
+	}, logger)
+
+	request := httptest.NewRequest(http.MethodPost, "/courses/api/catalog", strings.NewReader(`{"password":"correct horse battery staple"}`))
+	request.Header.Set("Content-Type", "application/json")
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+
+	var result errorResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+	if response.StatusCode != http.StatusServiceUnavailable || result.Code != "catalog_unavailable" {
+		t.Fatalf("response = %d %+v", response.StatusCode, result)
+	}
+	if strings.Contains(string(body), "gzip") {
+		t.Fatalf("response leaks cause: %s", body)
+	}
+	if !strings.Contains(logs.String(), "catalog is not gzip data") || !strings.Contains(logs.String(), result.RequestID) {
+		t.Fatalf("logs = %s", logs.String())
+	}
+}
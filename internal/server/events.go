@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	coursesEventsPath          = "/courses/api/events"
+	catalogEventsPollInterval  = 5 * time.Second
+	catalogEventsHeartbeat     = 25 * time.Second
+	catalogEventsMaxLifetime   = 30 * time.Minute
+	catalogEventsRetry         = 10 * time.Second
+	defaultCatalogEventStreams = 512
+	defaultCatalogClientStream = 4
+)
+
+var (
+	errCatalogEventsFull   = errors.New("too many event streams")
+	errCatalogEventsClient = errors.New("too many event streams from this client")
+	errCatalogEventsClosed = errors.New("event streams are closed")
+)
+
+// catalogEvents polls the published catalog and fans "catalog-updated"
+// events out to the open event streams. Each stream has a one-slot buffer
+// that keeps only the newest catalog, so a slow client never blocks the
+// others.
+type catalogEvents struct {
+	path         string
+	maxStreams   int
+	maxPerClient int
+	heartbeat    time.Duration
+	lifetime     time.Duration
+
+	mu        sync.Mutex
+	current   coursesMetaResponse
+	streams   map[chan coursesMetaResponse]string
+	perClient map[string]int
+	closed    bool
+}
+
+func newCatalogEvents(path string, maxStreams, maxPerClient int) *catalogEvents {
+	if maxStreams <= 0 {
+		maxStreams = defaultCatalogEventStreams
+	}
+	if maxPerClient <= 0 {
+		maxPerClient = defaultCatalogClientStream
+	}
+	events := &catalogEvents{
+		path:         path,
+		maxStreams:   maxStreams,
+		maxPerClient: maxPerClient,
+		heartbeat:    catalogEventsHeartbeat,
+		lifetime:     catalogEventsMaxLifetime,
+		streams:      make(map[chan coursesMetaResponse]string),
+		perClient:    make(map[string]int),
+	}
+	if meta, err := readCoursesCatalogMeta(path); err == nil {
+		events.current = meta
+	}
+	return events
+}
+
+// run polls the catalog until ctx is done and then ends every stream, so
+// shutdown does not wait for clients to disconnect.
+func (e *catalogEvents) run(ctx context.Context) {
+	ticker := time.NewTicker(catalogEventsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.close()
+			return
+		case <-ticker.C:
+			e.check()
+		}
+	}
+}
+
+// check publishes the catalog when its version differs from the last one
+// seen. A missing or unreadable catalog is not an update.
+func (e *catalogEvents) check() {
+	meta, err := readCoursesCatalogMeta(e.path)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if meta.Version == e.current.Version {
+		return
+	}
+	e.current = meta
+	for stream := range e.streams {
+		select {
+		case <-stream:
+		default:
+		}
+		stream <- meta
+	}
+}
+
+func (e *catalogEvents) subscribe(client string) (chan coursesMetaResponse, coursesMetaResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case e.closed:
+		return nil, coursesMetaResponse{}, errCatalogEventsClosed
+	case len(e.streams) >= e.maxStreams:
+		return nil, coursesMetaResponse{}, errCatalogEventsFull
+	case e.perClient[client] >= e.maxPerClient:
+		return nil, coursesMetaResponse{}, errCatalogEventsClient
+	}
+	stream := make(chan coursesMetaResponse, 1)
+	e.streams[stream] = client
+	e.perClient[client]++
+	return stream, e.current, nil
+}
+
+func (e *catalogEvents) unsubscribe(stream chan coursesMetaResponse) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	client, ok := e.streams[stream]
+	if !ok {
+		return
+	}
+	delete(e.streams, stream)
+	if e.perClient[client]--; e.perClient[client] <= 0 {
+		delete(e.perClient, client)
+	}
+}
+
+func (e *catalogEvents) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	for stream, client := range e.streams {
+		close(stream)
+		delete(e.streams, stream)
+		delete(e.perClient, client)
+	}
+}
+
+func handleCatalogEvents(events *catalogEvents) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		ctx.Set(fiber.HeaderCacheControl, "no-store")
+		stream, current, err := events.subscribe(clientIP(ctx))
+		if err != nil {
+			if errors.Is(err, errCatalogEventsClient) {
+				return errEventStreamsPerClient.withRetryAfter(catalogEventsRetry)
+			}
+			return errEventStreamsBusy.withCause(err).withRetryAfter(catalogEventsRetry)
+		}
+
+		ctx.Set(fiber.HeaderContentType, "text/event-stream")
+		ctx.Set("X-Accel-Buffering", "no")
+		return ctx.SendStreamWriter(func(w *bufio.Writer) {
+			defer events.unsubscribe(stream)
+			heartbeat := time.NewTicker(events.heartbeat)
+			defer heartbeat.Stop()
+			lifetime := time.NewTimer(events.lifetime)
+			defer lifetime.Stop()
+
+			fmt.Fprintf(w, "retry: %d\n\n", catalogEventsRetry.Milliseconds())
+			if current.Available {
+				writeCatalogEvent(w, current)
+			}
+			for w.Flush() == nil {
+				select {
+				case meta, ok := <-stream:
+					if !ok {
+						return
+					}
+					writeCatalogEvent(w, meta)
+				case <-heartbeat.C:
+					fmt.Fprint(w, ": heartbeat\n\n")
+				case <-lifetime.C:
+					// Ending long streams lets clients reconnect to
+					// another instance and spreads load after restarts.
+					return
+				}
+			}
+		})
+	}
+}
+
+func writeCatalogEvent(w *bufio.Writer, meta coursesMetaResponse) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: catalog-updated\ndata: %s\n\n", data)
+}
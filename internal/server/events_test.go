@@ -0,0 +1,109 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestCatalogEventsLimitStreams(t *testing.T) {
+	events := newCatalogEvents(writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2","entries":[]}`), 2, 1)
+
+	first, _, err := events.subscribe("198.51.100.1")
+	if err != nil {
+		t.Fatalf("subscribe() error = %v", err)
+	}
+	if _, _, err := events.subscribe("198.51.100.1"); !errors.Is(err, errCatalogEventsClient) {
+		t.Fatalf("second stream from client error = %v, want %v", err, errCatalogEventsClient)
+	}
+	if _, _, err := events.subscribe("198.51.100.2"); err != nil {
+		t.Fatalf("subscribe() other client error = %v", err)
+	}
+	if _, _, err := events.subscribe("198.51.100.3"); !errors.Is(err, errCatalogEventsFull) {
+		t.Fatalf("stream over total limit error = %v, want %v", err, errCatalogEventsFull)
+	}
+
+	events.unsubscribe(first)
+	if _, _, err := events.subscribe("198.51.100.1"); err != nil {
+		t.Fatalf("subscribe() after unsubscribe error = %v", err)
+	}
+	events.close()
+	if _, _, err := events.subscribe("198.51.100.4"); !errors.Is(err, errCatalogEventsClosed) {
+		t.Fatalf("subscribe() after close error = %v, want %v", err, errCatalogEventsClosed)
+	}
+}
+
+func TestCatalogEventsStreamPublishedCatalog(t *testing.T) {
+	catalogPath := writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2","entries":[]}`)
+	app := New(Config{CoursesCatalog: catalogPath}, testLogger())
+	initial := app.events.current.Version
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		response, err := app.Test(httptest.NewRequest(http.MethodGet, coursesEventsPath, nil), fiber.TestConfig{Timeout: 0})
+		if err != nil {
+			t.Errorf("test request: %v", err)
+		}
+		responses <- response
+	}()
+	waitForCatalogStreams(t, app.events, 1)
+
+	writeTestCoursesContent(t, catalogPath, `{"schema_version":"courses-catalog/v2","entries":[{"title":"Updated"}]}`)
+	replacedAt := time.Now().Add(time.Second)
+	if err := os.Chtimes(catalogPath, replacedAt, replacedAt); err != nil {
+		t.Fatalf("set replacement timestamp: %v", err)
+	}
+	app.events.check()
+	app.events.close()
+
+	response := <-responses
+	if response == nil {
+		t.FailNow()
+	}
+	defer response.Body.Close()
+	if got := response.Header.Get(fiber.HeaderContentType); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	if response.Header.Get("X-Cache") != "unreachable" || response.Header.Get("X-Ratelimit-Limit") != "" {
+		t.Fatalf("stream went through cache or limiter: %v", response.Header)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	events := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	if len(events) != 3 || !strings.HasPrefix(events[0], "retry: ") {
+		t.Fatalf("stream = %q", body)
+	}
+	if !strings.Contains(events[1], `"version":"`+initial+`"`) {
+		t.Fatalf("initial event = %q, want version %s", events[1], initial)
+	}
+	if !strings.HasPrefix(events[2], "event: catalog-updated\ndata: ") || strings.Contains(events[2], initial) {
+		t.Fatalf("update event = %q", events[2])
+	}
+}
+
+func waitForCatalogStreams(t *testing.T, events *catalogEvents, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events.mu.Lock()
+		got := len(events.streams)
+		events.mu.Unlock()
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("open streams = %d, want %d", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
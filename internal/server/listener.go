@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	unixAddrPrefix       = "unix:"
+	systemdListenFDStart = 3
+)
+
+// listen returns the socket passed by systemd when the process was socket
+// activated, otherwise it listens on addr: a TCP address or "unix:/path".
+func listen(addr, unixSocketMode string) (net.Listener, error) {
+	fds, err := systemdListenFDs(os.Getenv, os.Getpid())
+	if err != nil {
+		return nil, err
+	}
+	if fds > 0 {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+		file := os.NewFile(systemdListenFDStart, "systemd-socket")
+		defer file.Close()
+		ln, err := net.FileListener(file)
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket: %w", err)
+		}
+		return ln, nil
+	}
+
+	if path, ok := strings.CutPrefix(addr, unixAddrPrefix); ok {
+		return listenUnix(path, unixSocketMode)
+	}
+	return net.Listen("tcp4", addr)
+}
+
+// systemdListenFDs reports how many sockets systemd passed to this process.
+// Variables meant for another process, such as a parent that did not unset
+// them, are ignored.
+func systemdListenFDs(getenv func(string) string, pid int) (int, error) {
+	if getenv("LISTEN_FDS") == "" {
+		return 0, nil
+	}
+	if listenPID, err := strconv.Atoi(getenv("LISTEN_PID")); err != nil || listenPID != pid {
+		return 0, nil
+	}
+	fds, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	switch {
+	case err != nil || fds < 0:
+		return 0, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	case fds > 1:
+		return 0, fmt.Errorf("systemd passed %d sockets, want one", fds)
+	}
+	return fds, nil
+}
+
+func listenUnix(path, unixSocketMode string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path is empty")
+	}
+	mode, err := parseUnixSocketMode(unixSocketMode)
+	if err != nil {
+		return nil, err
+	}
+
+	// A socket left behind by a crashed process would make listen fail. Only
+	// sockets are removed so a typo cannot delete a regular file.
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("unix socket path %q exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func parseUnixSocketMode(raw string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(raw), 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid unix socket mode %q", raw)
+	}
+	return os.FileMode(mode), nil
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	ln, err := listen(unixAddrPrefix+path, "0600")
+	if err != nil {
+		t.Fatalf("listen() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0o600 {
+		t.Fatalf("socket mode = %o, want 600", got)
+	}
+
+	app := fiber.New()
+	resolver, _ := newProxyResolver([]string{"unix"}, "")
+	app.Use(resolver.middleware)
+	app.Get("/", func(ctx fiber.Ctx) error {
+		return ctx.SendString(clientIP(ctx))
+	})
+	go func() { _ = app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+	t.Cleanup(func() { _ = app.Shutdown() })
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	request, _ := http.NewRequest(http.MethodGet, "http://app/", nil)
+	request.Header.Set("X-Forwarded-For", "198.51.100.7")
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("request over unix socket: %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if string(body) != "198.51.100.7" {
+		t.Fatalf("client IP over trusted unix socket = %q", body)
+	}
+}
+
+func TestListenUnixRefusesToReplaceRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	if err := os.WriteFile(path, []byte("keep"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := listen(unixAddrPrefix+path, "0660"); err == nil {
+		t.Fatal("listen() error = nil, want refusal")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "keep" {
+		t.Fatalf("regular file was modified: %q, %v", data, err)
+	}
+	if _, err := listen(unixAddrPrefix+filepath.Join(t.TempDir(), "x.sock"), "999"); err == nil {
+		t.Fatal("listen() with invalid mode error = nil")
+	}
+}
+
+func TestSystemdListenFDs(t *testing.T) {
+	pid := 4242
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    int
+		wantErr bool
+	}{
+		{"not activated", nil, 0, false},
+		{"activated", map[string]string{"LISTEN_PID": strconv.Itoa(pid), "LISTEN_FDS": "1"}, 1, false},
+		{"other process", map[string]string{"LISTEN_PID": "1", "LISTEN_FDS": "1"}, 0, false},
+		{"malformed count", map[string]string{"LISTEN_PID": strconv.Itoa(pid), "LISTEN_FDS": "x"}, 0, true},
+		{"several sockets", map[string]string{"LISTEN_PID": strconv.Itoa(pid), "LISTEN_FDS": "2"}, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := systemdListenFDs(func(key string) string { return test.env[key] }, pid)
+			if (err != nil) != test.wantErr || got != test.want {
+				t.Fatalf("systemdListenFDs() = %d, %v; want %d, error %v", got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type requestLocal int
+
+const (
+	clientIPLocal requestLocal = iota
+	requestOriginLocal
+	adminHandlerErrorLocal
+)
+
+// proxyResolver derives the client address and public origin of a request,
+// trusting forwarding headers only when the peer is a configured proxy. The
+// "unix" entry trusts every peer connected over a Unix socket.
+type proxyResolver struct {
+	trusted      []netip.Prefix
+	trustUnix    bool
+	publicOrigin *url.URL
+}
+
+func newProxyResolver(trustedProxies []string, publicOrigin string) (*proxyResolver, error) {
+	resolver := &proxyResolver{}
+	for _, raw := range trustedProxies {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if strings.EqualFold(raw, "unix") {
+			resolver.trustUnix = true
+			continue
+		}
+		prefix, err := parseAddrOrPrefix(raw)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", raw, err)
+		}
+		resolver.trusted = append(resolver.trusted, prefix)
+	}
+	if origin := strings.TrimSpace(publicOrigin); origin != "" {
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+			(parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+			return nil, fmt.Errorf("public origin %q must be an http(s) scheme and host", origin)
+		}
+		resolver.publicOrigin = &url.URL{Scheme: parsed.Scheme, Host: strings.ToLower(parsed.Host)}
+	}
+	return resolver, nil
+}
+
+func parseAddrOrPrefix(raw string) (netip.Prefix, error) {
+	if strings.Contains(raw, "/") {
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (r *proxyResolver) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *proxyResolver) middleware(ctx fiber.Ctx) error {
+	peer, _ := netip.AddrFromSlice(ctx.RequestCtx().RemoteIP())
+	peer = peer.Unmap()
+	trustedPeer := peer.IsValid() && r.isTrusted(peer)
+	if _, ok := ctx.RequestCtx().RemoteAddr().(*net.UnixAddr); ok {
+		trustedPeer = r.trustUnix
+	}
+
+	client := peer
+	if trustedPeer {
+		client = r.forwardedClient(ctx, peer)
+	}
+	if client.IsValid() {
+		ctx.Locals(clientIPLocal, client.String())
+	}
+	ctx.Locals(requestOriginLocal, r.origin(ctx, trustedPeer))
+	return ctx.Next()
+}
+
+// forwardedClient walks the forwarding chain from the nearest hop outwards and
+// returns the first address that is not itself a trusted proxy.
+func (r *proxyResolver) forwardedClient(ctx fiber.Ctx, peer netip.Addr) netip.Addr {
+	var chain []netip.Addr
+	switch {
+	case ctx.Get(fiber.HeaderForwarded) != "":
+		chain = parseForwardedFor(ctx.Get(fiber.HeaderForwarded))
+	case ctx.Get(fiber.HeaderXForwardedFor) != "":
+		chain = parseForwardedAddrs(strings.Split(ctx.Get(fiber.HeaderXForwardedFor), ","))
+	case ctx.Get("X-Real-IP") != "":
+		chain = parseForwardedAddrs([]string{ctx.Get("X-Real-IP")})
+	}
+	if chain == nil {
+		return peer
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !r.isTrusted(chain[i]) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
+
+func (r *proxyResolver) origin(ctx fiber.Ctx, trustedPeer bool) *url.URL {
+	if r.publicOrigin != nil {
+		return r.publicOrigin
+	}
+	scheme := ctx.Scheme()
+	host := ctx.Host()
+	if trustedPeer {
+		if proto := firstForwardedValue(ctx.Get(fiber.HeaderXForwardedProto)); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstForwardedValue(ctx.Get(fiber.HeaderXForwardedHost)); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+	return &url.URL{Scheme: scheme, Host: strings.ToLower(host)}
+}
+
+func parseForwardedFor(header string) []netip.Addr {
+	var values []string
+	for element := range strings.SplitSeq(header, ",") {
+		for pair := range strings.SplitSeq(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				values = append(values, value)
+			}
+		}
+	}
+	return parseForwardedAddrs(values)
+}
+
+// parseForwardedAddrs returns nil when any hop is malformed, so a forged or
+// obfuscated chain falls back to the peer address instead of a guess.
+func parseForwardedAddrs(values []string) []netip.Addr {
+	addrs := make([]netip.Addr, 0, len(values))
+	for _, value := range values {
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if addrPort, err := netip.ParseAddrPort(value); err == nil {
+			addrs = append(addrs, addrPort.Addr().Unmap())
+			continue
+		}
+		addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
+		if err != nil {
+			return nil
+		}
+		addrs = append(addrs, addr.Unmap())
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	return addrs
+}
+
+func firstForwardedValue(header string) string {
+	value, _, _ := strings.Cut(header, ",")
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func clientIP(ctx fiber.Ctx) string {
+	if ip, ok := ctx.Locals(clientIPLocal).(string); ok && ip != "" {
+		return ip
+	}
+	return ctx.IP()
+}
+
+func requestOrigin(ctx fiber.Ctx) *url.URL {
+	if origin, ok := ctx.Locals(requestOriginLocal).(*url.URL); ok {
+		return origin
+	}
+	return &url.URL{Scheme: ctx.Scheme(), Host: strings.ToLower(ctx.Host())}
+}
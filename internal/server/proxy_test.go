@@ -0,0 +1,161 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestProxyResolverClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted peer ignores headers", nil, map[string]string{"X-Forwarded-For": "198.51.100.7"}, "0.0.0.0"},
+		{"trusted peer uses forwarded for", []string{"0.0.0.0"}, map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"rightmost untrusted hop wins", []string{"0.0.0.0", "10.0.0.0/8"}, map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.7, 10.1.2.3"}, "198.51.100.7"},
+		{"all trusted hops use leftmost", []string{"0.0.0.0", "10.0.0.0/8"}, map[string]string{"X-Forwarded-For": "10.0.0.1, 10.0.0.2"}, "10.0.0.1"},
+		{"forwarded header wins", []string{"0.0.0.0"}, map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=0.0.0.0`, "X-Forwarded-For": "198.51.100.7"}, "2001:db8::1"},
+		{"real ip fallback", []string{"0.0.0.0"}, map[string]string{"X-Real-IP": "198.51.100.8"}, "198.51.100.8"},
+		{"malformed chain keeps peer", []string{"0.0.0.0"}, map[string]string{"X-Forwarded-For": "198.51.100.7, not-an-ip"}, "0.0.0.0"},
+		{"obfuscated forwarded keeps peer", []string{"0.0.0.0"}, map[string]string{"Forwarded": "for=_hidden"}, "0.0.0.0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver, err := newProxyResolver(test.trusted, "")
+			if err != nil {
+				t.Fatalf("newProxyResolver() error = %v", err)
+			}
+			if got := proxyTestRequest(t, resolver, test.headers, func(ctx fiber.Ctx) string {
+				return clientIP(ctx)
+			}); got != test.want {
+				t.Fatalf("clientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProxyResolverOrigin(t *testing.T) {
+	headers := map[string]string{"X-Forwarded-Host": "courses.example", "X-Forwarded-Proto": "https"}
+	originOf := func(ctx fiber.Ctx) string {
+		return requestOrigin(ctx).String()
+	}
+
+	untrusted, _ := newProxyResolver(nil, "")
+	if got := proxyTestRequest(t, untrusted, headers, originOf); got != "http://example.com" {
+		t.Fatalf("untrusted origin = %q", got)
+	}
+	trusted, _ := newProxyResolver([]string{"0.0.0.0/32"}, "")
+	if got := proxyTestRequest(t, trusted, headers, originOf); got != "https://courses.example" {
+		t.Fatalf("trusted origin = %q", got)
+	}
+	public, _ := newProxyResolver(nil, "https://Public.Example/")
+	if got := proxyTestRequest(t, public, headers, originOf); got != "https://public.example" {
+		t.Fatalf("public origin = %q", got)
+	}
+}
+
+func TestNewProxyResolverRejectsInvalidConfig(t *testing.T) {
+	for _, test := range []struct {
+		trusted []string
+		origin  string
+	}{
+		{[]string{"10.0.0.0/33"}, ""},
+		{[]string{"proxy.internal"}, ""},
+		{nil, "courses.example"},
+		{nil, "ftp://courses.example"},
+		{nil, "https://courses.example/path"},
+	} {
+		if _, err := newProxyResolver(test.trusted, test.origin); err == nil {
+			t.Fatalf("newProxyResolver(%q, %q) error = nil, want error", test.trusted, test.origin)
+		}
+	}
+}
+
+func TestCatalogLimiterUsesResolvedClientIP(t *testing.T) {
+	app := New(Config{
+		CoursesCatalog:      writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2"}`),
+		CoursesPasswordHash: hashTestPassword(t, "correct horse battery staple"),
+		TrustedProxies:      []string{"0.0.0.0/32"},
+	}, testLogger())
+
+	attempt := func(client string) int {
+		request := httptest.NewRequest(http.MethodPost, "/courses/api/catalog", strings.NewReader(`{"password":"wrong"}`))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Forwarded-For", client)
+		response, err := app.Test(request)
+		if err != nil {
+			t.Fatalf("test request: %v", err)
+		}
+		defer response.Body.Close()
+		return response.StatusCode
+	}
+	for range 5 {
+		if got := attempt("198.51.100.7"); got != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d", got, http.StatusUnauthorized)
+		}
+	}
+	if got := attempt("198.51.100.7"); got != http.StatusTooManyRequests {
+		t.Fatalf("limited client status = %d, want %d", got, http.StatusTooManyRequests)
+	}
+	if got := attempt("198.51.100.8"); got != http.StatusUnauthorized {
+		t.Fatalf("other client status = %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestCatalogOriginCheckUsesPublicOrigin(t *testing.T) {
+	password := "correct horse battery staple"
+	app := New(Config{
+		CoursesCatalog:      writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2"}`),
+		CoursesPasswordHash: hashTestPassword(t, password),
+		PublicOrigin:        "https://courses.example",
+	}, testLogger())
+
+	request := httptest.NewRequest(http.MethodPost, "/courses/api/catalog", strings.NewReader(`{"password":"`+password+`"}`))
+	request.Host = "127.0.0.1:3000"
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Origin", "https://courses.example")
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == coursesSessionCookie && !cookie.Secure {
+			t.Fatal("session cookie is not Secure behind https public origin")
+		}
+	}
+}
+
+func proxyTestRequest(t *testing.T, resolver *proxyResolver, headers map[string]string, read func(fiber.Ctx) string) string {
+	t.Helper()
+
+	app := fiber.New()
+	app.Use(resolver.middleware)
+	app.Get("/", func(ctx fiber.Ctx) error {
+		return ctx.SendString(read(ctx))
+	})
+	request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	return string(body)
+}
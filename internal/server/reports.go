@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+const maxLinkReportRequestSize = 4 << 10
+
+type coursesLinkReportRequest struct {
+	CourseID string `json:"course_id"`
+	URL      string `json:"url"`
+	Reason   string `json:"reason"`
+}
+
+var errInvalidLinkReport = errors.New("invalid link report")
+
+// linkReportsQueue serializes submissions to CoursesLinkReportsFile. Each
+// report reloads and rewrites the whole file instead of keeping the queue in
+// memory, because courses-link-reports approves and dismisses reports in the
+// same file while the server runs; both hold the file's filelock for the
+// whole cycle so neither overwrites the other. Reports are rate limited per
+// client and the file is capped by LoadLinkReports, so a rewrite stays cheap.
+type linkReportsQueue struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newLinkReportsQueue(path string) *linkReportsQueue {
+	return &linkReportsQueue{path: path}
+}
+
+func handleCoursesLinkReport(queue *linkReportsQueue, sessions *coursesSessions) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		ctx.Set(fiber.HeaderCacheControl, "no-store")
+
+		if !sameOriginRequest(ctx) {
+			return errRequestForbidden
+		}
+		if !sessions.valid(ctx) {
+			return errSessionRequired
+		}
+		if !strings.HasPrefix(strings.ToLower(ctx.Get(fiber.HeaderContentType)), fiber.MIMEApplicationJSON) ||
+			len(ctx.Body()) > maxLinkReportRequestSize {
+			return errInvalidReport
+		}
+
+		var request coursesLinkReportRequest
+		if err := ctx.Bind().JSON(&request); err != nil {
+			return errInvalidReport
+		}
+
+		err := queue.submit(request, sessions.now())
+		switch {
+		case errors.Is(err, courses.ErrLinkReportsQueueFull):
+			return errReportQueueFull
+		case errors.Is(err, errInvalidLinkReport):
+			return errInvalidReport
+		case err != nil:
+			return errReportQueueUnavailable.withCause(err)
+		}
+		return ctx.SendStatus(fiber.StatusAccepted)
+	}
+}
+
+func (q *linkReportsQueue) submit(request coursesLinkReportRequest, now time.Time) error {
+	if strings.TrimSpace(q.path) == "" {
+		return errors.New("link reports path is empty")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	unlock, err := filelock.Lock(q.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	reports, err := loadLinkReportsQueue(q.path)
+	if err != nil {
+		return err
+	}
+	reason := courses.LinkReportReason(request.Reason)
+	if _, err := reports.Submit(request.CourseID, request.URL, reason, now); err != nil {
+		if errors.Is(err, courses.ErrLinkReportsQueueFull) {
+			return err
+		}
+		return errInvalidLinkReport
+	}
+	data, err := json.Marshal(reports)
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(q.path, ".link-reports-*.tmp", data)
+}
+
+func loadLinkReportsQueue(path string) (*courses.LinkReports, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return courses.NewLinkReports(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return courses.LoadLinkReports(file)
+}
+
+// writePrivateFile atomically replaces path with an owner-only file, using
+// tempPattern for the temporary file next to it.
+func writePrivateFile(path, tempPattern string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, tempPattern)
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
+	if err := file.Chmod(0o600); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
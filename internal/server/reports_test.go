@@ -0,0 +1,190 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+)
+
+const testReportBody = `{"course_id":"course:0123456789abcdef0123456789abcdef","url":"https://example.test/dead","reason":"dead"}`
+
+func TestCoursesLinkReportRequiresSession(t *testing.T) {
+	app, _ := testLinkReportsApp(t)
+
+	response := postLinkReport(t, app, "", testReportBody)
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", response.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestCoursesLinkReportQueuesReportAfterUnlock(t *testing.T) {
+	app, reportsPath := testLinkReportsApp(t)
+	session := unlockTestSession(t, app)
+
+	for range 2 {
+		response := postLinkReport(t, app, session, testReportBody)
+		if response.StatusCode != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d", response.StatusCode, http.StatusAccepted)
+		}
+		_ = response.Body.Close()
+	}
+
+	file, err := os.Open(reportsPath)
+	if err != nil {
+		t.Fatalf("open reports: %v", err)
+	}
+	defer file.Close()
+	reports, err := courses.LoadLinkReports(file)
+	if err != nil {
+		t.Fatalf("LoadLinkReports() error = %v", err)
+	}
+	pending := reports.Pending()
+	if len(pending) != 1 || pending[0].Reports != 2 || !pending[0].MatchesURL("https://example.test/dead") {
+		t.Fatalf("pending reports = %#v", pending)
+	}
+	info, err := os.Stat(reportsPath)
+	if err != nil {
+		t.Fatalf("stat reports: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("reports mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestCoursesLinkReportRejectsInvalidReports(t *testing.T) {
+	app, reportsPath := testLinkReportsApp(t)
+	session := unlockTestSession(t, app)
+
+	for _, body := range []string{
+		`{"course_id":"course:bad","url":"https://example.test/dead","reason":"dead"}`,
+		`{"course_id":"course:0123456789abcdef0123456789abcdef","url":"https://example.test/dead","reason":"broken"}`,
+		`{"course_id":"course:0123456789abcdef0123456789abcdef","url":"javascript:alert(1)","reason":"spam"}`,
+		`{`,
+		`{"url":"` + strings.Repeat("x", maxLinkReportRequestSize) + `"}`,
+	} {
+		response := postLinkReport(t, app, session, body)
+		if response.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d for %.40q", response.StatusCode, http.StatusBadRequest, body)
+		}
+		_ = response.Body.Close()
+	}
+	if _, err := os.Stat(reportsPath); !os.IsNotExist(err) {
+		t.Fatalf("reports file exists after invalid reports: %v", err)
+	}
+}
+
+func TestCoursesLinkReportRejectsForgedSessionAndCrossOrigin(t *testing.T) {
+	app, _ := testLinkReportsApp(t)
+	session := unlockTestSession(t, app)
+
+	forged := postLinkReport(t, app, session+"x", testReportBody)
+	defer forged.Body.Close()
+	if forged.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("forged status = %d, want %d", forged.StatusCode, http.StatusUnauthorized)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/courses/api/reports", strings.NewReader(testReportBody))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Origin", "https://evil.example")
+	request.Header.Set("Cookie", coursesSessionCookie+"="+session)
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-origin status = %d, want %d", response.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestLinkReportsQueueSerializesConcurrentSubmissions(t *testing.T) {
+	queue := newLinkReportsQueue(filepath.Join(t.TempDir(), "link-reports.json"))
+	now := time.Date(2026, 7, 27, 9, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for index := range 8 {
+		wg.Go(func() {
+			errs <- queue.submit(coursesLinkReportRequest{
+				CourseID: "course:0123456789abcdef0123456789abcdef",
+				URL:      fmt.Sprintf("https://example.test/dead/%d", index),
+				Reason:   "dead",
+			}, now)
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("submit() error = %v", err)
+		}
+	}
+
+	reports, err := loadLinkReportsQueue(queue.path)
+	if err != nil {
+		t.Fatalf("load reports: %v", err)
+	}
+	if pending := reports.Pending(); len(pending) != 8 {
+		t.Fatalf("pending reports = %d, want 8", len(pending))
+	}
+}
+
+func testLinkReportsApp(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	reportsPath := filepath.Join(t.TempDir(), "link-reports.json")
+	return New(Config{
+		CoursesCatalog:         writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2"}`),
+		CoursesPasswordHash:    hashTestPassword(t, "correct horse battery staple"),
+		CoursesLinkReportsFile: reportsPath,
+	}, testLogger()), reportsPath
+}
+
+func unlockTestSession(t *testing.T, app *Server) string {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/courses/api/catalog", strings.NewReader(`{"password":"correct horse battery staple"}`))
+	request.Header.Set("Content-Type", "application/json")
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("unlock status = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == coursesSessionCookie {
+			if !cookie.HttpOnly || cookie.Path != "/courses/api" || cookie.SameSite != http.SameSiteStrictMode {
+				t.Fatalf("session cookie = %#v", cookie)
+			}
+			return cookie.Value
+		}
+	}
+	t.Fatal("unlock response did not set session cookie")
+	return ""
+}
+
+func postLinkReport(t *testing.T, app *Server, session, body string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/courses/api/reports", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if session != "" {
+		request.Header.Set("Cookie", coursesSessionCookie+"="+session)
+	}
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("test request: %v", err)
+	}
+	return response
+}
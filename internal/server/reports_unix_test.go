@@ -0,0 +1,78 @@
+//go:build unix
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xenking/dummypage/internal/courses"
+	"github.com/xenking/dummypage/internal/filelock"
+)
+
+func TestLinkReportsQueueWaitsForModerationLock(t *testing.T) {
+	queue := newLinkReportsQueue(filepath.Join(t.TempDir(), "link-reports.json"))
+	now := time.Date(2026, 7, 27, 9, 0, 0, 0, time.UTC)
+	request := func(index int) coursesLinkReportRequest {
+		return coursesLinkReportRequest{
+			CourseID: "course:0123456789abcdef0123456789abcdef",
+			URL:      fmt.Sprintf("https://example.test/dead/%d", index),
+			Reason:   "dead",
+		}
+	}
+	if err := queue.submit(request(0), now); err != nil {
+		t.Fatalf("submit() error = %v", err)
+	}
+
+	// Play courses-link-reports: dismiss the first report under the file
+	// lock while new reports arrive.
+	unlock, err := filelock.Lock(queue.path)
+	if err != nil {
+		t.Fatalf("lock reports: %v", err)
+	}
+	reports, err := loadLinkReportsQueue(queue.path)
+	if err != nil {
+		t.Fatalf("load reports: %v", err)
+	}
+	first := reports.Pending()[0]
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for index := range 4 {
+		wg.Go(func() { errs <- queue.submit(request(index+1), now) })
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := reports.Dismiss(first.ID, now); err != nil {
+		t.Fatalf("Dismiss() error = %v", err)
+	}
+	data, err := json.Marshal(reports)
+	if err != nil {
+		t.Fatalf("marshal reports: %v", err)
+	}
+	if err := writePrivateFile(queue.path, ".link-reports-*.tmp", data); err != nil {
+		t.Fatalf("write reports: %v", err)
+	}
+	unlock()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("submit() error = %v", err)
+		}
+	}
+
+	reports, err = loadLinkReportsQueue(queue.path)
+	if err != nil {
+		t.Fatalf("load reports: %v", err)
+	}
+	if dismissed, _ := reports.Get(first.ID); dismissed.Status != courses.LinkReportStatusDismissed {
+		t.Fatalf("dismissed report = %#v", dismissed)
+	}
+	if pending := reports.Pending(); len(pending) != 4 {
+		t.Fatalf("pending reports = %d, want 4", len(pending))
+	}
+}
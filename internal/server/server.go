@@ -2,7 +2,8 @@ package server
 
 import (
 	"context"
-	"crypto/rand"
+	"io/fs"
+	"net/http"
 	"strings"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/gofiber/template/html/v2"
 	"github.com/phuslu/log"
 
+	"github.com/xenking/dummypage/internal/jobs"
 	"github.com/xenking/dummypage/internal/meta"
 	logadapter "github.com/xenking/dummypage/pkg/log"
 )
@@ -24,14 +26,31 @@ var appVersion string
 
 type Server struct {
 	*fiber.App
-	addr         string
-	cfg          Config
-	assetVersion string
+	addr     string
+	cfg      Config
+	staticFS fs.FS
+	assets   *assetManifest
+	proxies  *proxyResolver
+	access   *accessControl
+	sessions *coursesSessions
+	events   *catalogEvents
+	jobs     *jobs.Scheduler
+	reports  *linkReportsQueue
+
+	// challenges is nil unless CoursesChallenge is enabled.
+	challenges *unlockChallenges
+	// accounts is nil unless CoursesAccountsFile is set.
+	accounts *unlockAccounts
+	// spans is nil unless TraceFile is set.
+	spans *meta.SpanFile
 }
 
 type Config struct {
-	Addr    string `default:"localhost:3000"`
-	Version string `default:"2.0.0"`
+	// Addr is a TCP address or "unix:/path". A socket passed by systemd
+	// through LISTEN_FDS takes precedence.
+	Addr           string `default:"localhost:3000"`
+	UnixSocketMode string `default:"0660"`
+	Version        string `default:"2.0.0"`
 
 	FilesFolder             string `default:"./files"`
 	FilesPrefix             string `default:"files"`
@@ -40,20 +59,93 @@ type Config struct {
 	CoursesCatalog          string `default:"./data/catalog.json.gz"`
 	CoursesPasswordHash     string
 	CoursesPasswordHashFile string
-	ViewsFolder             string `default:"./static/templates"`
+	CoursesLinkReportsFile  string `default:"./data/link-reports.json"`
+	ViewsFolder             string
 	ViewsExt                string `default:".html"`
-	StaticFolder            string `default:"./static"`
+	StaticFolder            string
 	StaticPrefix            string `default:"/"`
 	TemplatesPrefix         string `default:"templates"`
+	TrustedProxies          []string
+	PublicOrigin            string
+
+	// AccessRules are "<prefix> <allow|deny> <cidr|@file>..." entries, see
+	// accessRule.
+	AccessRules []string
+
+	// AdminTokenHash is the base64 bcrypt hash of the bearer token for the
+	// admin API, which is disabled while it is empty.
+	AdminTokenHash string
+
+	// CoursesEventStreams caps the open catalog event streams in total and
+	// per client IP.
+	CoursesEventStreams          int `default:"512"`
+	CoursesEventStreamsPerClient int `default:"4"`
+
+	// CoursesChallenge requires a proof-of-work solution with every unlock
+	// request. Difficulty is counted in leading zero bits and rises from
+	// min to max bits with recent failed unlocks. Instances behind one
+	// balancer must share CoursesChallengeSecret.
+	CoursesChallenge        bool
+	CoursesChallengeSecret  string
+	CoursesChallengeMinBits int `default:"12"`
+	CoursesChallengeMaxBits int `default:"20"`
+
+	// CoursesAccountsFile lists the accounts allowed to unlock the catalog,
+	// managed with courses-accounts. When set, unlocking requires an account
+	// name, and accounts with TOTP enabled also need a code that is at most
+	// CoursesTOTPSkew 30 second steps early or late.
+	CoursesAccountsFile string
+	CoursesTOTPSkew     int `default:"1"`
+
+	// TraceParent continues W3C traces from incoming traceparent headers
+	// and returns the server span in the response. Spans timing the catalog
+	// read, validation and bcrypt go to TraceFile as JSON lines when it is
+	// set, and to the debug log otherwise.
+	TraceParent bool
+	TraceFile   string
+
+	ContentSecurityPolicy                  string
+	ContentSecurityPolicyReportOnly        bool
+	CoursesContentSecurityPolicy           string
+	CoursesContentSecurityPolicyReportOnly bool
+
+	Jobs jobs.Config
 }
 
 func New(cfg Config, logger *log.Logger) *Server {
 	appVersion = cfg.Version
-	s := newServer(cfg)
-	return s.setupMiddlewares(cfg, logger).registerRoutes()
+	s := newServer(cfg, logger)
+	proxies, err := newProxyResolver(cfg.TrustedProxies, cfg.PublicOrigin)
+	if err != nil {
+		logger.Error().Err(err).Msg("Invalid proxy configuration, forwarding headers are ignored")
+		proxies = &proxyResolver{}
+	}
+	s.proxies = proxies
+	access, err := newAccessControl(cfg.AccessRules, logger)
+	if err != nil {
+		logger.Error().Err(err).Msg("Invalid access rules, affected prefixes are closed")
+	}
+	s.access = access
+	s.jobs = jobs.New(cfg.Jobs, cfg.CoursesCatalog, logger)
+	if strings.TrimSpace(cfg.CoursesAccountsFile) != "" {
+		s.accounts = newUnlockAccounts(cfg.CoursesAccountsFile, cfg.CoursesTOTPSkew)
+	}
+	if cfg.CoursesChallenge {
+		s.challenges = newUnlockChallenges(cfg.CoursesChallengeSecret, cfg.CoursesChallengeMinBits, cfg.CoursesChallengeMaxBits)
+	}
+	if strings.TrimSpace(cfg.TraceFile) != "" {
+		s.spans, err = meta.OpenSpanFile(cfg.TraceFile)
+		if err != nil {
+			logger.Error().Err(err).Msg("Open trace file, spans go to the debug log")
+		}
+	}
+	return s.setupMiddlewares(cfg, logger).registerRoutes(logger)
 }
 
-func newServer(cfg Config) *Server {
+func newServer(cfg Config, logger *log.Logger) *Server {
+	staticFS := siteFS(cfg.StaticFolder, ".")
+	assets := loadAssetManifest(staticFS, cfg.StaticPrefix)
+	views := newViews(cfg, assets)
 	return &Server{
 		App: fiber.New(fiber.Config{
 			ReadTimeout:  10 * time.Second,
@@ -61,36 +153,57 @@ func newServer(cfg Config) *Server {
 			// Set IdleTimeout high to allow long-running downloads
 			IdleTimeout:       60 * time.Minute,
 			AppName:           "DummyPage",
-			Views:             html.New(cfg.ViewsFolder, cfg.ViewsExt),
+			Views:             views,
 			GETOnly:           false,
 			StreamRequestBody: false,
 			DisableKeepalive:  false,
+			ErrorHandler:      errorHandler(logger),
 		}),
-		addr:         cfg.Addr,
-		cfg:          cfg,
-		assetVersion: rand.Text(),
+		addr:     cfg.Addr,
+		cfg:      cfg,
+		staticFS: staticFS,
+		assets:   assets,
+		sessions: newCoursesSessions(),
+		events:   newCatalogEvents(cfg.CoursesCatalog, cfg.CoursesEventStreams, cfg.CoursesEventStreamsPerClient),
+		reports:  newLinkReportsQueue(cfg.CoursesLinkReportsFile),
 	}
 }
 
+func newViews(cfg Config, assets *assetManifest) *html.Engine {
+	views := html.NewFileSystem(http.FS(siteFS(cfg.ViewsFolder, "templates")), cfg.ViewsExt)
+	views.AddFunc("asset", assets.URL)
+	views.AddFunc("integrity", assets.Integrity)
+	return views
+}
+
 func (s *Server) setupMiddlewares(cfg Config, logger *log.Logger) *Server {
 	s.Use(recover.New())
+	s.Use(s.proxies.middleware)
 	s.Use(requestid.New())
-	s.Use("/courses", coursesSecurityHeaders)
+	s.Use(s.access.middleware)
+	s.Use(contentSecurityPolicy(cfg.ContentSecurityPolicy, cfg.ContentSecurityPolicyReportOnly))
+	coursesPolicy := cfg.CoursesContentSecurityPolicy
+	if strings.TrimSpace(coursesPolicy) == "" {
+		coursesPolicy = defaultCoursesContentSecurityPolicy
+	}
+	s.Use("/courses", contentSecurityPolicy(coursesPolicy, cfg.CoursesContentSecurityPolicyReportOnly), coursesSecurityHeaders)
 
 	s.Use(csrf.New(csrf.Config{
 		Next: func(c fiber.Ctx) bool {
-			return strings.HasPrefix(c.Path(), "/courses/api/")
+			return strings.HasPrefix(c.Path(), "/courses/api/") ||
+				strings.HasPrefix(c.Path(), adminAPIPrefix+"/") ||
+				c.Path() == cspReportsPath
 		},
 	}))
 	s.Use(limiter.New(limiter.Config{
-		Max:        10,
-		Expiration: 1 * time.Minute,
-		KeyGenerator: func(c fiber.Ctx) string {
-			return c.IP()
-		},
-		LimitReached: func(c fiber.Ctx) error {
-			return c.SendStatus(fiber.StatusTooManyRequests)
+		// Event streams are long-lived and capped by catalogEvents instead.
+		Next: func(c fiber.Ctx) bool {
+			return c.Path() == coursesEventsPath
 		},
+		Max:                    10,
+		Expiration:             1 * time.Minute,
+		KeyGenerator:           clientIP,
+		LimitReached:           limitReached,
 		SkipFailedRequests:     false,
 		SkipSuccessfulRequests: true,
 		LimiterMiddleware:      limiter.FixedWindow{},
@@ -100,14 +213,22 @@ func (s *Server) setupMiddlewares(cfg Config, logger *log.Logger) *Server {
 		CacheHeader: "X-Cache",
 		Next: func(c fiber.Ctx) bool {
 			skip := strings.HasPrefix(c.Path(), "/large/") ||
-				strings.HasPrefix(c.Path(), "/courses/api/")
+				strings.HasPrefix(c.Path(), "/courses/api/") ||
+				strings.HasPrefix(c.Path(), adminAPIPrefix+"/") ||
+				s.assets.owns(c.Path())
 			return skip
 		},
 		Methods: []string{fiber.MethodGet, fiber.MethodHead},
 	}))
-	s.Use(logadapter.New(logger))
+	access := logadapter.Config{ClientIP: clientIP, TraceParent: cfg.TraceParent}
+	if s.spans != nil {
+		access.Spans = s.spans
+	}
+	s.Use(logadapter.New(logger, access))
 
-	s.Use(cfg.StaticPrefix, static.New(cfg.StaticFolder, static.Config{
+	s.Use(s.assets.handler)
+	s.Use(cfg.StaticPrefix, static.New("", static.Config{
+		FS:            s.staticFS,
 		Compress:      true,
 		CacheDuration: 10 * time.Hour,
 		MaxAge:        int(time.Hour / time.Second),
@@ -127,32 +248,57 @@ func (s *Server) setupMiddlewares(cfg Config, logger *log.Logger) *Server {
 	return s
 }
 
-func (s *Server) registerRoutes() *Server {
+func (s *Server) registerRoutes(logger *log.Logger) *Server {
 	s.Get("/", handleIndex())
-	s.Get("/courses", handleCourses(s.assetVersion))
+	s.Get("/courses", handleCourses())
 	s.Get("/courses/api/meta", limiter.New(limiter.Config{
 		Max:               60,
 		Expiration:        1 * time.Minute,
+		KeyGenerator:      clientIP,
+		LimitReached:      limitReached,
 		LimiterMiddleware: limiter.FixedWindow{},
 	}), handleCoursesMeta(s.cfg))
+	s.Get(coursesEventsPath, handleCatalogEvents(s.events))
+	s.Get("/courses/api/challenge", limiter.New(limiter.Config{
+		Max:               30,
+		Expiration:        1 * time.Minute,
+		KeyGenerator:      clientIP,
+		LimitReached:      limitReached,
+		LimiterMiddleware: limiter.FixedWindow{},
+	}), handleCoursesChallenge(s.challenges))
 	s.Post("/courses/api/catalog", limiter.New(limiter.Config{
 		Max:                    5,
 		Expiration:             15 * time.Minute,
 		SkipSuccessfulRequests: true,
+		KeyGenerator:           clientIP,
+		LimitReached:           limitReached,
 		LimiterMiddleware:      limiter.FixedWindow{},
-	}), handleCoursesCatalog(s.cfg))
+	}), handleCoursesCatalog(s.cfg, s.sessions, s.challenges, s.accounts))
+	s.Post("/courses/api/reports", limiter.New(limiter.Config{
+		Max:               10,
+		Expiration:        1 * time.Hour,
+		KeyGenerator:      clientIP,
+		LimitReached:      limitReached,
+		LimiterMiddleware: limiter.FixedWindow{},
+	}), handleCoursesLinkReport(s.reports, s.sessions))
+	s.Post(cspReportsPath, limiter.New(limiter.Config{
+		Max:               30,
+		Expiration:        1 * time.Minute,
+		KeyGenerator:      clientIP,
+		LimitReached:      limitReached,
+		LimiterMiddleware: limiter.FixedWindow{},
+	}), handleCSPReport(logger, newCSPReportDeduper()))
 	s.Get("/version", handleVersion)
+	s.registerAdminRoutes()
 	s.Use(handleNotFound())
 
 	return s
 }
 
-func handleCourses(assetVersion string) fiber.Handler {
+func handleCourses() fiber.Handler {
 	return func(ctx fiber.Ctx) error {
-		if err := ctx.Status(fiber.StatusOK).Render("courses", fiber.Map{
-			"AssetVersion": assetVersion,
-		}); err != nil {
-			return ctx.Status(fiber.StatusInternalServerError).SendString("Internal Server Error")
+		if err := ctx.Status(fiber.StatusOK).Render("courses", fiber.Map{}); err != nil {
+			return errInternal.withCause(err)
 		}
 		return nil
 	}
@@ -162,7 +308,7 @@ func handleIndex() fiber.Handler {
 	return func(ctx fiber.Ctx) error {
 		err := ctx.Status(fiber.StatusOK).Render("index", fiber.Map{})
 		if err != nil {
-			return ctx.Status(500).SendString("Internal Server Error")
+			return errInternal.withCause(err)
 		}
 		return nil
 	}
@@ -170,11 +316,7 @@ func handleIndex() fiber.Handler {
 
 func handleNotFound() fiber.Handler {
 	return func(ctx fiber.Ctx) error {
-		err := ctx.Status(fiber.StatusNotFound).Render("404", fiber.Map{})
-		if err != nil {
-			return ctx.Status(500).SendString("Internal Server Error")
-		}
-		return nil
+		return errNotFound
 	}
 }
 func handleVersion(ctx fiber.Ctx) error {
@@ -186,8 +328,17 @@ func handleVersion(ctx fiber.Ctx) error {
 
 func (s *Server) Run(ctx context.Context) {
 	go s.listedShutdown(ctx)
+	go s.events.run(ctx)
+	if s.jobs.Enabled() {
+		go s.jobs.Run(ctx)
+	}
 
-	err := s.Listen(s.addr)
+	ln, err := listen(s.addr, s.cfg.UnixSocketMode)
+	if err != nil {
+		meta.GetLogger(ctx).Error().Err(err).Msg("Listen server")
+		return
+	}
+	err = s.Listener(ln)
 	if err != nil {
 		meta.GetLogger(ctx).Error().Err(err).Msg("Listen server")
 	}
@@ -199,4 +350,7 @@ func (s *Server) listedShutdown(ctx context.Context) {
 	if err != nil {
 		meta.GetLogger(ctx).Error().Err(err).Msg("Shutdown server")
 	}
+	if s.spans != nil {
+		_ = s.spans.Close()
+	}
 }
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	coursesSessionCookie = "courses_session"
+	coursesSessionTTL    = 7 * 24 * time.Hour
+)
+
+// coursesSessions issues stateless unlock sessions signed with a per-process
+// key, so a restart invalidates every outstanding session.
+type coursesSessions struct {
+	key []byte
+	now func() time.Time
+}
+
+func newCoursesSessions() *coursesSessions {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return &coursesSessions{key: key, now: time.Now}
+}
+
+func (s *coursesSessions) issue(ctx fiber.Ctx) {
+	expiresAt := s.now().Add(coursesSessionTTL)
+	ctx.Cookie(&fiber.Cookie{
+		Name:     coursesSessionCookie,
+		Value:    s.token(expiresAt.Unix()),
+		Path:     "/courses/api",
+		Expires:  expiresAt,
+		Secure:   requestOrigin(ctx).Scheme == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
+
+func (s *coursesSessions) valid(ctx fiber.Ctx) bool {
+	expiry, signature, ok := strings.Cut(ctx.Cookies(coursesSessionCookie), ".")
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || s.now().Unix() >= expiresAt {
+		return false
+	}
+	_, expected, _ := strings.Cut(s.token(expiresAt), ".")
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func (s *coursesSessions) token(expiresAt int64) string {
+	expiry := strconv.FormatInt(expiresAt, 10)
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("courses-session\x1f" + expiry))
+	return expiry + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestCoursesSessionsRejectExpiredAndForeignTokens(t *testing.T) {
+	now := time.Date(2026, 7, 27, 9, 0, 0, 0, time.UTC)
+	sessions := newCoursesSessions()
+	sessions.now = func() time.Time { return now }
+	other := newCoursesSessions()
+
+	valid := sessions.token(now.Add(time.Hour).Unix())
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"valid", valid, true},
+		{"expired", sessions.token(now.Unix()), false},
+		{"other key", other.token(now.Add(time.Hour).Unix()), false},
+		{"tampered expiry", "9" + valid, false},
+		{"malformed", "token", false},
+		{"empty", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(ctx fiber.Ctx) error {
+				if sessions.valid(ctx) {
+					return ctx.SendStatus(fiber.StatusOK)
+				}
+				return ctx.SendStatus(fiber.StatusUnauthorized)
+			})
+			request := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			request.Header.Set("Cookie", coursesSessionCookie+"="+test.token)
+			response, err := app.Test(request)
+			if err != nil {
+				t.Fatalf("test request: %v", err)
+			}
+			defer response.Body.Close()
+			if got := response.StatusCode == fiber.StatusOK; got != test.want {
+				t.Fatalf("valid() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package server
 
 import (
 	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"html"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -10,11 +13,12 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/andybalholm/brotli"
 )
 
-func TestCoursesAssetsShareStableCacheVersion(t *testing.T) {
+func TestCoursesAssetsAreFingerprintedByContent(t *testing.T) {
 	config := Config{
 		ViewsFolder:  filepath.Join("..", "..", "static", "templates"),
 		ViewsExt:     ".html",
@@ -23,26 +27,80 @@ func TestCoursesAssetsShareStableCacheVersion(t *testing.T) {
 	}
 	app := New(config, testLogger())
 
-	first := requestBody(t, app, "/courses", "")
-	second := requestBody(t, app, "/courses", "")
+	page := requestBody(t, app, "/courses", "")
+	cssURL := assetVersionFromHTML(t, page, `href="(/css/courses\.[0-9a-f]{16}\.css)"`)
+	jsURL := assetVersionFromHTML(t, page, `src="(/js/courses\.[0-9a-f]{16}\.js)"`)
+	workerURL := assetVersionFromHTML(t, page, `data-search-worker="(/js/courses-search-worker\.[0-9a-f]{16}\.js)"`)
+	minisearchURL := assetVersionFromHTML(t, page, `data-minisearch="(/js/vendor/minisearch\.min\.[0-9a-f]{16}\.js)"`)
 
-	cssVersion := assetVersionFromHTML(t, first, `href="/css/courses\.css\?v=([^"]+)"`)
-	jsVersion := assetVersionFromHTML(t, first, `src="/js/courses\.js\?v=([^"]+)"`)
-	if cssVersion != jsVersion {
-		t.Fatalf("asset versions differ: css=%q js=%q", cssVersion, jsVersion)
+	nextPage := requestBody(t, New(config, testLogger()), "/courses", "")
+	if got := assetVersionFromHTML(t, nextPage, `src="(/js/courses\.[0-9a-f]{16}\.js)"`); got != jsURL {
+		t.Fatalf("fingerprint changed across servers: first=%q next=%q", jsURL, got)
 	}
-	if got := assetVersionFromHTML(t, second, `src="/js/courses\.js\?v=([^"]+)"`); got != jsVersion {
-		t.Fatalf("asset version changed within one server: first=%q second=%q", jsVersion, got)
+
+	for _, asset := range []struct {
+		url    string
+		source string
+	}{
+		{cssURL, "css/courses.css"},
+		{jsURL, "js/courses.js"},
+		{workerURL, "js/courses-search-worker.js"},
+		{minisearchURL, "js/vendor/minisearch.min.js"},
+	} {
+		source, err := os.ReadFile(filepath.Join(config.StaticFolder, filepath.FromSlash(asset.source)))
+		if err != nil {
+			t.Fatalf("read %s: %v", asset.source, err)
+		}
+		response := requestAsset(t, app, asset.url, "")
+		body, err := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if err != nil {
+			t.Fatalf("read %s response: %v", asset.url, err)
+		}
+		if !bytes.Equal(body, source) {
+			t.Fatalf("%s does not serve %s", asset.url, asset.source)
+		}
+		if got := response.Header.Get("Cache-Control"); got != immutableAssetCacheControl {
+			t.Fatalf("%s Cache-Control = %q", asset.url, got)
+		}
+		digest := sha512.Sum384(source)
+		integrity := "sha384-" + base64.StdEncoding.EncodeToString(digest[:])
+		if asset.source != "js/courses-search-worker.js" && asset.source != "js/vendor/minisearch.min.js" &&
+			!strings.Contains(html.UnescapeString(page), `integrity="`+integrity+`"`) {
+			t.Fatalf("page is missing integrity %q for %s", integrity, asset.source)
+		}
 	}
+}
 
-	nextApp := New(config, testLogger())
-	nextVersion := assetVersionFromHTML(
-		t,
-		requestBody(t, nextApp, "/courses", ""),
-		`src="/js/courses\.js\?v=([^"]+)"`,
-	)
-	if nextVersion == jsVersion {
-		t.Fatalf("asset version was reused across servers: %q", jsVersion)
+func TestFingerprintedAssetServesPrecompressedVariants(t *testing.T) {
+	staticDir := t.TempDir()
+	source := bytes.Repeat([]byte("body { color: black; }\n"), 256)
+	if err := os.MkdirAll(filepath.Join(staticDir, "css"), 0o700); err != nil {
+		t.Fatalf("create css dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(staticDir, "css", "courses.css"), source, 0o600); err != nil {
+		t.Fatalf("write static source: %v", err)
+	}
+	app := New(Config{StaticFolder: staticDir, StaticPrefix: "/"}, testLogger())
+	url := app.assets.URL("css/courses.css")
+
+	response := requestAsset(t, app, url, "br")
+	defer response.Body.Close()
+	if got := response.Header.Get("Content-Encoding"); got != "br" {
+		t.Fatalf("Content-Encoding = %q, want br", got)
+	}
+	decompressed, err := io.ReadAll(brotli.NewReader(response.Body))
+	if err != nil {
+		t.Fatalf("decompress response: %v", err)
+	}
+	if !bytes.Equal(decompressed, source) {
+		t.Fatal("decompressed response does not match source")
+	}
+
+	identity := requestAsset(t, app, url, "")
+	defer identity.Body.Close()
+	if got := identity.Header.Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding without Accept-Encoding = %q", got)
 	}
 }
 
@@ -102,6 +160,23 @@ func requestBody(t *testing.T, app *Server, path, acceptEncoding string) string
 	return string(body)
 }
 
+func requestAsset(t *testing.T, app *Server, path, acceptEncoding string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatalf("request %s: %v", path, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("%s status = %d, want %d", path, response.StatusCode, http.StatusOK)
+	}
+	return response
+}
+
 func assetVersionFromHTML(t *testing.T, body, pattern string) string {
 	t.Helper()
 
@@ -111,3 +186,40 @@ func assetVersionFromHTML(t *testing.T, body, pattern string) string {
 	}
 	return match[1]
 }
+
+func TestEmbeddedSiteServesWithoutFolders(t *testing.T) {
+	app := New(Config{StaticPrefix: "/", ViewsExt: ".html"}, testLogger())
+
+	page := requestBody(t, app, "/courses", "")
+	jsURL := assetVersionFromHTML(t, page, `src="(/js/courses\.[0-9a-f]{16}\.js)"`)
+	source, err := os.ReadFile(filepath.Join("..", "..", "static", "js", "courses.js"))
+	if err != nil {
+		t.Fatalf("read courses.js: %v", err)
+	}
+	if got := requestBody(t, app, jsURL, ""); got != string(source) {
+		t.Fatal("embedded fingerprinted script does not match source")
+	}
+
+	response := requestAsset(t, app, "/css/courses.css", "br")
+	defer response.Body.Close()
+	if got := response.Header.Get("Content-Encoding"); got != "br" {
+		t.Fatalf("embedded static Content-Encoding = %q, want br", got)
+	}
+}
+
+func TestAssetManifestPrefersPrecompressedVariants(t *testing.T) {
+	files := fstest.MapFS{
+		"css/courses.css":    {Data: []byte("body{}")},
+		"css/courses.css.br": {Data: []byte("precompressed-br")},
+	}
+	manifest := loadAssetManifest(files, "/")
+
+	asset := manifest.byName["css/courses.css"]
+	brotliBody, gzipBody := asset.compressed()
+	if string(brotliBody) != "precompressed-br" {
+		t.Fatalf("brotli body = %q, want precompressed file", brotliBody)
+	}
+	if len(gzipBody) == 0 {
+		t.Fatal("gzip variant was not generated for missing precompressed file")
+	}
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/phuslu/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// reservedRoutes are registered by the server and must not be shadowed by a
+// static mount.
+var reservedRoutes = []string{"/courses", "/version", cspReportsPath, adminAPIPrefix}
+
+// Validate checks the configuration up front and reports every problem it
+// finds, so a misconfigured deployment fails at startup instead of on the
+// first request that needs the broken piece. The catalog is data rather
+// than configuration and is checked by ValidateCatalog.
+func Validate(cfg Config) error {
+	var problems []error
+	check := func(field string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	check("Addr", validateAddr(cfg.Addr, cfg.UnixSocketMode))
+	check("FilesFolder", validateReadableDir(cfg.FilesFolder))
+	check("LargeFilesFolder", validateReadableDir(cfg.LargeFilesFolder))
+	if strings.TrimSpace(cfg.ViewsFolder) != "" {
+		check("ViewsFolder", validateReadableDir(cfg.ViewsFolder))
+	}
+	if strings.TrimSpace(cfg.StaticFolder) != "" {
+		check("StaticFolder", validateReadableDir(cfg.StaticFolder))
+	}
+	check("CoursesPasswordHash", validatePasswordHash(cfg))
+	if strings.TrimSpace(cfg.AdminTokenHash) != "" {
+		check("AdminTokenHash", validateBcryptHash(cfg.AdminTokenHash))
+	}
+	if cfg.CoursesChallenge {
+		check("CoursesChallengeMinBits", validateChallengeBits(cfg.CoursesChallengeMinBits, cfg.CoursesChallengeMaxBits))
+	}
+	if strings.TrimSpace(cfg.CoursesAccountsFile) != "" {
+		check("CoursesAccountsFile", validateAccountsFile(cfg.CoursesAccountsFile, cfg.CoursesTOTPSkew))
+	}
+	if strings.TrimSpace(cfg.TraceFile) != "" {
+		check("TraceFile", validateWritableDir(filepath.Dir(cfg.TraceFile)))
+	}
+	check("CoursesLinkReportsFile", validateLinkReportsFile(cfg.CoursesLinkReportsFile))
+	problems = append(problems, validatePrefixes(cfg)...)
+	problems = append(problems, validateSite(cfg)...)
+
+	_, err := newProxyResolver(cfg.TrustedProxies, cfg.PublicOrigin)
+	check("TrustedProxies", err)
+	check("AccessRules", validateAccessRules(cfg.AccessRules))
+	check("Jobs", cfg.Jobs.Validate())
+	return errors.Join(problems...)
+}
+
+func validateAddr(addr, unixSocketMode string) error {
+	if path, ok := strings.CutPrefix(addr, unixAddrPrefix); ok {
+		if path == "" {
+			return errors.New("unix socket path is empty")
+		}
+		if _, err := parseUnixSocketMode(unixSocketMode); err != nil {
+			return err
+		}
+		if info, err := os.Lstat(path); err == nil && info.Mode().Type() != os.ModeSocket {
+			return fmt.Errorf("%q exists and is not a socket", path)
+		}
+		return validateWritableDir(filepath.Dir(path))
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateReadableDir(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return errors.New("path is empty")
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	info, err := dir.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%q is not a directory", path)
+	}
+	if _, err := dir.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%q is not readable: %w", path, err)
+	}
+	return nil
+}
+
+func validateWritableDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%q is not a directory", path)
+	}
+	probe, err := os.CreateTemp(path, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("%q is not writable: %w", path, err)
+	}
+	_ = probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func validatePasswordHash(cfg Config) error {
+	if file := strings.TrimSpace(cfg.CoursesPasswordHashFile); file != "" {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("hash file: %w", err)
+		}
+		if !info.Mode().IsRegular() || info.Size() <= 0 || info.Size() > 1024 {
+			return fmt.Errorf("hash file %q must be a regular file of 1 to 1024 bytes", file)
+		}
+		if info.Mode().Perm()&0o007 != 0 {
+			return fmt.Errorf("hash file %q is accessible to other users (mode %o)", file, info.Mode().Perm())
+		}
+	}
+	encoded := strings.TrimSpace(coursesPasswordHash(cfg))
+	if encoded == "" {
+		return errors.New("no password hash configured")
+	}
+	return validateBcryptHash(encoded)
+}
+
+func validateBcryptHash(encoded string) error {
+	hash, err := base64.RawStdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return fmt.Errorf("hash is not unpadded base64: %w", err)
+	}
+	if _, err := bcrypt.Cost(hash); err != nil {
+		return fmt.Errorf("hash is not bcrypt: %w", err)
+	}
+	return nil
+}
+
+func validateChallengeBits(minBits, maxBits int) error {
+	if minBits < 1 || maxBits > maxUnlockChallengeBits || minBits > maxBits {
+		return fmt.Errorf("difficulty %d..%d bits must satisfy 1 <= min <= max <= %d", minBits, maxBits, maxUnlockChallengeBits)
+	}
+	return nil
+}
+
+func validateAccountsFile(path string, skew int) error {
+	if skew < 0 || skew > 10 {
+		return fmt.Errorf("CoursesTOTPSkew %d must be between 0 and 10 steps", skew)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%q holds TOTP secrets and must be private to its owner (mode %o)", path, info.Mode().Perm())
+	}
+	if _, err := loadUnlockAccountsFile(path); err != nil {
+		return err
+	}
+	return validateWritableDir(filepath.Dir(path))
+}
+
+// ValidateCatalog checks that CoursesCatalog holds a servable catalog. A
+// missing or broken catalog only takes the courses API down, with a 503
+// until a build publishes one, so the server starts without it; a new
+// deployment or the rebuild job may still have to write the first one.
+func ValidateCatalog(cfg Config) error {
+	if _, _, err := readCoursesCatalog(context.Background(), cfg.CoursesCatalog); err != nil {
+		return fmt.Errorf("CoursesCatalog: %w", err)
+	}
+	return nil
+}
+
+func validateLinkReportsFile(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return errors.New("path is empty")
+	}
+	if _, err := loadLinkReportsQueue(path); err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		// The queue directory is created on the first report.
+		return nil
+	}
+	return validateWritableDir(dir)
+}
+
+func validatePrefixes(cfg Config) []error {
+	mounts := []struct {
+		field  string
+		prefix string
+	}{
+		{"FilesPrefix", normalizeMountPrefix(cfg.FilesPrefix)},
+		{"LargeFilesPrefix", normalizeMountPrefix(cfg.LargeFilesPrefix)},
+		{"StaticPrefix", normalizeMountPrefix(cfg.StaticPrefix)},
+	}
+	var problems []error
+	for i, mount := range mounts {
+		// The static site is served from the root by default and falls
+		// through to later handlers, so only the other mounts may not be "/".
+		if mount.prefix == "/" && mount.field != "StaticPrefix" {
+			problems = append(problems, fmt.Errorf("%s: %q shadows every route", mount.field, mount.prefix))
+			continue
+		}
+		for _, other := range mounts[i+1:] {
+			if other.prefix != "/" && prefixesOverlap(mount.prefix, other.prefix) {
+				problems = append(problems, fmt.Errorf("%s: %q conflicts with %s %q", mount.field, mount.prefix, other.field, other.prefix))
+			}
+		}
+		if mount.prefix == "/" {
+			continue
+		}
+		for _, route := range reservedRoutes {
+			if prefixesOverlap(mount.prefix, route) {
+				problems = append(problems, fmt.Errorf("%s: %q conflicts with route %q", mount.field, mount.prefix, route))
+			}
+		}
+	}
+	return problems
+}
+
+func normalizeMountPrefix(prefix string) string {
+	return "/" + strings.ToLower(strings.Trim(strings.TrimSpace(prefix), "/"))
+}
+
+func prefixesOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// validateSite checks that every fingerprinted asset exists and that the
+// pages the server renders parse and execute.
+func validateSite(cfg Config) []error {
+	var problems []error
+	assets := loadAssetManifest(siteFS(cfg.StaticFolder, "."), cfg.StaticPrefix)
+	for _, name := range fingerprintedAssets {
+		if _, ok := assets.byName[name]; !ok {
+			problems = append(problems, fmt.Errorf("StaticFolder: missing asset %q", name))
+		}
+	}
+
+	views := newViews(cfg, assets)
+	if err := views.Load(); err != nil {
+		return append(problems, fmt.Errorf("ViewsFolder: %w", err))
+	}
+	for _, name := range []string{"index", "courses", "404", "error"} {
+		if err := views.Render(io.Discard, name, fiber.Map{}); err != nil {
+			problems = append(problems, fmt.Errorf("ViewsFolder: template %q: %w", name, err))
+		}
+	}
+	return problems
+}
+
+func validateAccessRules(rules []string) error {
+	control, err := newAccessControl(rules, &log.Logger{Writer: log.IOWriter{Writer: io.Discard}})
+	problems := []error{err}
+	seen := make(map[*accessListFile]bool)
+	for _, rule := range control.rules {
+		for _, file := range rule.files {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			info, err := os.Stat(file.path)
+			if err == nil {
+				_, err = loadAccessListFile(file.path, info)
+			}
+			if err != nil {
+				problems = append(problems, fmt.Errorf("list %q: %w", file.path, err))
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateAcceptsWorkingConfig(t *testing.T) {
+	if err := Validate(validTestConfig(t)); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := validTestConfig(t)
+	dir := t.TempDir()
+	cfg.LargeFilesFolder = filepath.Join(dir, "missing")
+	cfg.CoursesPasswordHash = "not base64!"
+	cfg.LargeFilesPrefix = "/files/large"
+	cfg.StaticPrefix = "/courses/static"
+	cfg.ViewsFolder = dir
+	cfg.TrustedProxies = []string{"10.0.0.0/33"}
+	cfg.AccessRules = []string{"/metrics allow @" + filepath.Join(dir, "office.txt")}
+	cfg.CoursesChallenge = true
+	cfg.CoursesChallengeMinBits = 24
+	cfg.CoursesChallengeMaxBits = 16
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("{{ .Broken "), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("Validate() error = nil, want problems")
+	}
+	for _, want := range []string{
+		"LargeFilesFolder:",
+		"CoursesPasswordHash: hash is not unpadded base64",
+		`FilesPrefix: "/files" conflicts with LargeFilesPrefix "/files/large"`,
+		`StaticPrefix: "/courses/static" conflicts with route "/courses"`,
+		"ViewsFolder:",
+		"TrustedProxies:",
+		"AccessRules: list",
+		"CoursesChallengeMinBits: difficulty 24..16 bits",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidateCatalogIsSeparateFromConfiguration(t *testing.T) {
+	cfg := validTestConfig(t)
+	if err := ValidateCatalog(cfg); err != nil {
+		t.Fatalf("ValidateCatalog() error = %v", err)
+	}
+
+	for name, path := range map[string]string{
+		"missing": filepath.Join(t.TempDir(), "catalog.json.gz"),
+		"schema":  writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v1"}`),
+	} {
+		cfg.CoursesCatalog = path
+		if err := Validate(cfg); err != nil {
+			t.Fatalf("%s: Validate() error = %v, want the catalog left to ValidateCatalog", name, err)
+		}
+		if err := ValidateCatalog(cfg); err == nil || !strings.HasPrefix(err.Error(), "CoursesCatalog: ") {
+			t.Fatalf("%s: ValidateCatalog() error = %v", name, err)
+		}
+	}
+}
+
+func TestValidatePasswordHashFilePermissions(t *testing.T) {
+	cfg := validTestConfig(t)
+	cfg.CoursesPasswordHashFile = filepath.Join(t.TempDir(), "catalog-password.hash")
+	if err := os.WriteFile(cfg.CoursesPasswordHashFile, []byte(cfg.CoursesPasswordHash), 0o644); err != nil {
+		t.Fatalf("write hash: %v", err)
+	}
+	cfg.CoursesPasswordHash = ""
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "accessible to other users") {
+		t.Fatalf("Validate() error = %v, want permission problem", err)
+	}
+	if err := os.Chmod(cfg.CoursesPasswordHashFile, 0o600); err != nil {
+		t.Fatalf("chmod hash: %v", err)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+}
+
+func validTestConfig(t *testing.T) Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range []string{"files", "large"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o700); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+	}
+	return Config{
+		Addr:                   "localhost:3000",
+		UnixSocketMode:         "0660",
+		FilesFolder:            filepath.Join(dir, "files"),
+		FilesPrefix:            "files",
+		LargeFilesFolder:       filepath.Join(dir, "large"),
+		LargeFilesPrefix:       "large",
+		CoursesCatalog:         writeTestCoursesFile(t, `{"schema_version":"courses-catalog/v2"}`),
+		CoursesPasswordHash:    hashTestPassword(t, "correct horse battery staple"),
+		CoursesLinkReportsFile: filepath.Join(dir, "data", "link-reports.json"),
+		ViewsExt:               ".html",
+		StaticPrefix:           "/",
+	}
+}
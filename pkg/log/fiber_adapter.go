@@ -4,13 +4,54 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
 	"github.com/phuslu/log"
+
+	"github.com/xenking/dummypage/internal/meta"
 )
 
-func New(logger *log.Logger) fiber.Handler {
+type Config struct {
+	// ClientIP resolves the logged client address, c.IP() when nil.
+	ClientIP func(fiber.Ctx) string
+	// TraceParent continues W3C traces from the traceparent request header
+	// and returns the server span in the response header.
+	TraceParent bool
+	// Spans receives the spans handlers record; see meta.Request.
+	Spans meta.SpanWriter
+}
+
+// New logs every request once it is done, tagged with its matched route.
+// Handlers reach a logger tagged with the request ID, user and trace through
+// meta.GetLogger on the request context. The route is set only once the
+// handlers return, so their loggers carry it only if they set Route first.
+func New(logger *log.Logger, cfg Config) fiber.Handler {
+	clientIP := cfg.ClientIP
+	if clientIP == nil {
+		clientIP = func(c fiber.Ctx) string {
+			return c.IP()
+		}
+	}
 	return func(c fiber.Ctx) error {
 		start := time.Now()
+		request := &meta.Request{
+			ID:    requestid.FromContext(c),
+			Spans: cfg.Spans,
+		}
+		if cfg.TraceParent {
+			request.Trace = meta.NewTraceContext(c.Get(meta.TraceParentHeader))
+			c.Set(meta.TraceParentHeader, request.Trace.TraceParent())
+		}
+		c.SetContext(meta.WithRequest(meta.WithLogger(c.Context(), logger), request))
+
 		next := c.Next()
+		request.Route = c.Route().Path
+		if next != nil {
+			// Render the error here, as fiber's logger does, so the logged
+			// status is the one the client gets.
+			if err := c.App().ErrorHandler(c, next); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
 
 		end := time.Now()
 		latency := end.Sub(start)
@@ -21,19 +62,20 @@ func New(logger *log.Logger) fiber.Handler {
 			msg = next.Error()
 		}
 
+		requestLogger := meta.GetLogger(c.Context())
 		var e *log.Entry
 		switch {
 		case status >= 400 && status < 500:
-			e = logger.Warn()
+			e = requestLogger.Warn()
 		case status >= 500:
-			e = logger.Error()
+			e = requestLogger.Error()
 		default:
-			e = logger.Info()
+			e = requestLogger.Info()
 		}
 		e.Int("status", status).
 			Str("method", c.Method()).
 			Str("path", c.Path()).
-			Str("ip", c.IP()).
+			Str("ip", clientIP(c)).
 			Dur("latency", latency).
 			Str("user_agent", c.Get(fiber.HeaderUserAgent)).
 			Msg(msg)
@@ -0,0 +1,9 @@
+package static
+
+import "embed"
+
+// Files holds the site assets and templates so the binary can run without a
+// static directory next to it.
+//
+//go:embed css fonts img js templates
+var Files embed.FS
@@ -1,6 +1,14 @@
 "use strict";
 
-importScripts("./vendor/minisearch.min.js" + self.location.search);
+importScripts(minisearchURL());
+
+function minisearchURL() {
+    const fingerprinted = new URL(self.location.href).searchParams.get("minisearch");
+    if (fingerprinted && /^\/(?:[\w-]+\/)*js\/vendor\/minisearch\.min\.[0-9a-f]{16}\.js$/.test(fingerprinted)) {
+        return fingerprinted;
+    }
+    return "./vendor/minisearch.min.js";
+}
 
 const DB_NAME = "dummypage-courses";
 const DB_VERSION = 1;
@@ -76,6 +84,7 @@ function emptyFacets() {
   return {
     categories: [],
     formats: [],
+    channels: [],
     providers: [],
     years: [],
     hasPassword: {
@@ -251,6 +260,7 @@ function validateSource(source, description) {
   validateTimestamp(source.added_at, description + ".added_at");
   requireString(source.origin, description + ".origin");
   requireString(source.availability, description + ".availability");
+  if (source.channel != null) requireString(source.channel, description + ".channel");
 }
 
 function validateEntry(entry, index, ids) {
@@ -298,6 +308,10 @@ function validateEntry(entry, index, ids) {
   validateStringList(entry.format_sources, prefix + ".format_sources", true, true);
   validateStringList(entry.passwords, prefix + ".passwords", true);
   validateStringList(entry.notes, prefix + ".notes", true);
+  validateStringList(entry.channels, prefix + ".channels", false);
+  if (entry.hidden != null && typeof entry.hidden !== "boolean") {
+    invalidCatalog(prefix + ".hidden must be a boolean");
+  }
 
   if (!Array.isArray(entry.links)) invalidCatalog(prefix + ".links must be an array");
   for (let linkIndex = 0; linkIndex < entry.links.length; linkIndex += 1) {
@@ -322,6 +336,7 @@ function validateCatalog(catalog, phase) {
   }
   validateDefinitions(catalog.categories, "categories", true);
   validateDefinitions(catalog.formats, "formats", true);
+  validateDefinitions(catalog.channels, "channels", false);
 
   const ids = new Set();
   for (let index = 0; index < catalog.entries.length; index += 1) {
@@ -483,11 +498,13 @@ function buildFacetRecords(counts, definitions, labels) {
 function hydrateCatalog(catalog, phase) {
   const categoryDefinitions = createDefinitionLookup(catalog.categories);
   const formatDefinitions = createDefinitionLookup(catalog.formats);
+  const channelDefinitions = createDefinitionLookup(catalog.channels);
   const visibleEntries = [];
   const byId = new Map();
   const derivedById = new Map();
   const categoryCounts = new Map();
   const formatCounts = new Map();
+  const channelCounts = new Map();
   const providerCounts = new Map();
   const providerLabels = new Map();
   const yearCounts = new Map();
@@ -495,17 +512,19 @@ function hydrateCatalog(catalog, phase) {
 
   for (let index = 0; index < catalog.entries.length; index += 1) {
     const entry = catalog.entries[index];
-    if (isServiceEntry(entry)) continue;
+    if (entry.hidden === true || isServiceEntry(entry)) continue;
     const externalEntry = Object.assign({}, entry, {
       added_at: entry.last_added_at,
     });
 
     const categories = new Set(entry.categories.map(normalizeText));
     const formats = new Set(entry.formats.map(normalizeText));
+    const channels = new Set((entry.channels || []).map(normalizeText));
     const providers = deriveProviders(entry);
     const derived = {
       categories,
       formats,
+      channels,
       providers,
       hasPassword: entry.passwords.length > 0,
       title: normalizeText(entry.title),
@@ -519,6 +538,7 @@ function hydrateCatalog(catalog, phase) {
     derivedById.set(entry.id, derived);
     for (const category of categories) incrementCount(categoryCounts, category);
     for (const format of formats) incrementCount(formatCounts, format);
+    for (const channel of channels) incrementCount(channelCounts, channel);
     for (const [provider, metadata] of providers) {
       incrementCount(providerCounts, provider);
       if (!providerLabels.has(provider)) providerLabels.set(provider, metadata.label);
@@ -545,6 +565,7 @@ function hydrateCatalog(catalog, phase) {
     facets: {
       categories: buildFacetRecords(categoryCounts, categoryDefinitions),
       formats: buildFacetRecords(formatCounts, formatDefinitions),
+      channels: buildFacetRecords(channelCounts, channelDefinitions),
       providers: buildFacetRecords(
         providerCounts,
         { lookup: new Map(), hidden: new Set() },
@@ -842,6 +863,7 @@ function normalizeFilters(filters) {
   return {
     categories: new Set(requestStringArray(source.categories, "filters.categories")),
     formats: new Set(requestStringArray(source.formats, "filters.formats")),
+    channels: new Set(requestStringArray(source.channels, "filters.channels")),
     providers: new Set(requestStringArray(source.providers, "filters.providers")),
     years: new Set(requestYears(source.years)),
     hasPassword: source.hasPassword == null ? null : source.hasPassword,
@@ -859,6 +881,7 @@ function intersects(left, right) {
 function matchesFilters(derived, filters) {
   if (!intersects(derived.categories, filters.categories)) return false;
   if (!intersects(derived.formats, filters.formats)) return false;
+  if (!intersects(derived.channels, filters.channels)) return false;
   if (!intersects(new Set(derived.providers.keys()), filters.providers)) return false;
   if (filters.years.size > 0 && !filters.years.has(derived.year)) return false;
   if (filters.hasPassword !== null && filters.hasPassword !== derived.hasPassword) return false;
@@ -8,7 +8,10 @@
     const MOBILE_BREAKPOINT = 1120;
     const CONTENT_ITEMS_OPEN_LIMIT = 8;
     const ALLOWED_PROTOCOLS = new Set(["http:", "https:", "magnet:"]);
-    const assetVersion = new URL(document.currentScript.src).searchParams.get("v");
+    const assetURLs = Object.freeze({
+        searchWorker: document.currentScript.dataset.searchWorker || "/js/courses-search-worker.js",
+        minisearch: document.currentScript.dataset.minisearch || "",
+    });
     const MATERIAL_TYPE_LABELS = Object.freeze({
         archive: "архив",
         audio: "аудио",
@@ -19,6 +22,7 @@
     });
     const PHASE_LABELS = {
         opening: "Открываем локальную базу…",
+        challenge: "Проверяем браузер…",
         reading: "Читаем snapshot…",
         validating: "Проверяем данные…",
         indexing: "Строим поисковый индекс…",
@@ -55,6 +59,8 @@
         resetFilters: document.querySelector("#reset-filters"),
         formatFacet: document.querySelector("#format-facet"),
         formatOptions: document.querySelector("#format-options"),
+        channelFacet: document.querySelector("#channel-facet"),
+        channelOptions: document.querySelector("#channel-options"),
         categoryOptions: document.querySelector("#category-options"),
         providerSelect: document.querySelector("#provider-select"),
         yearSelect: document.querySelector("#year-select"),
@@ -79,6 +85,10 @@
         unlockForm: document.querySelector("#unlock-form"),
         unlockCopy: document.querySelector("#unlock-copy"),
         passwordInput: document.querySelector("#catalog-password"),
+        accountField: document.querySelector("#account-field"),
+        accountInput: document.querySelector("#catalog-account"),
+        codeField: document.querySelector("#code-field"),
+        codeInput: document.querySelector("#catalog-code"),
         passwordToggle: document.querySelector("#password-toggle"),
         unlockError: document.querySelector("#unlock-error"),
         importProgress: document.querySelector("#import-progress"),
@@ -87,6 +97,12 @@
         progressBar: document.querySelector("#progress-bar"),
         unlockCancel: document.querySelector("#unlock-cancel"),
         unlockSubmit: document.querySelector("#unlock-submit"),
+        reportDialog: document.querySelector("#report-dialog"),
+        reportForm: document.querySelector("#report-form"),
+        reportCopy: document.querySelector("#report-copy"),
+        reportError: document.querySelector("#report-error"),
+        reportCancel: document.querySelector("#report-cancel"),
+        reportSubmit: document.querySelector("#report-submit"),
         toast: document.querySelector("#toast"),
     };
 
@@ -98,6 +114,7 @@
         remoteMeta: null,
         facets: {
             formats: [],
+            channels: [],
             categories: [],
             providers: [],
             years: [],
@@ -105,6 +122,7 @@
         },
         filters: {
             formats: new Set(),
+            channels: new Set(),
             categories: new Set(),
             provider: "",
             year: "",
@@ -128,6 +146,8 @@
         updateAvailable: false,
         filtersModal: false,
         detailReturnFocus: null,
+        report: null,
+        events: null,
     };
 
     class RPCError extends Error {
@@ -416,6 +436,9 @@
         for (const input of dom.formatOptions.querySelectorAll("input")) {
             input.disabled = !enabled;
         }
+        for (const input of dom.channelOptions.querySelectorAll("input")) {
+            input.disabled = !enabled;
+        }
         for (const input of dom.categoryOptions.querySelectorAll("input")) {
             input.disabled = !enabled;
         }
@@ -517,6 +540,7 @@
         state.meta = data.meta || state.meta;
         state.facets = {
             formats: normalizedFacet(data.facets?.formats),
+            channels: normalizedFacet(data.facets?.channels),
             categories: normalizedFacet(data.facets?.categories),
             providers: normalizedFacet(data.facets?.providers),
             years: normalizedFacet(data.facets?.years),
@@ -571,6 +595,8 @@
     function renderFacets() {
         renderCheckFacet(dom.formatOptions, state.facets.formats, state.filters.formats, "format");
         dom.formatFacet.hidden = state.facets.formats.length === 0;
+        renderCheckFacet(dom.channelOptions, state.facets.channels, state.filters.channels, "channel");
+        dom.channelFacet.hidden = state.facets.channels.length < 2;
         renderCheckFacet(dom.categoryOptions, state.facets.categories, state.filters.categories, "category");
         renderSelectFacet(dom.providerSelect, state.facets.providers, "Все источники", state.filters.provider);
         renderSelectFacet(dom.yearSelect, state.facets.years, "Все годы", state.filters.year);
@@ -597,6 +623,13 @@
                 remove: () => state.filters.formats.delete(value),
             });
         }
+        for (const value of state.filters.channels) {
+            filters.push({
+                key: `channel:${value}`,
+                label: `Канал: ${facetLabel("channels", value)}`,
+                remove: () => state.filters.channels.delete(value),
+            });
+        }
         for (const value of state.filters.categories) {
             filters.push({
                 key: `category:${value}`,
@@ -659,6 +692,7 @@
 
     function resetFilters() {
         state.filters.formats.clear();
+        state.filters.channels.clear();
         state.filters.categories.clear();
         state.filters.provider = "";
         state.filters.year = "";
@@ -673,6 +707,7 @@
             query: state.query,
             filters: {
                 formats: [...state.filters.formats],
+                channels: [...state.filters.channels],
                 categories: [...state.filters.categories],
                 providers: state.filters.provider ? [state.filters.provider] : [],
                 years: state.filters.year && Number.isFinite(yearNumber) ? [yearNumber] : [],
@@ -967,7 +1002,7 @@
                 .map((link) => ({ ...link, safeURL: safeExternalURL(link?.url) }))
                 .filter((link) => link.safeURL)
             : [];
-        wrapper.append(renderLinksSection(links));
+        wrapper.append(renderLinksSection(links, entry.id));
 
         const passwords = Array.isArray(entry.passwords)
             ? entry.passwords.filter((password) => typeof password === "string" && password.length > 0)
@@ -992,7 +1027,7 @@
         target.append(wrapper);
     }
 
-    function renderLinksSection(links) {
+    function renderLinksSection(links, entryID) {
         const section = createElement("section", "detail-section");
         const heading = createElement("div", "detail-section-heading");
         heading.append(createElement("h3", "", `Ссылки (${russianNumber(links.length)})`));
@@ -1034,6 +1069,12 @@
                 copyButton,
             ));
             actions.append(open, copyButton);
+            if (typeof entryID === "string" && entryID) {
+                const report = createElement("button", "button button--quiet", "Сообщить");
+                report.type = "button";
+                report.addEventListener("click", () => openReport(entryID, link.url, label));
+                actions.append(report);
+            }
             item.append(copy, actions);
             const content = renderLinkContent(link.content);
             if (content) {
@@ -1193,6 +1234,67 @@
         }
     }
 
+    function openReport(courseID, url, label) {
+        state.report = { courseID, url };
+        dom.reportForm.reset();
+        dom.reportCopy.textContent = `${label}. Жалоба уйдёт модератору вместе с идентификатором курса.`;
+        dom.reportError.hidden = true;
+        dom.reportSubmit.disabled = false;
+        dom.reportCancel.disabled = false;
+        if (!dom.reportDialog.open) {
+            dom.reportDialog.showModal();
+        }
+    }
+
+    function setReportError(message) {
+        dom.reportError.textContent = message;
+        dom.reportError.hidden = false;
+        dom.reportSubmit.disabled = false;
+        dom.reportCancel.disabled = false;
+    }
+
+    async function submitReport(reason) {
+        if (!state.report) {
+            return;
+        }
+        dom.reportError.hidden = true;
+        dom.reportSubmit.disabled = true;
+        dom.reportCancel.disabled = true;
+        try {
+            const response = await fetch("/courses/api/reports", {
+                method: "POST",
+                headers: {
+                    Accept: "application/json",
+                    "Content-Type": "application/json",
+                },
+                credentials: "same-origin",
+                cache: "no-store",
+                body: JSON.stringify({
+                    course_id: state.report.courseID,
+                    url: state.report.url,
+                    reason,
+                }),
+            });
+            if (response.status === 401) {
+                setReportError("Сессия истекла. Обновите базу по общему паролю и отправьте жалобу снова.");
+                return;
+            }
+            if (response.status === 429) {
+                setReportError("Слишком много жалоб. Попробуйте позже.");
+                return;
+            }
+            if (!response.ok) {
+                setReportError(`Сервер не принял жалобу (HTTP ${response.status}). Попробуйте позже.`);
+                return;
+            }
+            state.report = null;
+            dom.reportDialog.close();
+            showToast("Спасибо, жалоба отправлена на модерацию");
+        } catch {
+            setReportError("Не удалось отправить жалобу. Проверьте подключение.");
+        }
+    }
+
     async function checkRemoteMeta(silent = false) {
         if (!navigator.onLine) {
             if (!state.cached && !silent) {
@@ -1217,16 +1319,7 @@
             if (!response.ok) {
                 throw new Error(`meta ${response.status}`);
             }
-            const meta = await response.json();
-            state.remoteMeta = meta && typeof meta === "object" ? meta : {};
-            state.updateAvailable = Boolean(
-                state.cached
-                && state.remoteMeta.available
-                && state.meta?.version
-                && state.remoteMeta.version
-                && state.meta.version !== state.remoteMeta.version,
-            );
-            updateStatusStrip();
+            applyRemoteMeta(await response.json());
 
             if (!state.remoteMeta.available && !state.cached) {
                 setResultsState(
@@ -1256,6 +1349,40 @@
         }
     }
 
+    function applyRemoteMeta(meta) {
+        state.remoteMeta = meta && typeof meta === "object" ? meta : {};
+        state.updateAvailable = Boolean(
+            state.cached
+            && state.remoteMeta.available
+            && state.meta?.version
+            && state.remoteMeta.version
+            && state.meta.version !== state.remoteMeta.version,
+        );
+        updateStatusStrip();
+    }
+
+    function subscribeCatalogEvents() {
+        if (state.events || typeof EventSource !== "function") {
+            return;
+        }
+        state.events = new EventSource("/courses/api/events");
+        state.events.addEventListener("catalog-updated", (event) => {
+            try {
+                applyRemoteMeta(JSON.parse(event.data));
+            } catch {
+                // A malformed event must not break the page; the next
+                // meta check corrects the state.
+            }
+        });
+        state.events.addEventListener("error", () => {
+            // The browser reconnects on its own unless the server refused
+            // the stream; then fall back to checking on demand.
+            if (state.events?.readyState === EventSource.CLOSED) {
+                state.events = null;
+            }
+        });
+    }
+
     function openUnlock(mode) {
         state.unlockMode = mode;
         dom.unlockCopy.textContent = mode === "update"
@@ -1297,6 +1424,60 @@
         dom.passwordInput.focus();
     }
 
+    // The server may require a proof-of-work before unlocking: a hash of the
+    // challenge and a counter with enough leading zero bits. Web Crypto keeps
+    // this inside the strict CSP without extra scripts.
+    async function solveUnlockChallenge() {
+        const response = await fetch("/courses/api/challenge", {
+            method: "GET",
+            headers: { Accept: "application/json" },
+            cache: "no-store",
+            credentials: "same-origin",
+        });
+        if (!response.ok) {
+            throw new Error(`challenge ${response.status}`);
+        }
+        const issued = await response.json();
+        if (!issued?.required) {
+            return {};
+        }
+
+        const encoder = new TextEncoder();
+        const prefix = `${issued.challenge}:`;
+        const expiresAt = Date.parse(issued.expires_at);
+        const batch = 256;
+        for (let base = 0; Date.now() < expiresAt; base += batch) {
+            const candidates = Array.from({ length: batch }, (_, index) => String(base + index));
+            const digests = await Promise.all(candidates.map(
+                (candidate) => crypto.subtle.digest("SHA-256", encoder.encode(prefix + candidate)),
+            ));
+            const found = digests.findIndex((digest) => leadingZeroBits(new Uint8Array(digest)) >= issued.difficulty);
+            if (found >= 0) {
+                return { challenge: issued.challenge, solution: candidates[found] };
+            }
+        }
+        throw new Error("challenge expired");
+    }
+
+    function leadingZeroBits(bytes) {
+        let zeros = 0;
+        for (const byte of bytes) {
+            if (byte !== 0) {
+                return zeros + Math.clz32(byte) - 24;
+            }
+            zeros += 8;
+        }
+        return zeros;
+    }
+
+    // The server asks for an account and a TOTP or recovery code only when
+    // accounts are configured, so the fields stay hidden until it does.
+    function requestUnlockField(field, input, message) {
+        field.hidden = false;
+        setUnlockError(message);
+        input.focus();
+    }
+
     async function importCatalog(password) {
         if (!navigator.onLine) {
             setUnlockError("Нет сети. Для загрузки snapshot подключитесь к интернету.");
@@ -1306,10 +1487,12 @@
         dom.unlockSubmit.disabled = true;
         dom.unlockCancel.disabled = true;
         dom.unlockSubmit.textContent = "Загрузка…";
-        updateImportProgress({ phase: "reading" });
 
         let catalog = null;
         try {
+            updateImportProgress({ phase: "challenge" });
+            const proof = await solveUnlockChallenge();
+            updateImportProgress({ phase: "reading" });
             const response = await fetch("/courses/api/catalog", {
                 method: "POST",
                 headers: {
@@ -1318,16 +1501,41 @@
                 },
                 credentials: "same-origin",
                 cache: "no-store",
-                body: JSON.stringify({ password }),
+                body: JSON.stringify({
+                    password,
+                    account: dom.accountInput.value.trim(),
+                    code: dom.codeInput.value.trim(),
+                    ...proof,
+                }),
             });
 
             dom.passwordInput.value = "";
+            dom.codeInput.value = "";
             password = "";
 
+            const failure = response.status === 401
+                ? await response.json().catch(() => ({}))
+                : {};
+            if (failure.code === "account_required") {
+                requestUnlockField(dom.accountField, dom.accountInput, "Введите учётную запись и общий пароль.");
+                return;
+            }
+            if (failure.code === "code_required") {
+                requestUnlockField(dom.codeField, dom.codeInput, "Введите пароль ещё раз и код из приложения-аутентификатора.");
+                return;
+            }
+            if (failure.code === "invalid_code") {
+                requestUnlockField(dom.codeField, dom.codeInput, "Код не подошёл. Введите пароль и новый код.");
+                return;
+            }
             if (response.status === 401) {
                 setUnlockError("Неверный пароль. Проверьте раскладку и попробуйте ещё раз.");
                 return;
             }
+            if (response.status === 428) {
+                setUnlockError("Проверка браузера устарела. Попробуйте ещё раз.");
+                return;
+            }
             if (response.status === 429) {
                 setUnlockError("Слишком много попыток. Подождите 15 минут и попробуйте снова.");
                 return;
@@ -1406,6 +1614,7 @@
             state.meta = null;
             state.facets = {
                 formats: [],
+                channels: [],
                 categories: [],
                 providers: [],
                 years: [],
@@ -1470,9 +1679,9 @@
         }
 
         try {
-            const workerURL = new URL("/js/courses-search-worker.js", window.location.origin);
-            if (assetVersion) {
-                workerURL.searchParams.set("v", assetVersion);
+            const workerURL = new URL(assetURLs.searchWorker, window.location.origin);
+            if (assetURLs.minisearch) {
+                workerURL.searchParams.set("minisearch", assetURLs.minisearch);
             }
             state.rpc = new WorkerRPC(workerURL.toString(), updateImportProgress);
             const result = await state.rpc.call("boot");
@@ -1482,6 +1691,7 @@
                 setConnection("Локальная база готова", "ready");
                 await runSearch(true);
                 void checkRemoteMeta(true);
+                subscribeCatalogEvents();
             } else {
                 setResultsState(
                     "Нужна локальная база",
@@ -1620,6 +1830,20 @@
             void runSearch(true);
         });
 
+        dom.channelOptions.addEventListener("change", (event) => {
+            const input = event.target;
+            if (!(input instanceof HTMLInputElement) || input.name !== "channel") {
+                return;
+            }
+            if (input.checked) {
+                state.filters.channels.add(input.value);
+            } else {
+                state.filters.channels.delete(input.value);
+            }
+            renderActiveFilters();
+            void runSearch(true);
+        });
+
         dom.categoryOptions.addEventListener("change", (event) => {
             const input = event.target;
             if (!(input instanceof HTMLInputElement) || input.name !== "category") {
@@ -1726,6 +1950,29 @@
             state.unlockMode = "idle";
         });
 
+        dom.reportCancel.addEventListener("click", () => {
+            state.report = null;
+            dom.reportDialog.close();
+        });
+
+        dom.reportDialog.addEventListener("cancel", (event) => {
+            if (dom.reportSubmit.disabled) {
+                event.preventDefault();
+                return;
+            }
+            state.report = null;
+        });
+
+        dom.reportForm.addEventListener("submit", (event) => {
+            event.preventDefault();
+            const reason = new FormData(dom.reportForm).get("reason");
+            if (!reason) {
+                setReportError("Выберите причину.");
+                return;
+            }
+            void submitReport(reason);
+        });
+
         dom.unlockForm.addEventListener("submit", (event) => {
             event.preventDefault();
             const password = dom.passwordInput.value;
@@ -322,7 +322,7 @@ window.addEventListener("DOMContentLoaded", async () => {
 
 async function startServer() {
   let resolveResult;
-  let workerVersion = null;
+  let workerMinisearch = null;
   const result = new Promise((resolve) => {
     resolveResult = resolve;
   });
@@ -331,7 +331,9 @@ async function startServer() {
     "utf8",
   );
   const page = template
-    .replaceAll("{{.AssetVersion}}", "test-asset-version")
+    .replace(/\{\{asset "js\/vendor\/minisearch\.min\.js"\}\}/g, "/js/vendor/test-minisearch.js")
+    .replace(/\{\{asset "([^"]+)"\}\}/g, "/$1")
+    .replace(/\{\{integrity "[^"]+"\}\}/g, "")
     .replace("</body>", testDriver + "\n</body>");
 
   const server = http.createServer(async (request, response) => {
@@ -347,7 +349,7 @@ async function startServer() {
       resolveResult({
         status: requestURL.searchParams.get("status"),
         message: requestURL.searchParams.get("message"),
-        workerVersion,
+        workerMinisearch,
       });
       return;
     }
@@ -365,7 +367,7 @@ async function startServer() {
       return;
     }
     if (requestURL.pathname === "/js/courses-search-worker.js") {
-      workerVersion = requestURL.searchParams.get("v");
+      workerMinisearch = requestURL.searchParams.get("minisearch");
       response.writeHead(200, { "content-type": "text/javascript; charset=utf-8" });
       response.end(fakeWorker);
       return;
@@ -424,7 +426,7 @@ async function runViewport(width, height) {
       }),
     ]);
     assert.equal(browserResult.status, "PASS", browserResult.message);
-    assert.equal(browserResult.workerVersion, "test-asset-version");
+    assert.equal(browserResult.workerMinisearch, "/js/vendor/test-minisearch.js");
   } finally {
     clearTimeout(timeout);
     if (chrome && chrome.exitCode == null) chrome.kill("SIGKILL");
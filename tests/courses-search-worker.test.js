@@ -100,6 +100,10 @@ const catalog = {
     { id: "video", label: "Video", count: 4 },
     { id: "book", label: "Book", count: 1 },
   ],
+  channels: [
+    { id: "1", label: "Fixture", count: 1 },
+    { id: "2", label: "Second fixture", count: 1 },
+  ],
   entries: [
     {
       id: "course-1",
@@ -116,6 +120,7 @@ const catalog = {
       formats: ["video"],
       primary_format: "video",
       format_sources: ["format-source-marker-339"],
+      channels: ["1"],
       links: [{
         url: "https://alpha.test/link-marker-334",
         host: "alpha.test",
@@ -165,7 +170,7 @@ const catalog = {
       primary_category: "design",
       formats: ["book"],
       primary_format: "book",
-      format_sources: ["title"],
+      format_sources: ["title:course"],
       links: [{
         url: "https://beta.test/b",
         host: "beta.test",
@@ -202,7 +207,7 @@ const catalog = {
       primary_category: "service",
       formats: ["video"],
       primary_format: "video",
-      format_sources: ["title"],
+      format_sources: ["title:course"],
       links: [{
         url: "https://hidden.test/c",
         host: "hidden.test",
@@ -239,7 +244,7 @@ const catalog = {
       primary_category: "it",
       formats: ["video"],
       primary_format: "video",
-      format_sources: ["title"],
+      format_sources: ["title:course"],
       links: [{
         url: "https://alpha.test/d",
         host: "alpha.test",
@@ -277,7 +282,8 @@ const catalog = {
       primary_category: "it",
       formats: ["video"],
       primary_format: "video",
-      format_sources: ["title"],
+      format_sources: ["title:course"],
+      channels: ["2"],
       links: [{
         url: "https://gamma.test/e",
         host: "gamma.test",
@@ -332,6 +338,22 @@ const catalog = {
   expect(imported.data.facets.categories.every((item) => item.value !== "service"), "service facet leaked");
   expect(imported.data.facets.formats.find((item) => item.value === "video").count === 3, "format facet incorrect");
   expect(imported.data.facets.hasPassword.withPassword === 1, "password facet incorrect");
+  expect(
+    imported.data.facets.channels.find((item) => item.value === "2").label === "Second fixture",
+    "channel facet incorrect",
+  );
+
+  const byChannel = await client.request("search", {
+    query: "",
+    filters: Object.assign({}, emptyFilters, { channels: ["2"] }),
+    sort: { field: "title", direction: "asc" },
+    offset: 0,
+    limit: 10,
+  });
+  expect(
+    byChannel.ok === true && byChannel.data.total === 1 && byChannel.data.entries[0].id === "course-5",
+    "channel filter failed",
+  );
 
   const normalized = await client.request("search", {
     query: "елка",